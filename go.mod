@@ -1,8 +1,10 @@
 module github.com/ssttevee/go-quickjs
 
-go 1.14
+go 1.21
 
 require (
 	github.com/dustin/go-humanize v1.0.0
-	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f
+	golang.org/x/net v0.33.0
+	golang.org/x/sys v0.28.0
+	golang.org/x/text v0.21.0
 )
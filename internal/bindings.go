@@ -11,6 +11,7 @@ package internal
 // #include "quickjs/quickjs.h"
 //
 // extern JSValue js_call_func_job(JSContext *ctx, int argc, JSValueConst *argv);
+// extern void js_set_context_opaque_id(JSContext *ctx, uintptr_t id);
 import "C"
 import (
 	"reflect"
@@ -30,6 +31,12 @@ func (v Value) Tag() Tag {
 	return Tag(v.tag)
 }
 
+// Ptr returns the address of the underlying heap object of reference counted
+// values such as objects, strings and symbols.
+func (v Value) Ptr() uintptr {
+	return *(*uintptr)(unsafe.Pointer(&v.u))
+}
+
 func Eval(ctx *Context, input string, filename string, flags EvalFlag) Value {
 	cinput := C.CString(input)
 	defer C.free(unsafe.Pointer(cinput))
@@ -153,6 +160,19 @@ func FreeContext(ctx *Context) {
 	C.JS_FreeContext((*C.JSContext)(ctx))
 }
 
+// SetContextOpaque stores the address of a go value in ctx. The address is
+// hidden from the go garbage collector, so it does not keep the value alive
+// and must be cleared before the value is freed.
+func SetContextOpaque(ctx *Context, addr uintptr) {
+	C.js_set_context_opaque_id((*C.JSContext)(ctx), C.uintptr_t(addr))
+}
+
+// GetContextOpaque returns the address that was stored in ctx by
+// SetContextOpaque, or nil if there is none.
+func GetContextOpaque(ctx *Context) unsafe.Pointer {
+	return C.JS_GetContextOpaque((*C.JSContext)(ctx))
+}
+
 func GetGlobalObject(ctx *Context) Value {
 	return Value(C.JS_GetGlobalObject((*C.JSContext)(ctx)))
 }
@@ -161,6 +181,10 @@ func NewError(ctx *Context) Value {
 	return Value(C.JS_NewError((*C.JSContext)(ctx)))
 }
 
+func SetPrototype(ctx *Context, obj, proto Value) int {
+	return int(C.JS_SetPrototype((*C.JSContext)(ctx), C.JSValue(obj), C.JSValue(proto)))
+}
+
 func DupValue(ctx *Context, v Value) Value {
 	return Value(C.JS_DupValue((*C.JSContext)(ctx), C.JSValue(v)))
 }
@@ -169,13 +193,21 @@ func NewString(ctx *Context, str string) Value {
 	cstr := C.CString(str)
 	defer C.free(unsafe.Pointer(cstr))
 
-	return Value(C.JS_NewString((*C.JSContext)(ctx), cstr))
+	return Value(C.JS_NewStringLen((*C.JSContext)(ctx), cstr, csize(len(str))))
 }
 
 func NewInt(ctx *Context, n int) Value {
 	return Value(C.JS_NewInt32((*C.JSContext)(ctx), C.int32_t(n)))
 }
 
+func NewInt64(ctx *Context, n int64) Value {
+	return Value(C.JS_NewInt64((*C.JSContext)(ctx), C.int64_t(n)))
+}
+
+func NewBigInt64(ctx *Context, n int64) Value {
+	return Value(C.JS_NewBigInt64((*C.JSContext)(ctx), C.int64_t(n)))
+}
+
 func NewFloat(ctx *Context, n float64) Value {
 	return Value(C.JS_NewFloat64((*C.JSContext)(ctx), C.double(n)))
 }
@@ -198,6 +230,10 @@ func ParseJSON(ctx *Context, data string, filename string) Value {
 	return Value(C.JS_ParseJSON((*C.JSContext)(ctx), cdata, csize(len(data)), cfilename))
 }
 
+func JSONStringify(ctx *Context, obj, replacer, space Value) Value {
+	return Value(C.JS_JSONStringify((*C.JSContext)(ctx), C.JSValue(obj), C.JSValue(replacer), C.JSValue(space)))
+}
+
 func IsError(ctx *Context, v Value) bool {
 	return C.JS_IsError((*C.JSContext)(ctx), C.JSValue(v)) != 0
 }
@@ -206,10 +242,18 @@ func IsArray(ctx *Context, v Value) bool {
 	return C.JS_IsArray((*C.JSContext)(ctx), C.JSValue(v)) != 0
 }
 
+func IsInstanceOf(ctx *Context, v Value, obj Value) int {
+	return int(C.JS_IsInstanceOf((*C.JSContext)(ctx), C.JSValue(v), C.JSValue(obj)))
+}
+
 func NewObject(ctx *Context) Value {
 	return Value(C.JS_NewObject((*C.JSContext)(ctx)))
 }
 
+func NewArray(ctx *Context) Value {
+	return Value(C.JS_NewArray((*C.JSContext)(ctx)))
+}
+
 func NewObjectProto(ctx *Context, v Value) Value {
 	return Value(C.JS_NewObjectProto((*C.JSContext)(ctx), C.JSValue(v)))
 }
@@ -229,10 +273,19 @@ func NewAtom(ctx *Context, name string) Atom {
 	return Atom(C.JS_NewAtom((*C.JSContext)(ctx), cname))
 }
 
+func DupAtom(ctx *Context, atom Atom) Atom {
+	return Atom(C.JS_DupAtom((*C.JSContext)(ctx), C.JSAtom(atom)))
+}
+
 func FreeAtom(ctx *Context, atom Atom) {
 	C.JS_FreeAtom((*C.JSContext)(ctx), C.JSAtom(atom))
 }
 
+// ValueToAtom returns 0 on exception.
+func ValueToAtom(ctx *Context, v Value) Atom {
+	return Atom(C.JS_ValueToAtom((*C.JSContext)(ctx), C.JSValue(v)))
+}
+
 func AtomToValue(ctx *Context, atom Atom) Value {
 	return Value(C.JS_AtomToValue((*C.JSContext)(ctx), C.JSAtom(atom)))
 }
@@ -274,9 +327,15 @@ func RunGC(rt *Runtime) {
 	C.JS_RunGC((*C.JSRuntime)(rt))
 }
 
+// WriteObject serializes obj. nil is returned and an exception is raised if
+// obj cannot be serialized.
 func WriteObject(ctx *Context, obj Value, flags WriteObjectFlag) []byte {
 	var size csize
 	ptr := C.JS_WriteObject((*C.JSContext)(ctx), &size, C.JSValue(obj), C.int(flags))
+	if ptr == nil {
+		return nil
+	}
+
 	defer C.js_free((*C.JSContext)(ctx), unsafe.Pointer(ptr))
 
 	ret := make([]byte, int(size))
@@ -293,6 +352,37 @@ func EvalFunction(ctx *Context, funObj Value) Value {
 	return Value(C.JS_EvalFunction((*C.JSContext)(ctx), C.JSValue(DupValue(ctx, funObj))))
 }
 
+// GetArrayBuffer returns the backing memory of an ArrayBuffer. The returned
+// slice is only valid for as long as the buffer is neither freed nor detached.
+//
+// nil is returned and an exception is raised if obj is not an ArrayBuffer.
+func GetArrayBuffer(ctx *Context, obj Value) []byte {
+	var size C.size_t
+	ptr := C.JS_GetArrayBuffer((*C.JSContext)(ctx), &size, C.JSValue(obj))
+	if ptr == nil {
+		return nil
+	}
+
+	return *(*[]byte)(makeSliceHeader(unsafe.Pointer(ptr), int(size)))
+}
+
+// GetTypedArrayBuffer returns the ArrayBuffer of a TypedArray and the region
+// of it that the TypedArray references, which are read from the TypedArray
+// itself rather than from its properties.
+//
+// An exception is returned and raised if obj is not a TypedArray or its
+// buffer is detached.
+func GetTypedArrayBuffer(ctx *Context, obj Value) (buffer Value, offset, length int) {
+	var byteOffset, byteLength C.size_t
+	buffer = Value(C.JS_GetTypedArrayBuffer((*C.JSContext)(ctx), C.JSValue(obj), &byteOffset, &byteLength, nil))
+
+	return buffer, int(byteOffset), int(byteLength)
+}
+
+func DetachArrayBuffer(ctx *Context, obj Value) {
+	C.JS_DetachArrayBuffer((*C.JSContext)(ctx), C.JSValue(obj))
+}
+
 func NewArrayBuffer(ctx *Context, data []byte) Value {
 	return Value(C.JS_NewArrayBufferCopy((*C.JSContext)(ctx), (*C.uint8_t)(unsafe.Pointer((*reflect.SliceHeader)(unsafe.Pointer(&data)).Data)), C.size_t(len(data))))
 }
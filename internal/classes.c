@@ -0,0 +1,10 @@
+#include "quickjs/quickjs.h"
+#include "_cgo_export.h"
+
+JSClassExoticMethods go_host_object_exotic_methods = {
+    .get_own_property = go_host_object_get_own_property,
+    .get_own_property_names = go_host_object_get_own_property_names,
+    .delete_property = go_host_object_delete_property,
+    .define_own_property = go_host_object_define_own_property,
+    .set_property = go_host_object_set_property,
+};
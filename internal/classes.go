@@ -1,13 +1,34 @@
 package internal
 
 // #include "quickjs/quickjs.h"
+//
+// extern JSClassExoticMethods go_host_object_exotic_methods;
+// extern void go_host_object_class_finalizer(JSRuntime *rt, JSValue val);
+// extern void js_set_opaque_id(JSValue obj, uintptr_t id);
 import "C"
-import "fmt"
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"unsafe"
+)
 
 var (
 	classDefs = map[C.JSClassID]C.JSClassDef{}
+
+	hostObjectClassID = C.JS_NewClassID(new(C.JSClassID))
+
+	savedHostObjectsMutex sync.RWMutex
+	savedHostObjects      = map[int]HostObject{}
 )
 
+func init() {
+	registerClassDefinition(hostObjectClassID, "HostObject", C.JSClassDef{
+		finalizer: (*C.JSClassFinalizer)(C.go_host_object_class_finalizer),
+		exotic:    &C.go_host_object_exotic_methods,
+	})
+}
+
 func registerClassDefinition(id C.JSClassID, name string, def C.JSClassDef) {
 	def.class_name = C.CString(name)
 	classDefs[id] = def
@@ -22,3 +43,186 @@ func defineCustomClasses(rt *C.JSRuntime) *C.JSRuntime {
 
 	return rt
 }
+
+// HostObject handles the own properties of objects created by NewHostObject.
+// Properties that are not handled are looked up on the prototype as usual.
+//
+// Methods that return an int follow the conventions of JSClassExoticMethods:
+// -1 after throwing an exception, 0 for false and 1 for true.
+type HostObject interface {
+	HasOwnProperty(ctx *Context, prop Atom) int
+
+	// GetOwnProperty returns the value of a property along with its flags.
+	// The returned value is owned by the caller.
+	GetOwnProperty(ctx *Context, prop Atom) (Value, PropertyFlag, int)
+
+	// GetOwnPropertyNames returns the names of all own properties. The
+	// returned atoms are owned by the caller.
+	GetOwnPropertyNames(ctx *Context) ([]Atom, int)
+
+	DefineOwnProperty(ctx *Context, prop Atom, value, getter, setter Value, flags PropertyFlag) int
+	SetProperty(ctx *Context, prop Atom, value Value, flags PropertyFlag) int
+	DeleteProperty(ctx *Context, prop Atom) int
+}
+
+// HostObjectFinalizer may be implemented by a HostObject to be notified when
+// its object is freed.
+type HostObjectFinalizer interface {
+	Finalize()
+}
+
+func hostObjectID(obj C.JSValue) int {
+	return int(uintptr(C.JS_GetOpaque(obj, hostObjectClassID)))
+}
+
+func lookupHostObject(id int) HostObject {
+	savedHostObjectsMutex.RLock()
+	defer savedHostObjectsMutex.RUnlock()
+
+	return savedHostObjects[id]
+}
+
+func saveHostObject(h HostObject) int {
+	savedHostObjectsMutex.Lock()
+	defer savedHostObjectsMutex.Unlock()
+
+	// pin to memory
+	var id int
+	for {
+		id = rand.Int()
+		_, ok := savedHostObjects[id]
+		if !ok {
+			break
+		}
+	}
+
+	savedHostObjects[id] = h
+
+	return id
+}
+
+//export go_host_object_class_finalizer
+func go_host_object_class_finalizer(runtime *C.JSRuntime, obj C.JSValue) {
+	id := hostObjectID(obj)
+
+	savedHostObjectsMutex.Lock()
+	h := savedHostObjects[id]
+
+	// unpin from memory
+	delete(savedHostObjects, id)
+	savedHostObjectsMutex.Unlock()
+
+	if f, ok := h.(HostObjectFinalizer); ok {
+		f.Finalize()
+	}
+}
+
+//export go_host_object_get_own_property
+func go_host_object_get_own_property(ctx *C.JSContext, desc *C.JSPropertyDescriptor, obj C.JSValue, prop C.JSAtom) C.int {
+	h := lookupHostObject(hostObjectID(obj))
+	if desc == nil {
+		return C.int(h.HasOwnProperty((*Context)(ctx), Atom(prop)))
+	}
+
+	value, flags, result := h.GetOwnProperty((*Context)(ctx), Atom(prop))
+	if result == 1 {
+		desc.flags = C.int(flags)
+		desc.value = C.JSValue(value)
+		desc.getter = C.JSValue(Undefined)
+		desc.setter = C.JSValue(Undefined)
+	}
+
+	return C.int(result)
+}
+
+//export go_host_object_get_own_property_names
+func go_host_object_get_own_property_names(ctx *C.JSContext, ptab **C.JSPropertyEnum, plen *C.uint32_t, obj C.JSValue) C.int {
+	atoms, result := lookupHostObject(hostObjectID(obj)).GetOwnPropertyNames((*Context)(ctx))
+	if result < 0 {
+		return C.int(result)
+	}
+
+	n := len(atoms)
+
+	// avoid allocating 0 bytes
+	size := n
+	if size == 0 {
+		size = 1
+	}
+
+	tab := C.js_malloc(ctx, csize(size)*csize(unsafe.Sizeof(C.JSPropertyEnum{})))
+	entries := *(*[]C.JSPropertyEnum)(makeSliceHeader(tab, n))
+	for i, atom := range atoms {
+		entries[i].is_enumerable = 0
+		entries[i].atom = C.JSAtom(atom)
+	}
+
+	*ptab = (*C.JSPropertyEnum)(tab)
+	*plen = C.uint32_t(n)
+
+	return 0
+}
+
+//export go_host_object_delete_property
+func go_host_object_delete_property(ctx *C.JSContext, obj C.JSValue, prop C.JSAtom) C.int {
+	return C.int(lookupHostObject(hostObjectID(obj)).DeleteProperty((*Context)(ctx), Atom(prop)))
+}
+
+//export go_host_object_define_own_property
+func go_host_object_define_own_property(ctx *C.JSContext, obj C.JSValue, prop C.JSAtom, value, getter, setter C.JSValue, flags C.int) C.int {
+	return C.int(lookupHostObject(hostObjectID(obj)).DefineOwnProperty((*Context)(ctx), Atom(prop), Value(value), Value(getter), Value(setter), PropertyFlag(flags)))
+}
+
+//export go_host_object_set_property
+func go_host_object_set_property(ctx *C.JSContext, obj C.JSValue, prop C.JSAtom, value, receiver C.JSValue, flags C.int) C.int {
+	return C.int(lookupHostObject(hostObjectID(obj)).SetProperty((*Context)(ctx), Atom(prop), Value(value), PropertyFlag(flags)))
+}
+
+// NewHostObject creates an object with the given prototype whose own
+// properties are handled by h.
+func NewHostObject(ctx *Context, proto Value, h HostObject) Value {
+	obj := C.JS_NewObjectProtoClass((*C.JSContext)(ctx), C.JSValue(proto), hostObjectClassID)
+	if Value(obj).Tag() == TagObject {
+		C.js_set_opaque_id(obj, C.uintptr_t(saveHostObject(h)))
+	}
+
+	return Value(obj)
+}
+
+// RejectPropertyWrite reports that a property of a host object could not be
+// written. Like ordinary objects with read-only properties, a TypeError is
+// thrown if the flags include PropertyFlagThrow, or PropertyFlagThrowStrict
+// and the caller is in strict mode.
+func RejectPropertyWrite(ctx *Context, prop Atom, flags PropertyFlag) int {
+	// let quickjs decide whether to throw by writing to a read-only property
+	// of a temporary object
+	obj := C.JS_NewObjectProto((*C.JSContext)(ctx), C.JSValue(Null))
+	defer C.JS_FreeValue((*C.JSContext)(ctx), obj)
+
+	if C.JS_DefinePropertyValue((*C.JSContext)(ctx), obj, C.JSAtom(prop), C.JSValue(Undefined), 0) < 0 {
+		return -1
+	}
+
+	return int(C.JS_SetPropertyInternal((*C.JSContext)(ctx), obj, C.JSAtom(prop), C.JSValue(Undefined), C.int(flags)))
+}
+
+// RejectProperty is like RejectPropertyWrite, but throws a TypeError with the
+// given message instead of the one for read-only properties.
+func RejectProperty(ctx *Context, prop Atom, flags PropertyFlag, message string) int {
+	result := RejectPropertyWrite(ctx, prop, flags)
+	if result >= 0 {
+		return result
+	}
+
+	// replace the exception
+	FreeValue(ctx, GetException(ctx))
+	ThrowTypeError(ctx, "%s", message)
+
+	return -1
+}
+
+// GetHostObject returns the handler of an object created by NewHostObject or
+// nil if v is not such an object.
+func GetHostObject(v Value) HostObject {
+	return lookupHostObject(hostObjectID(C.JSValue(v)))
+}
@@ -16,7 +16,15 @@ const (
 	PropertyFlagHasWritable     PropertyFlag = C.JS_PROP_HAS_WRITABLE
 	PropertyFlagHasEnumerable   PropertyFlag = C.JS_PROP_HAS_ENUMERABLE
 	PropertyFlagThrow           PropertyFlag = C.JS_PROP_THROW
+	PropertyFlagThrowStrict     PropertyFlag = C.JS_PROP_THROW_STRICT
 	PropertyFlagNoExotic        PropertyFlag = C.JS_PROP_NO_EXOTIC
+	PropertyFlagGetSet          PropertyFlag = C.JS_PROP_GETSET
+)
+
+type CallFlag int
+
+const (
+	CallFlagConstructor CallFlag = C.JS_CALL_FLAG_CONSTRUCTOR
 )
 
 type EvalFlag int
@@ -50,3 +58,13 @@ const (
 	ReadObjectSharedArrayBuffer ReadObjectFlag = C.JS_READ_OBJ_SAB
 	ReadObjectReference         ReadObjectFlag = C.JS_READ_OBJ_REFERENCE
 )
+
+type GetOwnPropertyNamesFlag int
+
+const (
+	GetOwnPropertyNamesStringMask  GetOwnPropertyNamesFlag = C.JS_GPN_STRING_MASK
+	GetOwnPropertyNamesSymbolMask  GetOwnPropertyNamesFlag = C.JS_GPN_SYMBOL_MASK
+	GetOwnPropertyNamesPrivateMask GetOwnPropertyNamesFlag = C.JS_GPN_PRIVATE_MASK
+	GetOwnPropertyNamesEnumOnly    GetOwnPropertyNamesFlag = C.JS_GPN_ENUM_ONLY
+	GetOwnPropertyNamesSetEnum     GetOwnPropertyNamesFlag = C.JS_GPN_SET_ENUM
+)
@@ -4,9 +4,11 @@ package internal
 // #include "quickjs/quickjs.h"
 //
 // extern void go_function_class_finalizer(JSRuntime *rt, JSValue val);
+// extern void js_set_opaque_id(JSValue obj, uintptr_t id);
 // extern JSValue go_function_class_call(JSContext *ctx, JSValueConst func_obj, JSValueConst this_val, int argc, JSValueConst *argv, int flags);
 import "C"
 import (
+	"fmt"
 	"math/rand"
 	"sync"
 	"unsafe"
@@ -16,7 +18,7 @@ var (
 	goFunctionClassID = C.JS_NewClassID(new(C.JSClassID))
 
 	savedFunctionsMutex sync.RWMutex
-	savedFunctions      = map[int]Function{}
+	savedFunctions      = map[int]FunctionWithFlags{}
 )
 
 func init() {
@@ -28,6 +30,10 @@ func init() {
 
 type Function func(ctx *Context, this Value, args []Value) Value
 
+// FunctionWithFlags is like Function but also receives the call flags. When
+// called with CallFlagConstructor, this is new.target.
+type FunctionWithFlags func(ctx *Context, this Value, args []Value, flags CallFlag) Value
+
 func funcID(obj C.JSValue) int {
 	return int(uintptr(C.JS_GetOpaque(obj, goFunctionClassID)))
 }
@@ -42,23 +48,30 @@ func go_function_class_finalizer(runtime *C.JSRuntime, obj C.JSValue) {
 }
 
 //export go_function_class_call
-func go_function_class_call(ctx *C.JSContext, obj C.JSValue, thisValue C.JSValue, argc C.int, argv *C.JSValue, flags C.int) C.JSValue {
+func go_function_class_call(ctx *C.JSContext, obj C.JSValue, thisValue C.JSValue, argc C.int, argv *C.JSValue, flags C.int) (result C.JSValue) {
+	defer func() {
+		// panics must not unwind through c frames
+		if p := recover(); p != nil {
+			result = C.JSValue(ThrowInternalError((*Context)(ctx), fmt.Sprintf("panic: %v", p)))
+		}
+	}()
+
 	args := make([]Value, int(argc))
 	for i, arg := range *(*[]C.JSValue)(makeSliceHeader(unsafe.Pointer(argv), int(argc))) {
 		args[i] = Value(arg)
 	}
 
-	return C.JSValue(lookupFunction(funcID(obj))((*Context)(ctx), Value(thisValue), args))
+	return C.JSValue(lookupFunction(funcID(obj))((*Context)(ctx), Value(thisValue), args, CallFlag(flags)))
 }
 
-func lookupFunction(id int) Function {
+func lookupFunction(id int) FunctionWithFlags {
 	savedFunctionsMutex.RLock()
 	defer savedFunctionsMutex.RUnlock()
 
 	return savedFunctions[id]
 }
 
-func saveFunction(f Function) int {
+func saveFunction(f FunctionWithFlags) int {
 	savedFunctionsMutex.Lock()
 	defer savedFunctionsMutex.Unlock()
 
@@ -79,7 +92,21 @@ func saveFunction(f Function) int {
 
 func NewFunction(ctx *Context, f Function) Value {
 	funcObj := C.JS_NewObjectClass((*C.JSContext)(ctx), C.int(goFunctionClassID))
-	C.JS_SetOpaque(funcObj, unsafe.Pointer(uintptr(saveFunction(f))))
+	C.js_set_opaque_id(funcObj, C.uintptr_t(saveFunction(func(ctx *Context, this Value, args []Value, flags CallFlag) Value {
+		return f(ctx, this, args)
+	})))
+
+	return Value(funcObj)
+}
+
+// NewConstructor creates a function with the given prototype that may be
+// called with new.
+func NewConstructor(ctx *Context, proto Value, f FunctionWithFlags) Value {
+	funcObj := C.JS_NewObjectProtoClass((*C.JSContext)(ctx), C.JSValue(proto), goFunctionClassID)
+	if Value(funcObj).Tag() == TagObject {
+		C.js_set_opaque_id(funcObj, C.uintptr_t(saveFunction(f)))
+		C.JS_SetConstructorBit((*C.JSContext)(ctx), funcObj, 1)
+	}
 
 	return Value(funcObj)
 }
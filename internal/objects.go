@@ -4,18 +4,23 @@ package internal
 // #include "quickjs/quickjs.h"
 //
 // extern void go_object_with_finalizer_class_finalizer(JSRuntime *rt, JSValue val);
+// extern void go_object_with_opaque_class_finalizer(JSRuntime *rt, JSValue val);
+// extern void js_set_opaque_id(JSValue obj, uintptr_t id);
 import "C"
 import (
 	"math/rand"
 	"sync"
-	"unsafe"
 )
 
 var (
 	objectWithFinalizerClassID = C.JS_NewClassID(new(C.JSClassID))
+	objectWithOpaqueClassID    = C.JS_NewClassID(new(C.JSClassID))
 
 	savedFinalizersMutex sync.Mutex
 	savedFinalizers      = map[int]func(){}
+
+	savedOpaquesMutex sync.RWMutex
+	savedOpaques      = map[int]interface{}{}
 )
 
 func lookupAndDeleteFinalizer(id int) func() {
@@ -52,15 +57,72 @@ func go_object_with_finalizer_class_finalizer(runtime *C.JSRuntime, obj C.JSValu
 	lookupAndDeleteFinalizer(int(uintptr(C.JS_GetOpaque(obj, objectWithFinalizerClassID))))()
 }
 
+func opaqueID(obj C.JSValue) int {
+	return int(uintptr(C.JS_GetOpaque(obj, objectWithOpaqueClassID)))
+}
+
+func saveOpaque(opaque interface{}) int {
+	savedOpaquesMutex.Lock()
+	defer savedOpaquesMutex.Unlock()
+
+	// pin to memory
+	var id int
+	for {
+		id = rand.Int()
+		_, ok := savedOpaques[id]
+		if !ok {
+			break
+		}
+	}
+
+	savedOpaques[id] = opaque
+
+	return id
+}
+
+//export go_object_with_opaque_class_finalizer
+func go_object_with_opaque_class_finalizer(runtime *C.JSRuntime, obj C.JSValue) {
+	savedOpaquesMutex.Lock()
+	defer savedOpaquesMutex.Unlock()
+
+	// unpin from memory
+	delete(savedOpaques, opaqueID(obj))
+}
+
 func init() {
 	registerClassDefinition(objectWithFinalizerClassID, "ObjectWithFinalizer", C.JSClassDef{
 		finalizer: (*C.JSClassFinalizer)(C.go_object_with_finalizer_class_finalizer),
 	})
+
+	registerClassDefinition(objectWithOpaqueClassID, "ObjectWithOpaque", C.JSClassDef{
+		finalizer: (*C.JSClassFinalizer)(C.go_object_with_opaque_class_finalizer),
+	})
 }
 
 func NewObjectWithFinalizer(ctx *Context, f func()) Value {
 	obj := C.JS_NewObjectClass((*C.JSContext)(ctx), C.int(objectWithFinalizerClassID))
-	C.JS_SetOpaque(obj, unsafe.Pointer(uintptr(saveFinalizer(f))))
+	C.js_set_opaque_id(obj, C.uintptr_t(saveFinalizer(f)))
 
 	return Value(obj)
 }
+
+// NewObjectWithOpaque creates an object with the given prototype that holds a
+// go value.
+func NewObjectWithOpaque(ctx *Context, proto Value, opaque interface{}) Value {
+	obj := C.JS_NewObjectProtoClass((*C.JSContext)(ctx), C.JSValue(proto), objectWithOpaqueClassID)
+	if Value(obj).Tag() == TagObject {
+		C.js_set_opaque_id(obj, C.uintptr_t(saveOpaque(opaque)))
+	}
+
+	return Value(obj)
+}
+
+// GetOpaque returns the go value held by an object created by
+// NewObjectWithOpaque.
+func GetOpaque(v Value) (interface{}, bool) {
+	savedOpaquesMutex.RLock()
+	defer savedOpaquesMutex.RUnlock()
+
+	opaque, ok := savedOpaques[opaqueID(C.JSValue(v))]
+	return opaque, ok
+}
@@ -0,0 +1,15 @@
+#include <stdint.h>
+#include "quickjs/quickjs.h"
+
+// js_set_opaque_id stores an id of a saved go value as the opaque pointer of
+// obj, so that go does not need to convert the id to an unsafe.Pointer.
+void js_set_opaque_id(JSValue obj, uintptr_t id)
+{
+    JS_SetOpaque(obj, (void *)id);
+}
+
+// js_set_context_opaque_id is like js_set_opaque_id for contexts.
+void js_set_context_opaque_id(JSContext *ctx, uintptr_t id)
+{
+    JS_SetContextOpaque(ctx, (void *)id);
+}
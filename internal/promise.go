@@ -0,0 +1,100 @@
+package internal
+
+// #include "quickjs/quickjs.h"
+//
+// // mirrors JSPromiseData
+// typedef struct {
+//     int promise_state;
+//     void *promise_reactions[2][2];
+//     int is_handled;
+//     JSValue promise_result;
+// } go_promise_data;
+//
+// static int go_promise_state(JSValue v, JSClassID id) {
+//     go_promise_data *data = JS_GetOpaque(v, id);
+//     return data ? data->promise_state : -1;
+// }
+//
+// static JSValue go_promise_result(JSContext *ctx, JSValue v, JSClassID id) {
+//     go_promise_data *data = JS_GetOpaque(v, id);
+//     return data ? JS_DupValue(ctx, data->promise_result) : JS_UNDEFINED;
+// }
+import "C"
+import (
+	"sync"
+)
+
+// promise class ids are not exported, but the opaque value of a promise is
+// never null, so the class id is found by probing a promise.
+var (
+	promiseClassIDOnce sync.Once
+	promiseClassID     C.JSClassID
+)
+
+func getPromiseClassID(ctx *Context) C.JSClassID {
+	promiseClassIDOnce.Do(func() {
+		promise, resolve, reject := NewPromiseCapability(ctx)
+		defer FreeValue(ctx, promise)
+		defer FreeValue(ctx, resolve)
+		defer FreeValue(ctx, reject)
+
+		for id := C.JSClassID(1); id < 256; id++ {
+			if C.JS_GetOpaque(C.JSValue(promise), id) != nil {
+				promiseClassID = id
+				return
+			}
+		}
+	})
+
+	return promiseClassID
+}
+
+// NewPromiseCapability creates a promise and the functions that settle it.
+func NewPromiseCapability(ctx *Context) (promise, resolve, reject Value) {
+	var funcs [2]C.JSValue
+	promise = Value(C.JS_NewPromiseCapability((*C.JSContext)(ctx), &funcs[0]))
+	if promise.Tag() == TagException {
+		return promise, Undefined, Undefined
+	}
+
+	return promise, Value(funcs[0]), Value(funcs[1])
+}
+
+type PromiseState int
+
+const (
+	PromiseStateNone PromiseState = iota - 1
+	PromiseStatePending
+	PromiseStateFulfilled
+	PromiseStateRejected
+)
+
+// GetPromiseState returns the state of a promise or PromiseStateNone if v is
+// not a promise.
+func GetPromiseState(ctx *Context, v Value) PromiseState {
+	if v.Tag() != TagObject {
+		return PromiseStateNone
+	}
+
+	id := getPromiseClassID(ctx)
+	if id == 0 {
+		return PromiseStateNone
+	}
+
+	return PromiseState(C.go_promise_state(C.JSValue(v), id))
+}
+
+// GetPromiseResult returns the value or rejection reason of a settled
+// promise, or undefined if v is pending or not a promise.
+func GetPromiseResult(ctx *Context, v Value) Value {
+	if v.Tag() != TagObject {
+		return Undefined
+	}
+
+	id := getPromiseClassID(ctx)
+	if id == 0 {
+		return Undefined
+	}
+
+	return Value(C.go_promise_result((*C.JSContext)(ctx), C.JSValue(v), id))
+}
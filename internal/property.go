@@ -17,7 +17,7 @@ func (p PropertyEnum) IsEnumerable() bool {
 	return p.is_enumerable != 0
 }
 
-func GetOwnPropertyNames(ctx *Context, obj Value, flags int) []PropertyEnum {
+func GetOwnPropertyNames(ctx *Context, obj Value, flags GetOwnPropertyNamesFlag) []PropertyEnum {
 	var tab *C.JSPropertyEnum
 	var size uint32
 	if C.JS_GetOwnPropertyNames((*C.JSContext)(ctx), (**C.JSPropertyEnum)(unsafe.Pointer(&tab)), (*C.uint32_t)(&size), C.JSValue(obj), C.int(flags)) < 0 {
@@ -38,3 +38,34 @@ func FreePropertyEnum(ctx *Context, tab []PropertyEnum) {
 
 	C.js_free((*C.JSContext)(ctx), unsafe.Pointer((*reflect.SliceHeader)(unsafe.Pointer(&tab)).Data))
 }
+
+// PropertyDescriptor describes an own property. Value, Getter and Setter must
+// be freed by the caller.
+type PropertyDescriptor struct {
+	Flags  PropertyFlag
+	Value  Value
+	Getter Value
+	Setter Value
+}
+
+// IsAccessor reports whether the property has a getter or a setter.
+func (d PropertyDescriptor) IsAccessor() bool {
+	return d.Flags&PropertyFlagGetSet != 0
+}
+
+// GetOwnProperty returns the descriptor of an own property. The result is -1
+// on exception, 0 if the property does not exist or 1 otherwise.
+func GetOwnProperty(ctx *Context, obj Value, prop Atom) (PropertyDescriptor, int) {
+	var desc C.JSPropertyDescriptor
+	result := int(C.JS_GetOwnProperty((*C.JSContext)(ctx), &desc, C.JSValue(obj), C.JSAtom(prop)))
+	if result != 1 {
+		return PropertyDescriptor{}, result
+	}
+
+	return PropertyDescriptor{
+		Flags:  PropertyFlag(desc.flags),
+		Value:  Value(desc.value),
+		Getter: Value(desc.getter),
+		Setter: Value(desc.setter),
+	}, result
+}
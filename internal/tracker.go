@@ -0,0 +1,46 @@
+package internal
+
+// #include "quickjs/quickjs.h"
+//
+// extern void go_promise_rejection_tracker(JSContext *ctx, JSValue promise, JSValue reason, int is_handled, void *opaque);
+import "C"
+import (
+	"sync"
+)
+
+// PromiseRejectionTracker is called when a promise is rejected without a
+// handler and again with handled set once a handler is added. The values are
+// only valid for the duration of the call.
+type PromiseRejectionTracker func(ctx *Context, promise, reason Value, handled bool)
+
+var (
+	savedTrackersMutex sync.RWMutex
+	savedTrackers      = map[*Runtime]PromiseRejectionTracker{}
+)
+
+//export go_promise_rejection_tracker
+func go_promise_rejection_tracker(ctx *C.JSContext, promise, reason C.JSValue, isHandled C.int, _ *C.void) {
+	savedTrackersMutex.RLock()
+	tracker := savedTrackers[(*Runtime)(C.JS_GetRuntime(ctx))]
+	savedTrackersMutex.RUnlock()
+
+	if tracker != nil {
+		tracker((*Context)(ctx), Value(promise), Value(reason), isHandled != 0)
+	}
+}
+
+// SetHostPromiseRejectionTracker sets the tracker of the runtime. A nil
+// tracker removes the current one.
+func SetHostPromiseRejectionTracker(rt *Runtime, f PromiseRejectionTracker) {
+	savedTrackersMutex.Lock()
+	defer savedTrackersMutex.Unlock()
+
+	if f == nil {
+		delete(savedTrackers, rt)
+		C.JS_SetHostPromiseRejectionTracker((*C.JSRuntime)(rt), nil, nil)
+		return
+	}
+
+	savedTrackers[rt] = f
+	C.JS_SetHostPromiseRejectionTracker((*C.JSRuntime)(rt), (*C.JSHostPromiseRejectionTracker)(C.go_promise_rejection_tracker), nil)
+}
@@ -0,0 +1,443 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// abortSignal is the opaque value of AbortSignal.
+type abortSignal struct {
+	eventTarget
+
+	aborted bool
+	reason  *Value
+
+	// algorithms are run when the signal is aborted, before the abort event
+	// is fired, such as removing the listeners that were added with the
+	// signal
+	algorithms []func()
+
+	// dependent is set for signals that are created by AbortSignal.any,
+	// which are aborted with any of their sources
+	dependent  bool
+	sources    []*Value
+	dependents []*Value
+}
+
+func thisAbortSignal(this *Value) (*abortSignal, error) {
+	s, ok := this.Opaque().(*abortSignal)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return s, nil
+}
+
+// abortController is the opaque value of AbortController.
+type abortController struct {
+	signal *Value
+}
+
+func thisAbortController(this *Value) (*abortController, error) {
+	c, ok := this.Opaque().(*abortController)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return c, nil
+}
+
+// eventAPI holds the classes of the events intrinsic of a realm, which refer
+// to each other.
+type eventAPI struct {
+	event       *Class
+	abortSignal *Class
+}
+
+func (api *eventAPI) newAbortSignal(r *Realm) (*Value, *abortSignal, error) {
+	s := &abortSignal{}
+	value, err := api.abortSignal.NewInstance(r, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return value, s, nil
+}
+
+// abort aborts the signal s of value with reason, or an AbortError if reason
+// is undefined, and then the signals that depend on it.
+func (api *eventAPI) abort(r *Realm, value *Value, s *abortSignal, reason *Value) error {
+	if s.aborted {
+		return nil
+	}
+
+	if reason.Tag() == TagUndefined {
+		reason = r.errorValue(newDOMException("AbortError", "This operation was aborted"))
+	}
+
+	s.aborted = true
+	s.reason = reason
+
+	// the reasons of all dependents are set before any abort event is fired
+	var dependents []*Value
+	for _, dependent := range s.dependents {
+		if ds := dependent.Opaque().(*abortSignal); !ds.aborted {
+			ds.aborted = true
+			ds.reason = reason
+			dependents = append(dependents, dependent)
+		}
+	}
+
+	if err := api.runAbortSteps(r, value, s); err != nil {
+		return err
+	}
+
+	for _, dependent := range dependents {
+		if err := api.runAbortSteps(r, dependent, dependent.Opaque().(*abortSignal)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (api *eventAPI) runAbortSteps(r *Realm, value *Value, s *abortSignal) error {
+	algorithms := s.algorithms
+	s.algorithms = nil
+
+	for _, algorithm := range algorithms {
+		algorithm()
+	}
+
+	_, err := api.fire(r, value, &s.eventTarget, "abort")
+	return err
+}
+
+// abortLater aborts the signal s of value with the reason returned by
+// reason on the event loop. The event loop does not wait for it.
+func (api *eventAPI) abortLater(r *Realm, value *Value, s *abortSignal, reason func() error) {
+	r.runtime.enqueueUnrefTask(func() error {
+		return api.abort(r, value, s, r.errorValue(reason()))
+	})
+}
+
+func (api *eventAPI) abortSignalSpec(eventTargetClass *Class) ClassSpec {
+	return ClassSpec{
+		Name:    "AbortSignal",
+		Extends: eventTargetClass,
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			return nil, NewTypeError("Illegal constructor")
+		},
+		Methods: map[string]interface{}{
+			"throwIfAborted": func(r *Realm, this *Value) error {
+				s, err := thisAbortSignal(this)
+				if err != nil {
+					return err
+				}
+
+				if s.aborted {
+					return (*Error)(s.reason)
+				}
+
+				return nil
+			},
+		},
+		Properties: map[string]ClassProperty{
+			"aborted": {
+				Get: func(r *Realm, this *Value) (bool, error) {
+					s, err := thisAbortSignal(this)
+					if err != nil {
+						return false, err
+					}
+
+					return s.aborted, nil
+				},
+			},
+			"reason": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					s, err := thisAbortSignal(this)
+					if err != nil {
+						return nil, err
+					}
+
+					if s.reason == nil {
+						return NewUndefined(), nil
+					}
+
+					return s.reason, nil
+				},
+			},
+			"onabort": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					s, err := thisAbortSignal(this)
+					if err != nil {
+						return nil, err
+					}
+
+					return s.handler("abort"), nil
+				},
+				Set: func(r *Realm, this *Value, value *Value) error {
+					s, err := thisAbortSignal(this)
+					if err != nil {
+						return err
+					}
+
+					s.setHandler("abort", value)
+
+					return nil
+				},
+			},
+		},
+		StaticMethods: map[string]interface{}{
+			"abort": func(r *Realm, _ *Value, args ...*Value) (*Value, error) {
+				value, s, err := api.newAbortSignal(r)
+				if err != nil {
+					return nil, err
+				}
+
+				if err := api.abort(r, value, s, optionalArg(args, 0)); err != nil {
+					return nil, err
+				}
+
+				return value, nil
+			},
+			"timeout": func(r *Realm, _ *Value, args ...*Value) (*Value, error) {
+				ms, err := r.toNumber(optionalArg(args, 0))
+				if err != nil {
+					return nil, err
+				}
+
+				if math.IsNaN(ms) || math.IsInf(ms, 0) || ms < 0 || ms > 1<<53-1 {
+					return nil, NewTypeError("The provided value is outside the range of an unsigned long long")
+				}
+
+				value, s, err := api.newAbortSignal(r)
+				if err != nil {
+					return nil, err
+				}
+
+				// timeouts that are too long for a time.Duration never
+				// elapse anyway
+				if ms < float64(math.MaxInt64/int64(time.Millisecond)) {
+					r.runtime.setUnrefTimer(time.Duration(ms*float64(time.Millisecond)), func() error {
+						return api.abort(r, value, s, r.errorValue(newDOMException("TimeoutError", "The operation was aborted due to timeout")))
+					})
+				}
+
+				return value, nil
+			},
+			"any": func(r *Realm, _ *Value, signals *Value) (*Value, error) {
+				var sources []*Value
+				if err := signals.Iterate(func(v *Value) (bool, error) {
+					if _, ok := v.Opaque().(*abortSignal); !ok {
+						return false, NewTypeError("The provided value is not of type 'AbortSignal'")
+					}
+
+					sources = append(sources, v)
+					return true, nil
+				}); err != nil {
+					return nil, err
+				}
+
+				value, s, err := api.newAbortSignal(r)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, source := range sources {
+					if ss := source.Opaque().(*abortSignal); ss.aborted {
+						s.aborted = true
+						s.reason = ss.reason
+						return value, nil
+					}
+				}
+
+				s.dependent = true
+
+				link := func(source *Value) {
+					for _, linked := range s.sources {
+						// objects are equal if they have the same pointer
+						if linked.value == source.value {
+							return
+						}
+					}
+
+					s.sources = append(s.sources, source)
+
+					ss := source.Opaque().(*abortSignal)
+					ss.dependents = append(ss.dependents, value)
+				}
+
+				for _, source := range sources {
+					// dependent signals are never sources themselves, so
+					// that aborting a source aborts all of its dependents
+					if ss := source.Opaque().(*abortSignal); ss.dependent {
+						for _, source := range ss.sources {
+							link(source)
+						}
+					} else {
+						link(source)
+					}
+				}
+
+				return value, nil
+			},
+		},
+	}
+}
+
+func (api *eventAPI) abortControllerSpec() ClassSpec {
+	return ClassSpec{
+		Name: "AbortController",
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			signal, _, err := api.newAbortSignal(r)
+			if err != nil {
+				return nil, err
+			}
+
+			return &abortController{signal: signal}, nil
+		},
+		Methods: map[string]interface{}{
+			"abort": func(r *Realm, this *Value, args ...*Value) error {
+				c, err := thisAbortController(this)
+				if err != nil {
+					return err
+				}
+
+				return api.abort(r, c.signal, c.signal.Opaque().(*abortSignal), optionalArg(args, 0))
+			},
+		},
+		Properties: map[string]ClassProperty{
+			"signal": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					c, err := thisAbortController(this)
+					if err != nil {
+						return nil, err
+					}
+
+					return c.signal, nil
+				},
+			},
+		},
+	}
+}
+
+// contextAbortReason returns the reason of a signal that is aborted because
+// ctx is done.
+func contextAbortReason(ctx context.Context) error {
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, context.DeadlineExceeded):
+		return newDOMException("TimeoutError", "The operation was aborted due to timeout")
+	case errors.Is(cause, context.Canceled):
+		return newDOMException("AbortError", "This operation was aborted")
+	default:
+		return cause
+	}
+}
+
+// NewAbortSignal creates an AbortSignal that is aborted by the event loop
+// when ctx is done, which does not wait for it. The reason of the signal is a
+// TimeoutError if the deadline of ctx was exceeded, an AbortError if ctx was
+// cancelled, or the cause of ctx otherwise.
+//
+// The realm must have been created with AddIntrinsicEvents.
+func (r *Realm) NewAbortSignal(ctx context.Context) (*Value, error) {
+	if r.events == nil {
+		return nil, errors.New("AbortSignal is not available in this realm")
+	}
+
+	value, s, err := r.events.newAbortSignal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.Err() != nil {
+		if err := r.events.abort(r, value, s, r.errorValue(contextAbortReason(ctx))); err != nil {
+			return nil, err
+		}
+
+		return value, nil
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		r.events.abortLater(r, value, s, func() error {
+			return contextAbortReason(ctx)
+		})
+	})
+
+	// stop waiting for ctx once the runtime is closed
+	context.AfterFunc(r.runtime.ctx, func() {
+		stop()
+	})
+
+	return value, nil
+}
+
+// defineEvents defines EventTarget, Event, CustomEvent, AbortController and
+// AbortSignal as globals of r.
+func (r *Realm) defineEvents() error {
+	if err := r.defineDOMException(); err != nil {
+		return err
+	}
+
+	api := &eventAPI{}
+
+	eventTargetClass, err := r.defineGlobalClass(api.eventTargetSpec())
+	if err != nil {
+		return err
+	}
+
+	if api.event, err = r.defineGlobalClass(eventSpec); err != nil {
+		return err
+	}
+
+	customEvent, err := r.defineGlobalClass(newCustomEventSpec(api.event))
+	if err != nil {
+		return err
+	}
+
+	if api.abortSignal, err = r.defineGlobalClass(api.abortSignalSpec(eventTargetClass)); err != nil {
+		return err
+	}
+
+	abortController, err := r.defineGlobalClass(api.abortControllerSpec())
+	if err != nil {
+		return err
+	}
+
+	if err := r.defineClassToStringTags(eventTargetClass, api.event, customEvent, api.abortSignal, abortController); err != nil {
+		return err
+	}
+
+	eventCtor, err := api.event.Constructor(r)
+	if err != nil {
+		return err
+	}
+
+	eventProto, err := eventCtor.Get("prototype")
+	if err != nil {
+		return err
+	}
+
+	for _, phase := range []struct {
+		name  string
+		value int
+	}{
+		{"NONE", eventPhaseNone},
+		{"CAPTURING_PHASE", eventPhaseCapturing},
+		{"AT_TARGET", eventPhaseAtTarget},
+		{"BUBBLING_PHASE", eventPhaseBubbling},
+	} {
+		for _, obj := range []*Value{eventCtor, eventProto} {
+			if _, err := obj.DefineProperty(phase.name, DefinePropertyValue(phase.value), DefinePropertyEnumerable(true)); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.events = api
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAbortController(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicEvents)
+
+	mustEval(t, r, `
+		globalThis.c = new AbortController();
+		globalThis.calls = [];
+		c.signal.onabort = (e) => calls.push("onabort:" + e.type + ":" + e.isTrusted + ":" + (e.target === c.signal));
+		c.signal.addEventListener("abort", () => calls.push("listener:" + c.signal.aborted));
+	`)
+
+	expectString(t, r, `c.signal instanceof AbortSignal && c.signal instanceof EventTarget && c.signal === c.signal`, "true")
+	expectString(t, r, `[c.signal.aborted, c.signal.reason].join()`, "false,")
+	expectString(t, r, `c.signal.throwIfAborted(); "ok"`, "ok")
+
+	expectString(t, r, `c.abort(); c.abort("again"); calls.join()`, "onabort:abort:true:true,listener:true")
+	expectString(t, r, `[c.signal.reason instanceof DOMException, c.signal.reason.name, c.signal.reason.message].join()`, "true,AbortError,This operation was aborted")
+	expectString(t, r, `try { c.signal.throwIfAborted() } catch (e) { e === c.signal.reason }`, "true")
+
+	expectString(t, r, `{ const c = new AbortController(); c.abort(42); c.signal.reason }`, "42")
+	expectString(t, r, `{ const c = new AbortController(); c.abort(null); c.signal.reason }`, "null")
+	expectString(t, r, `{ const c = new AbortController(); try { c.signal.onabort = 1; String(c.signal.onabort) } catch (e) { e.name } }`, "null")
+
+	for _, script := range []string{
+		`new AbortSignal()`,
+		`AbortController()`,
+		`Reflect.apply(AbortController.prototype.abort, {}, [])`,
+		`Reflect.apply(AbortSignal.prototype.throwIfAborted, {}, [])`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { e.name }`, "TypeError")
+	}
+}
+
+func TestAbortSignalAbort(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicEvents)
+
+	expectString(t, r, `{ const s = AbortSignal.abort(); [s.aborted, s.reason.name].join() }`, "true,AbortError")
+	expectString(t, r, `{ const reason = new Error("x"); AbortSignal.abort(reason).reason === reason }`, "true")
+	expectString(t, r, `try { AbortSignal.abort("why").throwIfAborted() } catch (e) { e }`, "why")
+}
+
+func TestAbortSignalAny(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicEvents)
+
+	expectString(t, r, `{
+		const a = new AbortController();
+		const b = new AbortController();
+		const any = AbortSignal.any([a.signal, b.signal]);
+		const nested = AbortSignal.any([any]);
+		const calls = [];
+		any.onabort = () => calls.push("any:" + nested.aborted);
+		nested.onabort = () => calls.push("nested");
+		const before = any.aborted;
+		b.abort("b");
+		a.abort("a");
+		[before, any.reason, nested.reason, calls.join()].join()
+	}`, "false,b,b,any:true,nested")
+
+	expectString(t, r, `AbortSignal.any([new AbortController().signal, AbortSignal.abort("done")]).reason`, "done")
+	expectString(t, r, `AbortSignal.any([]).aborted`, "false")
+	expectString(t, r, `try { AbortSignal.any([{}]) } catch (e) { e.name }`, "TypeError")
+	expectString(t, r, `try { AbortSignal.any(1) } catch (e) { e.name }`, "TypeError")
+}
+
+func TestAbortSignalTimeout(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicEvents)
+
+	expectAwait(t, r, `new Promise((resolve) => {
+		const s = AbortSignal.timeout(10);
+		const aborted = s.aborted;
+		s.onabort = () => resolve([aborted, s.aborted, s.reason instanceof DOMException, s.reason.name, s.reason.code].join());
+	})`, "false,true,true,TimeoutError,23")
+
+	for _, script := range []string{
+		`AbortSignal.timeout(-1)`,
+		`AbortSignal.timeout(NaN)`,
+		`AbortSignal.timeout(Infinity)`,
+		`AbortSignal.timeout(2 ** 53)`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { e.name }`, "TypeError")
+	}
+}
+
+func TestAbortSignalTimeoutIsUnref(t *testing.T) {
+	rt := newTestRuntime(t)
+
+	r, err := rt.NewRealm(AddIntrinsicEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustEval(t, r, `globalThis.signal = AbortSignal.timeout(1e12)`)
+
+	if rt.HasAsyncTasks() {
+		t.Error("expected the timer not to be an async task")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := rt.StartEventLoop(ctx, false); err != nil {
+		t.Fatalf("expected the event loop not to wait for the timer, got %v", err)
+	}
+
+	rt.mutex.Lock()
+	pending := len(rt.unrefTimers)
+	rt.mutex.Unlock()
+
+	if pending != 1 {
+		t.Fatalf("got %d pending timers, want 1", pending)
+	}
+
+	// the timer and the values that it holds are released on Close
+	rt.Close()
+
+	rt.mutex.Lock()
+	pending = len(rt.unrefTimers)
+	rt.mutex.Unlock()
+
+	if pending != 0 {
+		t.Errorf("got %d pending timers after Close, want 0", pending)
+	}
+
+	mustEval(t, r, `AbortSignal.timeout(0)`)
+
+	rt.mutex.Lock()
+	pending = len(rt.unrefTimers)
+	rt.mutex.Unlock()
+
+	if pending != 0 {
+		t.Errorf("expected no timer to be started after Close, got %d", pending)
+	}
+}
+
+func TestNewAbortSignal(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicEvents)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signal, err := r.NewAbortSignal(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "signal", signal)
+
+	expectString(t, r, `signal.aborted`, "false")
+
+	cancel()
+	expectAwait(t, r, `new Promise((resolve) => signal.onabort = () => resolve(signal.reason.name))`, "AbortError")
+
+	for _, test := range []struct {
+		ctx  func() (context.Context, context.CancelFunc)
+		want string
+	}{
+		{func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), -time.Second)
+		}, "TimeoutError"},
+		{func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancelCause(context.Background())
+			cancel(errors.New("custom cause"))
+			return ctx, func() {}
+		}, "custom cause"},
+	} {
+		ctx, cancel := test.ctx()
+		defer cancel()
+
+		signal, err := r.NewAbortSignal(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		setGlobal(t, r, "signal", signal)
+
+		expectString(t, r, `[signal.aborted, signal.reason instanceof DOMException ? signal.reason.name : signal.reason.message].join()`, "true,"+test.want)
+	}
+}
@@ -0,0 +1,113 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAsyncFunction(t *testing.T) {
+	r := newTestRealm(t)
+
+	add, err := r.NewAsyncFunction(func(ctx context.Context, r *Realm, this *Value, a, b int) (int, error) {
+		time.Sleep(time.Millisecond)
+		return a + b, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fail, err := r.NewAsyncFunction(func(ctx context.Context, r *Realm, this *Value) error {
+		return NewRangeError("out of range")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	explode, err := r.NewAsyncFunction(func(ctx context.Context, r *Realm, this *Value) error {
+		panic("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "add", add)
+	setGlobal(t, r, "fail", fail)
+	setGlobal(t, r, "explode", explode)
+
+	expectString(t, r, `add(1, 2) instanceof Promise`, "true")
+
+	if got := mustAwait(t, r, `Promise.all([add(1, 2), add(3, 4)]).then(v => v.join())`).String(); got != "3,7" {
+		t.Errorf("add = %q, want %q", got, "3,7")
+	}
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`fail()`, "RangeError: out of range"},
+		{`explode()`, "boom"},
+		{`add("a", 2)`, "TypeError"},
+	} {
+		_, err := await(r, test.script)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want %q", test.script, err, test.want)
+		}
+	}
+}
+
+func TestNewAsyncFunctionCancelledOnClose(t *testing.T) {
+	r := newTestRealm(t)
+
+	cancelled := make(chan error, 1)
+	wait, err := r.NewAsyncFunction(func(ctx context.Context, r *Realm, this *Value) error {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "wait", wait)
+	mustEval(t, r, `wait()`)
+
+	if !r.runtime.HasAsyncTasks() {
+		t.Error("expected the pending call to count as an async task")
+	}
+
+	r.runtime.Close()
+
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ctx.Err() = %v, want %v", err, context.Canceled)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("context was not cancelled when the runtime was closed")
+	}
+}
+
+func TestNewAsyncFunctionInvalidSignature(t *testing.T) {
+	r := newTestRealm(t)
+
+	for _, f := range []interface{}{
+		1,
+		func(r *Realm, this *Value) error { return nil },
+		func(ctx context.Context, r *Realm, this *Value) {},
+		func(ctx context.Context, r *Realm, this *Value) int { return 0 },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%T: expected a panic", f)
+				}
+			}()
+
+			r.NewAsyncFunction(f)
+		}()
+	}
+}
@@ -0,0 +1,408 @@
+package js
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// asyncSource produces the values of an async iterable that is backed by go.
+type asyncSource interface {
+	// next blocks until the next value is available and returns false once
+	// there are no more values.
+	next(ctx context.Context) (reflect.Value, bool, error)
+
+	// close releases the source when iteration stops early.
+	close()
+}
+
+// chanSource receives values from a channel.
+type chanSource struct {
+	ch reflect.Value
+}
+
+func (s *chanSource) next(ctx context.Context) (reflect.Value, bool, error) {
+	chosen, value, ok := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: s.ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	})
+	if chosen == 1 {
+		return reflect.Value{}, false, ctx.Err()
+	}
+
+	return value, ok, nil
+}
+
+func (s *chanSource) close() {}
+
+// pullSource calls a function of the form func() (T, bool) for each value.
+type pullSource struct {
+	mutex sync.Mutex
+	fn    reflect.Value
+}
+
+func (s *pullSource) next(ctx context.Context) (value reflect.Value, ok bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return reflect.Value{}, false, err
+	}
+
+	defer recoverPanic(func(panicErr error) {
+		value, ok, err = reflect.Value{}, false, panicErr
+	})
+
+	out := s.fn.Call(nil)
+	return out[0], out[1].Bool(), nil
+}
+
+func (s *pullSource) close() {}
+
+// seqSource runs a function of the form func(yield func(T) bool) or
+// func(yield func(K, V) bool) on its own goroutine, which is paused until a
+// value is requested. Pairs are produced as two element arrays.
+type seqSource struct {
+	once    sync.Once
+	seq     reflect.Value
+	values  chan reflect.Value
+	stop    chan struct{}
+	stopped sync.Once
+
+	// err is set before values is closed if seq panics
+	err error
+}
+
+func newSeqSource(seq reflect.Value) *seqSource {
+	return &seqSource{
+		seq:    seq,
+		values: make(chan reflect.Value),
+		stop:   make(chan struct{}),
+	}
+}
+
+// start runs seq until it returns, yield returns false or ctx is done.
+func (s *seqSource) start(ctx context.Context) {
+	yieldType := s.seq.Type().In(0)
+	yield := reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+		value := args[0]
+		if len(args) == 2 {
+			value = reflect.ValueOf([]interface{}{args[0].Interface(), args[1].Interface()})
+		}
+
+		select {
+		case s.values <- value:
+			return []reflect.Value{reflect.ValueOf(true)}
+
+		case <-s.stop:
+			return []reflect.Value{reflect.ValueOf(false)}
+
+		case <-ctx.Done():
+			return []reflect.Value{reflect.ValueOf(false)}
+		}
+	})
+
+	go func() {
+		defer close(s.values)
+		defer recoverPanic(func(err error) {
+			s.err = err
+		})
+
+		s.seq.Call([]reflect.Value{yield})
+	}()
+}
+
+func (s *seqSource) next(ctx context.Context) (reflect.Value, bool, error) {
+	s.once.Do(func() {
+		s.start(ctx)
+	})
+
+	select {
+	case value, ok := <-s.values:
+		if !ok {
+			return reflect.Value{}, false, s.err
+		}
+
+		return value, true, nil
+
+	case <-ctx.Done():
+		return reflect.Value{}, false, ctx.Err()
+	}
+}
+
+func (s *seqSource) close() {
+	s.stopped.Do(func() {
+		close(s.stop)
+	})
+}
+
+// isSeqFunc reports whether t is of the form func(yield func(T) bool) or
+// func(yield func(K, V) bool).
+func isSeqFunc(t reflect.Type) bool {
+	if t.NumIn() != 1 || t.NumOut() != 0 || t.IsVariadic() {
+		return false
+	}
+
+	yield := t.In(0)
+	if yield.Kind() != reflect.Func || yield.IsVariadic() {
+		return false
+	}
+
+	return (yield.NumIn() == 1 || yield.NumIn() == 2) && yield.NumOut() == 1 && yield.Out(0).Kind() == reflect.Bool
+}
+
+// isPullFunc reports whether t is of the form func() (T, bool).
+func isPullFunc(t reflect.Type) bool {
+	return t.NumIn() == 0 && t.NumOut() == 2 && t.Out(1).Kind() == reflect.Bool
+}
+
+// asyncIterator holds the state of an async iterator that is backed by an
+// asyncSource. Except for the finalizer of the iterator, it is only used by
+// the goroutine that owns the runtime.
+type asyncIterator struct {
+	realm  *Realm
+	source asyncSource
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// done is set once the source is exhausted or fails or the iterator is
+	// returned, after which the source is no longer used
+	done bool
+
+	// pending is set while waiting for the source on behalf of the first
+	// request in the queue
+	pending bool
+
+	// queue holds the calls to next and return that have not settled yet, in
+	// the order in which they were made
+	queue []asyncIteratorRequest
+}
+
+type asyncIteratorRequest struct {
+	resolve, reject func(interface{}) error
+
+	// ret is set for calls to return, which settle with value
+	ret   bool
+	value reflect.Value
+}
+
+// enqueue adds a request that is settled after all requests before it.
+func (it *asyncIterator) enqueue(req asyncIteratorRequest) error {
+	it.queue = append(it.queue, req)
+	return it.pump()
+}
+
+// pump settles queued requests until one has to wait for the source.
+func (it *asyncIterator) pump() error {
+	for !it.pending && len(it.queue) > 0 {
+		req := it.queue[0]
+		if !it.done && !req.ret {
+			it.pending = true
+			it.realm.runtime.goAsync(func(context.Context) func() error {
+				value, ok, err := it.source.next(it.ctx)
+
+				return func() error {
+					return it.settle(value, ok, err)
+				}
+			})
+
+			return nil
+		}
+
+		it.queue = it.queue[1:]
+		if err := it.resolve(req, req.value, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// settle settles the first request in the queue with a result of the source.
+func (it *asyncIterator) settle(value reflect.Value, ok bool, err error) error {
+	it.pending = false
+
+	req := it.queue[0]
+	it.queue = it.queue[1:]
+
+	switch {
+	case it.done:
+		// the iterator was returned while waiting, so err is only the result
+		// of cancelling the source
+		err = it.resolve(req, reflect.Value{}, true)
+
+	case err != nil:
+		it.finish()
+		err = req.reject(it.realm.errorValue(err))
+
+	default:
+		if !ok {
+			it.finish()
+		}
+
+		err = it.resolve(req, value, !ok)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return it.pump()
+}
+
+func (it *asyncIterator) resolve(req asyncIteratorRequest, value reflect.Value, done bool) error {
+	if done && !req.ret {
+		value = reflect.Value{}
+	}
+
+	result, err := it.realm.newIteratorResult(value, done)
+	if err != nil {
+		return req.reject(it.realm.errorValue(err))
+	}
+
+	return req.resolve(result)
+}
+
+// finish stops using the source.
+func (it *asyncIterator) finish() {
+	it.done = true
+	it.cancel()
+	it.source.close()
+}
+
+// newAsyncIterable creates an async iterator that is also an async iterable,
+// so that it can be used with for await.
+//
+// Each call to next waits for a single value on a new goroutine, so values
+// are not produced faster than they are consumed. Calls to next and return
+// settle in the order in which they were made. Calling return, such as by
+// breaking out of a loop, closes the source, after which next resolves with
+// done set. The source is also closed if the iterator is garbage collected.
+func (r *Realm) newAsyncIterable(source asyncSource) (*Value, error) {
+	it := &asyncIterator{
+		realm:  r,
+		source: source,
+	}
+
+	it.ctx, it.cancel = context.WithCancel(r.runtime.ctx)
+
+	iterator, err := r.NewObjectWithFinalizer(func() {
+		// done cannot be set here, since finalizers may run on any goroutine
+		it.cancel()
+		it.source.close()
+	})
+	if err != nil {
+		it.cancel()
+		return nil, err
+	}
+
+	// objects with finalizers have no prototype
+	objectConstructor, err := r.globalProperty("Object")
+	if err != nil {
+		return nil, err
+	}
+
+	objectProto, err := objectConstructor.Get("prototype")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := objectConstructor.Invoke("setPrototypeOf", iterator, objectProto); err != nil {
+		return nil, err
+	}
+
+	next, err := r.NewFunction(func(r *Realm, _ *Value) (*Value, error) {
+		promise, resolve, reject := r.NewPromise()
+		if promise == nil {
+			return nil, resolve(nil)
+		}
+
+		if err := it.enqueue(asyncIteratorRequest{resolve: resolve, reject: reject}); err != nil {
+			return nil, err
+		}
+
+		return promise, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := r.NewFunction(func(r *Realm, _ *Value, args ...*Value) (*Value, error) {
+		promise, resolve, reject := r.NewPromise()
+		if promise == nil {
+			return nil, resolve(nil)
+		}
+
+		// cancel pending calls to next right away, since they may never
+		// settle otherwise
+		it.finish()
+
+		req := asyncIteratorRequest{resolve: resolve, reject: reject, ret: true}
+		if len(args) > 0 {
+			req.value = reflect.ValueOf(args[0])
+		}
+
+		if err := it.enqueue(req); err != nil {
+			return nil, err
+		}
+
+		return promise, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	self, err := r.NewFunction(func(r *Realm, this *Value) (*Value, error) {
+		return this, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	asyncIterator, err := r.wellKnownSymbol("asyncIterator")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, method := range []struct {
+		name  *Atom
+		value *Value
+	}{
+		{r.NewStringAtom("next"), next},
+		{r.NewStringAtom("return"), ret},
+		{asyncIterator, self},
+	} {
+		if _, err := iterator.DefinePropertyAtom(method.name, DefinePropertyValue(method.value), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+			return nil, err
+		}
+	}
+
+	return iterator, nil
+}
+
+func (r *Realm) newIteratorResult(value reflect.Value, done bool) (*Value, error) {
+	result, err := r.NewObject()
+	if err != nil {
+		return nil, err
+	}
+
+	var converted *Value
+	if value.IsValid() {
+		converted, err = r.Convert(value.Interface())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		converted = NewUndefined()
+	}
+
+	if _, err := result.Set("value", converted); err != nil {
+		return nil, err
+	}
+
+	if _, err := result.Set("done", NewBoolean(done)); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
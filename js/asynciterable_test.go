@@ -0,0 +1,220 @@
+package js
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+const collectScript = `(async () => {
+	const values = [];
+	for await (const value of source) {
+		values.push(JSON.stringify(value));
+	}
+	return values.join();
+})()`
+
+func TestAsyncIterableChan(t *testing.T) {
+	r := newTestRealm(t)
+
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for i := 1; i <= 3; i++ {
+			ch <- i
+		}
+	}()
+
+	setGlobal(t, r, "source", (<-chan int)(ch))
+
+	if got := mustAwait(t, r, collectScript).String(); got != "1,2,3" {
+		t.Errorf("got %q, want %q", got, "1,2,3")
+	}
+
+	expectString(t, r, `Object.getPrototypeOf(source) === Object.prototype`, "true")
+	expectString(t, r, `source[Symbol.asyncIterator]() === source`, "true")
+}
+
+func TestAsyncIterableSeq(t *testing.T) {
+	r := newTestRealm(t)
+
+	setGlobal(t, r, "source", func(yield func(string, int) bool) {
+		_ = yield("a", 1) && yield("b", 2)
+	})
+
+	if got := mustAwait(t, r, collectScript).String(); got != `["a",1],["b",2]` {
+		t.Errorf("got %q", got)
+	}
+
+	setGlobal(t, r, "source", func(yield func(int) bool) {
+		yield(1)
+		panic("boom")
+	})
+
+	if _, err := await(r, collectScript); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the panic to reject, got %v", err)
+	}
+}
+
+func TestAsyncIterablePull(t *testing.T) {
+	r := newTestRealm(t)
+
+	n := 0
+	setGlobal(t, r, "source", func() (int, bool) {
+		n++
+		return n, n <= 2
+	})
+
+	if got := mustAwait(t, r, collectScript).String(); got != "1,2" {
+		t.Errorf("got %q, want %q", got, "1,2")
+	}
+}
+
+// newStoppableSeq returns a seq that yields forever and a channel that is
+// closed once it returns.
+func newStoppableSeq() (func(yield func(int) bool), chan struct{}) {
+	stopped := make(chan struct{})
+	return func(yield func(int) bool) {
+		defer close(stopped)
+		for i := 0; yield(i); i++ {
+		}
+	}, stopped
+}
+
+func expectStopped(t *testing.T, stopped chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the seq was not stopped")
+	}
+}
+
+func TestAsyncIterableBreakStopsSeq(t *testing.T) {
+	r := newTestRealm(t)
+
+	seq, stopped := newStoppableSeq()
+	setGlobal(t, r, "source", seq)
+
+	mustAwait(t, r, `(async () => { for await (const value of source) { if (value == 2) break } })()`)
+	expectStopped(t, stopped)
+}
+
+func TestAsyncIterableFinalizerStopsSeq(t *testing.T) {
+	r := newTestRealm(t)
+
+	seq, stopped := newStoppableSeq()
+
+	fn, err := r.NewFunction(func(r *Realm, _ *Value) (*Value, error) {
+		return r.Convert(seq)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "makeSource", fn)
+
+	// the iterator is only referenced by the script, which drops it
+	mustAwait(t, r, `(async () => { await makeSource().next() })()`)
+
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		internal.RunGC(r.runtime.runtime)
+
+		select {
+		case <-stopped:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	expectStopped(t, stopped)
+}
+
+func TestAsyncIterableNextAfterDone(t *testing.T) {
+	r := newTestRealm(t)
+
+	setGlobal(t, r, "source", func(yield func(int) bool) {
+		yield(1)
+	})
+
+	// every call after the end resolves without going to the source
+	mustEval(t, r, `globalThis.results = []`)
+	mustAwait(t, r, `(async () => {
+		for (let i = 0; i < 5; i++) {
+			results.push(JSON.stringify(await source.next()));
+		}
+	})()`)
+
+	expectString(t, r, `results.join()`, `{"value":1,"done":false},{"done":true},{"done":true},{"done":true},{"done":true}`)
+
+	mustAwait(t, r, `(async () => {
+		results = [JSON.stringify(await source.return(7)), JSON.stringify(await source.next())];
+	})()`)
+
+	expectString(t, r, `results.join()`, `{"value":7,"done":true},{"done":true}`)
+}
+
+func TestAsyncIterableConcurrentNext(t *testing.T) {
+	r := newTestRealm(t)
+
+	ch := make(chan int, 3)
+	ch <- 1
+	ch <- 2
+	ch <- 3
+	close(ch)
+
+	setGlobal(t, r, "source", ch)
+
+	got := mustAwait(t, r, `(async () => {
+		const order = [];
+		const results = [source.next(), source.next(), source.next(), source.next()].map((p, i) => p.then(result => {
+			order.push(i);
+			return result.done ? "done" : result.value;
+		}));
+
+		return (await Promise.all(results)).join() + " " + order.join();
+	})()`).String()
+
+	if got != "1,2,3,done 0,1,2,3" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestAsyncIterableReturnWhileWaiting(t *testing.T) {
+	r := newTestRealm(t)
+
+	// the channel never produces a value
+	setGlobal(t, r, "source", make(chan int))
+
+	got := mustAwait(t, r, `(async () => {
+		const next = source.next();
+		const ret = source.return("r");
+		return JSON.stringify(await next) + " " + JSON.stringify(await ret);
+	})()`).String()
+
+	if got != `{"done":true} {"value":"r","done":true}` {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestAsyncIterableRuntimeClosed(t *testing.T) {
+	r := newTestRealm(t)
+
+	setGlobal(t, r, "source", make(chan int))
+
+	promise := mustEval(t, r, `source.next()`)
+	r.runtime.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := promise.Await(ctx); err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("expected the pending call to be rejected, got %v", err)
+	}
+}
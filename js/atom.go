@@ -27,6 +27,15 @@ func (r *Realm) createAtom(atom internal.Atom) *Atom {
 	return a
 }
 
+func (r *Realm) atomToString(atom internal.Atom) (string, error) {
+	v, err := r.createAndResolveValue(internal.AtomToString(r.context, atom))
+	if err != nil {
+		return "", err
+	}
+
+	return v.ToString(), nil
+}
+
 func (r *Realm) NewStringAtom(s string) *Atom {
 	return r.createAtom(internal.NewAtom(r.context, s))
 }
@@ -47,3 +56,31 @@ func (a *Atom) ToStringValue() (*Value, error) {
 func (a *Atom) ToValue() (*Value, error) {
 	return a.realm.createAndResolveValue(internal.AtomToValue(a.realm.context, a.atom))
 }
+
+// ToAtom converts v to a property key.
+func (v *Value) ToAtom() (*Atom, error) {
+	defer runtime.KeepAlive(v)
+
+	atom := internal.ValueToAtom(v.realm.context, v.value)
+	if atom == 0 {
+		return nil, v.realm.getError()
+	}
+
+	return v.realm.createAtom(atom), nil
+}
+
+// wellKnownSymbol returns a property key of the Symbol constructor, such as
+// iterator.
+func (r *Realm) wellKnownSymbol(name string) (*Atom, error) {
+	symbol, err := r.globalProperty("Symbol")
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := symbol.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return value.ToAtom()
+}
@@ -0,0 +1,425 @@
+package js
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+// ClassProperty is an accessor property of a class.
+//
+// Get and Set are functions that are accepted by Realm.NewFunction, such as
+// func(r *Realm, this *Value) (T, error) and
+// func(r *Realm, this *Value, value T) error. A property without a setter is
+// read-only.
+type ClassProperty struct {
+	Get interface{}
+	Set interface{}
+}
+
+// ClassSpec describes a class that is implemented in go.
+type ClassSpec struct {
+	// Name is the name of the global constructor.
+	Name string
+
+	// Constructor is called by `new` and returns the opaque value of the new
+	// instance, which can later be retrieved with Value.Opaque.
+	//
+	// If Constructor is nil, the constructor of the class that is extended is
+	// used instead. If there is none, calling `new` throws a TypeError.
+	Constructor func(r *Realm, args []*Value) (interface{}, error)
+
+	// Length is the number of arguments that Constructor expects, which is
+	// reported by the length property of the constructor.
+	Length int
+
+	// Methods of the prototype. Each method must be accepted by
+	// Realm.NewFunction.
+	Methods map[string]interface{}
+
+	// Properties are accessors on the prototype.
+	Properties map[string]ClassProperty
+
+	// StaticMethods of the constructor. Each method must be accepted by
+	// Realm.NewFunction.
+	StaticMethods map[string]interface{}
+
+	// StaticProperties are values of the constructor which are converted
+	// with Realm.Convert.
+	StaticProperties map[string]interface{}
+
+	// Extends is an optional class to inherit from.
+	Extends *Class
+}
+
+// Class is a class that is implemented in go.
+type Class struct {
+	runtime *Runtime
+	spec    ClassSpec
+}
+
+// DefineClass defines a class that is installed as a global in every realm
+// that is created afterwards. Use Class.Constructor to use the class in
+// realms that already exist.
+func (rt *Runtime) DefineClass(spec ClassSpec) (*Class, error) {
+	if spec.Name == "" {
+		return nil, errors.New("class name must not be empty")
+	}
+
+	if spec.Extends != nil && spec.Extends.runtime != rt {
+		return nil, errors.New("cannot extend a class of another runtime")
+	}
+
+	if spec.Length < 0 {
+		return nil, fmt.Errorf("class %s: length must not be negative, got %d", spec.Name, spec.Length)
+	}
+
+	for _, members := range []struct {
+		kind    string
+		methods map[string]interface{}
+	}{
+		{"method", spec.Methods},
+		{"static method", spec.StaticMethods},
+	} {
+		for _, name := range sortedKeys(members.methods) {
+			if err := checkFunction(reflect.ValueOf(members.methods[name])); err != nil {
+				return nil, fmt.Errorf("class %s: %s %s: %w", spec.Name, members.kind, name, err)
+			}
+		}
+	}
+
+	for name, property := range spec.Properties {
+		if property.Get == nil && property.Set == nil {
+			return nil, fmt.Errorf("class %s: property %s must have a getter or a setter", spec.Name, name)
+		}
+
+		for _, accessor := range []struct {
+			kind string
+			f    interface{}
+		}{
+			{"getter", property.Get},
+			{"setter", property.Set},
+		} {
+			if accessor.f == nil {
+				continue
+			}
+
+			if err := checkFunction(reflect.ValueOf(accessor.f)); err != nil {
+				return nil, fmt.Errorf("class %s: %s of property %s: %w", spec.Name, accessor.kind, name, err)
+			}
+		}
+	}
+
+	c := &Class{
+		runtime: rt,
+		spec:    spec,
+	}
+
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	rt.classes = append(rt.classes, c)
+
+	return c, nil
+}
+
+// Name returns the name of the class.
+func (c *Class) Name() string {
+	return c.spec.Name
+}
+
+// constructingClass returns the class whose constructor is used to construct
+// instances of c, which is c itself or the closest class that it extends that
+// has a constructor.
+func (c *Class) constructingClass() *Class {
+	for ; c != nil; c = c.spec.Extends {
+		if c.spec.Constructor != nil {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (c *Class) constructor() func(r *Realm, args []*Value) (interface{}, error) {
+	if c := c.constructingClass(); c != nil {
+		return c.spec.Constructor
+	}
+
+	return nil
+}
+
+// Constructor returns the constructor of the class in the given realm.
+func (c *Class) Constructor(r *Realm) (*Value, error) {
+	if r.runtime != c.runtime {
+		return nil, errors.New("class belongs to another runtime")
+	}
+
+	if ctor, ok := r.prototype(c); ok {
+		return ctor, nil
+	}
+
+	var ctorProto, proto *Value
+	if c.spec.Extends != nil {
+		parent, err := c.spec.Extends.Constructor(r)
+		if err != nil {
+			return nil, err
+		}
+
+		parentProto, err := parent.Get("prototype")
+		if err != nil {
+			return nil, err
+		}
+
+		ctorProto = parent
+
+		proto, err = r.NewObjectProto(parentProto)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		function, err := r.globalProperty("Function")
+		if err != nil {
+			return nil, err
+		}
+
+		ctorProto, err = function.Get("prototype")
+		if err != nil {
+			return nil, err
+		}
+
+		proto, err = r.NewObject()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctor, err := r.newClassConstructor(c, ctorProto)
+	if err != nil {
+		return nil, err
+	}
+
+	r.SetConstructor(ctor, proto)
+
+	var length int
+	if constructing := c.constructingClass(); constructing != nil {
+		length = constructing.spec.Length
+	}
+
+	if _, err := ctor.DefineProperty("length", DefinePropertyValue(length), DefinePropertyConfigurable(true)); err != nil {
+		return nil, err
+	}
+
+	if _, err := ctor.DefineProperty("name", DefinePropertyValue(c.spec.Name), DefinePropertyConfigurable(true)); err != nil {
+		return nil, err
+	}
+
+	if err := defineClassMembers(proto, c.spec.Methods, c.spec.Properties); err != nil {
+		return nil, err
+	}
+
+	if err := defineClassMembers(ctor, c.spec.StaticMethods, nil); err != nil {
+		return nil, err
+	}
+
+	for _, name := range sortedKeys(c.spec.StaticProperties) {
+		if _, err := ctor.DefineProperty(name, DefinePropertyValue(c.spec.StaticProperties[name]), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+			return nil, err
+		}
+	}
+
+	r.setPrototype(c, ctor)
+
+	return ctor, nil
+}
+
+func (r *Realm) newClassConstructor(c *Class, proto *Value) (*Value, error) {
+	defer runtime.KeepAlive(proto)
+
+	return r.createAndResolveValue(internal.NewConstructor(r.context, proto.value, func(ctx *internal.Context, thisValue internal.Value, args []internal.Value, flags internal.CallFlag) (result internal.Value) {
+		defer recoverPanic(func(err error) {
+			result = r.throw(err)
+		})
+
+		if flags&internal.CallFlagConstructor == 0 {
+			return r.throw(NewTypeError("class constructor %s cannot be invoked without 'new'", c.spec.Name))
+		}
+
+		constructor := c.constructor()
+		if constructor == nil {
+			return r.throw(NewTypeError("%s is not constructible", c.spec.Name))
+		}
+
+		newTarget := r.createValue(internal.DupValue(ctx, thisValue))
+		proto, err := newTarget.Get("prototype")
+		if err != nil {
+			return r.throw(err)
+		}
+
+		if !proto.IsObject() {
+			// fall back to the prototype of the class, as with ordinary
+			// constructors
+			ctor, err := c.Constructor(r)
+			if err != nil {
+				return r.throw(err)
+			}
+
+			proto, err = ctor.Get("prototype")
+			if err != nil {
+				return r.throw(err)
+			}
+		}
+
+		argValues := make([]*Value, len(args))
+		for i, arg := range args {
+			argValues[i] = r.createValue(internal.DupValue(ctx, arg))
+		}
+
+		opaque, err := constructor(r, argValues)
+		if err != nil {
+			return r.throw(err)
+		}
+
+		defer runtime.KeepAlive(proto)
+
+		return internal.NewObjectWithOpaque(ctx, proto.value, opaque)
+	}))
+}
+
+func defineClassMembers(obj *Value, methods map[string]interface{}, properties map[string]ClassProperty) error {
+	for _, name := range sortedKeys(methods) {
+		method, err := obj.realm.NewFunction(methods[name])
+		if err != nil {
+			return err
+		}
+
+		if _, err := obj.DefineProperty(name, DefinePropertyValue(method), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		property := properties[name]
+
+		opts := []DefinePropertyOption{DefinePropertyConfigurable(true)}
+		if property.Get != nil {
+			opts = append(opts, DefinePropertyGetter(property.Get))
+		}
+
+		if property.Set != nil {
+			opts = append(opts, DefinePropertySetter(property.Set))
+		}
+
+		if _, err := obj.DefineProperty(name, opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// NewInstance creates an instance of the class in the given realm that holds
+// opaque without calling the constructor.
+func (c *Class) NewInstance(r *Realm, opaque interface{}) (*Value, error) {
+	ctor, err := c.Constructor(r)
+	if err != nil {
+		return nil, err
+	}
+
+	proto, err := ctor.Get("prototype")
+	if err != nil {
+		return nil, err
+	}
+
+	defer runtime.KeepAlive(proto)
+
+	return r.createAndResolveValue(internal.NewObjectWithOpaque(r.context, proto.value, opaque))
+}
+
+// Opaque returns the go value held by an instance of a class defined with
+// Runtime.DefineClass or nil if v is not such an instance.
+func (v *Value) Opaque() interface{} {
+	if !v.IsObject() {
+		return nil
+	}
+
+	defer runtime.KeepAlive(v)
+
+	opaque, _ := internal.GetOpaque(v.value)
+	return opaque
+}
+
+// defineGlobalClass defines a class that only exists in r as a global, such
+// as the classes of intrinsics.
+func (r *Realm) defineGlobalClass(spec ClassSpec) (*Class, error) {
+	c := &Class{
+		runtime: r.runtime,
+		spec:    spec,
+	}
+
+	ctor, err := c.Constructor(r)
+	if err != nil {
+		return nil, err
+	}
+
+	global, err := r.GlobalObject()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := global.DefineProperty(spec.Name, DefinePropertyValue(ctor), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (r *Realm) installClasses() error {
+	r.runtime.mutex.Lock()
+	classes := append([]*Class{}, r.runtime.classes...)
+	r.runtime.mutex.Unlock()
+
+	if len(classes) == 0 {
+		return nil
+	}
+
+	global, err := r.GlobalObject()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range classes {
+		ctor, err := c.Constructor(r)
+		if err != nil {
+			return err
+		}
+
+		if _, err := global.DefineProperty(c.spec.Name, DefinePropertyValue(ctor), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
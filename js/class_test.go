@@ -0,0 +1,171 @@
+package js
+
+import (
+	"strings"
+	"testing"
+)
+
+type testPoint struct {
+	X, Y int
+}
+
+func testPointSpec() ClassSpec {
+	return ClassSpec{
+		Name: "Point",
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			return &testPoint{X: optionalArg(args, 0).ToInt(), Y: optionalArg(args, 1).ToInt()}, nil
+		},
+		Length: 2,
+		Methods: map[string]interface{}{
+			"sum": func(r *Realm, this *Value) (int, error) {
+				p, ok := this.Opaque().(*testPoint)
+				if !ok {
+					return 0, NewTypeError("Illegal invocation")
+				}
+
+				return p.X + p.Y, nil
+			},
+		},
+		Properties: map[string]ClassProperty{
+			"x": {
+				Get: func(r *Realm, this *Value) (int, error) {
+					return this.Opaque().(*testPoint).X, nil
+				},
+				Set: func(r *Realm, this *Value, x int) error {
+					this.Opaque().(*testPoint).X = x
+					return nil
+				},
+			},
+		},
+		StaticMethods: map[string]interface{}{
+			"origin": func(r *Realm, this *Value) (*Value, error) {
+				return r.Eval(`new Point(0, 0)`)
+			},
+		},
+		StaticProperties: map[string]interface{}{
+			"dimensions": 2,
+		},
+	}
+}
+
+func TestDefineClass(t *testing.T) {
+	r := newTestRealm(t)
+
+	class, err := r.runtime.DefineClass(testPointSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// classes are installed in realms that are created afterwards
+	if _, err := r.Eval(`Point`); err == nil {
+		t.Error("expected the class to be missing from an existing realm")
+	}
+
+	r, err = r.runtime.NewRealm()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectString(t, r, `const p = new Point(1, 2); p.sum()`, "3")
+	expectString(t, r, `p.x = 5; p.x`, "5")
+	expectString(t, r, `p instanceof Point`, "true")
+	expectString(t, r, `[Point.name, Point.length, Point.dimensions].join()`, "Point,2,2")
+	expectString(t, r, `Point.origin().sum()`, "0")
+
+	if got := mustEval(t, r, `p`).Opaque().(*testPoint); got.X != 5 || got.Y != 2 {
+		t.Errorf("Opaque() = %+v", got)
+	}
+
+	instance, err := class.NewInstance(r, &testPoint{X: 3, Y: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := instance.Invoke("sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum.ToInt() != 7 {
+		t.Errorf("sum() = %d, want 7", sum.ToInt())
+	}
+
+	for _, script := range []string{`Point(1, 2)`, `Point.prototype.sum.call({})`} {
+		if _, err := r.Eval(script); err == nil || !strings.Contains(err.Error(), "TypeError") {
+			t.Errorf("%s: expected a TypeError, got %v", script, err)
+		}
+	}
+}
+
+func TestDefineClassExtends(t *testing.T) {
+	rt := newTestRuntime(t)
+
+	base, err := rt.DefineClass(testPointSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rt.DefineClass(ClassSpec{
+		Name:    "Point3",
+		Extends: base,
+		Methods: map[string]interface{}{
+			"double": func(r *Realm, this *Value) (int, error) {
+				return this.Opaque().(*testPoint).X * 2, nil
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rt.DefineClass(ClassSpec{Name: "Abstract"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := rt.NewRealm()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectString(t, r, `const p = new Point3(3, 4); [p.sum(), p.double(), p instanceof Point].join()`, "7,6,true")
+	expectString(t, r, `Point3.length`, "2")
+	expectString(t, r, `Abstract.length`, "0")
+
+	if _, err := r.Eval(`new Abstract()`); err == nil || !strings.Contains(err.Error(), "not constructible") {
+		t.Errorf("expected a class without a constructor to throw, got %v", err)
+	}
+}
+
+func TestDefineClassErrors(t *testing.T) {
+	rt := newTestRuntime(t)
+	other := newTestRuntime(t)
+
+	otherClass, err := other.DefineClass(ClassSpec{Name: "Other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name string
+		spec ClassSpec
+		want string
+	}{
+		{"empty name", ClassSpec{}, "class name must not be empty"},
+		{"other runtime", ClassSpec{Name: "A", Extends: otherClass}, "another runtime"},
+		{"negative length", ClassSpec{Name: "A", Length: -1}, "length must not be negative"},
+		{"method", ClassSpec{Name: "A", Methods: map[string]interface{}{"m": func() {}}}, "method m: f must have at least 2 args"},
+		{"static method", ClassSpec{Name: "A", StaticMethods: map[string]interface{}{"m": func(r *Realm, this *Value) int { return 0 }}}, "static method m: last return value f must be error"},
+		{"not a function", ClassSpec{Name: "A", Methods: map[string]interface{}{"m": 1}}, "f must be a function"},
+		{"getter", ClassSpec{Name: "A", Properties: map[string]ClassProperty{"p": {Get: func(this *Value) {}}}}, "getter of property p"},
+		{"setter", ClassSpec{Name: "A", Properties: map[string]ClassProperty{"p": {Set: func(r *Realm, v int) error { return nil }}}}, "setter of property p"},
+		{"no accessors", ClassSpec{Name: "A", Properties: map[string]ClassProperty{"p": {}}}, "must have a getter or a setter"},
+	} {
+		if _, err := rt.DefineClass(test.spec); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.want)
+		}
+	}
+
+	// invalid classes must not be installed in new realms
+	if _, err := rt.NewRealm(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,539 @@
+package js
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// console implements the console namespace of a realm. Output is passed to
+// handler as records whose message is the formatted output.
+type console struct {
+	handler slog.Handler
+	options InspectOptions
+
+	counts map[string]int
+	timers map[string]time.Time
+	indent string
+}
+
+func newConsole(handler slog.Handler, options InspectOptions) *console {
+	return &console{
+		handler: handler,
+		options: options,
+		counts:  map[string]int{},
+		timers:  map[string]time.Time{},
+	}
+}
+
+// print passes msg to the handler, indented by the current group.
+func (c *console) print(level slog.Level, method, msg string, attrs ...slog.Attr) error {
+	ctx := context.Background()
+	if !c.handler.Enabled(ctx, level) {
+		return nil
+	}
+
+	if c.indent != "" {
+		msg = c.indent + strings.ReplaceAll(msg, "\n", "\n"+c.indent)
+	}
+
+	record := slog.NewRecord(time.Now(), level, msg, 0)
+	record.AddAttrs(slog.String("method", method))
+	record.AddAttrs(attrs...)
+
+	return c.handler.Handle(ctx, record)
+}
+
+// logger returns a console method that formats its arguments as with
+// util.format.
+func (c *console) logger(level slog.Level, method string) interface{} {
+	return func(r *Realm, _ *Value, args ...*Value) error {
+		return c.print(level, method, r.formatLogArgs(args, c.options))
+	}
+}
+
+func (c *console) assert(r *Realm, _ *Value, args ...*Value) error {
+	if len(args) > 0 {
+		ok, err := args[0].IsTruthy()
+		if err != nil || ok {
+			return err
+		}
+
+		args = args[1:]
+	}
+
+	msg := "Assertion failed"
+	if len(args) > 0 {
+		msg += ": " + r.formatLogArgs(args, c.options)
+	}
+
+	return c.print(slog.LevelError, "assert", msg)
+}
+
+func (c *console) trace(r *Realm, _ *Value, args ...*Value) error {
+	msg := "Trace"
+	if len(args) > 0 {
+		msg += ": " + r.formatLogArgs(args, c.options)
+	}
+
+	if stack := r.currentStack(); stack != "" {
+		msg += "\n" + stack
+	}
+
+	return c.print(slog.LevelDebug, "trace", msg)
+}
+
+// label returns the label argument of count and time methods.
+func label(args []*Value) string {
+	if len(args) == 0 || args[0].Tag() == TagUndefined {
+		return "default"
+	}
+
+	return primitiveString(args[0])
+}
+
+func (c *console) count(_ *Realm, _ *Value, args ...*Value) error {
+	l := label(args)
+	c.counts[l]++
+
+	return c.print(slog.LevelInfo, "count", l+": "+strconv.Itoa(c.counts[l]), slog.String("label", l), slog.Int("count", c.counts[l]))
+}
+
+func (c *console) countReset(_ *Realm, _ *Value, args ...*Value) error {
+	l := label(args)
+	if _, ok := c.counts[l]; !ok {
+		return c.print(slog.LevelWarn, "countReset", "Count for '"+l+"' does not exist", slog.String("label", l))
+	}
+
+	delete(c.counts, l)
+
+	return nil
+}
+
+func (c *console) time(_ *Realm, _ *Value, args ...*Value) error {
+	l := label(args)
+	if _, ok := c.timers[l]; ok {
+		return c.print(slog.LevelWarn, "time", "Label '"+l+"' already exists for console.time()", slog.String("label", l))
+	}
+
+	c.timers[l] = time.Now()
+
+	return nil
+}
+
+// timeLog prints the time that has elapsed since console.time was called
+// with the same label, followed by the remaining arguments.
+func (c *console) timeLog(r *Realm, method string, args []*Value, stop bool) error {
+	l := label(args)
+	start, ok := c.timers[l]
+	if !ok {
+		return c.print(slog.LevelWarn, method, "No such label '"+l+"' for console."+method+"()", slog.String("label", l))
+	}
+
+	if stop {
+		delete(c.timers, l)
+	}
+
+	elapsed := time.Since(start)
+
+	msg := l + ": " + formatDuration(elapsed)
+	if len(args) > 1 {
+		msg += " " + r.formatLogArgs(args[1:], c.options)
+	}
+
+	return c.print(slog.LevelInfo, method, msg, slog.String("label", l), slog.Duration("elapsed", elapsed))
+}
+
+func formatDuration(d time.Duration) string {
+	ms := float64(d) / float64(time.Millisecond)
+	switch {
+	case ms >= 60*60*1000:
+		return strconv.FormatFloat(ms/(60*60*1000), 'f', 3, 64) + "h"
+	case ms >= 60*1000:
+		return strconv.FormatFloat(ms/(60*1000), 'f', 3, 64) + "min"
+	case ms >= 1000:
+		return strconv.FormatFloat(ms/1000, 'f', 3, 64) + "s"
+	}
+
+	return strconv.FormatFloat(ms, 'f', 3, 64) + "ms"
+}
+
+func (c *console) group(r *Realm, _ *Value, args ...*Value) error {
+	if len(args) > 0 {
+		if err := c.print(slog.LevelInfo, "group", r.formatLogArgs(args, c.options)); err != nil {
+			return err
+		}
+	}
+
+	c.indent += "  "
+
+	return nil
+}
+
+func (c *console) groupEnd(_ *Realm, _ *Value) {
+	if len(c.indent) >= 2 {
+		c.indent = c.indent[2:]
+	}
+}
+
+func (c *console) dir(r *Realm, _ *Value, args ...*Value) error {
+	if len(args) == 0 {
+		return c.print(slog.LevelInfo, "dir", "undefined")
+	}
+
+	return c.print(slog.LevelInfo, "dir", args[0].Inspect(c.options))
+}
+
+func (c *console) table(r *Realm, _ *Value, args ...*Value) error {
+	if len(args) == 0 || !args[0].IsObject() {
+		return c.print(slog.LevelInfo, "table", r.formatLogArgs(args, c.options))
+	}
+
+	var columns []*Value
+	if len(args) > 1 && args[1].IsArray() {
+		if err := args[1].Iterate(func(v *Value) (bool, error) {
+			columns = append(columns, v)
+			return true, nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	table, err := r.renderTable(args[0], columns, c.options)
+	if err != nil {
+		return err
+	}
+
+	return c.print(slog.LevelInfo, "table", table)
+}
+
+// install defines the console methods on obj.
+func (c *console) install(obj *Value) error {
+	methods := map[string]interface{}{
+		"log":            c.logger(slog.LevelInfo, "log"),
+		"info":           c.logger(slog.LevelInfo, "info"),
+		"debug":          c.logger(slog.LevelDebug, "debug"),
+		"warn":           c.logger(slog.LevelWarn, "warn"),
+		"error":          c.logger(slog.LevelError, "error"),
+		"dirxml":         c.logger(slog.LevelInfo, "dirxml"),
+		"trace":          c.trace,
+		"assert":         c.assert,
+		"count":          c.count,
+		"countReset":     c.countReset,
+		"time":           c.time,
+		"group":          c.group,
+		"groupCollapsed": c.group,
+		"groupEnd":       c.groupEnd,
+		"dir":            c.dir,
+		"table":          c.table,
+		"timeLog": func(r *Realm, _ *Value, args ...*Value) error {
+			return c.timeLog(r, "timeLog", args, false)
+		},
+		"timeEnd": func(r *Realm, _ *Value, args ...*Value) error {
+			return c.timeLog(r, "timeEnd", args, true)
+		},
+	}
+
+	return defineClassMembers(obj, methods, nil)
+}
+
+// formatLogArgs formats console arguments as with node's util.format. If the
+// first argument is a string, it may contain the substitutions %s, %d, %i,
+// %f, %j, %o, %O, %c and %%. Remaining arguments are appended, separated by
+// spaces, with strings as they are and other values inspected.
+func (r *Realm) formatLogArgs(args []*Value, opts InspectOptions) string {
+	var b strings.Builder
+
+	if len(args) > 0 && args[0].IsString() {
+		format := args[0].ToString()
+		args = args[1:]
+
+		for i := 0; i < len(format); i++ {
+			if format[i] != '%' || i == len(format)-1 {
+				b.WriteByte(format[i])
+				continue
+			}
+
+			verb := format[i+1]
+			if verb == '%' {
+				b.WriteByte('%')
+				i++
+				continue
+			}
+
+			if !strings.ContainsRune("sdifjoOc", rune(verb)) || len(args) == 0 {
+				b.WriteByte('%')
+				continue
+			}
+
+			b.WriteString(r.formatLogArg(verb, args[0], opts))
+			args = args[1:]
+			i++
+		}
+	} else if len(args) > 0 {
+		b.WriteString(formatLogValue(args[0], opts))
+		args = args[1:]
+	}
+
+	for _, arg := range args {
+		b.WriteByte(' ')
+		b.WriteString(formatLogValue(arg, opts))
+	}
+
+	return b.String()
+}
+
+// formatLogValue formats a value that is not substituted into a format
+// string.
+func formatLogValue(v *Value, opts InspectOptions) string {
+	if v.IsString() {
+		return v.ToString()
+	}
+
+	return v.Inspect(opts)
+}
+
+func (r *Realm) formatLogArg(verb byte, arg *Value, opts InspectOptions) string {
+	switch verb {
+	case 's':
+		switch {
+		case arg.IsString():
+			return arg.ToString()
+
+		case arg.IsObject(), arg.IsBigInt(), arg.Tag() == TagFloat64:
+			return arg.inspect(opts, 0)
+		}
+
+		return primitiveString(arg)
+
+	case 'd', 'i', 'f':
+		if arg.IsBigInt() {
+			return arg.ToBigInt().String() + "n"
+		}
+
+		if arg.IsObject() || arg.IsSymbol() {
+			return "NaN"
+		}
+
+		fn := map[byte]string{'d': "Number", 'i': "parseInt", 'f': "parseFloat"}[verb]
+		n, err := r.globalProperty(fn)
+		if err != nil {
+			return "NaN"
+		}
+
+		result, err := n.Call(nil, arg)
+		if err != nil {
+			return "NaN"
+		}
+
+		return result.Inspect(opts)
+
+	case 'j':
+		s, err := r.Stringify(arg, "")
+		if err != nil {
+			return "[Circular]"
+		}
+
+		return s
+
+	case 'o':
+		return arg.inspect(opts, 4)
+
+	case 'O':
+		return arg.Inspect(opts)
+	}
+
+	// %c is css, which is ignored
+	return ""
+}
+
+// currentStack returns the js stack trace of the caller, without the
+// native frame of the function that is calling it.
+func (r *Realm) currentStack() string {
+	errorCtor, err := r.globalProperty("Error")
+	if err != nil {
+		return ""
+	}
+
+	errorValue, err := errorCtor.Construct()
+	if err != nil {
+		return ""
+	}
+
+	stack, err := errorValue.Get("stack")
+	if err != nil || !stack.IsString() {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimRight(stack.ToString(), "\n"), "\n")
+	if len(lines) > 0 && strings.HasSuffix(lines[0], "(native)") {
+		lines = lines[1:]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// renderTable renders tabular data as with console.table.
+func (r *Realm) renderTable(data *Value, columns []*Value, opts InspectOptions) (string, error) {
+	const (
+		indexHeader  = "(index)"
+		valuesHeader = "Values"
+	)
+
+	var (
+		header    = []string{indexHeader}
+		rows      [][]string
+		keyIndex  = map[string]int{}
+		hasValues bool
+		cells     []map[string]string
+		values    []string
+	)
+
+	filter := map[string]bool{}
+	for _, column := range columns {
+		name := primitiveString(column)
+		filter[name] = true
+		keyIndex[name] = len(header)
+		header = append(header, name)
+	}
+
+	cell := func(v *Value) string {
+		return v.inspect(opts, 0)
+	}
+
+	var indexes []string
+	if err := data.forEachOwnEnumerableProperty(func(index string, row *Value) error {
+		indexes = append(indexes, index)
+
+		rowCells := map[string]string{}
+		value := ""
+
+		if row.IsObject() && !row.IsFunction() {
+			if err := row.forEachOwnEnumerableProperty(func(key string, v *Value) error {
+				if len(columns) > 0 && !filter[key] {
+					return nil
+				}
+
+				if _, ok := keyIndex[key]; !ok {
+					keyIndex[key] = len(header)
+					header = append(header, key)
+				}
+
+				rowCells[key] = cell(v)
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else {
+			hasValues = true
+			value = cell(row)
+		}
+
+		cells = append(cells, rowCells)
+		values = append(values, value)
+
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	if hasValues {
+		header = append(header, valuesHeader)
+	}
+
+	for i, index := range indexes {
+		row := make([]string, len(header))
+		row[0] = index
+		for key, value := range cells[i] {
+			row[keyIndex[key]] = value
+		}
+
+		if hasValues {
+			row[len(row)-1] = values[i]
+		}
+
+		rows = append(rows, row)
+	}
+
+	return renderTableRows(header, rows), nil
+}
+
+func renderTableRows(header []string, rows [][]string) string {
+	widths := make([]int, len(header))
+	for i, name := range header {
+		widths[i] = displayWidth(name) + 2
+	}
+
+	for _, row := range rows {
+		for i, value := range row {
+			if width := displayWidth(value) + 2; width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+
+	divider := func(left, middle, right string) string {
+		parts := make([]string, len(widths))
+		for i, width := range widths {
+			parts[i] = strings.Repeat("─", width)
+		}
+
+		return left + strings.Join(parts, middle) + right
+	}
+
+	renderRow := func(row []string) string {
+		parts := make([]string, len(row))
+		for i, value := range row {
+			padding := widths[i] - displayWidth(value)
+			left := padding / 2
+			parts[i] = strings.Repeat(" ", left) + value + strings.Repeat(" ", padding-left)
+		}
+
+		return "│" + strings.Join(parts, "│") + "│"
+	}
+
+	lines := []string{
+		divider("┌", "┬", "┐"),
+		renderRow(header),
+		divider("├", "┼", "┤"),
+	}
+
+	for _, row := range rows {
+		lines = append(lines, renderRow(row))
+	}
+
+	lines = append(lines, divider("└", "┴", "┘"))
+
+	return strings.Join(lines, "\n")
+}
+
+// logHandler writes records to a log.Logger as lines such as "INFO: message"
+// and ignores attributes.
+type logHandler struct {
+	logger *log.Logger
+}
+
+func (h logHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h logHandler) Handle(_ context.Context, record slog.Record) error {
+	if h.logger == nil {
+		log.Println(record.Level.String()+":", record.Message)
+	} else {
+		h.logger.Println(record.Level.String()+":", record.Message)
+	}
+
+	return nil
+}
+
+func (h logHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h logHandler) WithGroup(string) slog.Handler {
+	return h
+}
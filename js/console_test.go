@@ -0,0 +1,199 @@
+package js
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// recordingHandler records the messages of the records that it handles.
+type recordingHandler struct {
+	level   slog.Level
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *recordingHandler) Handle(_ context.Context, record slog.Record) error {
+	h.records = append(h.records, record)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h *recordingHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+func (h *recordingHandler) messages() []string {
+	messages := make([]string, len(h.records))
+	for i, record := range h.records {
+		messages[i] = record.Message
+	}
+
+	h.records = nil
+
+	return messages
+}
+
+func newTestConsole(t *testing.T) (*Realm, *recordingHandler) {
+	t.Helper()
+
+	h := &recordingHandler{level: slog.LevelDebug}
+	return newTestRealm(t, AddIntrinsicConsoleWithHandler(h)), h
+}
+
+func expectMessages(t *testing.T, h *recordingHandler, want ...string) {
+	t.Helper()
+
+	got := h.messages()
+	if strings.Join(got, "\x00") != strings.Join(want, "\x00") {
+		t.Errorf("got messages %q, want %q", got, want)
+	}
+}
+
+func TestConsoleFormat(t *testing.T) {
+	r, h := newTestConsole(t)
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`console.log("a", 1, "b")`, "a 1 b"},
+		{`console.log("%s is %d years", "Bob", 42)`, "Bob is 42 years"},
+		{`console.log("%i %f", 4.5, "1.5")`, "4 1.5"},
+		{`console.log("%d", {})`, "NaN"},
+		{`console.log("%d", 10n)`, "10n"},
+		{`console.log("%j", {a: [1]})`, `{"a":[1]}`},
+		{`console.log("%c styled", "color: red")`, " styled"},
+		{`console.log("100%% %x %s")`, "100% %x %s"},
+		{`console.log("%s", "a", "extra")`, "a extra"},
+		{`console.log(1, "two")`, "1 two"},
+		{`console.log()`, ""},
+		{`console.log({a: [1, "s"]}, "s")`, "{ a: [ 1, 's' ] } s"},
+		{`console.log("%s|%o", {a: 1}, [1])`, "{ a: 1 }|[ 1 ]"},
+		{`console.dir("s")`, "'s'"},
+		{`console.dir()`, "undefined"},
+	} {
+		mustEval(t, r, test.script)
+		expectMessages(t, h, test.want)
+	}
+}
+
+func TestConsoleLevels(t *testing.T) {
+	r, h := newTestConsole(t)
+
+	mustEval(t, r, `console.debug("d"); console.info("i"); console.warn("w"); console.error("e"); console.assert(true, "ok"); console.assert(false, "failed %d", 1)`)
+
+	var got []string
+	for _, record := range h.records {
+		var method string
+		record.Attrs(func(attr slog.Attr) bool {
+			if attr.Key == "method" {
+				method = attr.Value.String()
+			}
+
+			return true
+		})
+
+		got = append(got, record.Level.String()+" "+method+" "+record.Message)
+	}
+
+	want := []string{"DEBUG debug d", "INFO info i", "WARN warn w", "ERROR error e", "ERROR assert Assertion failed: failed 1"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// disabled levels are not handled
+	h.records = nil
+	h.level = slog.LevelWarn
+	mustEval(t, r, `console.log("hidden"); console.warn("shown")`)
+	expectMessages(t, h, "shown")
+}
+
+func TestConsoleCountAndTime(t *testing.T) {
+	r, h := newTestConsole(t)
+
+	mustEval(t, r, `console.count(); console.count(); console.count("x"); console.countReset(); console.count(); console.countReset("missing")`)
+	expectMessages(t, h, "default: 1", "default: 2", "x: 1", "default: 1", "Count for 'missing' does not exist")
+
+	mustEval(t, r, `console.time("t"); console.time("t"); console.timeLog("t", "extra"); console.timeEnd("t"); console.timeEnd("t")`)
+
+	got := h.messages()
+	if len(got) != 4 {
+		t.Fatalf("got messages %q", got)
+	}
+
+	if got[0] != "Label 't' already exists for console.time()" || got[3] != "No such label 't' for console.timeEnd()" {
+		t.Errorf("got messages %q", got)
+	}
+
+	if !regexp.MustCompile(`^t: \d+\.\d{3}ms extra$`).MatchString(got[1]) || !regexp.MustCompile(`^t: \d+\.\d{3}ms$`).MatchString(got[2]) {
+		t.Errorf("got messages %q", got)
+	}
+}
+
+func TestConsoleGroup(t *testing.T) {
+	r, h := newTestConsole(t)
+
+	mustEval(t, r, `console.group("outer"); console.log("a\nb"); console.groupCollapsed(); console.log("c"); console.groupEnd(); console.groupEnd(); console.groupEnd(); console.log("d")`)
+	expectMessages(t, h, "outer", "  a\n  b", "    c", "d")
+}
+
+func TestConsoleTrace(t *testing.T) {
+	r, h := newTestConsole(t)
+
+	mustEval(t, r, `function traced() { console.trace("here") }; traced()`)
+
+	got := h.messages()
+	if len(got) != 1 || !strings.HasPrefix(got[0], "Trace: here\n") || !strings.Contains(got[0], "at traced") || strings.Contains(got[0], "(native)") {
+		t.Errorf("got messages %q", got)
+	}
+}
+
+func TestConsoleTable(t *testing.T) {
+	r, h := newTestConsole(t)
+
+	mustEval(t, r, `console.table([{a: 1, b: "x"}, {a: 2}, 3])`)
+	expectMessages(t, h, strings.Join([]string{
+		"┌─────────┬───┬─────┬────────┐",
+		"│ (index) │ a │  b  │ Values │",
+		"├─────────┼───┼─────┼────────┤",
+		"│    0    │ 1 │ 'x' │        │",
+		"│    1    │ 2 │     │        │",
+		"│    2    │   │     │   3    │",
+		"└─────────┴───┴─────┴────────┘",
+	}, "\n"))
+
+	mustEval(t, r, `console.table({r: {a: 1, b: 2}}, ["b"])`)
+	expectMessages(t, h, strings.Join([]string{
+		"┌─────────┬───┐",
+		"│ (index) │ b │",
+		"├─────────┼───┤",
+		"│    r    │ 2 │",
+		"└─────────┴───┘",
+	}, "\n"))
+
+	// values that are not objects are logged
+	mustEval(t, r, `console.table("plain")`)
+	expectMessages(t, h, "plain")
+}
+
+func TestConsoleWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	r := newTestRealm(t, AddIntrinsicConsoleWithLogger(log.New(&buf, "", 0)))
+
+	mustEval(t, r, `console.log("hello"); console.error("oops")`)
+
+	if got := buf.String(); got != "INFO: hello\nERROR: oops\n" {
+		t.Errorf("got %q", got)
+	}
+}
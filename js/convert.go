@@ -2,7 +2,10 @@ package js
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"time"
 
 	"github.com/ssttevee/go-quickjs/internal"
 )
@@ -19,6 +22,9 @@ func (l Literal) JSValue(r *Realm) (*Value, error) {
 
 func (r *Realm) Convert(v interface{}) (*Value, error) {
 	switch v := v.(type) {
+	case nil:
+		return NewNull(), nil
+
 	case *Value:
 		return v, nil
 
@@ -26,21 +32,7 @@ func (r *Realm) Convert(v interface{}) (*Value, error) {
 		return v.JSValue(r)
 
 	case error:
-		msg, err := r.Convert(v.Error())
-		if err != nil {
-			return nil, err
-		}
-
-		obj, err := r.createAndResolveValue(internal.NewError(r.context))
-		if err != nil {
-			return nil, err
-		}
-
-		if _, err := obj.Set("message", msg); err != nil {
-			return nil, err
-		}
-
-		return obj, nil
+		return r.newGoError(v)
 
 	case string:
 		return r.NewString(v)
@@ -72,13 +64,147 @@ func (r *Realm) Convert(v interface{}) (*Value, error) {
 		}
 
 		return obj, nil
+
+	case []byte:
+		return r.newUint8Array(v)
+
+	case time.Time:
+		return r.NewDate(v)
+
+	case *big.Int:
+		return r.NewBigInt(v)
 	}
 
-	if reflect.TypeOf(v).Kind() == reflect.Func {
-		return r.NewFunction(v)
+	return r.convertReflect(reflect.ValueOf(v))
+}
+
+// convertReflect converts values by their kind.
+//
+// Numbers, strings and booleans are converted to their js equivalent. Slices
+// and maps with string keys are copied into arrays and objects. Structs and
+// pointers to structs are exposed as go objects. Channels and iterator
+// functions, such as func(yield func(T) bool) and func() (T, bool), are exposed
+// as async iterables.
+func (r *Realm) convertReflect(v reflect.Value) (*Value, error) {
+	switch v.Kind() {
+	case reflect.Func:
+		switch {
+		case isSeqFunc(v.Type()):
+			return r.newAsyncIterable(newSeqSource(v))
+
+		case isPullFunc(v.Type()):
+			return r.newAsyncIterable(&pullSource{fn: v})
+		}
+
+		return r.NewFunction(v.Interface())
+
+	case reflect.Chan:
+		if v.Type().ChanDir()&reflect.RecvDir == 0 {
+			break
+		}
+
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+
+		return r.newAsyncIterable(&chanSource{ch: v})
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return r.createAndResolveValue(internal.NewInt64(r.context, v.Int()))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := v.Uint(); n <= math.MaxInt64 {
+			return r.createAndResolveValue(internal.NewInt64(r.context, int64(n)))
+		}
+
+		return r.NewFloat(float64(v.Uint()))
+
+	case reflect.Float32, reflect.Float64:
+		return r.NewFloat(v.Float())
+
+	case reflect.String:
+		return r.NewString(v.String())
+
+	case reflect.Bool:
+		return r.NewBoolean(v.Bool())
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return r.newUint8Array(v.Bytes())
+		}
+
+		fallthrough
+
+	case reflect.Array:
+		arr, err := r.NewArray()
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			elem, err := r.Convert(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := arr.SetIndex(i, elem); err != nil {
+				return nil, err
+			}
+		}
+
+		return arr, nil
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+
+		obj, err := r.NewObject()
+		if err != nil {
+			return nil, err
+		}
+
+		iter := v.MapRange()
+		for iter.Next() {
+			elem, err := r.Convert(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := obj.Set(iter.Key().String(), elem); err != nil {
+				return nil, err
+			}
+		}
+
+		return obj, nil
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+
+		if v.Elem().Kind() == reflect.Struct {
+			return r.newGoObject(v)
+		}
+
+		return r.Convert(v.Elem().Interface())
+
+	case reflect.Struct:
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+
+		return r.newGoObject(ptr)
 	}
 
-	panic(fmt.Sprintf("type conversion not implemented for %T", v))
+	panic(fmt.Sprintf("type conversion not implemented for %s", v.Type()))
 }
 
 func (r *Realm) convertArgs(args []interface{}) ([]*Value, error) {
@@ -0,0 +1,871 @@
+package js
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+
+	// hash functions of crypto.subtle
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// cryptoObject is the opaque value of the crypto global.
+type cryptoObject struct {
+	subtle *Value
+}
+
+// maxRandomValuesLength is the maximum byte length of arrays that are passed
+// to getRandomValues.
+const maxRandomValuesLength = 65536
+
+var integerTypedArrays = map[string]bool{
+	"Int8Array":         true,
+	"Uint8Array":        true,
+	"Uint8ClampedArray": true,
+	"Int16Array":        true,
+	"Uint16Array":       true,
+	"Int32Array":        true,
+	"Uint32Array":       true,
+	"BigInt64Array":     true,
+	"BigUint64Array":    true,
+}
+
+func thisCrypto(this *Value) (*cryptoObject, error) {
+	c, ok := this.Opaque().(*cryptoObject)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return c, nil
+}
+
+func newCryptoSpec(subtle *Class) ClassSpec {
+	return ClassSpec{
+		Name: "Crypto",
+		Methods: map[string]interface{}{
+			"getRandomValues": func(r *Realm, this *Value, array *Value) (*Value, error) {
+				if _, err := thisCrypto(this); err != nil {
+					return nil, err
+				}
+
+				name := ""
+				if array.IsObject() {
+					toStringTag, err := r.wellKnownSymbol("toStringTag")
+					if err != nil {
+						return nil, err
+					}
+
+					tag, err := array.GetAtom(toStringTag)
+					if err != nil {
+						return nil, err
+					}
+
+					if tag.IsString() {
+						name = tag.ToString()
+					}
+				}
+
+				if !integerTypedArrays[name] {
+					return nil, newDOMException("TypeMismatchError", "The data argument must be an integer-type TypedArray")
+				}
+
+				data, _, err := array.bufferSource()
+				if err != nil {
+					return nil, err
+				}
+
+				if len(data) > maxRandomValuesLength {
+					return nil, newDOMException("QuotaExceededError", "The ArrayBufferView's byte length (%d) exceeds the number of bytes of entropy available via this API (%d)", len(data), maxRandomValuesLength)
+				}
+
+				if _, err := rand.Read(data); err != nil {
+					return nil, err
+				}
+
+				return array, nil
+			},
+			"randomUUID": func(r *Realm, this *Value) (string, error) {
+				if _, err := thisCrypto(this); err != nil {
+					return "", err
+				}
+
+				var b [16]byte
+				if _, err := rand.Read(b[:]); err != nil {
+					return "", err
+				}
+
+				// version 4, variant 10
+				b[6] = b[6]&0x0f | 0x40
+				b[8] = b[8]&0x3f | 0x80
+
+				return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+			},
+		},
+		Properties: map[string]ClassProperty{
+			"subtle": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					c, err := thisCrypto(this)
+					if err != nil {
+						return nil, err
+					}
+
+					if c.subtle == nil {
+						value, err := subtle.NewInstance(r, &subtleCrypto{})
+						if err != nil {
+							return nil, err
+						}
+
+						c.subtle = value
+					}
+
+					return c.subtle, nil
+				},
+			},
+		},
+	}
+}
+
+var cryptoHashes = map[string]crypto.Hash{
+	"SHA-1":   crypto.SHA1,
+	"SHA-256": crypto.SHA256,
+	"SHA-384": crypto.SHA384,
+	"SHA-512": crypto.SHA512,
+}
+
+var cryptoAlgorithmNames = []string{"SHA-1", "SHA-256", "SHA-384", "SHA-512", "HMAC", "ECDSA"}
+
+// normalizeAlgorithm returns the name of an algorithm identifier, which is
+// either a name or an object with a name, in its canonical case along with
+// the object, if any.
+func normalizeAlgorithm(r *Realm, algorithm *Value) (string, *Value, error) {
+	params := algorithm
+	if !algorithm.IsObject() {
+		params = nil
+	} else {
+		var err error
+		if algorithm, err = algorithm.Get("name"); err != nil {
+			return "", nil, err
+		}
+
+		if algorithm.Tag() == TagUndefined {
+			return "", nil, NewTypeError("Algorithm: name: Missing or not a string")
+		}
+	}
+
+	name, err := r.toString(algorithm)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, known := range cryptoAlgorithmNames {
+		if strings.EqualFold(name, known) {
+			return known, params, nil
+		}
+	}
+
+	return "", nil, newDOMException("NotSupportedError", "Unrecognized algorithm name")
+}
+
+// hashParam returns the hash function of the hash member of an algorithm.
+func hashParam(r *Realm, params *Value) (string, crypto.Hash, error) {
+	if params == nil {
+		return "", 0, NewTypeError("Algorithm: hash: Missing")
+	}
+
+	hash, err := params.Get("hash")
+	if err != nil {
+		return "", 0, err
+	}
+
+	if hash.Tag() == TagUndefined {
+		return "", 0, NewTypeError("Algorithm: hash: Missing")
+	}
+
+	name, _, err := normalizeAlgorithm(r, hash)
+	if err != nil {
+		return "", 0, err
+	}
+
+	h, ok := cryptoHashes[name]
+	if !ok {
+		return "", 0, newDOMException("NotSupportedError", "Unrecognized hash algorithm %s", name)
+	}
+
+	return name, h, nil
+}
+
+// cryptoData returns a copy of a BufferSource argument.
+func cryptoData(v *Value) ([]byte, error) {
+	data, ok, err := v.bufferSource()
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, NewTypeError("Data must be an ArrayBuffer or ArrayBufferView")
+	}
+
+	return bytes.Clone(data), nil
+}
+
+// cryptoKey is the opaque value of CryptoKey instances.
+type cryptoKey struct {
+	typ         string
+	extractable bool
+	usages      []string
+
+	// algorithm is the name of the algorithm and hash is the name of the
+	// hash of HMAC keys
+	algorithm string
+	hash      string
+
+	secret  []byte
+	public  *ecdsa.PublicKey
+	private *ecdsa.PrivateKey
+
+	algorithmValue *Value
+	usagesValue    *Value
+}
+
+func thisCryptoKey(this *Value) (*cryptoKey, error) {
+	k, ok := this.Opaque().(*cryptoKey)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return k, nil
+}
+
+func (k *cryptoKey) hasUsage(usage string) bool {
+	for _, u := range k.usages {
+		if u == usage {
+			return true
+		}
+	}
+
+	return false
+}
+
+var cryptoKeySpec = ClassSpec{
+	Name: "CryptoKey",
+	Properties: map[string]ClassProperty{
+		"type": {
+			Get: func(r *Realm, this *Value) (string, error) {
+				k, err := thisCryptoKey(this)
+				if err != nil {
+					return "", err
+				}
+
+				return k.typ, nil
+			},
+		},
+		"extractable": {
+			Get: func(r *Realm, this *Value) (bool, error) {
+				k, err := thisCryptoKey(this)
+				if err != nil {
+					return false, err
+				}
+
+				return k.extractable, nil
+			},
+		},
+		"algorithm": {
+			Get: func(r *Realm, this *Value) (*Value, error) {
+				k, err := thisCryptoKey(this)
+				if err != nil {
+					return nil, err
+				}
+
+				if k.algorithmValue == nil {
+					algorithm := map[string]interface{}{
+						"name": k.algorithm,
+					}
+
+					switch k.algorithm {
+					case "HMAC":
+						algorithm["hash"] = map[string]interface{}{"name": k.hash}
+						algorithm["length"] = len(k.secret) * 8
+					case "ECDSA":
+						algorithm["namedCurve"] = "P-256"
+					}
+
+					value, err := r.Convert(algorithm)
+					if err != nil {
+						return nil, err
+					}
+
+					k.algorithmValue = value
+				}
+
+				return k.algorithmValue, nil
+			},
+		},
+		"usages": {
+			Get: func(r *Realm, this *Value) (*Value, error) {
+				k, err := thisCryptoKey(this)
+				if err != nil {
+					return nil, err
+				}
+
+				if k.usagesValue == nil {
+					// keys without usages have an empty array, not null
+					value, err := r.Convert(append([]string{}, k.usages...))
+					if err != nil {
+						return nil, err
+					}
+
+					k.usagesValue = value
+				}
+
+				return k.usagesValue, nil
+			},
+		},
+	},
+}
+
+// subtleCrypto is the opaque value of crypto.subtle.
+type subtleCrypto struct{}
+
+func thisSubtleCrypto(this *Value) error {
+	if _, ok := this.Opaque().(*subtleCrypto); !ok {
+		return NewTypeError("Illegal invocation")
+	}
+
+	return nil
+}
+
+// cryptoPromise runs f on another goroutine and returns a promise of its
+// result. Errors that occur before f is called, such as those of invalid
+// arguments, reject the promise too.
+func cryptoPromise(r *Realm, this *Value, prepare func() (func() (interface{}, error), error)) (*Value, error) {
+	promise, resolve, reject := r.NewPromise()
+	if promise == nil {
+		return nil, resolve(nil)
+	}
+
+	if err := thisSubtleCrypto(this); err != nil {
+		return promise, reject(r.errorValue(err))
+	}
+
+	f, err := prepare()
+	if err != nil {
+		return promise, reject(r.errorValue(err))
+	}
+
+	r.runtime.goAsync(func(context.Context) func() error {
+		result, err := f()
+
+		return func() error {
+			if err != nil {
+				return reject(r.errorValue(err))
+			}
+
+			if data, ok := result.([]byte); ok {
+				buffer, err := r.NewArrayBuffer(data)
+				if err != nil {
+					return reject(r.errorValue(err))
+				}
+
+				return resolve(buffer)
+			}
+
+			return resolve(result)
+		}
+	})
+
+	return promise, nil
+}
+
+// base64urlMember returns a base64url encoded member of a jwk.
+func base64urlMember(jwk *Value, name string) ([]byte, bool, error) {
+	value, err := jwk.Get(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if value.Tag() == TagUndefined {
+		return nil, false, nil
+	}
+
+	if !value.IsString() {
+		return nil, false, newDOMException("DataError", "The JWK member %q must be a string", name)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value.ToString())
+	if err != nil {
+		return nil, false, newDOMException("DataError", "The JWK member %q could not be base64url decoded", name)
+	}
+
+	return data, true, nil
+}
+
+func stringMember(r *Realm, obj *Value, name string) (string, error) {
+	value, err := obj.Get(name)
+	if err != nil {
+		return "", err
+	}
+
+	if value.Tag() == TagUndefined {
+		return "", nil
+	}
+
+	return r.toString(value)
+}
+
+// importKeyData converts the keyData argument of importKey, which is a
+// BufferSource for all formats except jwk.
+func importKeyData(format string, keyData *Value) ([]byte, *Value, error) {
+	switch format {
+	case "raw", "spki", "pkcs8":
+		data, err := cryptoData(keyData)
+		return data, nil, err
+
+	case "jwk":
+		if !keyData.IsObject() {
+			return nil, nil, NewTypeError("Key data must be an object for JWK import")
+		}
+
+		return nil, keyData, nil
+	}
+
+	return nil, nil, NewTypeError("Invalid key format %q", format)
+}
+
+func importHMACKey(r *Realm, format string, data []byte, jwk, params *Value) (*cryptoKey, error) {
+	hash, _, err := hashParam(r, params)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "raw":
+	case "jwk":
+		kty, err := stringMember(r, jwk, "kty")
+		if err != nil {
+			return nil, err
+		}
+
+		if kty != "oct" {
+			return nil, newDOMException("DataError", "The JWK \"kty\" member was not \"oct\"")
+		}
+
+		var ok bool
+		if data, ok, err = base64urlMember(jwk, "k"); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, newDOMException("DataError", "The JWK \"k\" member is missing")
+		}
+
+	default:
+		return nil, newDOMException("NotSupportedError", "Unsupported key format %q for HMAC", format)
+	}
+
+	if len(data) == 0 {
+		return nil, newDOMException("DataError", "HMAC key data must not be empty")
+	}
+
+	return &cryptoKey{
+		typ:       "secret",
+		algorithm: "HMAC",
+		hash:      hash,
+		secret:    data,
+	}, nil
+}
+
+// p256PublicKey converts an uncompressed point to a public key.
+func p256PublicKey(point []byte) (*ecdsa.PublicKey, error) {
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, newDOMException("DataError", "Invalid P-256 public key")
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(point[1:33]),
+		Y:     new(big.Int).SetBytes(point[33:]),
+	}, nil
+}
+
+func importECDSAKey(r *Realm, format string, data []byte, jwk, params *Value) (*cryptoKey, error) {
+	if params == nil {
+		return nil, NewTypeError("Algorithm: namedCurve: Missing")
+	}
+
+	curve, err := stringMember(r, params, "namedCurve")
+	if err != nil {
+		return nil, err
+	}
+
+	if curve != "P-256" {
+		return nil, newDOMException("NotSupportedError", "Unsupported named curve %q", curve)
+	}
+
+	k := &cryptoKey{algorithm: "ECDSA"}
+
+	switch format {
+	case "raw":
+		if k.public, err = p256PublicKey(data); err != nil {
+			return nil, err
+		}
+
+	case "spki":
+		key, err := x509.ParsePKIXPublicKey(data)
+		if err != nil {
+			return nil, newDOMException("DataError", "Invalid keyData")
+		}
+
+		public, ok := key.(*ecdsa.PublicKey)
+		if !ok || public.Curve != elliptic.P256() {
+			return nil, newDOMException("DataError", "The key is not a P-256 public key")
+		}
+
+		k.public = public
+
+	case "pkcs8":
+		key, err := x509.ParsePKCS8PrivateKey(data)
+		if err != nil {
+			return nil, newDOMException("DataError", "Invalid keyData")
+		}
+
+		private, ok := key.(*ecdsa.PrivateKey)
+		if !ok || private.Curve != elliptic.P256() {
+			return nil, newDOMException("DataError", "The key is not a P-256 private key")
+		}
+
+		k.private = private
+
+	case "jwk":
+		for name, want := range map[string]string{"kty": "EC", "crv": "P-256"} {
+			value, err := stringMember(r, jwk, name)
+			if err != nil {
+				return nil, err
+			}
+
+			if value != want {
+				return nil, newDOMException("DataError", "The JWK %q member was not %q", name, want)
+			}
+		}
+
+		x, okX, err := base64urlMember(jwk, "x")
+		if err != nil {
+			return nil, err
+		}
+
+		y, okY, err := base64urlMember(jwk, "y")
+		if err != nil {
+			return nil, err
+		}
+
+		if !okX || !okY || len(x) != 32 || len(y) != 32 {
+			return nil, newDOMException("DataError", "Invalid JWK \"x\" or \"y\" member")
+		}
+
+		point := append(append([]byte{4}, x...), y...)
+		if k.public, err = p256PublicKey(point); err != nil {
+			return nil, err
+		}
+
+		d, ok, err := base64urlMember(jwk, "d")
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			private, err := ecdh.P256().NewPrivateKey(d)
+			if err != nil || !bytes.Equal(private.PublicKey().Bytes(), point) {
+				return nil, newDOMException("DataError", "Invalid JWK \"d\" member")
+			}
+
+			k.private = &ecdsa.PrivateKey{
+				PublicKey: *k.public,
+				D:         new(big.Int).SetBytes(d),
+			}
+		}
+
+	default:
+		return nil, newDOMException("NotSupportedError", "Unsupported key format %q for ECDSA", format)
+	}
+
+	if k.private != nil {
+		k.typ = "private"
+		k.public = nil
+	} else {
+		k.typ = "public"
+	}
+
+	return k, nil
+}
+
+var allowedKeyUsages = map[string][]string{
+	"secret":  {"sign", "verify"},
+	"public":  {"verify"},
+	"private": {"sign"},
+}
+
+func keyUsages(r *Realm, k *cryptoKey, usages *Value) error {
+	if err := usages.Iterate(func(v *Value) (bool, error) {
+		usage, err := r.toString(v)
+		if err != nil {
+			return false, err
+		}
+
+		allowed := false
+		for _, u := range allowedKeyUsages[k.typ] {
+			allowed = allowed || u == usage
+		}
+
+		if !allowed {
+			return false, newDOMException("SyntaxError", "Cannot create a key using the specified key usages")
+		}
+
+		if !k.hasUsage(usage) {
+			k.usages = append(k.usages, usage)
+		}
+
+		return true, nil
+	}); err != nil {
+		return err
+	}
+
+	if len(k.usages) == 0 && k.typ != "public" {
+		return newDOMException("SyntaxError", "Usages cannot be empty when creating a key")
+	}
+
+	return nil
+}
+
+// signingKey validates the algorithm and key arguments of sign and verify.
+func signingKey(r *Realm, algorithm, key *Value, usage string) (*cryptoKey, crypto.Hash, error) {
+	name, params, err := normalizeAlgorithm(r, algorithm)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	k, ok := key.Opaque().(*cryptoKey)
+	if !ok {
+		return nil, 0, NewTypeError("The key argument must be a CryptoKey")
+	}
+
+	if k.algorithm != name || !k.hasUsage(usage) {
+		return nil, 0, newDOMException("InvalidAccessError", "The requested operation is not valid for the provided key")
+	}
+
+	if name == "HMAC" {
+		return k, cryptoHashes[k.hash], nil
+	}
+
+	if name != "ECDSA" {
+		return nil, 0, newDOMException("NotSupportedError", "Algorithm %s does not support %s", name, usage)
+	}
+
+	_, hash, err := hashParam(r, params)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return k, hash, nil
+}
+
+func hashData(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func newSubtleCryptoSpec(key *Class) ClassSpec {
+	return ClassSpec{
+		Name: "SubtleCrypto",
+		Methods: map[string]interface{}{
+			"digest": func(r *Realm, this *Value, algorithm, data *Value) (*Value, error) {
+				return cryptoPromise(r, this, func() (func() (interface{}, error), error) {
+					name, _, err := normalizeAlgorithm(r, algorithm)
+					if err != nil {
+						return nil, err
+					}
+
+					hash, ok := cryptoHashes[name]
+					if !ok {
+						return nil, newDOMException("NotSupportedError", "Algorithm %s does not support digest", name)
+					}
+
+					b, err := cryptoData(data)
+					if err != nil {
+						return nil, err
+					}
+
+					return func() (interface{}, error) {
+						return hashData(hash, b), nil
+					}, nil
+				})
+			},
+			"importKey": func(r *Realm, this *Value, format, keyData, algorithm, extractable, usages *Value) (*Value, error) {
+				return cryptoPromise(r, this, func() (func() (interface{}, error), error) {
+					formatString, err := r.toString(format)
+					if err != nil {
+						return nil, err
+					}
+
+					data, jwk, err := importKeyData(formatString, keyData)
+					if err != nil {
+						return nil, err
+					}
+
+					name, params, err := normalizeAlgorithm(r, algorithm)
+					if err != nil {
+						return nil, err
+					}
+
+					var k *cryptoKey
+					switch name {
+					case "HMAC":
+						k, err = importHMACKey(r, formatString, data, jwk, params)
+					case "ECDSA":
+						k, err = importECDSAKey(r, formatString, data, jwk, params)
+					default:
+						err = newDOMException("NotSupportedError", "Algorithm %s does not support importKey", name)
+					}
+
+					if err != nil {
+						return nil, err
+					}
+
+					if k.extractable, err = extractable.IsTruthy(); err != nil {
+						return nil, err
+					}
+
+					if err := keyUsages(r, k, usages); err != nil {
+						return nil, err
+					}
+
+					value, err := key.NewInstance(r, k)
+					if err != nil {
+						return nil, err
+					}
+
+					return func() (interface{}, error) {
+						return value, nil
+					}, nil
+				})
+			},
+			"sign": func(r *Realm, this *Value, algorithm, key, data *Value) (*Value, error) {
+				return cryptoPromise(r, this, func() (func() (interface{}, error), error) {
+					k, hash, err := signingKey(r, algorithm, key, "sign")
+					if err != nil {
+						return nil, err
+					}
+
+					b, err := cryptoData(data)
+					if err != nil {
+						return nil, err
+					}
+
+					return func() (interface{}, error) {
+						if k.algorithm == "HMAC" {
+							mac := hmac.New(hash.New, k.secret)
+							mac.Write(b)
+							return mac.Sum(nil), nil
+						}
+
+						sigR, sigS, err := ecdsa.Sign(rand.Reader, k.private, hashData(hash, b))
+						if err != nil {
+							return nil, newDOMException("OperationError", "%s", err)
+						}
+
+						// signatures are the concatenation of r and s
+						signature := make([]byte, 64)
+						sigR.FillBytes(signature[:32])
+						sigS.FillBytes(signature[32:])
+
+						return signature, nil
+					}, nil
+				})
+			},
+			"verify": func(r *Realm, this *Value, algorithm, key, signature, data *Value) (*Value, error) {
+				return cryptoPromise(r, this, func() (func() (interface{}, error), error) {
+					k, hash, err := signingKey(r, algorithm, key, "verify")
+					if err != nil {
+						return nil, err
+					}
+
+					sig, err := cryptoData(signature)
+					if err != nil {
+						return nil, err
+					}
+
+					b, err := cryptoData(data)
+					if err != nil {
+						return nil, err
+					}
+
+					return func() (interface{}, error) {
+						if k.algorithm == "HMAC" {
+							mac := hmac.New(hash.New, k.secret)
+							mac.Write(b)
+							return hmac.Equal(mac.Sum(nil), sig), nil
+						}
+
+						if len(sig) != 64 {
+							return false, nil
+						}
+
+						sigR := new(big.Int).SetBytes(sig[:32])
+						sigS := new(big.Int).SetBytes(sig[32:])
+
+						return ecdsa.Verify(k.public, hashData(hash, b), sigR, sigS), nil
+					}, nil
+				})
+			},
+		},
+	}
+}
+
+// defineCrypto defines crypto, Crypto, SubtleCrypto and CryptoKey as globals
+// of r.
+func (r *Realm) defineCrypto() error {
+	if err := r.defineDOMException(); err != nil {
+		return err
+	}
+
+	key, err := r.defineGlobalClass(cryptoKeySpec)
+	if err != nil {
+		return err
+	}
+
+	subtle, err := r.defineGlobalClass(newSubtleCryptoSpec(key))
+	if err != nil {
+		return err
+	}
+
+	cryptoClass, err := r.defineGlobalClass(newCryptoSpec(subtle))
+	if err != nil {
+		return err
+	}
+
+	if err := r.defineClassToStringTags(key, subtle, cryptoClass); err != nil {
+		return err
+	}
+
+	instance, err := cryptoClass.NewInstance(r, &cryptoObject{})
+	if err != nil {
+		return err
+	}
+
+	global, err := r.GlobalObject()
+	if err != nil {
+		return err
+	}
+
+	_, err = global.DefineProperty("crypto", DefinePropertyValue(instance), DefinePropertyWritable(true), DefinePropertyConfigurable(true))
+	return err
+}
@@ -0,0 +1,263 @@
+package js
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"math/big"
+	"regexp"
+	"testing"
+)
+
+func newTestCryptoRealm(t *testing.T) *Realm {
+	t.Helper()
+
+	r := newTestRealm(t, AddIntrinsicTextEncoding, AddIntrinsicCrypto)
+	mustEval(t, r, `
+		globalThis.hex = (buffer) => Array.from(new Uint8Array(buffer), (b) => b.toString(16).padStart(2, "0")).join("");
+		globalThis.bytes = (s) => new TextEncoder().encode(s);
+		globalThis.errorName = (f) => { try { f(); return "no error" } catch (e) { return e.name } };
+	`)
+
+	return r
+}
+
+func TestCryptoGetRandomValues(t *testing.T) {
+	r := newTestCryptoRealm(t)
+
+	for _, typ := range []string{"Int8Array", "Uint8Array", "Uint8ClampedArray", "Int16Array", "Uint16Array", "Int32Array", "Uint32Array", "BigInt64Array", "BigUint64Array"} {
+		expectString(t, r, `{ const a = new `+typ+`(16); crypto.getRandomValues(a) === a }`, "true")
+	}
+
+	// 32 random bytes are all zero with negligible probability
+	expectString(t, r, `new Uint8Array(32).some((b) => b !== 0) || crypto.getRandomValues(new Uint8Array(32)).some((b) => b !== 0)`, "true")
+	expectString(t, r, `crypto.getRandomValues(new Uint8Array(65536)).length`, "65536")
+
+	for script, want := range map[string]string{
+		`crypto.getRandomValues(new Uint8Array(65537))`:                  "QuotaExceededError",
+		`crypto.getRandomValues(new Uint32Array(16385))`:                 "QuotaExceededError",
+		`crypto.getRandomValues(new Float32Array(4))`:                    "TypeMismatchError",
+		`crypto.getRandomValues(new ArrayBuffer(4))`:                     "TypeMismatchError",
+		`crypto.getRandomValues([1, 2, 3])`:                              "TypeMismatchError",
+		`Reflect.apply(crypto.getRandomValues, {}, [new Uint8Array(4)])`: "TypeError",
+	} {
+		expectString(t, r, `errorName(() => `+script+`)`, want)
+	}
+}
+
+func TestCryptoRandomUUID(t *testing.T) {
+	r := newTestCryptoRealm(t)
+
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	seen := map[string]bool{}
+	for i := 0; i < 16; i++ {
+		uuid := mustEval(t, r, `crypto.randomUUID()`).String()
+		if !pattern.MatchString(uuid) {
+			t.Errorf("randomUUID() = %q, want a version 4 uuid", uuid)
+		}
+
+		if seen[uuid] {
+			t.Errorf("randomUUID() returned %q twice", uuid)
+		}
+
+		seen[uuid] = true
+	}
+
+	expectString(t, r, `errorName(() => Reflect.apply(crypto.randomUUID, {}, []))`, "TypeError")
+}
+
+func TestCryptoClasses(t *testing.T) {
+	r := newTestCryptoRealm(t)
+
+	expectString(t, r, `crypto instanceof Crypto && crypto.subtle instanceof SubtleCrypto`, "true")
+	expectString(t, r, `crypto.subtle === crypto.subtle`, "true")
+	expectString(t, r, `[crypto, crypto.subtle].map(String).join()`, "[object Crypto],[object SubtleCrypto]")
+	expectString(t, r, `errorName(() => new Crypto())`, "TypeError")
+	expectString(t, r, `errorName(() => Reflect.apply(Object.getOwnPropertyDescriptor(Crypto.prototype, "subtle").get, {}, []))`, "TypeError")
+
+	expectString(t, r, `try { crypto.getRandomValues(new Uint8Array(65537)) } catch (e) { e instanceof DOMException && e.code === DOMException.QUOTA_EXCEEDED_ERR }`, "true")
+}
+
+func TestCryptoDigest(t *testing.T) {
+	r := newTestCryptoRealm(t)
+
+	data := []byte("The quick brown fox jumps over the lazy dog")
+
+	for name, h := range map[string]hash.Hash{
+		"SHA-1":   sha1.New(),
+		"SHA-256": sha256.New(),
+		"SHA-384": sha512.New384(),
+		"SHA-512": sha512.New(),
+	} {
+		h.Write(data)
+		want := hex.EncodeToString(h.Sum(nil))
+
+		expectAwait(t, r, `crypto.subtle.digest("`+name+`", bytes(`+quoteJS(string(data))+`)).then(hex)`, want)
+		expectAwait(t, r, `crypto.subtle.digest({name: "`+name+`"}, bytes(`+quoteJS(string(data))+`).buffer).then(hex)`, want)
+	}
+
+	// algorithm names are case insensitive and views are hashed by their
+	// bytes only
+	sum := sha256.Sum256([]byte("bc"))
+	expectAwait(t, r, `crypto.subtle.digest("sha-256", new Uint8Array(bytes("abcd").buffer, 1, 2)).then(hex)`, hex.EncodeToString(sum[:]))
+
+	for script, want := range map[string]string{
+		`crypto.subtle.digest("MD5", bytes("a"))`:                          "NotSupportedError",
+		`crypto.subtle.digest("HMAC", bytes("a"))`:                         "NotSupportedError",
+		`crypto.subtle.digest({}, bytes("a"))`:                             "TypeError",
+		`crypto.subtle.digest("SHA-256", "a")`:                             "TypeError",
+		`Reflect.apply(crypto.subtle.digest, {}, ["SHA-256", bytes("a")])`: "TypeError",
+	} {
+		expectAwait(t, r, script+`.then(() => "resolved", (e) => e.name)`, want)
+	}
+}
+
+func TestCryptoHMAC(t *testing.T) {
+	r := newTestCryptoRealm(t)
+
+	// test case 2 of RFC 4231
+	mac := hmac.New(sha256.New, []byte("Jefe"))
+	mac.Write([]byte("what do ya want for nothing?"))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	mustAwait(t, r, `crypto.subtle.importKey("raw", bytes("Jefe"), {name: "HMAC", hash: "SHA-256"}, false, ["sign", "verify"]).then((key) => globalThis.key = key)`)
+
+	expectString(t, r, `key instanceof CryptoKey`, "true")
+	expectString(t, r, `JSON.stringify([key.type, key.extractable, key.algorithm.name, key.algorithm.hash, key.algorithm.length, key.usages])`, `["secret",false,"HMAC",{"name":"SHA-256"},32,["sign","verify"]]`)
+	expectString(t, r, `key.algorithm === key.algorithm && key.usages === key.usages`, "true")
+
+	expectAwait(t, r, `crypto.subtle.sign("HMAC", key, bytes("what do ya want for nothing?")).then(hex)`, want)
+	expectAwait(t, r, `crypto.subtle.sign("HMAC", key, bytes("what do ya want for nothing?")).then((sig) => crypto.subtle.verify("HMAC", key, sig, bytes("what do ya want for nothing?")))`, "true")
+	expectAwait(t, r, `crypto.subtle.sign("HMAC", key, bytes("what do ya want for nothing?")).then((sig) => crypto.subtle.verify("HMAC", key, sig, bytes("something else")))`, "false")
+
+	// the same key as a jwk
+	expectAwait(t, r, `crypto.subtle.importKey("jwk", {kty: "oct", k: "`+base64.RawURLEncoding.EncodeToString([]byte("Jefe"))+`"}, {name: "HMAC", hash: {name: "SHA-256"}}, true, ["sign"])
+		.then((key) => crypto.subtle.sign({name: "HMAC"}, key, bytes("what do ya want for nothing?")))
+		.then(hex)`, want)
+
+	for script, want := range map[string]string{
+		`crypto.subtle.importKey("raw", bytes("k"), "HMAC", false, ["sign"])`:                                                                                       "TypeError",
+		`crypto.subtle.importKey("raw", bytes("k"), {name: "HMAC", hash: "MD5"}, false, ["sign"])`:                                                                  "NotSupportedError",
+		`crypto.subtle.importKey("raw", bytes(""), {name: "HMAC", hash: "SHA-256"}, false, ["sign"])`:                                                               "DataError",
+		`crypto.subtle.importKey("raw", bytes("k"), {name: "HMAC", hash: "SHA-256"}, false, ["encrypt"])`:                                                           "SyntaxError",
+		`crypto.subtle.importKey("raw", bytes("k"), {name: "HMAC", hash: "SHA-256"}, false, [])`:                                                                    "SyntaxError",
+		`crypto.subtle.importKey("spki", bytes("k"), {name: "HMAC", hash: "SHA-256"}, false, ["sign"])`:                                                             "NotSupportedError",
+		`crypto.subtle.importKey("pem", bytes("k"), {name: "HMAC", hash: "SHA-256"}, false, ["sign"])`:                                                              "TypeError",
+		`crypto.subtle.importKey("jwk", {kty: "EC", k: "aw"}, {name: "HMAC", hash: "SHA-256"}, false, ["sign"])`:                                                    "DataError",
+		`crypto.subtle.importKey("jwk", {kty: "oct", k: "!"}, {name: "HMAC", hash: "SHA-256"}, false, ["sign"])`:                                                    "DataError",
+		`crypto.subtle.importKey("jwk", {kty: "oct"}, {name: "HMAC", hash: "SHA-256"}, false, ["sign"])`:                                                            "DataError",
+		`crypto.subtle.importKey("jwk", "k", {name: "HMAC", hash: "SHA-256"}, false, ["sign"])`:                                                                     "TypeError",
+		`crypto.subtle.importKey("raw", bytes("k"), "AES-GCM", false, ["sign"])`:                                                                                    "NotSupportedError",
+		`crypto.subtle.importKey("raw", bytes("k"), "SHA-256", false, ["sign"])`:                                                                                    "NotSupportedError",
+		`crypto.subtle.sign("HMAC", {}, bytes("a"))`:                                                                                                                "TypeError",
+		`crypto.subtle.sign("ECDSA", key, bytes("a"))`:                                                                                                              "InvalidAccessError",
+		`crypto.subtle.sign("HMAC", key, 1)`:                                                                                                                        "TypeError",
+		`crypto.subtle.importKey("raw", bytes("k"), {name: "HMAC", hash: "SHA-256"}, false, ["verify"]).then((key) => crypto.subtle.sign("HMAC", key, bytes("a")))`: "InvalidAccessError",
+	} {
+		expectAwait(t, r, script+`.then(() => "resolved", (e) => e.name)`, want)
+	}
+}
+
+func TestCryptoECDSA(t *testing.T) {
+	r := newTestCryptoRealm(t)
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	x, y, d := private.X.FillBytes(make([]byte, 32)), private.Y.FillBytes(make([]byte, 32)), private.D.FillBytes(make([]byte, 32))
+	raw := elliptic.Marshal(elliptic.P256(), private.X, private.Y)
+
+	spki, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustEval(t, r, `
+		globalThis.unhex = (s) => new Uint8Array(s.match(/../g).map((b) => parseInt(b, 16)));
+		globalThis.algorithm = {name: "ECDSA", namedCurve: "P-256"};
+		globalThis.signing = {name: "ECDSA", hash: "SHA-256"};
+		globalThis.jwk = {kty: "EC", crv: "P-256", x: "`+b64(x)+`", y: "`+b64(y)+`"};
+		globalThis.raw = unhex("`+hex.EncodeToString(raw)+`");
+		globalThis.spki = unhex("`+hex.EncodeToString(spki)+`");
+		globalThis.pkcs8 = unhex("`+hex.EncodeToString(pkcs8)+`");
+	`)
+
+	mustAwait(t, r, `Promise.all([
+		crypto.subtle.importKey("jwk", {...jwk, d: "`+b64(d)+`"}, algorithm, false, ["sign"]),
+		crypto.subtle.importKey("pkcs8", pkcs8, algorithm, false, ["sign"]),
+		crypto.subtle.importKey("jwk", jwk, algorithm, true, ["verify"]),
+		crypto.subtle.importKey("raw", raw, algorithm, true, ["verify"]),
+		crypto.subtle.importKey("spki", spki, algorithm, true, []),
+	]).then((keys) => globalThis.keys = keys)`)
+
+	expectString(t, r, `JSON.stringify(keys.map((key) => [key.type, key.extractable, key.algorithm.name, key.algorithm.namedCurve, key.usages]))`,
+		`[["private",false,"ECDSA","P-256",["sign"]],`+
+			`["private",false,"ECDSA","P-256",["sign"]],`+
+			`["public",true,"ECDSA","P-256",["verify"]],`+
+			`["public",true,"ECDSA","P-256",["verify"]],`+
+			`["public",true,"ECDSA","P-256",[]]]`)
+
+	// signatures of js are verified by go
+	signature := mustAwait(t, r, `crypto.subtle.sign(signing, keys[1], bytes("message")).then(hex)`).String()
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != 64 {
+		t.Fatalf("sign() = %q, want 64 bytes", signature)
+	}
+
+	digest := sha256.Sum256([]byte("message"))
+	if !ecdsa.Verify(&private.PublicKey, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Error("the signature was not verified by go")
+	}
+
+	// and signatures of go are verified by js
+	sigR, sigS, err := ecdsa.Sign(rand.Reader, private, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustEval(t, r, `globalThis.signature = unhex("`+hex.EncodeToString(append(sigR.FillBytes(make([]byte, 32)), sigS.FillBytes(make([]byte, 32))...))+`")`)
+	expectAwait(t, r, `Promise.all([keys[2], keys[3]].map((key) => crypto.subtle.verify(signing, key, signature, bytes("message")))).then(String)`, "true,true")
+	expectAwait(t, r, `crypto.subtle.verify(signing, keys[2], signature, bytes("other"))`, "false")
+	expectAwait(t, r, `crypto.subtle.verify(signing, keys[2], signature.slice(1), bytes("message"))`, "false")
+	expectAwait(t, r, `crypto.subtle.verify({name: "ECDSA", hash: "SHA-384"}, keys[2], signature, bytes("message"))`, "false")
+
+	for script, want := range map[string]string{
+		`crypto.subtle.importKey("raw", raw, {name: "ECDSA"}, false, ["verify"])`:                      "NotSupportedError",
+		`crypto.subtle.importKey("raw", raw, {name: "ECDSA", namedCurve: "P-384"}, false, ["verify"])`: "NotSupportedError",
+		`crypto.subtle.importKey("raw", raw, "ECDSA", false, ["verify"])`:                              "TypeError",
+		`crypto.subtle.importKey("raw", raw.slice(1), algorithm, false, ["verify"])`:                   "DataError",
+		`crypto.subtle.importKey("raw", raw, algorithm, false, ["sign"])`:                              "SyntaxError",
+		`crypto.subtle.importKey("spki", raw, algorithm, false, ["verify"])`:                           "DataError",
+		`crypto.subtle.importKey("pkcs8", spki, algorithm, false, ["sign"])`:                           "DataError",
+		`crypto.subtle.importKey("pkcs8", pkcs8, algorithm, false, [])`:                                "SyntaxError",
+		`crypto.subtle.importKey("jwk", {...jwk, crv: "P-384"}, algorithm, false, ["verify"])`:         "DataError",
+		`crypto.subtle.importKey("jwk", {...jwk, kty: "oct"}, algorithm, false, ["verify"])`:           "DataError",
+		`crypto.subtle.importKey("jwk", {...jwk, x: "AA"}, algorithm, false, ["verify"])`:              "DataError",
+		`crypto.subtle.importKey("jwk", {...jwk, d: jwk.x}, algorithm, false, ["sign"])`:               "DataError",
+		`crypto.subtle.sign(signing, keys[2], bytes("message"))`:                                       "InvalidAccessError",
+		`crypto.subtle.sign("ECDSA", keys[0], bytes("message"))`:                                       "TypeError",
+		`crypto.subtle.sign({name: "ECDSA", hash: "MD5"}, keys[0], bytes("message"))`:                  "NotSupportedError",
+		`crypto.subtle.verify(signing, keys[4], signature, bytes("message"))`:                          "InvalidAccessError",
+		`crypto.subtle.verify(signing, keys[2], "signature", bytes("message"))`:                        "TypeError",
+	} {
+		expectAwait(t, r, script+`.then(() => "resolved", (e) => e.name)`, want)
+	}
+}
@@ -0,0 +1,145 @@
+package js
+
+import (
+	"errors"
+	"fmt"
+)
+
+// domException is a go error that is thrown as a DOMException, such as an
+// AbortError or a NotSupportedError.
+//
+// It is thrown as an instance of the DOMException class of the realm if the
+// realm has one, which is added by the intrinsics that throw DOMExceptions,
+// and as an error with the name of the DOMException otherwise.
+type domException struct {
+	name    string
+	message string
+}
+
+func newDOMException(name, format string, v ...interface{}) error {
+	return &domException{
+		name:    name,
+		message: fmt.Sprintf(format, v...),
+	}
+}
+
+func (e *domException) Error() string {
+	return e.message
+}
+
+func (e *domException) JSErrorName() string {
+	return e.name
+}
+
+// domExceptionConstructor is the key of the DOMException constructor in the
+// prototypes of a realm.
+type domExceptionConstructor struct{}
+
+// domExceptionScript defines DOMException as a subclass of Error, so that its
+// instances have a stack and are errors to Value.IsError. The table holds
+// the legacy constants and the names of the errors that have their codes.
+const domExceptionScript = `(function () {
+	const table = [
+		["INDEX_SIZE_ERR", "IndexSizeError"],
+		["DOMSTRING_SIZE_ERR"],
+		["HIERARCHY_REQUEST_ERR", "HierarchyRequestError"],
+		["WRONG_DOCUMENT_ERR", "WrongDocumentError"],
+		["INVALID_CHARACTER_ERR", "InvalidCharacterError"],
+		["NO_DATA_ALLOWED_ERR"],
+		["NO_MODIFICATION_ALLOWED_ERR", "NoModificationAllowedError"],
+		["NOT_FOUND_ERR", "NotFoundError"],
+		["NOT_SUPPORTED_ERR", "NotSupportedError"],
+		["INUSE_ATTRIBUTE_ERR", "InUseAttributeError"],
+		["INVALID_STATE_ERR", "InvalidStateError"],
+		["SYNTAX_ERR", "SyntaxError"],
+		["INVALID_MODIFICATION_ERR", "InvalidModificationError"],
+		["NAMESPACE_ERR", "NamespaceError"],
+		["INVALID_ACCESS_ERR", "InvalidAccessError"],
+		["VALIDATION_ERR"],
+		["TYPE_MISMATCH_ERR", "TypeMismatchError"],
+		["SECURITY_ERR", "SecurityError"],
+		["NETWORK_ERR", "NetworkError"],
+		["ABORT_ERR", "AbortError"],
+		["URL_MISMATCH_ERR", "URLMismatchError"],
+		["QUOTA_EXCEEDED_ERR", "QuotaExceededError"],
+		["TIMEOUT_ERR", "TimeoutError"],
+		["INVALID_NODE_TYPE_ERR", "InvalidNodeTypeError"],
+		["DATA_CLONE_ERR", "DataCloneError"],
+	];
+
+	const codes = new Map();
+	table.forEach(([, name], i) => name && codes.set(name, i + 1));
+
+	class DOMException extends Error {
+		#name;
+		#code;
+
+		constructor(message = "", name = "Error") {
+			super(message);
+			this.#name = String(name);
+			this.#code = codes.get(this.#name) || 0;
+		}
+
+		get name() {
+			return this.#name;
+		}
+
+		get code() {
+			return this.#code;
+		}
+	}
+
+	for (const key of ["name", "code"]) {
+		Object.defineProperty(DOMException.prototype, key, { enumerable: true });
+	}
+
+	table.forEach(([constant], i) => {
+		for (const target of [DOMException, DOMException.prototype]) {
+			Object.defineProperty(target, constant, { value: i + 1, enumerable: true });
+		}
+	});
+
+	return DOMException;
+})()`
+
+// defineDOMException defines DOMException as a global of r, unless it was
+// already defined by another intrinsic.
+func (r *Realm) defineDOMException() error {
+	if _, ok := r.prototype(domExceptionConstructor{}); ok {
+		return nil
+	}
+
+	ctor, err := r.eval(domExceptionScript, "<DOMException>")
+	if err != nil {
+		return err
+	}
+
+	global, err := r.GlobalObject()
+	if err != nil {
+		return err
+	}
+
+	if _, err := global.DefineProperty("DOMException", DefinePropertyValue(ctor), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return err
+	}
+
+	r.setPrototype(domExceptionConstructor{}, ctor)
+
+	return nil
+}
+
+// newDOMExceptionValue creates the DOMException that err is thrown as, or
+// returns nil if err is not a DOMException or r has no DOMException class.
+func (r *Realm) newDOMExceptionValue(err error) (*Value, error) {
+	var e *domException
+	if !errors.As(err, &e) {
+		return nil, nil
+	}
+
+	ctor, ok := r.prototype(domExceptionConstructor{})
+	if !ok {
+		return nil, nil
+	}
+
+	return ctor.Construct(e.message, e.name)
+}
@@ -0,0 +1,85 @@
+package js
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDOMException(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicDOMException)
+
+	mustEval(t, r, `globalThis.e = new DOMException("was aborted", "AbortError")`)
+
+	for script, want := range map[string]string{
+		`e instanceof DOMException && e instanceof Error`: "true",
+		`[e.name, e.message, e.code].join()`:              "AbortError,was aborted,20",
+		`String(e)`:                                       "AbortError: was aborted",
+		`Object.prototype.toString.call(e)`:               "[object Error]",
+		`typeof e.stack`:                                  "string",
+		`Object.keys(e).length`:                           "0",
+		`DOMException.name + DOMException.length`:         "DOMException0",
+
+		// the name is a getter of the prototype, not an own property
+		`e.hasOwnProperty("name") || e.hasOwnProperty("code")`: "false",
+
+		`{ const e = new DOMException(); [e.name, e.message, e.code].join() }`:                              "Error,,0",
+		`{ const e = new DOMException(1, {toString: () => "Custom"}); [e.name, e.message, e.code].join() }`: "Custom,1,0",
+		`new DOMException("", "NotSupportedError").code`:                                                    "9",
+		`new DOMException("", "DataCloneError").code`:                                                       "25",
+		`new DOMException("", "toString").code`:                                                             "0",
+
+		`[DOMException.INDEX_SIZE_ERR, DOMException.VALIDATION_ERR, DOMException.DATA_CLONE_ERR].join()`: "1,16,25",
+		`e.ABORT_ERR === DOMException.ABORT_ERR`:                                                         "true",
+		`DOMException.ABORT_ERR = 1; DOMException.ABORT_ERR`:                                             "20",
+	} {
+		expectString(t, r, script, want)
+	}
+
+	expectString(t, r, `try { Object.getOwnPropertyDescriptor(DOMException.prototype, "name").get.call({}) } catch (e) { e.name }`, "TypeError")
+	expectString(t, r, `try { DOMException() } catch (e) { e.name }`, "TypeError")
+}
+
+func TestDOMExceptionIsOptional(t *testing.T) {
+	r := newTestRealm(t)
+
+	expectString(t, r, `typeof DOMException`, "undefined")
+
+	// DOMExceptions of go are errors with their name in realms without the
+	// class
+	setGlobal(t, r, "abort", func(*Realm, *Value) error {
+		return newDOMException("AbortError", "aborted in %s", "go")
+	})
+
+	expectString(t, r, `try { abort() } catch (e) { [e instanceof Error, e.name, e.message, e.code].join() }`, "true,AbortError,aborted in go,")
+}
+
+func TestDOMExceptionFromGo(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicDOMException, AddIntrinsicWebGlobals)
+
+	setGlobal(t, r, "abort", func(*Realm, *Value) error {
+		return newDOMException("AbortError", "aborted in %s", "go")
+	})
+
+	expectString(t, r, `try { abort() } catch (e) { [e instanceof DOMException, e.name, e.message, e.code].join() }`, "true,AbortError,aborted in go,20")
+
+	// the class of the realm is used even if the global is replaced
+	expectString(t, r, `DOMException = undefined; try { btoa("€") } catch (e) { [e.constructor.name, e.name, e.code].join() }`, "DOMException,InvalidCharacterError,5")
+
+	err := evalError(t, r, `abort()`)
+	if err.Name() != "AbortError" || err.Message() != "aborted in go" {
+		t.Errorf("Name(), Message() = %q, %q", err.Name(), err.Message())
+	}
+
+	var domErr *domException
+	if !errors.As(err, &domErr) || domErr.name != "AbortError" {
+		t.Errorf("expected the go error to be unwrapped, got %v", err)
+	}
+}
+
+func TestDOMExceptionIntrinsics(t *testing.T) {
+	// intrinsics that throw DOMExceptions add the class once
+	r := newTestRealm(t, AddIntrinsicDOMException, AddIntrinsicWebGlobals)
+
+	expectString(t, r, `const first = DOMException; try { atob("a") } catch {} first === DOMException`, "true")
+	expectString(t, newTestRealm(t, AddIntrinsicWebGlobals), `typeof DOMException`, "function")
+}
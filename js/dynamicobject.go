@@ -0,0 +1,82 @@
+package js
+
+import "github.com/ssttevee/go-quickjs/internal"
+
+// DynamicObject provides the properties of an object created with
+// Realm.NewDynamicObject on demand.
+//
+// Property keys are the string form of the keys used by scripts. Symbol keyed
+// properties are not forwarded and behave as if they did not exist.
+type DynamicObject interface {
+	// Get returns the value of the property which is converted with
+	// Realm.Convert, or false if the property does not exist.
+	Get(key string) (interface{}, bool, error)
+
+	// Set sets the value of the property. Returning false rejects the
+	// assignment, which throws a TypeError in strict mode code.
+	Set(key string, value *Value) (bool, error)
+
+	// Has reports whether the property exists.
+	Has(key string) (bool, error)
+
+	// Delete deletes the property. Returning false rejects the deletion.
+	Delete(key string) (bool, error)
+
+	// Keys returns the keys of all properties in enumeration order.
+	Keys() ([]string, error)
+}
+
+type dynamicObject struct {
+	realm  *Realm
+	object DynamicObject
+}
+
+func (o *dynamicObject) get(key string) (*Value, error) {
+	value, ok, err := o.object.Get(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return o.realm.Convert(value)
+}
+
+func (o *dynamicObject) has(key string) (bool, error) {
+	return o.object.Has(key)
+}
+
+func (o *dynamicObject) set(key string, value *Value) (bool, error) {
+	return o.object.Set(key, value)
+}
+
+func (o *dynamicObject) delete(key string) (bool, error) {
+	return o.object.Delete(key)
+}
+
+func (o *dynamicObject) keys() ([]string, error) {
+	return o.object.Keys()
+}
+
+func (o *dynamicObject) flags(string) internal.PropertyFlag {
+	// properties may be deleted unless Delete rejects it
+	return internal.PropertyFlagWritable | internal.PropertyFlagEnumerable | internal.PropertyFlagConfigurable
+}
+
+// NewDynamicObject creates an object whose own properties are provided by
+// object. Properties are looked up each time they are accessed rather than
+// copied up front.
+func (r *Realm) NewDynamicObject(object DynamicObject) (*Value, error) {
+	objectConstructor, err := r.globalProperty("Object")
+	if err != nil {
+		return nil, err
+	}
+
+	proto, err := objectConstructor.Get("prototype")
+	if err != nil {
+		return nil, err
+	}
+
+	return r.newHostObject(proto, &dynamicObject{
+		realm:  r,
+		object: object,
+	})
+}
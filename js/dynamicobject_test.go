@@ -0,0 +1,158 @@
+package js
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testStore is a dynamic object whose keys starting with "ro" are read-only.
+type testStore struct {
+	values map[string]string
+	err    error
+}
+
+func (s *testStore) Get(key string) (interface{}, bool, error) {
+	if s.err != nil {
+		return nil, false, s.err
+	}
+
+	value, ok := s.values[key]
+	return value, ok, nil
+}
+
+func (s *testStore) Set(key string, value *Value) (bool, error) {
+	if strings.HasPrefix(key, "ro") {
+		return false, nil
+	}
+
+	s.values[key] = value.String()
+	return true, nil
+}
+
+func (s *testStore) Has(key string) (bool, error) {
+	_, ok := s.values[key]
+	return ok, nil
+}
+
+func (s *testStore) Delete(key string) (bool, error) {
+	if strings.HasPrefix(key, "ro") {
+		return false, nil
+	}
+
+	delete(s.values, key)
+	return true, nil
+}
+
+func (s *testStore) Keys() ([]string, error) {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func newTestStore(t *testing.T, r *Realm) *testStore {
+	t.Helper()
+
+	store := &testStore{values: map[string]string{"a": "1", "ro": "2"}}
+
+	object, err := r.NewDynamicObject(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "store", object)
+
+	return store
+}
+
+func TestDynamicObject(t *testing.T) {
+	r := newTestRealm(t)
+
+	store := newTestStore(t, r)
+
+	expectString(t, r, `store.a`, "1")
+	expectString(t, r, `store.missing`, "undefined")
+	expectString(t, r, `["a" in store, "missing" in store].join()`, "true,false")
+	expectString(t, r, `Object.keys(store).join()`, "a,ro")
+	expectString(t, r, `JSON.stringify(store)`, `{"a":"1","ro":"2"}`)
+	expectString(t, r, `store.toString()`, "[object Object]")
+	expectString(t, r, `JSON.stringify(Object.getOwnPropertyDescriptor(store, "a"))`, `{"value":"1","writable":true,"enumerable":true,"configurable":true}`)
+
+	mustEval(t, r, `store.b = 2; delete store.a`)
+	if _, ok := store.values["a"]; ok || store.values["b"] != "2" {
+		t.Errorf("writes are not visible in go: %v", store.values)
+	}
+
+	store.values["c"] = "3"
+	expectString(t, r, `store.c`, "3")
+
+	exported, err := mustEval(t, r, `store`).Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exported != store {
+		t.Errorf("Export() = %#v, want the dynamic object", exported)
+	}
+}
+
+func TestDynamicObjectRejections(t *testing.T) {
+	r := newTestRealm(t)
+
+	store := newTestStore(t, r)
+
+	// rejections are silent in sloppy mode
+	expectString(t, r, `store.ro = 3; store.ro`, "2")
+	expectString(t, r, `delete store.ro`, "false")
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`"use strict"; store.ro = 3`, "'ro' is read-only"},
+		{`"use strict"; store.rox = 3`, "cannot add property 'rox'"},
+		{`"use strict"; store[Symbol("s")] = 3`, "cannot add property 's'"},
+		{`"use strict"; delete store.ro`, "could not delete property"},
+		{`Object.defineProperty(store, "a", {get() {}})`, "cannot define property 'a'"},
+		{`Object.defineProperty(store, "rox", {value: 1})`, "cannot add property 'rox'"},
+	} {
+		_, err := r.Eval(test.script)
+		if err == nil || !strings.Contains(err.Error(), "TypeError") || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want a TypeError containing %q", test.script, err, test.want)
+		}
+	}
+
+	if store.values["ro"] != "2" {
+		t.Errorf("read-only value was changed to %q", store.values["ro"])
+	}
+
+	store.err = errors.New("boom")
+	if _, err := r.Eval(`store.a`); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the error of Get to be thrown, got %v", err)
+	}
+}
+
+func TestGoObjectRejectionMessages(t *testing.T) {
+	r := newTestRealm(t)
+
+	setGlobal(t, r, "p", &testPerson{})
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`"use strict"; p.missing = 1`, "cannot add property 'missing'"},
+		{`Object.defineProperty(p, "Name", {get() {}})`, "cannot define property 'Name'"},
+	} {
+		_, err := r.Eval(test.script)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want %q", test.script, err, test.want)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package js
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"runtime/debug"
+	"strconv"
 	"strings"
 
 	"github.com/ssttevee/go-quickjs/internal"
@@ -23,9 +25,36 @@ func (r *Realm) getError() error {
 		return nil
 	}
 
-	debug.PrintStack()
+	err := (*Error)(r.createValue(v))
+	if f := r.runtime.exceptionObserver(); f != nil {
+		f(err, debug.Stack())
+	}
+
+	if r.runtime.shouldRepanic() {
+		var panicErr *PanicError
+		if errors.As(err, &panicErr) {
+			panic(panicErr)
+		}
+	}
+
+	return err
+}
+
+// OnException sets a function that is called with each exception that is
+// retrieved from js, such as an error returned by Eval, along with the go stack
+// at which it was retrieved. A nil function removes the current one.
+func (rt *Runtime) OnException(f func(err *Error, goStack []byte)) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
 
-	return (*Error)(r.createValue(v))
+	rt.onException = f
+}
+
+func (rt *Runtime) exceptionObserver() func(err *Error, goStack []byte) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	return rt.onException
 }
 
 func (e *Error) Error() string {
@@ -37,6 +66,10 @@ func (e *Error) Error() string {
 	switch tag {
 	case TagUndefined, TagNull:
 		return tag.String()
+
+	case TagObject:
+		// thrown objects that are not errors rarely have a useful toString
+		return (*Value)(e).Inspect(InspectOptions{})
 	}
 
 	return Must((*Value)(e).Invoke("toString")).String()
@@ -51,6 +84,234 @@ func (e *Error) Stack() string {
 	return strings.TrimSpace(stack)
 }
 
+// property returns the string value of a property of the error or an empty
+// string if the error is not an object or the property is not set.
+func (e *Error) property(name string) string {
+	if !(*Value)(e).IsObject() {
+		return ""
+	}
+
+	value, err := (*Value)(e).Get(name)
+	if err != nil {
+		return ""
+	}
+
+	switch value.Tag() {
+	case TagUndefined, TagNull:
+		return ""
+	}
+
+	return value.String()
+}
+
+// Name returns the name of the error, such as TypeError.
+func (e *Error) Name() string {
+	return e.property("name")
+}
+
+// Message returns the message of the error.
+func (e *Error) Message() string {
+	return e.property("message")
+}
+
+// Value returns the thrown value.
+func (e *Error) Value() *Value {
+	return (*Value)(e)
+}
+
+// errorCause is the cached cause of an error, which is nil at the end of its
+// chain of causes.
+type errorCause struct {
+	err *Error
+}
+
+// Cause returns the cause of the error or nil if there is none. The cause is
+// looked up once, and a cause that is already in the chain of causes of e is
+// not returned again, so that errors.Is and errors.As do not loop forever.
+func (e *Error) Cause() error {
+	if e.causeCache == nil {
+		e.cacheCauses()
+	}
+
+	if e.causeCache.err == nil {
+		return nil
+	}
+
+	return e.causeCache.err
+}
+
+// cacheCauses caches the causes of e and of the errors in its chain of causes.
+func (e *Error) cacheCauses() {
+	seen := map[uintptr]bool{}
+	for err := e; err.causeCache == nil; err = err.causeCache.err {
+		err.causeCache = &errorCause{}
+		seen[err.value.Ptr()] = true
+
+		cause := err.lookupCause()
+		if cause == nil || (*Value)(cause).IsObject() && seen[cause.value.Ptr()] {
+			return
+		}
+
+		err.causeCache.err = cause
+	}
+}
+
+// lookupCause returns the cause property of the error or nil if it is not set,
+// undefined or null.
+func (e *Error) lookupCause() *Error {
+	if !(*Value)(e).IsObject() {
+		return nil
+	}
+
+	cause, err := (*Value)(e).Get("cause")
+	if err != nil {
+		return nil
+	}
+
+	switch cause.Tag() {
+	case TagUndefined, TagNull:
+		return nil
+	}
+
+	return (*Error)(cause)
+}
+
+// Errors returns the errors of an AggregateError.
+func (e *Error) Errors() []error {
+	if !(*Value)(e).IsObject() {
+		return nil
+	}
+
+	errorsValue, err := (*Value)(e).Get("errors")
+	if err != nil || !errorsValue.IsArray() {
+		return nil
+	}
+
+	var errs []error
+	if err := errorsValue.Iterate(func(v *Value) (bool, error) {
+		errs = append(errs, (*Error)(v))
+		return true, nil
+	}); err != nil {
+		return nil
+	}
+
+	return errs
+}
+
+// Unwrap returns the go error that was thrown as e, such as an error returned
+// by a function created with NewFunction, or otherwise the cause of the error.
+func (e *Error) Unwrap() error {
+	if err := e.goError(); err != nil {
+		return err
+	}
+
+	return e.Cause()
+}
+
+// StackFrame is a call site of a stack trace.
+type StackFrame struct {
+	// Function is the name of the function, which is empty for top level
+	// code.
+	Function string
+
+	// File is the name of the script or module, which is empty for native
+	// functions.
+	File string
+
+	// Line and Column are 1-based positions in File or 0 if unknown. The
+	// version of quickjs that is bound by this package only reports lines.
+	Line   int
+	Column int
+
+	// Native is set for functions that are not implemented in js.
+	Native bool
+}
+
+func (f StackFrame) String() string {
+	var b strings.Builder
+	if f.Function != "" {
+		b.WriteString(f.Function)
+		b.WriteString(" (")
+	}
+
+	switch {
+	case f.Native:
+		b.WriteString("native")
+
+	default:
+		b.WriteString(f.File)
+		if f.Line > 0 {
+			b.WriteString(":" + strconv.Itoa(f.Line))
+		}
+
+		if f.Column > 0 {
+			b.WriteString(":" + strconv.Itoa(f.Column))
+		}
+	}
+
+	if f.Function != "" {
+		b.WriteString(")")
+	}
+
+	return b.String()
+}
+
+// StackFrames parses the stack trace of the error, most recent call first.
+func (e *Error) StackFrames() []StackFrame {
+	return parseStackFrames(e.property("stack"))
+}
+
+// parseStackFrames parses stack traces of the form:
+//
+//	at f (file.js:1)
+//	at <anonymous> (native)
+//	at file.js:2
+func parseStackFrames(stack string) []StackFrame {
+	var frames []StackFrame
+	for _, line := range strings.Split(stack, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "at ") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "at ")
+
+		var frame StackFrame
+		location := line
+		if i := strings.LastIndex(line, " ("); i >= 0 && strings.HasSuffix(line, ")") {
+			frame.Function = line[:i]
+			location = line[i+2 : len(line)-1]
+		}
+
+		if location == "native" {
+			frame.Native = true
+		} else {
+			frame.File, frame.Line = parseStackLocation(location)
+		}
+
+		frames = append(frames, frame)
+	}
+
+	return frames
+}
+
+// parseStackLocation splits a location of the form file:line, where line is
+// optional. Since file names may contain colons, such as those of scripts
+// evaluated by Eval, only the last number is parsed.
+func parseStackLocation(location string) (string, int) {
+	i := strings.LastIndex(location, ":")
+	if i < 0 {
+		return location, 0
+	}
+
+	line, err := strconv.Atoi(location[i+1:])
+	if err != nil {
+		return location, 0
+	}
+
+	return location[:i], line
+}
+
 type SyntaxError string
 
 func NewSyntaxError(format string, v ...interface{}) error {
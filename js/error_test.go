@@ -0,0 +1,237 @@
+package js
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func evalError(t *testing.T, r *Realm, script string) *Error {
+	t.Helper()
+
+	_, err := r.Eval(script)
+
+	var jsErr *Error
+	if !errors.As(err, &jsErr) {
+		t.Fatalf("%s: got error %v, want an *Error", script, err)
+	}
+
+	return jsErr
+}
+
+func TestErrorFields(t *testing.T) {
+	r := newTestRealm(t)
+
+	err := evalError(t, r, `throw Object.assign(new TypeError("bad"), {cause: new RangeError("inner")})`)
+	if err.Name() != "TypeError" || err.Message() != "bad" {
+		t.Errorf("Name(), Message() = %q, %q", err.Name(), err.Message())
+	}
+
+	if err.Error() != "TypeError: bad" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+
+	var cause *Error
+	if !errors.As(err.Cause(), &cause) || cause.Error() != "RangeError: inner" {
+		t.Errorf("Cause() = %v", err.Cause())
+	}
+
+	if err.Unwrap() == nil {
+		t.Error("expected Unwrap to return the cause")
+	}
+
+	err = evalError(t, r, `throw new Error("no cause")`)
+	if err.Cause() != nil {
+		t.Errorf("Cause() = %v, want nil", err.Cause())
+	}
+
+	if err.Errors() != nil {
+		t.Errorf("Errors() = %v, want nil", err.Errors())
+	}
+}
+
+func TestErrorCauseLoops(t *testing.T) {
+	r := newTestRealm(t)
+
+	for _, test := range []struct {
+		script string
+		want   []string
+	}{
+		{`{ const e = new Error("self"); e.cause = e; throw e }`, []string{"Error: self"}},
+		{`{ const a = new Error("a"), b = new Error("b"); a.cause = b; b.cause = a; throw a }`, []string{"Error: a", "Error: b"}},
+		{`{ const a = new Error("a"), b = new Error("b"); a.cause = b; b.cause = b; throw a }`, []string{"Error: a", "Error: b"}},
+	} {
+		err := evalError(t, r, test.script)
+
+		var got []string
+		for err := error(err); err != nil; err = errors.Unwrap(err) {
+			got = append(got, err.Error())
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: chain = %q, want %q", test.script, got, test.want)
+		}
+
+		if errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("%s: unexpected errors.Is match", test.script)
+		}
+
+		if err.Cause() != err.Cause() {
+			t.Errorf("%s: expected the cause to be cached", test.script)
+		}
+	}
+}
+
+func TestErrorNullCause(t *testing.T) {
+	r := newTestRealm(t)
+
+	for _, script := range []string{
+		`throw Object.assign(new Error("x"), {cause: null})`,
+		`throw Object.assign(new Error("x"), {cause: undefined})`,
+	} {
+		if err := evalError(t, r, script); err.Cause() != nil {
+			t.Errorf("%s: Cause() = %v, want nil", script, err.Cause())
+		}
+	}
+
+	// other primitives are causes
+	if err := evalError(t, r, `throw Object.assign(new Error("x"), {cause: 0})`); err.Cause() == nil || err.Cause().Error() != "0" {
+		t.Errorf("Cause() = %v, want 0", err.Cause())
+	}
+}
+
+func TestErrorAggregate(t *testing.T) {
+	r := newTestRealm(t)
+
+	err := evalError(t, r, `throw new AggregateError([new Error("a"), "b"], "all failed")`)
+
+	var got []string
+	for _, err := range err.Errors() {
+		got = append(got, err.Error())
+	}
+
+	if want := []string{"Error: a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Errors() = %q, want %q", got, want)
+	}
+}
+
+func TestErrorNonErrorValues(t *testing.T) {
+	r := newTestRealm(t)
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`throw "str"`, "str"},
+		{`throw 1`, "1"},
+		{`throw undefined`, "undefined"},
+	} {
+		err := evalError(t, r, test.script)
+		if err.Error() != test.want {
+			t.Errorf("%s: Error() = %q, want %q", test.script, err.Error(), test.want)
+		}
+
+		if err.Name() != "" || err.Message() != "" || err.Cause() != nil || err.StackFrames() != nil {
+			t.Errorf("%s: expected no structured fields", test.script)
+		}
+	}
+
+	if err := evalError(t, r, `throw {name: "Custom", message: "object"}`); err.Name() != "Custom" || err.Message() != "object" {
+		t.Errorf("Name(), Message() = %q, %q", err.Name(), err.Message())
+	}
+}
+
+func TestErrorStackFrames(t *testing.T) {
+	r := newTestRealm(t)
+
+	err := evalError(t, r, "function inner() {\n  throw new Error('x')\n}\nfunction outer() { inner() }\nouter()")
+
+	frames := err.StackFrames()
+	if len(frames) < 3 {
+		t.Fatalf("StackFrames() = %+v, want at least 3 frames", frames)
+	}
+
+	if frames[0].Function != "inner" || frames[0].Line != 2 || frames[0].Native {
+		t.Errorf("frame 0 = %+v", frames[0])
+	}
+
+	if frames[1].Function != "outer" || frames[1].Line != 4 || frames[1].File != frames[0].File {
+		t.Errorf("frame 1 = %+v", frames[1])
+	}
+
+	err = evalError(t, r, `[1].map(() => { throw new Error("y") })`)
+	if frames := err.StackFrames(); len(frames) < 2 || !frames[1].Native {
+		t.Errorf("expected a native frame for map, got %+v", frames)
+	}
+}
+
+func TestParseStackFrames(t *testing.T) {
+	got := parseStackFrames("Error: x\n    at f (file.js:1)\n    at <anonymous> (native)\n    at eval:script:3\n    at g (a:b)\nnot a frame")
+	want := []StackFrame{
+		{Function: "f", File: "file.js", Line: 1},
+		{Function: "<anonymous>", Native: true},
+		{File: "eval:script", Line: 3},
+		{Function: "g", File: "a:b"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStackFrames() = %+v, want %+v", got, want)
+	}
+
+	for _, test := range []struct {
+		frame StackFrame
+		want  string
+	}{
+		{want[0], "f (file.js:1)"},
+		{want[1], "<anonymous> (native)"},
+		{want[2], "eval:script:3"},
+		{StackFrame{File: "f.js", Line: 1, Column: 2}, "f.js:1:2"},
+	} {
+		if got := test.frame.String(); got != test.want {
+			t.Errorf("String() = %q, want %q", got, test.want)
+		}
+	}
+
+	if frames := parseStackFrames(""); frames != nil {
+		t.Errorf("parseStackFrames(\"\") = %+v, want nil", frames)
+	}
+}
+
+func TestOnException(t *testing.T) {
+	r := newTestRealm(t)
+
+	var (
+		observed []string
+		stacks   [][]byte
+	)
+
+	r.runtime.OnException(func(err *Error, goStack []byte) {
+		observed = append(observed, err.Error())
+		stacks = append(stacks, goStack)
+	})
+
+	r.Eval(`throw new RangeError("first")`)
+
+	// exceptions that are caught in js are not observed
+	mustEval(t, r, `try { throw new Error("caught") } catch {}`)
+
+	r.Eval(`null.x`)
+
+	if len(observed) != 2 || observed[0] != "RangeError: first" || !strings.HasPrefix(observed[1], "TypeError:") {
+		t.Errorf("observed %q", observed)
+	}
+
+	for _, stack := range stacks {
+		if !strings.Contains(string(stack), "error_test.go") {
+			t.Errorf("expected the go stack of the caller, got %s", stack)
+		}
+	}
+
+	r.runtime.OnException(nil)
+	r.Eval(`throw 1`)
+
+	if len(observed) != 2 {
+		t.Errorf("expected the observer to be removed, observed %q", observed)
+	}
+}
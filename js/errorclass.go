@@ -0,0 +1,190 @@
+package js
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+// JSErrorNamer is implemented by go errors that are thrown as instances of a
+// js error class other than Error.
+//
+// The class is looked up by name in the global object when the error is
+// thrown, so it may be a builtin such as "RangeError", a class defined with
+// Runtime.DefineErrorClass or a class defined in js. Its constructor is not
+// called. If there is no such class, an Error is thrown with its name set
+// instead.
+type JSErrorNamer interface {
+	error
+	JSErrorName() string
+}
+
+// JSErrorPropertiesProvider is implemented by go errors that set additional
+// enumerable properties on thrown errors, such as a code or a status.
+// Properties are converted with Realm.Convert.
+type JSErrorPropertiesProvider interface {
+	error
+	JSErrorProperties() map[string]interface{}
+}
+
+type errorClass struct {
+	name    string
+	extends string
+}
+
+// DefineErrorClass defines a subclass of the global error class named by
+// extends, or Error if extends is empty, that is installed as a global in
+// every realm that is created afterwards. Error classes are installed in the
+// order that they are defined, so they may extend each other.
+func (rt *Runtime) DefineErrorClass(name, extends string) error {
+	if name == "" {
+		return errors.New("class name must not be empty")
+	}
+
+	if extends == "" {
+		extends = "Error"
+	}
+
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	rt.errorClasses = append(rt.errorClasses, errorClass{
+		name:    name,
+		extends: extends,
+	})
+
+	return nil
+}
+
+const errorClassScript = `(function (parent, name) {
+	var C = ({ [name]: class extends parent {} })[name];
+	Object.defineProperty(C.prototype, "name", { value: name, writable: true, configurable: true });
+	return C;
+})`
+
+func (r *Realm) installErrorClasses() error {
+	r.runtime.mutex.Lock()
+	classes := append([]errorClass{}, r.runtime.errorClasses...)
+	r.runtime.mutex.Unlock()
+
+	if len(classes) == 0 {
+		return nil
+	}
+
+	global, err := r.GlobalObject()
+	if err != nil {
+		return err
+	}
+
+	define, err := r.eval(errorClassScript, "<error classes>")
+	if err != nil {
+		return err
+	}
+
+	for _, c := range classes {
+		parent, err := global.Get(c.extends)
+		if err != nil {
+			return err
+		}
+
+		if !parent.IsFunction() {
+			return NewTypeError("cannot extend %s, which is not a constructor", c.extends)
+		}
+
+		ctor, err := define.Call(nil, parent, c.name)
+		if err != nil {
+			return err
+		}
+
+		if _, err := global.DefineProperty(c.name, DefinePropertyValue(ctor), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newGoError creates the js error that err is converted to.
+func (r *Realm) newGoError(err error) (*Value, error) {
+	obj, convErr := r.newNamedError(err)
+	if convErr != nil {
+		return nil, convErr
+	}
+
+	var provider JSErrorPropertiesProvider
+	if !errors.As(err, &provider) {
+		return obj, nil
+	}
+
+	properties := provider.JSErrorProperties()
+	for _, name := range sortedKeys(properties) {
+		if _, convErr := obj.Set(name, properties[name]); convErr != nil {
+			return nil, convErr
+		}
+	}
+
+	return obj, nil
+}
+
+func (r *Realm) newNamedError(err error) (*Value, error) {
+	if obj, convErr := r.newDOMExceptionValue(err); obj != nil || convErr != nil {
+		return obj, convErr
+	}
+
+	var namer JSErrorNamer
+	if errors.As(err, &namer) {
+		name := namer.JSErrorName()
+
+		ctor, convErr := r.globalProperty(name)
+		if convErr != nil {
+			return nil, convErr
+		}
+
+		obj, convErr := r.newError(err.Error())
+		if convErr != nil {
+			return nil, convErr
+		}
+
+		if ctor.IsFunction() {
+			// the constructor is not called so that the stack trace starts
+			// where the error was thrown
+			proto, convErr := ctor.Get("prototype")
+			if convErr != nil {
+				return nil, convErr
+			}
+
+			if proto.IsObject() {
+				defer runtime.KeepAlive(obj)
+				defer runtime.KeepAlive(proto)
+
+				if internal.SetPrototype(r.context, obj.value, proto.value) == -1 {
+					return nil, r.getError()
+				}
+
+				return obj, nil
+			}
+		}
+
+		if _, convErr := obj.DefineProperty("name", DefinePropertyValue(name), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); convErr != nil {
+			return nil, convErr
+		}
+
+		return obj, nil
+	}
+
+	return r.newError(err.Error())
+}
+
+func (r *Realm) newError(message string) (*Value, error) {
+	obj, err := r.createAndResolveValue(internal.NewError(r.context))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := obj.DefineProperty("message", DefinePropertyValue(message), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
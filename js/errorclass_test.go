@@ -0,0 +1,110 @@
+package js
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type namedError struct {
+	name, message string
+}
+
+func (e *namedError) Error() string {
+	return e.message
+}
+
+func (e *namedError) JSErrorName() string {
+	return e.name
+}
+
+type statusError struct {
+	namedError
+	status int
+}
+
+func (e *statusError) JSErrorProperties() map[string]interface{} {
+	return map[string]interface{}{"status": e.status, "retry": false}
+}
+
+func TestNamedErrors(t *testing.T) {
+	rt := newTestRuntime(t)
+
+	if err := rt.DefineErrorClass("HttpError", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rt.DefineErrorClass("NotFoundError", "HttpError"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := rt.NewRealm()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var thrown error
+	fail, err := r.NewFunction(func(r *Realm, this *Value) error {
+		return thrown
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "fail", fail)
+
+	const inspect = `try { fail() } catch (e) { [e.name, e.message, e instanceof Error, e instanceof HttpError, e instanceof NotFoundError, e.status, e.retry].join() }`
+
+	for _, test := range []struct {
+		err  error
+		want string
+	}{
+		{&namedError{"RangeError", "out of range"}, "RangeError,out of range,true,false,false,,"},
+		{&namedError{"HttpError", "http"}, "HttpError,http,true,true,false,,"},
+		{&namedError{"NotFoundError", "missing"}, "NotFoundError,missing,true,true,true,,"},
+		// classes that do not exist only set the name
+		{&namedError{"UnknownError", "unknown"}, "UnknownError,unknown,true,false,false,,"},
+		{&statusError{namedError{"NotFoundError", "missing"}, 404}, "NotFoundError,missing,true,true,true,404,false"},
+		{errors.New("plain"), "Error,plain,true,false,false,,"},
+	} {
+		thrown = test.err
+		expectString(t, r, inspect, test.want)
+
+		// the error still unwraps to the go error
+		if _, err := r.Eval(`fail()`); !errors.Is(err, test.err) {
+			t.Errorf("got error %v, want %v", err, test.err)
+		}
+	}
+
+	// classes defined in js are found too
+	mustEval(t, r, `globalThis.ScriptError = class extends Error {}`)
+	thrown = &namedError{"ScriptError", "script"}
+	expectString(t, r, `try { fail() } catch (e) { e instanceof ScriptError && e.message }`, "script")
+
+	// exceptions while creating the error are thrown instead of it
+	mustEval(t, r, `Object.defineProperty(globalThis, "BrokenError", { get() { throw new TypeError("broken") } })`)
+	thrown = &namedError{"BrokenError", "broken"}
+	expectString(t, r, `try { fail() } catch (e) { String(e) }`, "TypeError: broken")
+
+	// the stack trace starts where the error was thrown
+	expectString(t, r, `function thrower() { fail() }; try { thrower() } catch (e) { e.stack.includes("thrower") }`, "true")
+
+	// defined classes behave like builtin error classes in js
+	expectString(t, r, `const e = new NotFoundError("js", {cause: 1}); [e.name, e.message, String(e)].join()`, "NotFoundError,js,NotFoundError: js")
+}
+
+func TestDefineErrorClassErrors(t *testing.T) {
+	rt := newTestRuntime(t)
+
+	if err := rt.DefineErrorClass("", ""); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+
+	if err := rt.DefineErrorClass("BadError", "Missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rt.NewRealm(); err == nil || !strings.Contains(err.Error(), "cannot extend Missing") {
+		t.Errorf("NewRealm() = %v, want an error for the missing parent", err)
+	}
+}
@@ -0,0 +1,707 @@
+package js
+
+// event phases of Event.prototype.eventPhase
+const (
+	eventPhaseNone      = 0
+	eventPhaseCapturing = 1
+	eventPhaseAtTarget  = 2
+	eventPhaseBubbling  = 3
+)
+
+// event is the opaque value of Event and CustomEvent.
+type event struct {
+	typ        string
+	bubbles    bool
+	cancelable bool
+	composed   bool
+	isTrusted  bool
+	timeStamp  float64
+
+	// detail is the detail of a CustomEvent
+	detail *Value
+
+	target        *Value
+	currentTarget *Value
+	eventPhase    int
+
+	initialized              bool
+	dispatching              bool
+	stopPropagation          bool
+	stopImmediatePropagation bool
+	canceled                 bool
+	inPassiveListener        bool
+}
+
+func newEvent(r *Realm, typ string) *event {
+	return &event{
+		typ:         typ,
+		timeStamp:   r.now(),
+		initialized: true,
+	}
+}
+
+func thisEvent(this *Value) (*event, error) {
+	ev, ok := this.Opaque().(*event)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return ev, nil
+}
+
+// cancel sets the canceled flag of ev, unless it may not be canceled.
+func (ev *event) cancel() {
+	if ev.cancelable && !ev.inPassiveListener {
+		ev.canceled = true
+	}
+}
+
+// init initializes ev as with Event.prototype.initEvent.
+func (ev *event) init(typ string, bubbles, cancelable bool) {
+	ev.initialized = true
+	ev.stopPropagation = false
+	ev.stopImmediatePropagation = false
+	ev.canceled = false
+	ev.isTrusted = false
+	ev.target = nil
+	ev.typ = typ
+	ev.bubbles = bubbles
+	ev.cancelable = cancelable
+}
+
+// newEventFromArgs creates an event from the type and eventInitDict
+// arguments of the constructors of Event and CustomEvent.
+func newEventFromArgs(r *Realm, args []*Value) (*event, *Value, error) {
+	if len(args) == 0 {
+		return nil, nil, NewTypeError("1 argument required, but only 0 present")
+	}
+
+	typ, err := r.toString(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ev := newEvent(r, typ)
+
+	init := optionalArg(args, 1)
+	if init.Tag() == TagUndefined || init.Tag() == TagNull {
+		return ev, init, nil
+	}
+
+	if !init.IsObject() {
+		return nil, nil, NewTypeError("The provided value is not of type 'EventInit'")
+	}
+
+	if ev.bubbles, err = booleanOption(init, "bubbles"); err != nil {
+		return nil, nil, err
+	}
+
+	if ev.cancelable, err = booleanOption(init, "cancelable"); err != nil {
+		return nil, nil, err
+	}
+
+	if ev.composed, err = booleanOption(init, "composed"); err != nil {
+		return nil, nil, err
+	}
+
+	return ev, init, nil
+}
+
+func eventProperty(get func(ev *event) interface{}) ClassProperty {
+	return ClassProperty{
+		Get: func(r *Realm, this *Value) (interface{}, error) {
+			ev, err := thisEvent(this)
+			if err != nil {
+				return nil, err
+			}
+
+			return get(ev), nil
+		},
+	}
+}
+
+// optionalBooleanArg converts the argument at i to a boolean, which is false
+// if it is missing.
+func optionalBooleanArg(args []*Value, i int) (bool, error) {
+	if i < len(args) {
+		return args[i].IsTruthy()
+	}
+
+	return false, nil
+}
+
+// nullable returns NewNull() if v is nil.
+func nullable(v *Value) *Value {
+	if v == nil {
+		return NewNull()
+	}
+
+	return v
+}
+
+var eventSpec = ClassSpec{
+	Name: "Event",
+	Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+		ev, _, err := newEventFromArgs(r, args)
+		return ev, err
+	},
+	Length: 1,
+	Methods: map[string]interface{}{
+		"composedPath": func(r *Realm, this *Value) ([]*Value, error) {
+			ev, err := thisEvent(this)
+			if err != nil {
+				return nil, err
+			}
+
+			if !ev.dispatching {
+				return []*Value{}, nil
+			}
+
+			return []*Value{ev.currentTarget}, nil
+		},
+		"stopPropagation": func(r *Realm, this *Value) error {
+			ev, err := thisEvent(this)
+			if err != nil {
+				return err
+			}
+
+			ev.stopPropagation = true
+
+			return nil
+		},
+		"stopImmediatePropagation": func(r *Realm, this *Value) error {
+			ev, err := thisEvent(this)
+			if err != nil {
+				return err
+			}
+
+			ev.stopPropagation = true
+			ev.stopImmediatePropagation = true
+
+			return nil
+		},
+		"preventDefault": func(r *Realm, this *Value) error {
+			ev, err := thisEvent(this)
+			if err != nil {
+				return err
+			}
+
+			ev.cancel()
+
+			return nil
+		},
+		"initEvent": func(r *Realm, this *Value, args ...*Value) error {
+			ev, err := thisEvent(this)
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 0 {
+				return NewTypeError("1 argument required, but only 0 present")
+			}
+
+			typ, err := r.toString(args[0])
+			if err != nil {
+				return err
+			}
+
+			bubbles, err := optionalBooleanArg(args, 1)
+			if err != nil {
+				return err
+			}
+
+			cancelable, err := optionalBooleanArg(args, 2)
+			if err != nil {
+				return err
+			}
+
+			if !ev.dispatching {
+				ev.init(typ, bubbles, cancelable)
+			}
+
+			return nil
+		},
+	},
+	Properties: map[string]ClassProperty{
+		"type": eventProperty(func(ev *event) interface{} {
+			return ev.typ
+		}),
+		"target": eventProperty(func(ev *event) interface{} {
+			return nullable(ev.target)
+		}),
+		"srcElement": eventProperty(func(ev *event) interface{} {
+			return nullable(ev.target)
+		}),
+		"currentTarget": eventProperty(func(ev *event) interface{} {
+			return nullable(ev.currentTarget)
+		}),
+		"eventPhase": eventProperty(func(ev *event) interface{} {
+			return ev.eventPhase
+		}),
+		"bubbles": eventProperty(func(ev *event) interface{} {
+			return ev.bubbles
+		}),
+		"cancelable": eventProperty(func(ev *event) interface{} {
+			return ev.cancelable
+		}),
+		"defaultPrevented": eventProperty(func(ev *event) interface{} {
+			return ev.canceled
+		}),
+		"composed": eventProperty(func(ev *event) interface{} {
+			return ev.composed
+		}),
+		"isTrusted": eventProperty(func(ev *event) interface{} {
+			return ev.isTrusted
+		}),
+		"timeStamp": eventProperty(func(ev *event) interface{} {
+			return ev.timeStamp
+		}),
+		"cancelBubble": {
+			Get: func(r *Realm, this *Value) (bool, error) {
+				ev, err := thisEvent(this)
+				if err != nil {
+					return false, err
+				}
+
+				return ev.stopPropagation, nil
+			},
+			Set: func(r *Realm, this *Value, value bool) error {
+				ev, err := thisEvent(this)
+				if err != nil {
+					return err
+				}
+
+				if value {
+					ev.stopPropagation = true
+				}
+
+				return nil
+			},
+		},
+		"returnValue": {
+			Get: func(r *Realm, this *Value) (bool, error) {
+				ev, err := thisEvent(this)
+				if err != nil {
+					return false, err
+				}
+
+				return !ev.canceled, nil
+			},
+			Set: func(r *Realm, this *Value, value bool) error {
+				ev, err := thisEvent(this)
+				if err != nil {
+					return err
+				}
+
+				if !value {
+					ev.cancel()
+				}
+
+				return nil
+			},
+		},
+	},
+}
+
+func newCustomEventSpec(eventClass *Class) ClassSpec {
+	return ClassSpec{
+		Name:    "CustomEvent",
+		Extends: eventClass,
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			ev, init, err := newEventFromArgs(r, args)
+			if err != nil {
+				return nil, err
+			}
+
+			if init.IsObject() {
+				if ev.detail, err = init.Get("detail"); err != nil {
+					return nil, err
+				}
+			}
+
+			return ev, nil
+		},
+		Length: 1,
+		Methods: map[string]interface{}{
+			"initCustomEvent": func(r *Realm, this *Value, args ...*Value) error {
+				ev, err := thisEvent(this)
+				if err != nil {
+					return err
+				}
+
+				if len(args) == 0 {
+					return NewTypeError("1 argument required, but only 0 present")
+				}
+
+				typ, err := r.toString(args[0])
+				if err != nil {
+					return err
+				}
+
+				bubbles, err := optionalBooleanArg(args, 1)
+				if err != nil {
+					return err
+				}
+
+				cancelable, err := optionalBooleanArg(args, 2)
+				if err != nil {
+					return err
+				}
+
+				if !ev.dispatching {
+					ev.init(typ, bubbles, cancelable)
+					ev.detail = optionalArg(args, 3)
+				}
+
+				return nil
+			},
+		},
+		Properties: map[string]ClassProperty{
+			"detail": eventProperty(func(ev *event) interface{} {
+				return nullable(ev.detail)
+			}),
+		},
+	}
+}
+
+// eventListener is an event listener of an EventTarget.
+type eventListener struct {
+	typ      string
+	callback *Value
+	capture  bool
+	once     bool
+	passive  bool
+	removed  bool
+
+	// handler is set for the listeners of event handler attributes, such as
+	// onabort, whose callback is called even if it is not a function
+	handler bool
+}
+
+// eventTarget is the opaque value of EventTarget.
+type eventTarget struct {
+	listeners []*eventListener
+
+	// handlers are the listeners of event handler attributes by event type
+	handlers map[string]*eventListener
+}
+
+// eventTargetOpaque is implemented by the opaque values of EventTarget and
+// the classes that extend it.
+type eventTargetOpaque interface {
+	getEventTarget() *eventTarget
+}
+
+func (t *eventTarget) getEventTarget() *eventTarget {
+	return t
+}
+
+func thisEventTarget(this *Value) (*eventTarget, error) {
+	t, ok := this.Opaque().(eventTargetOpaque)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return t.getEventTarget(), nil
+}
+
+func (t *eventTarget) removeListener(l *eventListener) {
+	l.removed = true
+
+	for i, listener := range t.listeners {
+		if listener == l {
+			t.listeners = append(t.listeners[:i:i], t.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *eventTarget) findListener(typ string, callback *Value, capture bool) *eventListener {
+	for _, l := range t.listeners {
+		// objects are equal if they have the same pointer
+		if !l.handler && l.typ == typ && l.capture == capture && l.callback.value == callback.value {
+			return l
+		}
+	}
+
+	return nil
+}
+
+// handler returns the value of the event handler attribute for typ.
+func (t *eventTarget) handler(typ string) *Value {
+	if l, ok := t.handlers[typ]; ok {
+		return l.callback
+	}
+
+	return NewNull()
+}
+
+// setHandler sets the event handler attribute for typ. Objects replace the
+// callback of the listener of the attribute, which keeps its position, and
+// other values remove it.
+func (t *eventTarget) setHandler(typ string, value *Value) {
+	l, ok := t.handlers[typ]
+	if !value.IsObject() {
+		if ok {
+			t.removeListener(l)
+			delete(t.handlers, typ)
+		}
+
+		return
+	}
+
+	if ok {
+		l.callback = value
+		return
+	}
+
+	l = &eventListener{
+		typ:      typ,
+		callback: value,
+		handler:  true,
+	}
+
+	if t.handlers == nil {
+		t.handlers = map[string]*eventListener{}
+	}
+
+	t.handlers[typ] = l
+	t.listeners = append(t.listeners, l)
+}
+
+// dispatch dispatches the event ev of eventValue to target, whose opaque
+// value is t, and returns false if the event was canceled. Exceptions that
+// are thrown by listeners are reported to Runtime.OnException, but otherwise
+// ignored.
+func (t *eventTarget) dispatch(target, eventValue *Value, ev *event) bool {
+	ev.dispatching = true
+	ev.target = target
+	ev.currentTarget = target
+	ev.eventPhase = eventPhaseAtTarget
+
+	// listeners that are added during dispatch are not invoked
+	listeners := append([]*eventListener(nil), t.listeners...)
+
+	// there is no path, so the capturing listeners are just invoked before
+	// the others
+	t.invoke(listeners, eventValue, ev, true)
+	t.invoke(listeners, eventValue, ev, false)
+
+	ev.eventPhase = eventPhaseNone
+	ev.currentTarget = nil
+	ev.dispatching = false
+	ev.stopPropagation = false
+	ev.stopImmediatePropagation = false
+
+	return !ev.canceled
+}
+
+func (t *eventTarget) invoke(listeners []*eventListener, eventValue *Value, ev *event, capture bool) {
+	if ev.stopPropagation {
+		return
+	}
+
+	for _, l := range listeners {
+		if l.removed || l.typ != ev.typ || l.capture != capture {
+			continue
+		}
+
+		if l.once {
+			t.removeListener(l)
+		}
+
+		callback, thisValue := l.callback, ev.currentTarget
+		if !l.handler && !callback.IsFunction() {
+			handleEvent, err := callback.Get("handleEvent")
+			if err != nil {
+				continue
+			}
+
+			callback, thisValue = handleEvent, l.callback
+		}
+
+		ev.inPassiveListener = l.passive
+		result, err := callback.Call(thisValue, eventValue)
+		ev.inPassiveListener = false
+
+		if err == nil && l.handler && result.Tag() == TagBool && !result.ToBool() {
+			ev.cancel()
+		}
+
+		if ev.stopImmediatePropagation {
+			return
+		}
+	}
+}
+
+// listenerOptions converts the options argument of addEventListener and
+// removeEventListener, which is either an object or the capture flag.
+func listenerOptions(options *Value) (*eventListener, *Value, error) {
+	l := &eventListener{}
+	if options.Tag() == TagUndefined {
+		return l, nil, nil
+	}
+
+	if !options.IsObject() {
+		var err error
+		l.capture, err = options.IsTruthy()
+		return l, nil, err
+	}
+
+	var err error
+	if l.capture, err = booleanOption(options, "capture"); err != nil {
+		return nil, nil, err
+	}
+
+	if l.once, err = booleanOption(options, "once"); err != nil {
+		return nil, nil, err
+	}
+
+	if l.passive, err = booleanOption(options, "passive"); err != nil {
+		return nil, nil, err
+	}
+
+	signal, err := options.Get("signal")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if signal.Tag() == TagUndefined {
+		return l, nil, nil
+	}
+
+	if _, ok := signal.Opaque().(*abortSignal); !ok {
+		return nil, nil, NewTypeError("Failed to read the 'signal' property from 'AddEventListenerOptions': The provided value is not of type 'AbortSignal'")
+	}
+
+	return l, signal, nil
+}
+
+// eventListenerArgs converts the type and callback arguments of
+// addEventListener and removeEventListener. callback is nil if it is null.
+func eventListenerArgs(r *Realm, args []*Value) (string, *Value, error) {
+	if len(args) < 2 {
+		return "", nil, NewTypeError("2 arguments required, but only %d present", len(args))
+	}
+
+	typ, err := r.toString(args[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch {
+	case args[1].Tag() == TagNull || args[1].Tag() == TagUndefined:
+		return typ, nil, nil
+	case !args[1].IsObject():
+		return "", nil, NewTypeError("The provided callback is not an object")
+	}
+
+	return typ, args[1], nil
+}
+
+func (api *eventAPI) eventTargetSpec() ClassSpec {
+	return ClassSpec{
+		Name: "EventTarget",
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			return &eventTarget{}, nil
+		},
+		Methods: map[string]interface{}{
+			"addEventListener": func(r *Realm, this *Value, args ...*Value) error {
+				t, err := thisEventTarget(this)
+				if err != nil {
+					return err
+				}
+
+				typ, callback, err := eventListenerArgs(r, args)
+				if err != nil {
+					return err
+				}
+
+				l, signal, err := listenerOptions(optionalArg(args, 2))
+				if err != nil {
+					return err
+				}
+
+				if callback == nil || t.findListener(typ, callback, l.capture) != nil {
+					return nil
+				}
+
+				l.typ = typ
+				l.callback = callback
+
+				if signal != nil {
+					s := signal.Opaque().(*abortSignal)
+					if s.aborted {
+						return nil
+					}
+
+					s.algorithms = append(s.algorithms, func() {
+						t.removeListener(l)
+					})
+				}
+
+				t.listeners = append(t.listeners, l)
+
+				return nil
+			},
+			"removeEventListener": func(r *Realm, this *Value, args ...*Value) error {
+				t, err := thisEventTarget(this)
+				if err != nil {
+					return err
+				}
+
+				typ, callback, err := eventListenerArgs(r, args)
+				if err != nil {
+					return err
+				}
+
+				l, _, err := listenerOptions(optionalArg(args, 2))
+				if err != nil {
+					return err
+				}
+
+				if callback == nil {
+					return nil
+				}
+
+				if l := t.findListener(typ, callback, l.capture); l != nil {
+					t.removeListener(l)
+				}
+
+				return nil
+			},
+			"dispatchEvent": func(r *Realm, this *Value, eventValue *Value) (bool, error) {
+				t, err := thisEventTarget(this)
+				if err != nil {
+					return false, err
+				}
+
+				ev, ok := eventValue.Opaque().(*event)
+				if !ok {
+					return false, NewTypeError("The provided value is not of type 'Event'")
+				}
+
+				if ev.dispatching || !ev.initialized {
+					return false, newDOMException("InvalidStateError", "The event is already being dispatched")
+				}
+
+				ev.isTrusted = false
+
+				return t.dispatch(this, eventValue, ev), nil
+			},
+		},
+	}
+}
+
+// fire dispatches a trusted event of typ to target, whose opaque value is t.
+func (api *eventAPI) fire(r *Realm, target *Value, t *eventTarget, typ string) (bool, error) {
+	ev := newEvent(r, typ)
+	ev.isTrusted = true
+
+	eventValue, err := api.event.NewInstance(r, ev)
+	if err != nil {
+		return false, err
+	}
+
+	return t.dispatch(target, eventValue, ev), nil
+}
@@ -0,0 +1,134 @@
+package js
+
+import (
+	"testing"
+)
+
+func TestEvent(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicEvents)
+
+	mustEval(t, r, `globalThis.e = new Event("ping", {bubbles: true, cancelable: true})`)
+
+	for script, want := range map[string]string{
+		`[e.type, e.bubbles, e.cancelable, e.composed, e.isTrusted, e.eventPhase].join()`: "ping,true,true,false,false,0",
+		`[e.target, e.currentTarget, e.srcElement].join()`:                                ",,",
+		`typeof e.timeStamp === "number" && e.timeStamp >= 0`:                             "true",
+		`e.composedPath().length`:                                                         "0",
+		`String(e)`:                                                                       "[object Event]",
+
+		`{ const e = new Event("x"); e.preventDefault(); e.defaultPrevented }`:                                 "false",
+		`{ const e = new Event("x"); [e.bubbles, e.cancelable].join() }`:                                       "false,false",
+		`{ const e = new Event("x", null); e.type }`:                                                           "x",
+		`{ const e = new Event("x"); e.initEvent("y", true, true); [e.type, e.bubbles, e.cancelable].join() }`: "y,true,true",
+		`{ const e = new Event("x"); e.cancelBubble = true; e.cancelBubble }`:                                  "true",
+
+		`{ const e = new CustomEvent("c", {detail: {n: 1}}); e instanceof Event && e.detail.n }`: "1",
+		`new CustomEvent("c").detail`: "null",
+		`{ const e = new CustomEvent("c"); e.initCustomEvent("d", false, false, 2); e.type + e.detail }`: "d2",
+	} {
+		expectString(t, r, script, want)
+	}
+
+	expectString(t, r, `e.defaultPrevented || !e.returnValue`, "false")
+	expectString(t, r, `e.preventDefault(); e.defaultPrevented`, "true")
+	expectString(t, r, `e.returnValue`, "false")
+
+	for _, script := range []string{
+		`new Event()`,
+		`new Event("x", 1)`,
+		`Event("x")`,
+		`Reflect.apply(Object.getOwnPropertyDescriptor(Event.prototype, "type").get, {}, [])`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { e.name }`, "TypeError")
+	}
+}
+
+func TestEventTarget(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicEvents)
+
+	mustEval(t, r, `
+		globalThis.target = new EventTarget();
+		globalThis.calls = [];
+		globalThis.listener = (e) => calls.push("fn:" + e.type + ":" + (e.target === target) + ":" + e.eventPhase);
+	`)
+
+	expectString(t, r, `
+		target.addEventListener("ping", listener);
+		// duplicates are ignored
+		target.addEventListener("ping", listener);
+		target.addEventListener("ping", {handleEvent(e) { calls.push("object:" + (this !== target)) }});
+		target.addEventListener("ping", () => calls.push("capture"), true);
+		target.addEventListener("ping", () => calls.push("once"), {once: true});
+		target.addEventListener("other", () => calls.push("other"));
+		target.addEventListener("ping", null);
+		target.dispatchEvent(new Event("ping"));
+		target.dispatchEvent(new Event("ping"));
+		calls.join()
+	`, "capture,fn:ping:true:2,object:true,once,capture,fn:ping:true:2,object:true")
+
+	expectString(t, r, `
+		calls.length = 0;
+		target.removeEventListener("ping", listener);
+		// the capture flag must match
+		target.removeEventListener("ping", () => {}, true);
+		target.dispatchEvent(new Event("ping"));
+		calls.join()
+	`, "capture,object:true")
+
+	// listeners may stop the propagation to later listeners and cancel the
+	// event
+	expectString(t, r, `{
+		const t = new EventTarget();
+		const calls = [];
+		t.addEventListener("x", (e) => { calls.push(1); e.preventDefault(); e.stopImmediatePropagation() });
+		t.addEventListener("x", () => calls.push(2));
+		const result = t.dispatchEvent(new Event("x", {cancelable: true}));
+		[result, calls.join("")].join()
+	}`, "false,1")
+
+	// passive listeners cannot cancel events
+	expectString(t, r, `{
+		const t = new EventTarget();
+		t.addEventListener("x", (e) => e.preventDefault(), {passive: true});
+		t.dispatchEvent(new Event("x", {cancelable: true}))
+	}`, "true")
+
+	// listeners that are added during dispatch are not invoked and errors of
+	// listeners do not stop the dispatch
+	expectString(t, r, `{
+		const t = new EventTarget();
+		const calls = [];
+		t.addEventListener("x", () => { t.addEventListener("x", () => calls.push("added")); throw new Error("ignored") });
+		t.addEventListener("x", () => calls.push("after"));
+		t.dispatchEvent(new Event("x"));
+		calls.join()
+	}`, "after")
+
+	// listeners are removed when their signal is aborted
+	expectString(t, r, `{
+		const t = new EventTarget();
+		const c = new AbortController();
+		const calls = [];
+		t.addEventListener("x", () => calls.push("x"), {signal: c.signal});
+		t.dispatchEvent(new Event("x"));
+		c.abort();
+		t.dispatchEvent(new Event("x"));
+		t.addEventListener("x", () => calls.push("aborted"), {signal: c.signal});
+		t.dispatchEvent(new Event("x"));
+		calls.join()
+	}`, "x")
+
+	expectString(t, r, `try { target.dispatchEvent({type: "x"}) } catch (e) { e.name }`, "TypeError")
+	expectString(t, r, `try { target.addEventListener("x") } catch (e) { e.name }`, "TypeError")
+	expectString(t, r, `try { target.addEventListener("x", 1) } catch (e) { e.name }`, "TypeError")
+	expectString(t, r, `try { target.addEventListener("x", () => {}, {signal: {}}) } catch (e) { e.name }`, "TypeError")
+	expectString(t, r, `try { Reflect.apply(target.addEventListener, {}, ["x", () => {}]) } catch (e) { e.name }`, "TypeError")
+	expectString(t, r, `{
+		const t = new EventTarget();
+		const e = new Event("x");
+		let name;
+		t.addEventListener("x", () => { try { t.dispatchEvent(e) } catch (err) { name = err.name } });
+		t.dispatchEvent(e);
+		name
+	}`, "InvalidStateError")
+}
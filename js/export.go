@@ -0,0 +1,365 @@
+package js
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"runtime"
+	"time"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+const defaultExportMaxDepth = 100
+
+type exportConfig struct {
+	maxDepth int
+}
+
+type ExportOption func(*exportConfig)
+
+// ExportMaxDepth limits how deeply nested objects and arrays may be before
+// Export gives up with an *ExportDepthError.
+func ExportMaxDepth(n int) ExportOption {
+	return func(c *exportConfig) {
+		c.maxDepth = n
+	}
+}
+
+type ExportCycleError struct{}
+
+func (e *ExportCycleError) Error() string {
+	return "cannot export cyclic value"
+}
+
+type ExportDepthError struct {
+	MaxDepth int
+}
+
+func (e *ExportDepthError) Error() string {
+	return fmt.Sprintf("value exceeds the maximum export depth of %d", e.MaxDepth)
+}
+
+// ExportTypeError is returned by Export for values that have no go
+// representation, such as symbols.
+type ExportTypeError struct {
+	Type string
+}
+
+func (e *ExportTypeError) Error() string {
+	return fmt.Sprintf("cannot export %s", e.Type)
+}
+
+type exporter struct {
+	config exportConfig
+
+	// objects on the path from the root to the value currently being exported
+	ancestors map[uintptr]struct{}
+}
+
+// Export recursively converts the value to plain go values.
+//
+// Primitives are exported as they are by Interface, BigInts as *big.Int,
+// arrays and sets as []interface{}, maps as [][2]interface{} of their entries
+// in insertion order, dates as time.Time, ArrayBuffers and views thereof as a
+// copy of their bytes, functions as *Function, errors as *Error and all other
+// objects as map[string]interface{} of their own enumerable properties.
+// Objects that wrap go values are exported as the original go value.
+// Symbols cannot be represented in go and result in an *ExportTypeError.
+func (v *Value) Export(opts ...ExportOption) (interface{}, error) {
+	config := exportConfig{
+		maxDepth: defaultExportMaxDepth,
+	}
+
+	for _, option := range opts {
+		option(&config)
+	}
+
+	e := exporter{
+		config:    config,
+		ancestors: map[uintptr]struct{}{},
+	}
+
+	return e.export(v, 0)
+}
+
+func (e *exporter) export(v *Value, depth int) (interface{}, error) {
+	switch v.Tag() {
+	case TagObject:
+		return e.exportObject(v, depth)
+
+	case TagBigInt:
+		return v.ToBigInt(), nil
+
+	case TagBigFloat, TagBigDecimal:
+		f, _, err := big.ParseFloat(internal.ToString(v.realm.context, v.value), 10, 0, big.ToNearestEven)
+		if err != nil {
+			return nil, err
+		}
+
+		return f, nil
+
+	case TagSymbol:
+		return nil, &ExportTypeError{Type: "symbol"}
+	}
+
+	return v.Interface(), nil
+}
+
+func (e *exporter) exportObject(v *Value, depth int) (interface{}, error) {
+	if goValue, ok := v.goValue(); ok {
+		return goValue, nil
+	}
+
+	if v.IsFunction() {
+		return (*Function)(v), nil
+	}
+
+	if v.IsError() {
+		return (*Error)(v), nil
+	}
+
+	if ok, err := v.realm.isInstanceOfGlobal(v, "Date"); err != nil {
+		return nil, err
+	} else if ok {
+		return exportDate(v)
+	}
+
+	if data, ok, err := v.bytes(); err != nil {
+		return nil, err
+	} else if ok {
+		return data, nil
+	}
+
+	ptr := v.value.Ptr()
+	if _, ok := e.ancestors[ptr]; ok {
+		return nil, &ExportCycleError{}
+	}
+
+	if depth >= e.config.maxDepth {
+		return nil, &ExportDepthError{MaxDepth: e.config.maxDepth}
+	}
+
+	e.ancestors[ptr] = struct{}{}
+	defer delete(e.ancestors, ptr)
+
+	if v.IsArray() {
+		return e.exportArray(v, depth)
+	}
+
+	if ok, err := v.realm.isInstanceOfGlobal(v, "Map"); err != nil {
+		return nil, err
+	} else if ok {
+		return e.exportMapEntries(v, depth)
+	}
+
+	if ok, err := v.realm.isInstanceOfGlobal(v, "Set"); err != nil {
+		return nil, err
+	} else if ok {
+		return e.exportSet(v, depth)
+	}
+
+	return e.exportMap(v, depth)
+}
+
+func (e *exporter) exportArray(v *Value, depth int) (interface{}, error) {
+	lengthValue, err := v.Get("length")
+	if err != nil {
+		return nil, err
+	}
+
+	n := lengthValue.ToInt()
+	ret := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		elem, err := v.Index(i)
+		if err != nil {
+			return nil, err
+		}
+
+		ret[i], err = e.export(elem, depth+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// exportMapEntries exports the entries of a Map as key-value pairs, since
+// their keys may be of any type.
+func (e *exporter) exportMapEntries(v *Value, depth int) (interface{}, error) {
+	ret := [][2]interface{}{}
+	err := v.Iterate(func(entry *Value) (bool, error) {
+		var pair [2]interface{}
+		for i := range pair {
+			elem, err := entry.Index(i)
+			if err != nil {
+				return false, err
+			}
+
+			if pair[i], err = e.export(elem, depth+1); err != nil {
+				return false, err
+			}
+		}
+
+		ret = append(ret, pair)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (e *exporter) exportSet(v *Value, depth int) (interface{}, error) {
+	ret := []interface{}{}
+	err := v.Iterate(func(elem *Value) (bool, error) {
+		exported, err := e.export(elem, depth+1)
+		if err != nil {
+			return false, err
+		}
+
+		ret = append(ret, exported)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (e *exporter) exportMap(v *Value, depth int) (interface{}, error) {
+	ret := map[string]interface{}{}
+	err := v.forEachOwnEnumerableProperty(func(key string, elem *Value) error {
+		var err error
+		ret[key], err = e.export(elem, depth+1)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func exportDate(v *Value) (time.Time, error) {
+	msValue, err := v.Invoke("getTime")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ms := msValue.ToFloat()
+	if math.IsNaN(ms) {
+		// invalid date
+		return time.Time{}, nil
+	}
+
+	// time values are always integral milliseconds
+	n := int64(ms)
+
+	return time.Unix(n/1000, n%1000*int64(time.Millisecond)), nil
+}
+
+// bytes returns a copy of the contents of an ArrayBuffer or of the region of
+// an ArrayBuffer that is referenced by a TypedArray or DataView.
+func (v *Value) bytes() ([]byte, bool, error) {
+	data, ok, err := v.bufferSource()
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+
+	return append([]byte{}, data...), true, nil
+}
+
+// bufferSource is like bytes, but returns the backing memory itself, which is
+// only valid while v is kept alive and its buffer is not detached.
+func (v *Value) bufferSource() ([]byte, bool, error) {
+	if ok, err := v.realm.isInstanceOfGlobal(v, "ArrayBuffer"); err != nil {
+		return nil, false, err
+	} else if ok {
+		defer runtime.KeepAlive(v)
+
+		data := internal.GetArrayBuffer(v.realm.context, v.value)
+		if data == nil {
+			return nil, false, v.realm.getError()
+		}
+
+		return data, true, nil
+	}
+
+	arrayBufferConstructor, err := v.realm.globalProperty("ArrayBuffer")
+	if err != nil {
+		return nil, false, err
+	}
+
+	isViewValue, err := arrayBufferConstructor.Invoke("isView", v)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !isViewValue.ToBool() {
+		return nil, false, nil
+	}
+
+	// the region of a TypedArray is read from the TypedArray itself, so that
+	// it cannot be spoofed by overriding its properties
+	buffer, offset, length := internal.GetTypedArrayBuffer(v.realm.context, v.value)
+	if buffer.Tag() == internal.TagException {
+		// DataViews are not TypedArrays
+		internal.FreeValue(v.realm.context, internal.GetException(v.realm.context))
+
+		return v.dataViewSource()
+	}
+
+	defer internal.FreeValue(v.realm.context, buffer)
+
+	data := internal.GetArrayBuffer(v.realm.context, buffer)
+	if data == nil {
+		return nil, false, v.realm.getError()
+	}
+
+	if offset+length > len(data) {
+		return nil, false, NewTypeError("The view is outside the bounds of its ArrayBuffer")
+	}
+
+	return data[offset : offset+length], true, nil
+}
+
+// dataViewSource is like bufferSource for DataViews, whose region is read
+// from their properties and checked against the bounds of their buffer.
+func (v *Value) dataViewSource() ([]byte, bool, error) {
+	buffer, err := v.Get("buffer")
+	if err != nil {
+		return nil, false, err
+	}
+
+	offset, err := v.Get("byteOffset")
+	if err != nil {
+		return nil, false, err
+	}
+
+	length, err := v.Get("byteLength")
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer runtime.KeepAlive(buffer)
+
+	data := internal.GetArrayBuffer(v.realm.context, buffer.value)
+	if data == nil {
+		return nil, false, v.realm.getError()
+	}
+
+	if !offset.IsNumber() || !length.IsNumber() {
+		return nil, false, NewTypeError("The view is outside the bounds of its ArrayBuffer")
+	}
+
+	start, end := offset.ToFloat(), offset.ToFloat()+length.ToFloat()
+	if start < 0 || end < start || end > float64(len(data)) || start != math.Trunc(start) || end != math.Trunc(end) {
+		return nil, false, NewTypeError("The view is outside the bounds of its ArrayBuffer")
+	}
+
+	return data[int(start):int(end)], true, nil
+}
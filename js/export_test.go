@@ -0,0 +1,128 @@
+package js
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExport(t *testing.T) {
+	r := newTestRealm(t)
+
+	for _, test := range []struct {
+		script string
+		want   interface{}
+	}{
+		{`null`, nil},
+		{`"a"`, "a"},
+		{`true`, true},
+		{`[1, "b", [null]]`, []interface{}{1, "b", []interface{}{nil}}},
+		{`({a: {b: 1.5}})`, map[string]interface{}{"a": map[string]interface{}{"b": 1.5}}},
+		{`123456789012345678901234567890n`, func() *big.Int { n, _ := new(big.Int).SetString("123456789012345678901234567890", 10); return n }()},
+		{`new Uint8Array([1, 2, 3]).subarray(1)`, []byte{2, 3}},
+		{`new Date(1500)`, time.Unix(1, 500*int64(time.Millisecond))},
+		{`new Map([[1, "a"], ["k", {x: 2}]])`, [][2]interface{}{{1, "a"}, {"k", map[string]interface{}{"x": 2}}}},
+		{`new Set([3, "c", 3])`, []interface{}{3, "c"}},
+	} {
+		exported, err := mustEval(t, r, test.script).Export()
+		if err != nil {
+			t.Errorf("%s: %v", test.script, err)
+			continue
+		}
+
+		if date, ok := exported.(time.Time); ok {
+			if !date.Equal(test.want.(time.Time)) {
+				t.Errorf("%s = %v, want %v", test.script, date, test.want)
+			}
+
+			continue
+		}
+
+		if !reflect.DeepEqual(exported, test.want) {
+			t.Errorf("%s = %#v, want %#v", test.script, exported, test.want)
+		}
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	r := newTestRealm(t)
+
+	exported, err := mustEval(t, r, `({m: new Map([["a", 1]]), s: new Set(["b"])})`).Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"m":[["a",1]],"s":["b"]}`; string(data) != want {
+		t.Errorf("json = %s, want %s", data, want)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	r := newTestRealm(t)
+
+	var cycleErr *ExportCycleError
+	if _, err := mustEval(t, r, `var a = {}; a.self = a; a`).Export(); !errors.As(err, &cycleErr) {
+		t.Errorf("cyclic object: got %v, want *ExportCycleError", err)
+	}
+
+	if _, err := mustEval(t, r, `var m = new Map(); m.set("m", m); m`).Export(); !errors.As(err, &cycleErr) {
+		t.Errorf("cyclic map: got %v, want *ExportCycleError", err)
+	}
+
+	// the same object may appear more than once if it is not an ancestor
+	if _, err := mustEval(t, r, `var o = {}; [o, o]`).Export(); err != nil {
+		t.Errorf("shared object: %v", err)
+	}
+
+	var depthErr *ExportDepthError
+	if _, err := mustEval(t, r, `[[[1]]]`).Export(ExportMaxDepth(2)); !errors.As(err, &depthErr) || depthErr.MaxDepth != 2 {
+		t.Errorf("deep array: got %v, want *ExportDepthError", err)
+	}
+
+	var typeErr *ExportTypeError
+	for _, script := range []string{`Symbol("s")`, `({s: Symbol("s")})`, `new Map([[Symbol.iterator, 1]])`} {
+		if _, err := mustEval(t, r, script).Export(); !errors.As(err, &typeErr) || typeErr.Type != "symbol" {
+			t.Errorf("%s: got %v, want *ExportTypeError", script, err)
+		}
+	}
+}
+
+func TestExportSpoofedViews(t *testing.T) {
+	r := newTestRealm(t)
+
+	// the region of a TypedArray is not read from its properties
+	for _, script := range []string{
+		`var u = new Uint8Array([1, 2, 3, 4]); Object.defineProperty(u, "byteLength", {value: 1000}); u`,
+		`var u = new Uint8Array([1, 2, 3, 4]); Object.defineProperty(u, "byteOffset", {value: 2}); u`,
+		`var u = new Uint8Array([1, 2, 3, 4]); Object.defineProperty(u, "buffer", {value: new ArrayBuffer(8)}); u`,
+		`new DataView(new Uint8Array([0, 1, 2, 3, 4, 5]).buffer, 1, 4)`,
+	} {
+		exported, err := mustEval(t, r, script).Export()
+		if err != nil {
+			t.Errorf("%s: %v", script, err)
+		} else if !reflect.DeepEqual(exported, []byte{1, 2, 3, 4}) {
+			t.Errorf("%s = %v, want [1 2 3 4]", script, exported)
+		}
+	}
+
+	// the properties of DataViews are checked against their buffer
+	for _, script := range []string{
+		`var d = new DataView(new ArrayBuffer(4)); Object.defineProperty(d, "byteLength", {value: 1000}); d`,
+		`var d = new DataView(new ArrayBuffer(4)); Object.defineProperty(d, "byteOffset", {value: -1}); d`,
+		`var d = new DataView(new ArrayBuffer(4)); Object.defineProperty(d, "byteOffset", {value: 0.5}); d`,
+		`var d = new DataView(new ArrayBuffer(4)); Object.defineProperty(d, "byteLength", {value: "2"}); d`,
+	} {
+		var typeErr TypeError
+		if _, err := mustEval(t, r, script).Export(); !errors.As(err, &typeErr) {
+			t.Errorf("%s: got %v, want a TypeError", script, err)
+		}
+	}
+}
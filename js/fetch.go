@@ -0,0 +1,1751 @@
+package js
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FetchOptions restricts the hosts that fetch may send requests to. Hosts are
+// checked before each request is sent, including those of redirects.
+type FetchOptions struct {
+	// AllowHosts are the hosts that requests may be sent to. A host of the
+	// form "*.example.com" matches example.com and all of its subdomains. All
+	// hosts are allowed if AllowHosts is empty. Hosts are compared without
+	// case, in their ascii form and without a trailing dot.
+	AllowHosts []string
+
+	// DenyHosts are the hosts that requests are never sent to, even if they
+	// are allowed by AllowHosts.
+	DenyHosts []string
+}
+
+// normalizeHost converts host to lowercase ascii and removes one trailing dot,
+// so that different forms of a host are matched in the same way. It returns
+// false if host is not a valid domain.
+func normalizeHost(host string) (string, bool) {
+	ascii, ok := domainToASCII(host)
+	if !ok {
+		return "", false
+	}
+
+	return strings.TrimSuffix(ascii, "."), true
+}
+
+func matchHost(pattern, host string) bool {
+	wildcard := strings.HasPrefix(pattern, "*.")
+	if wildcard {
+		pattern = pattern[2:]
+	}
+
+	pattern, ok := normalizeHost(pattern)
+	if !ok {
+		return false
+	}
+
+	if wildcard {
+		return host == pattern || strings.HasSuffix(host, "."+pattern)
+	}
+
+	return host == pattern
+}
+
+func (o FetchOptions) allowsHost(host string) bool {
+	host, ok := normalizeHost(host)
+	if !ok {
+		return false
+	}
+
+	for _, pattern := range o.DenyHosts {
+		if matchHost(pattern, host) {
+			return false
+		}
+	}
+
+	if len(o.AllowHosts) == 0 {
+		return true
+	}
+
+	for _, pattern := range o.AllowHosts {
+		if matchHost(pattern, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hostFilterTransport rejects requests to hosts that are not allowed.
+type hostFilterTransport struct {
+	transport http.RoundTripper
+	options   FetchOptions
+}
+
+func (t *hostFilterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.options.allowsHost(req.URL.Hostname()) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+
+		return nil, fmt.Errorf("requests to %s are not allowed", req.URL.Hostname())
+	}
+
+	return t.transport.RoundTrip(req)
+}
+
+// fetchAbort holds the reason of an aborted fetch, which is set by the abort
+// listener of the signal.
+type fetchAbort struct {
+	reason *Value
+}
+
+// fetchBodySource is the underlying source of a fetchBody, which is shared by
+// clones so that it is only read once.
+type fetchBodySource interface {
+	// read returns the whole body.
+	read() ([]byte, error)
+
+	// open returns a reader of the body for its stream, which is closed once
+	// the stream is done with it if it is an io.Closer.
+	open() io.Reader
+
+	// share is called when the body is cloned, after which the body must be
+	// readable by each clone.
+	share()
+}
+
+// fetchBody is the body of a Request or Response.
+type fetchBody struct {
+	source fetchBodySource
+	used   bool
+
+	// stream is the ReadableStream of the body, which is created when the
+	// body property is first read
+	stream      *Value
+	streamState *readableStream
+
+	// abort is set for bodies of responses to fetch, whose reads fail when
+	// the request is aborted
+	abort *fetchAbort
+
+	// finish is called once the body has been read or its stream is done,
+	// which releases the resources of the request
+	finish func()
+}
+
+// bodyUsed reports whether the body has been read, including through its
+// stream.
+func (b *fetchBody) bodyUsed() bool {
+	return b.used || (b.streamState != nil && b.streamState.disturbed)
+}
+
+func (b *fetchBody) done() {
+	if b.finish != nil {
+		b.finish()
+	}
+}
+
+type bytesBodySource []byte
+
+func (s bytesBodySource) read() ([]byte, error) {
+	return s, nil
+}
+
+func (s bytesBodySource) open() io.Reader {
+	return bytes.NewReader(s)
+}
+
+func (s bytesBodySource) share() {}
+
+func newBytesBody(data []byte) *fetchBody {
+	return &fetchBody{
+		source: bytesBodySource(data),
+	}
+}
+
+// readerBodySource reads a response body once and releases the request when
+// it is done.
+type readerBodySource struct {
+	once   sync.Once
+	rc     io.ReadCloser
+	cancel context.CancelFunc
+	data   []byte
+	err    error
+
+	// shared is set once the body is cloned, after which its stream reads
+	// the whole body first
+	shared bool
+}
+
+func (s *readerBodySource) read() ([]byte, error) {
+	s.once.Do(func() {
+		s.data, s.err = io.ReadAll(s.rc)
+		s.close()
+	})
+
+	return s.data, s.err
+}
+
+func (s *readerBodySource) open() io.Reader {
+	if s.shared {
+		return &sharedBodyReader{source: s}
+	}
+
+	return readerBodyStream{s}
+}
+
+func (s *readerBodySource) share() {
+	s.shared = true
+}
+
+func (s *readerBodySource) close() {
+	s.rc.Close()
+	s.cancel()
+}
+
+// readerBodyStream streams a response body that is not shared with clones.
+type readerBodyStream struct {
+	source *readerBodySource
+}
+
+func (s readerBodyStream) Read(p []byte) (int, error) {
+	return s.source.rc.Read(p)
+}
+
+func (s readerBodyStream) Close() error {
+	s.source.close()
+	return nil
+}
+
+// sharedBodyReader reads the whole body of a response that is shared with
+// clones on the first read.
+type sharedBodyReader struct {
+	source *readerBodySource
+	reader *bytes.Reader
+}
+
+func (r *sharedBodyReader) Read(p []byte) (int, error) {
+	if r.reader == nil {
+		data, err := r.source.read()
+		if err != nil {
+			return 0, err
+		}
+
+		r.reader = bytes.NewReader(data)
+	}
+
+	return r.reader.Read(p)
+}
+
+func newReaderBody(rc io.ReadCloser, cancel context.CancelFunc, abort *fetchAbort, finish func()) *fetchBody {
+	source := &readerBodySource{
+		rc:     rc,
+		cancel: cancel,
+	}
+
+	// release responses whose bodies are never read
+	runtime.SetFinalizer(source, (*readerBodySource).close)
+
+	return &fetchBody{
+		source: source,
+		abort:  abort,
+		finish: finish,
+	}
+}
+
+func (b *fetchBody) clone() (*fetchBody, error) {
+	if b == nil {
+		return nil, nil
+	}
+
+	if b.bodyUsed() || (b.streamState != nil && b.streamState.locked()) {
+		return nil, NewTypeError("Body has already been used")
+	}
+
+	b.source.share()
+
+	c := *b
+	c.stream = nil
+	c.streamState = nil
+
+	return &c, nil
+}
+
+// bodyError returns the reason that reading body failed with err, which is
+// the reason of the abort if the request was aborted.
+func bodyError(r *Realm, body *fetchBody, err error) *Value {
+	if body.abort != nil && body.abort.reason != nil {
+		return body.abort.reason
+	}
+
+	return r.errorValue(NewTypeError("failed to read body: %s", err))
+}
+
+// bodyStream returns the ReadableStream of body, which is created once, null
+// if there is no body or undefined if streams are not available in the
+// realm.
+func bodyStream(r *Realm, body *fetchBody) (*Value, error) {
+	if body == nil {
+		return NewNull(), nil
+	}
+
+	if body.stream != nil {
+		return body.stream, nil
+	}
+
+	if r.streams == nil {
+		return NewUndefined(), nil
+	}
+
+	if body.used {
+		// the body was consumed before its stream was created, so the stream
+		// is empty and locked as if it had been read
+		stream, s, err := r.streams.newReaderStream(r, bytes.NewReader(nil), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := r.streams.acquireReader(r, s); err != nil {
+			return nil, err
+		}
+
+		s.disturbed = true
+		body.stream, body.streamState = stream, s
+
+		return stream, nil
+	}
+
+	stream, s, err := r.streams.newReaderStream(r, &lazyReader{open: body.source.open}, func(err error) *Value {
+		return bodyError(r, body, err)
+	}, body.done)
+	if err != nil {
+		return nil, err
+	}
+
+	body.stream, body.streamState = stream, s
+
+	return stream, nil
+}
+
+// lazyReader opens its reader on the first read, so that the body of a
+// response is only read once its stream is.
+type lazyReader struct {
+	open   func() io.Reader
+	reader io.Reader
+}
+
+func (r *lazyReader) Read(p []byte) (int, error) {
+	if r.reader == nil {
+		r.reader = r.open()
+	}
+
+	return r.reader.Read(p)
+}
+
+func (r *lazyReader) Close() error {
+	if r.reader == nil {
+		// the reader is opened so that the request is released
+		r.reader = r.open()
+	}
+
+	if closer, ok := r.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// extractBody converts the body of a Request or Response along with its
+// default content type.
+func extractBody(r *Realm, init *Value) (*fetchBody, string, error) {
+	if p, ok := init.Opaque().(*urlSearchParams); ok {
+		return newBytesBody([]byte(p.String())), "application/x-www-form-urlencoded;charset=UTF-8", nil
+	}
+
+	if !init.IsString() {
+		data, ok, err := init.bufferSource()
+		if err != nil {
+			return nil, "", err
+		}
+
+		if ok {
+			return newBytesBody(append([]byte(nil), data...)), "", nil
+		}
+	}
+
+	s, err := r.toUSVString(init)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return newBytesBody([]byte(s)), "text/plain;charset=UTF-8", nil
+}
+
+// consumeBody reads body on another goroutine and returns a promise of the
+// result of convert.
+func consumeBody(r *Realm, body *fetchBody, convert func(r *Realm, data []byte) (interface{}, error)) (*Value, error) {
+	promise, resolve, reject := r.NewPromise()
+	if promise == nil {
+		return nil, resolve(nil)
+	}
+
+	if body == nil {
+		result, err := convert(r, nil)
+		if err != nil {
+			return promise, reject(r.errorValue(err))
+		}
+
+		return promise, resolve(result)
+	}
+
+	if body.bodyUsed() || (body.streamState != nil && body.streamState.locked()) {
+		return promise, reject(r.errorValue(NewTypeError("Body is unusable: Body has already been read")))
+	}
+
+	if body.streamState != nil {
+		// the stream is locked as if it was read by the body methods
+		if _, err := r.streams.acquireReader(r, body.streamState); err != nil {
+			return promise, reject(r.errorValue(err))
+		}
+	}
+
+	body.used = true
+
+	r.runtime.goAsync(func(context.Context) func() error {
+		data, err := body.source.read()
+
+		return func() error {
+			body.done()
+
+			if err != nil {
+				return reject(bodyError(r, body, err))
+			}
+
+			result, err := convert(r, data)
+			if err != nil {
+				return reject(r.errorValue(err))
+			}
+
+			return resolve(result)
+		}
+	})
+
+	return promise, nil
+}
+
+func decodeBodyText(data []byte) (string, error) {
+	decoding, name, _ := newTextDecoding("utf-8")
+	d := &textDecoder{
+		label:    name,
+		encoding: name,
+		decoding: decoding,
+	}
+
+	return d.decode(data, false)
+}
+
+// bodyMethods returns the methods and properties that Request and Response
+// share. body returns the body of an instance.
+func bodyMethods(body func(this *Value) (*fetchBody, error)) (map[string]interface{}, ClassProperty) {
+	method := func(convert func(r *Realm, data []byte) (interface{}, error)) func(r *Realm, this *Value) (*Value, error) {
+		return func(r *Realm, this *Value) (*Value, error) {
+			b, err := body(this)
+			if err != nil {
+				return nil, err
+			}
+
+			return consumeBody(r, b, convert)
+		}
+	}
+
+	methods := map[string]interface{}{
+		"arrayBuffer": method(func(r *Realm, data []byte) (interface{}, error) {
+			return r.NewArrayBuffer(data)
+		}),
+		"bytes": method(func(r *Realm, data []byte) (interface{}, error) {
+			return r.newUint8Array(data)
+		}),
+		"json": method(func(r *Realm, data []byte) (interface{}, error) {
+			text, err := decodeBodyText(data)
+			if err != nil {
+				return nil, err
+			}
+
+			json, err := r.globalProperty("JSON")
+			if err != nil {
+				return nil, err
+			}
+
+			return json.Invoke("parse", text)
+		}),
+		"text": method(func(r *Realm, data []byte) (interface{}, error) {
+			return decodeBodyText(data)
+		}),
+	}
+
+	bodyUsed := ClassProperty{
+		Get: func(r *Realm, this *Value) (bool, error) {
+			b, err := body(this)
+			if err != nil {
+				return false, err
+			}
+
+			return b != nil && b.bodyUsed(), nil
+		},
+	}
+
+	return methods, bodyUsed
+}
+
+type fetchHeader struct {
+	name  string
+	value string
+}
+
+// fetchHeaders is the opaque value of Headers instances. Names are stored in
+// lowercase.
+type fetchHeaders struct {
+	list      []fetchHeader
+	immutable bool
+}
+
+func thisHeaders(this *Value) (*fetchHeaders, error) {
+	h, ok := this.Opaque().(*fetchHeaders)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return h, nil
+}
+
+func (h *fetchHeaders) clone() *fetchHeaders {
+	return &fetchHeaders{
+		list:      append([]fetchHeader(nil), h.list...),
+		immutable: h.immutable,
+	}
+}
+
+func (h *fetchHeaders) get(name string) (string, bool) {
+	var values []string
+	for _, header := range h.list {
+		if header.name == name {
+			values = append(values, header.value)
+		}
+	}
+
+	return strings.Join(values, ", "), values != nil
+}
+
+func (h *fetchHeaders) has(name string) bool {
+	_, ok := h.get(name)
+	return ok
+}
+
+func (h *fetchHeaders) delete(name string) {
+	list := h.list[:0]
+	for _, header := range h.list {
+		if header.name != name {
+			list = append(list, header)
+		}
+	}
+
+	h.list = list
+}
+
+func (h *fetchHeaders) set(name, value string) {
+	found := false
+	list := h.list[:0]
+	for _, header := range h.list {
+		if header.name != name {
+			list = append(list, header)
+		} else if !found {
+			list = append(list, fetchHeader{name: name, value: value})
+			found = true
+		}
+	}
+
+	if !found {
+		list = append(list, fetchHeader{name: name, value: value})
+	}
+
+	h.list = list
+}
+
+// sortedAndCombined returns the headers that are iterated, which are sorted
+// by name and combined, except for set-cookie.
+func (h *fetchHeaders) sortedAndCombined() []fetchHeader {
+	names := make([]string, 0, len(h.list))
+	seen := map[string]bool{}
+	for _, header := range h.list {
+		if !seen[header.name] {
+			seen[header.name] = true
+			names = append(names, header.name)
+		}
+	}
+
+	sort.Strings(names)
+
+	headers := make([]fetchHeader, 0, len(names))
+	for _, name := range names {
+		if name == "set-cookie" {
+			for _, header := range h.list {
+				if header.name == name {
+					headers = append(headers, header)
+				}
+			}
+
+			continue
+		}
+
+		value, _ := h.get(name)
+		headers = append(headers, fetchHeader{name: name, value: value})
+	}
+
+	return headers
+}
+
+func (h *fetchHeaders) fill(r *Realm, init *Value) error {
+	if other, ok := init.Opaque().(*fetchHeaders); ok {
+		h.list = append(h.list, other.list...)
+		return nil
+	}
+
+	if !init.IsObject() {
+		return NewTypeError("The \"init\" argument must be an object")
+	}
+
+	pairs, err := r.namesAndValues(init)
+	if err != nil {
+		return err
+	}
+
+	for _, pair := range pairs {
+		if err := h.append(pair[0], pair[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *fetchHeaders) append(name, value string) error {
+	name, value, err := normalizeHeader(name, value)
+	if err != nil {
+		return err
+	}
+
+	h.list = append(h.list, fetchHeader{name: name, value: value})
+	return nil
+}
+
+func isHeaderNameByte(c byte) bool {
+	return isASCIIAlphanumeric(rune(c)) || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+func validateHeaderName(name string) (string, error) {
+	if name == "" {
+		return "", NewTypeError("Header name must not be empty")
+	}
+
+	for i := 0; i < len(name); i++ {
+		if !isHeaderNameByte(name[i]) {
+			return "", NewTypeError("Invalid header name: %q", name)
+		}
+	}
+
+	return strings.ToLower(name), nil
+}
+
+func normalizeHeader(name, value string) (string, string, error) {
+	name, err := validateHeaderName(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	value = strings.Trim(value, "\t\n\r ")
+	if strings.ContainsAny(value, "\x00\r\n") {
+		return "", "", NewTypeError("Invalid value for header %s", name)
+	}
+
+	return name, value, nil
+}
+
+// headerArgs converts the arguments of the methods of Headers.
+func headerArgs(r *Realm, this *Value, args []*Value, n int) (*fetchHeaders, []string, error) {
+	h, err := thisHeaders(this)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(args) < n {
+		return nil, nil, NewTypeError("%d arguments required, but only %d present", n, len(args))
+	}
+
+	strs := make([]string, n)
+	for i := range strs {
+		if strs[i], err = r.toUSVString(args[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return h, strs, nil
+}
+
+func mutableHeaderArgs(r *Realm, this *Value, args []*Value, n int) (*fetchHeaders, []string, error) {
+	h, strs, err := headerArgs(r, this, args, n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if h.immutable {
+		return nil, nil, NewTypeError("Headers are immutable")
+	}
+
+	return h, strs, nil
+}
+
+func headersIteratorMethod(f func(header fetchHeader) interface{}) func(r *Realm, this *Value) (*Value, error) {
+	return func(r *Realm, this *Value) (*Value, error) {
+		h, err := thisHeaders(this)
+		if err != nil {
+			return nil, err
+		}
+
+		return r.newListIterator(func() int {
+			return len(h.sortedAndCombined())
+		}, func(i int) interface{} {
+			return f(h.sortedAndCombined()[i])
+		})
+	}
+}
+
+var headersSpec = ClassSpec{
+	Name: "Headers",
+	Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+		h := &fetchHeaders{}
+		if len(args) > 0 && args[0].Tag() != TagUndefined {
+			if err := h.fill(r, args[0]); err != nil {
+				return nil, err
+			}
+		}
+
+		return h, nil
+	},
+	Methods: map[string]interface{}{
+		"append": func(r *Realm, this *Value, args ...*Value) error {
+			h, strs, err := mutableHeaderArgs(r, this, args, 2)
+			if err != nil {
+				return err
+			}
+
+			return h.append(strs[0], strs[1])
+		},
+		"delete": func(r *Realm, this *Value, args ...*Value) error {
+			h, strs, err := mutableHeaderArgs(r, this, args, 1)
+			if err != nil {
+				return err
+			}
+
+			name, err := validateHeaderName(strs[0])
+			if err != nil {
+				return err
+			}
+
+			h.delete(name)
+			return nil
+		},
+		"get": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+			h, strs, err := headerArgs(r, this, args, 1)
+			if err != nil {
+				return nil, err
+			}
+
+			name, err := validateHeaderName(strs[0])
+			if err != nil {
+				return nil, err
+			}
+
+			value, ok := h.get(name)
+			if !ok {
+				return NewNull(), nil
+			}
+
+			return r.NewString(value)
+		},
+		"getSetCookie": func(r *Realm, this *Value) ([]string, error) {
+			h, err := thisHeaders(this)
+			if err != nil {
+				return nil, err
+			}
+
+			values := []string{}
+			for _, header := range h.list {
+				if header.name == "set-cookie" {
+					values = append(values, header.value)
+				}
+			}
+
+			return values, nil
+		},
+		"has": func(r *Realm, this *Value, args ...*Value) (bool, error) {
+			h, strs, err := headerArgs(r, this, args, 1)
+			if err != nil {
+				return false, err
+			}
+
+			name, err := validateHeaderName(strs[0])
+			if err != nil {
+				return false, err
+			}
+
+			return h.has(name), nil
+		},
+		"set": func(r *Realm, this *Value, args ...*Value) error {
+			h, strs, err := mutableHeaderArgs(r, this, args, 2)
+			if err != nil {
+				return err
+			}
+
+			name, value, err := normalizeHeader(strs[0], strs[1])
+			if err != nil {
+				return err
+			}
+
+			h.set(name, value)
+			return nil
+		},
+		"forEach": func(r *Realm, this *Value, callback *Value, args ...*Value) error {
+			h, err := thisHeaders(this)
+			if err != nil {
+				return err
+			}
+
+			if !callback.IsFunction() {
+				return NewTypeError("The \"callback\" argument must be of type function")
+			}
+
+			thisArg := optionalArg(args, 0)
+			for i := 0; ; i++ {
+				headers := h.sortedAndCombined()
+				if i >= len(headers) {
+					return nil
+				}
+
+				if _, err := callback.Call(thisArg, headers[i].value, headers[i].name, this); err != nil {
+					return err
+				}
+			}
+		},
+		"entries": headersIteratorMethod(func(header fetchHeader) interface{} {
+			return []string{header.name, header.value}
+		}),
+		"keys": headersIteratorMethod(func(header fetchHeader) interface{} {
+			return header.name
+		}),
+		"values": headersIteratorMethod(func(header fetchHeader) interface{} {
+			return header.value
+		}),
+	},
+}
+
+// fetchRequest is the opaque value of Request instances.
+type fetchRequest struct {
+	method   string
+	url      *urlRecord
+	headers  *fetchHeaders
+	body     *fetchBody
+	signal   *Value
+	redirect string
+
+	headersValue *Value
+}
+
+func thisRequest(this *Value) (*fetchRequest, error) {
+	req, ok := this.Opaque().(*fetchRequest)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return req, nil
+}
+
+var forbiddenMethods = map[string]bool{
+	"CONNECT": true,
+	"TRACE":   true,
+	"TRACK":   true,
+}
+
+func normalizeMethod(method string) (string, error) {
+	if method == "" {
+		return "", NewTypeError("Invalid request method")
+	}
+
+	for i := 0; i < len(method); i++ {
+		if !isHeaderNameByte(method[i]) {
+			return "", NewTypeError("Invalid request method %q", method)
+		}
+	}
+
+	switch upper := strings.ToUpper(method); upper {
+	case "DELETE", "GET", "HEAD", "OPTIONS", "POST", "PUT":
+		method = upper
+	}
+
+	if forbiddenMethods[strings.ToUpper(method)] {
+		return "", NewTypeError("Request method %s is not allowed", method)
+	}
+
+	return method, nil
+}
+
+// stringOption returns a property of an options object converted to a string
+// and whether it is defined.
+func stringOption(r *Realm, options *Value, name string) (string, bool, error) {
+	value, err := options.Get(name)
+	if err != nil {
+		return "", false, err
+	}
+
+	if value.Tag() == TagUndefined {
+		return "", false, nil
+	}
+
+	s, err := r.toUSVString(value)
+	if err != nil {
+		return "", false, err
+	}
+
+	return s, true, nil
+}
+
+// newFetchRequest creates a request from the arguments of the Request
+// constructor and fetch.
+func newFetchRequest(r *Realm, args []*Value) (*fetchRequest, error) {
+	if len(args) == 0 {
+		return nil, NewTypeError("The \"input\" argument must be specified")
+	}
+
+	var req *fetchRequest
+	if input, ok := args[0].Opaque().(*fetchRequest); ok {
+		req = &fetchRequest{
+			method:   input.method,
+			url:      input.url,
+			headers:  input.headers.clone(),
+			body:     input.body,
+			signal:   input.signal,
+			redirect: input.redirect,
+		}
+	} else {
+		s, err := r.toUSVString(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		u, ok := parseURL(s, nil)
+		if !ok {
+			return nil, NewTypeError("Invalid URL: %s", s)
+		}
+
+		if u.includesCredentials() {
+			return nil, NewTypeError("Request cannot be constructed from a URL that includes credentials: %s", s)
+		}
+
+		req = &fetchRequest{
+			method:   "GET",
+			url:      u,
+			headers:  &fetchHeaders{},
+			redirect: "follow",
+		}
+	}
+
+	if len(args) < 2 || !args[1].IsObject() {
+		if req.body != nil && req.body.used {
+			return nil, NewTypeError("Cannot construct a Request with a Request object that has already been used")
+		}
+
+		return req, nil
+	}
+
+	init := args[1]
+
+	if method, ok, err := stringOption(r, init, "method"); err != nil {
+		return nil, err
+	} else if ok {
+		if req.method, err = normalizeMethod(method); err != nil {
+			return nil, err
+		}
+	}
+
+	if redirect, ok, err := stringOption(r, init, "redirect"); err != nil {
+		return nil, err
+	} else if ok {
+		switch redirect {
+		case "follow", "error", "manual":
+			req.redirect = redirect
+		default:
+			return nil, NewTypeError("Invalid redirect mode %q", redirect)
+		}
+	}
+
+	signal, err := init.Get("signal")
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case signal.Tag() == TagNull:
+		req.signal = nil
+	case signal.IsObject():
+		req.signal = signal
+	case signal.Tag() != TagUndefined:
+		return nil, NewTypeError("The \"signal\" option must be an AbortSignal")
+	}
+
+	headers, err := init.Get("headers")
+	if err != nil {
+		return nil, err
+	}
+
+	if headers.Tag() != TagUndefined {
+		req.headers = &fetchHeaders{}
+		if err := req.headers.fill(r, headers); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := init.Get("body")
+	if err != nil {
+		return nil, err
+	}
+
+	if body.Tag() != TagUndefined && body.Tag() != TagNull {
+		if req.method == "GET" || req.method == "HEAD" {
+			return nil, NewTypeError("Request with GET/HEAD method cannot have body")
+		}
+
+		var contentType string
+		if req.body, contentType, err = extractBody(r, body); err != nil {
+			return nil, err
+		}
+
+		if contentType != "" && !req.headers.has("content-type") {
+			req.headers.list = append(req.headers.list, fetchHeader{name: "content-type", value: contentType})
+		}
+	} else if req.body != nil && req.body.used {
+		return nil, NewTypeError("Cannot construct a Request with a Request object that has already been used")
+	}
+
+	return req, nil
+}
+
+// fetchResponse is the opaque value of Response instances.
+type fetchResponse struct {
+	typ        string
+	url        string
+	redirected bool
+	status     int
+	statusText string
+	headers    *fetchHeaders
+	body       *fetchBody
+
+	headersValue *Value
+}
+
+func thisResponse(this *Value) (*fetchResponse, error) {
+	res, ok := this.Opaque().(*fetchResponse)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return res, nil
+}
+
+func isNullBodyStatus(status int) bool {
+	switch status {
+	case 101, 103, 204, 205, 304:
+		return true
+	}
+
+	return false
+}
+
+func isRedirectStatus(status int) bool {
+	switch status {
+	case 301, 302, 303, 307, 308:
+		return true
+	}
+
+	return false
+}
+
+// initResponse applies the init argument of the Response constructor.
+func initResponse(r *Realm, res *fetchResponse, init *Value, body *fetchBody, contentType string) error {
+	if init.IsObject() {
+		status, err := init.Get("status")
+		if err != nil {
+			return err
+		}
+
+		if status.Tag() != TagUndefined {
+			n, err := r.toNumber(status)
+			if err != nil {
+				return err
+			}
+
+			if n < 200 || n > 599 || n != float64(int(n)) {
+				return NewRangeError("The status provided (%v) is outside the range [200, 599]", n)
+			}
+
+			res.status = int(n)
+		}
+
+		if statusText, ok, err := stringOption(r, init, "statusText"); err != nil {
+			return err
+		} else if ok {
+			res.statusText = statusText
+		}
+
+		headers, err := init.Get("headers")
+		if err != nil {
+			return err
+		}
+
+		if headers.Tag() != TagUndefined {
+			if err := res.headers.fill(r, headers); err != nil {
+				return err
+			}
+		}
+	}
+
+	if body != nil {
+		if isNullBodyStatus(res.status) {
+			return NewTypeError("Response with null body status cannot have body")
+		}
+
+		res.body = body
+
+		if contentType != "" && !res.headers.has("content-type") {
+			res.headers.list = append(res.headers.list, fetchHeader{name: "content-type", value: contentType})
+		}
+	}
+
+	return nil
+}
+
+// fetchAPI holds the classes of the fetch intrinsic of a realm, which refer
+// to each other.
+type fetchAPI struct {
+	transport http.RoundTripper
+
+	headers  *Class
+	request  *Class
+	response *Class
+}
+
+// headersValue returns the Headers instance of h, which is created once.
+func (api *fetchAPI) headersValue(r *Realm, h *fetchHeaders, cache **Value) (*Value, error) {
+	if *cache == nil {
+		value, err := api.headers.NewInstance(r, h)
+		if err != nil {
+			return nil, err
+		}
+
+		*cache = value
+	}
+
+	return *cache, nil
+}
+
+func (api *fetchAPI) requestSpec() ClassSpec {
+	methods, bodyUsed := bodyMethods(func(this *Value) (*fetchBody, error) {
+		req, err := thisRequest(this)
+		if err != nil {
+			return nil, err
+		}
+
+		return req.body, nil
+	})
+
+	methods["clone"] = func(r *Realm, this *Value) (*Value, error) {
+		req, err := thisRequest(this)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := req.body.clone()
+		if err != nil {
+			return nil, err
+		}
+
+		return api.request.NewInstance(r, &fetchRequest{
+			method:   req.method,
+			url:      req.url,
+			headers:  req.headers.clone(),
+			body:     body,
+			signal:   req.signal,
+			redirect: req.redirect,
+		})
+	}
+
+	property := func(get func(req *fetchRequest) interface{}) ClassProperty {
+		return ClassProperty{
+			Get: func(r *Realm, this *Value) (interface{}, error) {
+				req, err := thisRequest(this)
+				if err != nil {
+					return nil, err
+				}
+
+				return get(req), nil
+			},
+		}
+	}
+
+	return ClassSpec{
+		Name: "Request",
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			return newFetchRequest(r, args)
+		},
+		Length:  1,
+		Methods: methods,
+		Properties: map[string]ClassProperty{
+			"bodyUsed": bodyUsed,
+			"method": property(func(req *fetchRequest) interface{} {
+				return req.method
+			}),
+			"url": property(func(req *fetchRequest) interface{} {
+				return req.url.String()
+			}),
+			"redirect": property(func(req *fetchRequest) interface{} {
+				return req.redirect
+			}),
+			"signal": property(func(req *fetchRequest) interface{} {
+				if req.signal == nil {
+					return NewNull()
+				}
+
+				return req.signal
+			}),
+			"headers": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					req, err := thisRequest(this)
+					if err != nil {
+						return nil, err
+					}
+
+					return api.headersValue(r, req.headers, &req.headersValue)
+				},
+			},
+		},
+	}
+}
+
+func (api *fetchAPI) responseSpec() ClassSpec {
+	methods, bodyUsed := bodyMethods(func(this *Value) (*fetchBody, error) {
+		res, err := thisResponse(this)
+		if err != nil {
+			return nil, err
+		}
+
+		return res.body, nil
+	})
+
+	methods["clone"] = func(r *Realm, this *Value) (*Value, error) {
+		res, err := thisResponse(this)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := res.body.clone()
+		if err != nil {
+			return nil, err
+		}
+
+		c := *res
+		c.headers = res.headers.clone()
+		c.body = body
+		c.headersValue = nil
+
+		return api.response.NewInstance(r, &c)
+	}
+
+	property := func(get func(res *fetchResponse) interface{}) ClassProperty {
+		return ClassProperty{
+			Get: func(r *Realm, this *Value) (interface{}, error) {
+				res, err := thisResponse(this)
+				if err != nil {
+					return nil, err
+				}
+
+				return get(res), nil
+			},
+		}
+	}
+
+	return ClassSpec{
+		Name: "Response",
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			res := &fetchResponse{
+				typ:     "default",
+				status:  200,
+				headers: &fetchHeaders{},
+			}
+
+			var body *fetchBody
+			var contentType string
+			if len(args) > 0 && args[0].Tag() != TagUndefined && args[0].Tag() != TagNull {
+				var err error
+				if body, contentType, err = extractBody(r, args[0]); err != nil {
+					return nil, err
+				}
+			}
+
+			if err := initResponse(r, res, optionalArg(args, 1), body, contentType); err != nil {
+				return nil, err
+			}
+
+			return res, nil
+		},
+		Methods: methods,
+		StaticMethods: map[string]interface{}{
+			"error": func(r *Realm, _ *Value) (*Value, error) {
+				return api.response.NewInstance(r, &fetchResponse{
+					typ:     "error",
+					headers: &fetchHeaders{immutable: true},
+				})
+			},
+			"redirect": func(r *Realm, _ *Value, args ...*Value) (*Value, error) {
+				if len(args) == 0 {
+					return nil, NewTypeError("The \"url\" argument must be specified")
+				}
+
+				s, err := r.toUSVString(args[0])
+				if err != nil {
+					return nil, err
+				}
+
+				u, ok := parseURL(s, nil)
+				if !ok {
+					return nil, NewTypeError("Invalid URL: %s", s)
+				}
+
+				status := 302
+				if len(args) > 1 && args[1].Tag() != TagUndefined {
+					n, err := r.toNumber(args[1])
+					if err != nil {
+						return nil, err
+					}
+
+					status = int(n)
+					if !isRedirectStatus(status) || n != float64(status) {
+						return nil, NewRangeError("Invalid status code %v", n)
+					}
+				}
+
+				return api.response.NewInstance(r, &fetchResponse{
+					typ:    "default",
+					status: status,
+					headers: &fetchHeaders{
+						list:      []fetchHeader{{name: "location", value: u.String()}},
+						immutable: true,
+					},
+				})
+			},
+			"json": func(r *Realm, _ *Value, args ...*Value) (*Value, error) {
+				json, err := r.globalProperty("JSON")
+				if err != nil {
+					return nil, err
+				}
+
+				data, err := json.Invoke("stringify", optionalArg(args, 0))
+				if err != nil {
+					return nil, err
+				}
+
+				if !data.IsString() {
+					return nil, NewTypeError("Value is not JSON serializable")
+				}
+
+				res := &fetchResponse{
+					typ:     "default",
+					status:  200,
+					headers: &fetchHeaders{},
+				}
+
+				if err := initResponse(r, res, optionalArg(args, 1), newBytesBody([]byte(data.ToString())), "application/json"); err != nil {
+					return nil, err
+				}
+
+				return api.response.NewInstance(r, res)
+			},
+		},
+		Properties: map[string]ClassProperty{
+			"body": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					res, err := thisResponse(this)
+					if err != nil {
+						return nil, err
+					}
+
+					return bodyStream(r, res.body)
+				},
+			},
+			"bodyUsed": bodyUsed,
+			"type": property(func(res *fetchResponse) interface{} {
+				return res.typ
+			}),
+			"url": property(func(res *fetchResponse) interface{} {
+				return res.url
+			}),
+			"redirected": property(func(res *fetchResponse) interface{} {
+				return res.redirected
+			}),
+			"status": property(func(res *fetchResponse) interface{} {
+				return res.status
+			}),
+			"ok": property(func(res *fetchResponse) interface{} {
+				return res.status >= 200 && res.status <= 299
+			}),
+			"statusText": property(func(res *fetchResponse) interface{} {
+				return res.statusText
+			}),
+			"headers": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					res, err := thisResponse(this)
+					if err != nil {
+						return nil, err
+					}
+
+					return api.headersValue(r, res.headers, &res.headersValue)
+				},
+			},
+		},
+	}
+}
+
+// abortSignalState returns whether signal is aborted and its reason, which is
+// an AbortError if the signal has no reason.
+func abortSignalState(r *Realm, signal *Value) (bool, *Value, error) {
+	aborted, err := signal.Get("aborted")
+	if err != nil {
+		return false, nil, err
+	}
+
+	isAborted, err := aborted.IsTruthy()
+	if err != nil || !isAborted {
+		return false, nil, err
+	}
+
+	reason, err := signal.Get("reason")
+	if err != nil {
+		return false, nil, err
+	}
+
+	if reason.Tag() == TagUndefined {
+		reason = r.errorValue(newDOMException("AbortError", "This operation was aborted"))
+	}
+
+	return true, reason, nil
+}
+
+var errUnexpectedRedirect = errors.New("unexpected redirect")
+
+// do sends req and returns its response. The response body is read on
+// demand and must be closed.
+func (api *fetchAPI) do(ctx context.Context, req *fetchRequest, body []byte) (*http.Response, bool, error) {
+	u := req.url.clone()
+	u.fragment = nil
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), bodyReader)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, header := range req.headers.list {
+		if header.name == "host" {
+			httpReq.Host = header.value
+			continue
+		}
+
+		httpReq.Header.Add(header.name, header.value)
+	}
+
+	if !req.headers.has("accept") {
+		httpReq.Header.Set("Accept", "*/*")
+	}
+
+	redirected := false
+	client := &http.Client{
+		Transport: api.transport,
+		CheckRedirect: func(_ *http.Request, via []*http.Request) error {
+			switch req.redirect {
+			case "error":
+				return errUnexpectedRedirect
+			case "manual":
+				return http.ErrUseLastResponse
+			}
+
+			if len(via) >= 20 {
+				return errors.New("too many redirects")
+			}
+
+			redirected = true
+			return nil
+		},
+	}
+
+	res, err := client.Do(httpReq)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return res, redirected, nil
+}
+
+// newResponse creates the response of a fetch. finish is called once the
+// body has been read, or immediately if the response has no body.
+func (api *fetchAPI) newResponse(req *fetchRequest, res *http.Response, redirected bool, cancel context.CancelFunc, abort *fetchAbort, finish func()) *fetchResponse {
+	headers := &fetchHeaders{immutable: true}
+
+	names := make([]string, 0, len(res.Header))
+	for name := range res.Header {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range res.Header[name] {
+			headers.list = append(headers.list, fetchHeader{name: strings.ToLower(name), value: value})
+		}
+	}
+
+	response := &fetchResponse{
+		typ:        "basic",
+		url:        res.Request.URL.String(),
+		redirected: redirected,
+		status:     res.StatusCode,
+		statusText: strings.TrimPrefix(res.Status, strconv.Itoa(res.StatusCode)+" "),
+		headers:    headers,
+	}
+
+	if req.method == "HEAD" || isNullBodyStatus(res.StatusCode) {
+		res.Body.Close()
+		cancel()
+		finish()
+	} else {
+		response.body = newReaderBody(res.Body, cancel, abort, finish)
+	}
+
+	return response
+}
+
+func (api *fetchAPI) fetch(r *Realm, _ *Value, args ...*Value) (*Value, error) {
+	promise, resolve, reject := r.NewPromise()
+	if promise == nil {
+		return nil, resolve(nil)
+	}
+
+	req, err := newFetchRequest(r, args)
+	if err != nil {
+		return promise, reject(r.errorValue(err))
+	}
+
+	switch req.url.scheme {
+	case "http", "https":
+	default:
+		return promise, reject(r.errorValue(NewTypeError("fetch failed: scheme %s is not supported", req.url.scheme)))
+	}
+
+	if req.body != nil {
+		if req.body.used {
+			return promise, reject(r.errorValue(NewTypeError("Body has already been used")))
+		}
+
+		req.body.used = true
+	}
+
+	ctx, cancel := context.WithCancel(r.runtime.ctx)
+	abort := &fetchAbort{}
+
+	var removeListener func()
+
+	if req.signal != nil {
+		aborted, reason, err := abortSignalState(r, req.signal)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		if aborted {
+			cancel()
+			return promise, reject(reason)
+		}
+
+		// the listener gets the signal as this rather than holding it, so
+		// that it does not keep the signal alive
+		listener, err := r.NewFunction(func(r *Realm, this *Value) error {
+			_, reason, err := abortSignalState(r, this)
+			if err != nil {
+				return err
+			}
+
+			abort.reason = reason
+			cancel()
+
+			return nil
+		})
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		if addEventListener, err := req.signal.Get("addEventListener"); err != nil {
+			cancel()
+			return nil, err
+		} else if addEventListener.IsFunction() {
+			if _, err := addEventListener.Call(req.signal, "abort", listener); err != nil {
+				cancel()
+				return nil, err
+			}
+
+			signal := req.signal
+			removeListener = func() {
+				removeEventListener, err := signal.Get("removeEventListener")
+				if err == nil && removeEventListener.IsFunction() {
+					_, _ = removeEventListener.Call(signal, "abort", listener)
+				}
+			}
+		}
+	}
+
+	// finish removes the abort listener once the fetch has failed or its
+	// response has been read
+	finish := func() {
+		if removeListener != nil {
+			removeListener()
+			removeListener = nil
+		}
+	}
+
+	r.runtime.goAsync(func(context.Context) func() error {
+		var body []byte
+		var err error
+		if req.body != nil {
+			body, err = req.body.source.read()
+		}
+
+		var res *http.Response
+		var redirected bool
+		if err == nil {
+			res, redirected, err = api.do(ctx, req, body)
+		}
+
+		return func() error {
+			if err != nil {
+				cancel()
+				finish()
+
+				if abort.reason != nil {
+					return reject(abort.reason)
+				}
+
+				return reject(r.errorValue(NewTypeError("fetch failed: %s", err)))
+			}
+
+			response, err := api.response.NewInstance(r, api.newResponse(req, res, redirected, cancel, abort, finish))
+			if err != nil {
+				finish()
+				return reject(r.errorValue(err))
+			}
+
+			return resolve(response)
+		}
+	})
+
+	return promise, nil
+}
+
+// defineFetch defines fetch, Headers, Request and Response as globals of r.
+func (r *Realm) defineFetch(transport http.RoundTripper, opts FetchOptions) error {
+	if err := r.defineDOMException(); err != nil {
+		return err
+	}
+
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	api := &fetchAPI{
+		transport: &hostFilterTransport{
+			transport: transport,
+			options:   opts,
+		},
+	}
+
+	var err error
+	if api.headers, err = r.defineGlobalClass(headersSpec); err != nil {
+		return err
+	}
+
+	if api.request, err = r.defineGlobalClass(api.requestSpec()); err != nil {
+		return err
+	}
+
+	if api.response, err = r.defineGlobalClass(api.responseSpec()); err != nil {
+		return err
+	}
+
+	headersCtor, err := api.headers.Constructor(r)
+	if err != nil {
+		return err
+	}
+
+	headersProto, err := headersCtor.Get("prototype")
+	if err != nil {
+		return err
+	}
+
+	entries, err := headersProto.Get("entries")
+	if err != nil {
+		return err
+	}
+
+	iterator, err := r.wellKnownSymbol("iterator")
+	if err != nil {
+		return err
+	}
+
+	if _, err := headersProto.DefinePropertyAtom(iterator, DefinePropertyValue(entries), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return err
+	}
+
+	if err := r.defineClassToStringTags(api.headers, api.request, api.response); err != nil {
+		return err
+	}
+
+	fetch, err := r.NewFunction(api.fetch)
+	if err != nil {
+		return err
+	}
+
+	global, err := r.GlobalObject()
+	if err != nil {
+		return err
+	}
+
+	_, err = global.DefineProperty("fetch", DefinePropertyValue(fetch), DefinePropertyWritable(true), DefinePropertyConfigurable(true))
+	return err
+}
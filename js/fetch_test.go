@@ -0,0 +1,382 @@
+package js
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("X-Multi", "a")
+		w.Header().Add("X-Multi", "b")
+		io.WriteString(w, `{"ok":true}`)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		w.Header().Set("X-Method", req.Method)
+		w.Header().Set("X-Content-Type", req.Header.Get("Content-Type"))
+		w.Header().Set("X-Custom", req.Header.Get("X-Custom"))
+		w.Write(body)
+	})
+	mux.HandleFunc("/chunks", func(w http.ResponseWriter, req *http.Request) {
+		for _, chunk := range []string{"a", "b", "c"} {
+			io.WriteString(w, chunk)
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "/json", http.StatusFound)
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/hang", func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	})
+	mux.HandleFunc("/hang-body", func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "partial")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newTestFetchRealm(t *testing.T, opts ...RealmOption) (*Realm, *httptest.Server) {
+	t.Helper()
+
+	server := newTestServer(t)
+	r := newTestRealm(t, append([]RealmOption{AddIntrinsicEvents, AddIntrinsicStreams, AddIntrinsicURL, AddIntrinsicFetch(nil)}, opts...)...)
+	mustEval(t, r, `globalThis.base = `+quoteJS(server.URL))
+
+	return r, server
+}
+
+func quoteJS(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func expectAwait(t *testing.T, r *Realm, script, want string) {
+	t.Helper()
+
+	if got := mustAwait(t, r, script).String(); got != want {
+		t.Errorf("%s: got %q, want %q", script, got, want)
+	}
+}
+
+func expectRejection(t *testing.T, r *Realm, script, want string) {
+	t.Helper()
+
+	if _, err := await(r, script); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("%s: got error %v, want %q", script, err, want)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	r, _ := newTestFetchRealm(t)
+
+	expectAwait(t, r, `fetch(base + "/json").then(res => [res.status, res.ok, res.statusText, res.type, res.headers.get("x-multi"), res.headers.get("content-type")].join("|"))`, "200|true|OK|basic|a, b|application/json")
+	expectAwait(t, r, `fetch(base + "/json").then(res => res.json()).then(v => v.ok)`, "true")
+	expectAwait(t, r, `fetch(base + "/redirect").then(res => [res.redirected, res.url.endsWith("/json")].join())`, "true,true")
+	expectAwait(t, r, `fetch(base + "/redirect", {redirect: "manual"}).then(res => res.status)`, "302")
+	expectRejection(t, r, `fetch(base + "/redirect", {redirect: "error"})`, "TypeError: fetch failed")
+	expectAwait(t, r, `fetch(base + "/echo", {method: "POST", body: "hello", headers: {"X-Custom": "1"}}).then(async res => [res.headers.get("x-method"), res.headers.get("x-content-type"), res.headers.get("x-custom"), await res.text()].join("|"))`, "POST|text/plain;charset=UTF-8|1|hello")
+	expectAwait(t, r, `fetch(base + "/echo", {method: "PUT", body: new URLSearchParams({a: "1 2"})}).then(async res => res.headers.get("x-content-type") + "|" + await res.text())`, "application/x-www-form-urlencoded;charset=UTF-8|a=1+2")
+	expectAwait(t, r, `fetch(new Request(base + "/echo", {method: "POST", body: new Uint8Array([104, 105])})).then(res => res.text())`, "hi")
+	expectAwait(t, r, `fetch(base + "/empty").then(res => [res.status, res.body].join())`, "204,")
+
+	expectRejection(t, r, `fetch("data:text/plain,x")`, "scheme data is not supported")
+	expectRejection(t, r, `fetch("not a url")`, "TypeError")
+	expectRejection(t, r, `fetch(base, {method: "CONNECT"})`, "TypeError")
+	expectRejection(t, r, `fetch("http://127.0.0.1:1/")`, "TypeError: fetch failed")
+}
+
+func TestFetchHostFilter(t *testing.T) {
+	server := newTestServer(t)
+	r := newTestRealm(t, AddIntrinsicFetchWithOptions(nil, FetchOptions{AllowHosts: []string{"*.example.com"}}))
+
+	expectRejection(t, r, `fetch(`+quoteJS(server.URL+"/json")+`)`, "TypeError: fetch failed")
+}
+
+func TestFetchHostNormalization(t *testing.T) {
+	allow := FetchOptions{AllowHosts: []string{"*.Example.COM", "bücher.example", "allowed.test."}}
+	for host, want := range map[string]bool{
+		"api.example.com":       true,
+		"API.EXAMPLE.COM":       true,
+		"api.example.com.":      true,
+		"example.com":           true,
+		"badexample.com":        false,
+		"bücher.example":        true,
+		"BÜCHER.example.":       true,
+		"xn--bcher-kva.example": true,
+		"allowed.test":          true,
+		"allowed.test.":         true,
+		"":                      false,
+	} {
+		if got := allow.allowsHost(host); got != want {
+			t.Errorf("AllowHosts: allowsHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	deny := FetchOptions{DenyHosts: []string{"internal.corp", "*.Secret.example.com", "xn--mnchen-3ya.example"}}
+	for host, want := range map[string]bool{
+		"internal.corp":           false,
+		"internal.corp.":          false,
+		"INTERNAL.Corp":           false,
+		"db.secret.example.com":   false,
+		"db.secret.example.com.":  false,
+		"DB.SECRET.example.com":   false,
+		"secret.example.com":      false,
+		"münchen.example":         false,
+		"MÜNCHEN.example.":        false,
+		"xn--mnchen-3ya.example.": false,
+		"public.example.com":      true,
+		"corp":                    true,
+	} {
+		if got := deny.allowsHost(host); got != want {
+			t.Errorf("DenyHosts: allowsHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestFetchBodyUsed(t *testing.T) {
+	r, _ := newTestFetchRealm(t)
+
+	expectAwait(t, r, `fetch(base + "/json").then(async res => {
+		const before = res.bodyUsed;
+		await res.text();
+		let error;
+		try { await res.text() } catch (e) { error = e.name }
+		try { res.clone() } catch (e) { error += " " + e.name }
+		return [before, res.bodyUsed, error].join();
+	})`, "false,true,TypeError TypeError")
+
+	expectAwait(t, r, `fetch(base + "/json").then(async res => {
+		const clone = res.clone();
+		return [await res.text(), await clone.text()].join("|");
+	})`, `{"ok":true}|{"ok":true}`)
+}
+
+func TestFetchResponseBody(t *testing.T) {
+	r, _ := newTestFetchRealm(t, AddIntrinsicTextEncoding)
+
+	expectAwait(t, r, `fetch(base + "/chunks").then(async res => {
+		if (!(res.body instanceof ReadableStream) || res.body !== res.body) {
+			throw new Error("expected the same stream");
+		}
+
+		const decoder = new TextDecoder();
+		let text = "";
+		for await (const chunk of res.body) {
+			if (!(chunk instanceof Uint8Array)) {
+				throw new Error("expected Uint8Arrays");
+			}
+
+			text += decoder.decode(chunk, {stream: true});
+		}
+
+		let error;
+		try { await res.text() } catch (e) { error = e.name }
+
+		return [text, res.bodyUsed, error].join();
+	})`, "abc,true,TypeError")
+
+	// reading the body locks its stream
+	expectAwait(t, r, `fetch(base + "/json").then(async res => {
+		const body = res.body;
+		const text = await res.text();
+		return [text, body.locked, res.bodyUsed].join("|");
+	})`, `{"ok":true}|true|true`)
+
+	// the stream of a consumed body is locked
+	expectAwait(t, r, `fetch(base + "/json").then(async res => {
+		await res.text();
+		return res.body.locked;
+	})`, "true")
+
+	// bodies that are read from their stream cannot be read again
+	expectAwait(t, r, `fetch(base + "/json").then(async res => {
+		const reader = res.body.getReader();
+		await reader.read();
+		let error;
+		try { await res.json() } catch (e) { error = e.name }
+		return error;
+	})`, "TypeError")
+
+	// clones can be streamed independently
+	expectAwait(t, r, `fetch(base + "/chunks").then(async res => {
+		const clone = res.clone();
+		const decoder = new TextDecoder();
+		let text = "";
+		for await (const chunk of res.body) {
+			text += decoder.decode(chunk, {stream: true});
+		}
+
+		return [text, await clone.text()].join();
+	})`, "abc,abc")
+
+	expectAwait(t, r, `new Response("constructed").body.getReader().read().then(({value}) => new TextDecoder().decode(value))`, "constructed")
+	expectAwait(t, r, `Promise.resolve(new Response(null).body)`, "null")
+
+	// cancelling the stream releases the request
+	expectAwait(t, r, `fetch(base + "/hang-body").then(async res => {
+		const reader = res.body.getReader();
+		const {value} = await reader.read();
+		await reader.cancel();
+		return new TextDecoder().decode(value);
+	})`, "partial")
+}
+
+func TestFetchResponseBodyWithoutStreams(t *testing.T) {
+	server := newTestServer(t)
+	r := newTestRealm(t, AddIntrinsicFetch(nil))
+
+	expectAwait(t, r, `fetch(`+quoteJS(server.URL+"/json")+`).then(async res => [typeof res.body, await res.text()].join())`, `undefined,{"ok":true}`)
+}
+
+func TestFetchAbort(t *testing.T) {
+	r, _ := newTestFetchRealm(t)
+
+	expectRejection(t, r, `fetch(base + "/json", {signal: AbortSignal.abort()})`, "AbortError")
+	expectRejection(t, r, `fetch(base + "/json", {signal: AbortSignal.abort(new RangeError("reason"))})`, "RangeError: reason")
+
+	expectRejection(t, r, `(() => {
+		const controller = new AbortController();
+		const promise = fetch(base + "/hang", {signal: controller.signal});
+		Promise.resolve().then(() => controller.abort());
+		return promise;
+	})()`, "AbortError")
+
+	// aborting while the body is read fails the read
+	expectRejection(t, r, `(async () => {
+		const controller = new AbortController();
+		const res = await fetch(base + "/hang-body", {signal: controller.signal});
+		const text = res.text();
+		controller.abort(new TypeError("stopped"));
+		return text;
+	})()`, "TypeError: stopped")
+
+	expectRejection(t, r, `(async () => {
+		const controller = new AbortController();
+		const res = await fetch(base + "/hang-body", {signal: controller.signal});
+		const reader = res.body.getReader();
+		await reader.read();
+		controller.abort(new TypeError("stopped stream"));
+		return reader.read();
+	})()`, "TypeError: stopped stream")
+}
+
+func TestFetchRemovesAbortListener(t *testing.T) {
+	r, _ := newTestFetchRealm(t)
+
+	mustEval(t, r, `
+		globalThis.trackListeners = signal => {
+			signal.listeners = 0;
+			signal.addEventListener = function (...args) {
+				this.listeners++;
+				return Reflect.apply(AbortSignal.prototype.addEventListener, this, args);
+			};
+			signal.removeEventListener = function (...args) {
+				this.listeners--;
+				return Reflect.apply(AbortSignal.prototype.removeEventListener, this, args);
+			};
+			return signal;
+		};
+	`)
+
+	for _, script := range []string{
+		// the response is read
+		`(async () => {
+			const signal = trackListeners(new AbortController().signal);
+			const res = await fetch(base + "/json", {signal});
+			const during = signal.listeners;
+			await res.text();
+			return during + "," + signal.listeners;
+		})()`,
+		// the response is streamed
+		`(async () => {
+			const signal = trackListeners(new AbortController().signal);
+			const res = await fetch(base + "/chunks", {signal});
+			const during = signal.listeners;
+			for await (const chunk of res.body) {}
+			return during + "," + signal.listeners;
+		})()`,
+		// the stream is cancelled
+		`(async () => {
+			const signal = trackListeners(new AbortController().signal);
+			const res = await fetch(base + "/hang-body", {signal});
+			const during = signal.listeners;
+			await res.body.cancel();
+			return during + "," + signal.listeners;
+		})()`,
+	} {
+		expectAwait(t, r, script, "1,0")
+	}
+
+	// the fetch fails
+	expectAwait(t, r, `(async () => {
+		const signal = trackListeners(new AbortController().signal);
+		await fetch("http://127.0.0.1:1/", {signal}).catch(() => {});
+		return signal.listeners;
+	})()`, "0")
+
+	// the response has no body
+	expectAwait(t, r, `(async () => {
+		const signal = trackListeners(new AbortController().signal);
+		await fetch(base + "/empty", {signal});
+		return signal.listeners;
+	})()`, "0")
+}
+
+func TestFetchClasses(t *testing.T) {
+	r, _ := newTestFetchRealm(t)
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`(() => { const h = new Headers({"Content-Type": "a"}); h.append("x-b", "1"); h.append("X-B", "2"); return [...h].join(";") })()`, "content-type,a;x-b,1, 2"},
+		{`(() => { const h = new Headers([["set-cookie", "a"], ["set-cookie", "b"]]); return h.getSetCookie().join() + "|" + [...h].length })()`, "a,b|2"},
+		{`new Headers({a: " padded "}).get("a")`, "padded"},
+		{`(() => { const req = new Request("http://h/p", {method: "post", headers: {a: "1"}}); return [req.method, req.url, req.headers.get("a"), req.redirect].join() })()`, "POST,http://h/p,1,follow"},
+		{`new Request(new Request("http://h/", {method: "PUT"})).method`, "PUT"},
+		{`(() => { const res = new Response("x", {status: 201, statusText: "Made", headers: {"x": "y"}}); return [res.status, res.statusText, res.headers.get("x"), res.headers.get("content-type")].join() })()`, "201,Made,y,text/plain;charset=UTF-8"},
+		{`(() => { const res = Response.redirect("http://h/", 301); return [res.status, res.headers.get("location")].join() })()`, "301,http://h/"},
+		{`[Response.error().type, Response.error().status].join()`, "error,0"},
+		{`Object.prototype.toString.call(new Headers())`, "[object Headers]"},
+	} {
+		expectString(t, r, test.script, test.want)
+	}
+
+	expectAwait(t, r, `Response.json({a: 1}).text()`, `{"a":1}`)
+
+	for _, script := range []string{
+		`new Headers({"bad name": "x"})`,
+		`Response.error().headers.set("a", "b")`,
+		`new Request("http://h/", {method: "GET", body: "x"})`,
+		`new Response("x", {status: 204})`,
+		`new Response(null, {status: 99})`,
+		`Response.redirect("http://h/", 200)`,
+		`Response.json(undefined)`,
+	} {
+		if _, err := r.Eval(script); err == nil {
+			t.Errorf("%s: expected an error", script)
+		}
+	}
+}
+
+func TestFetchDOMException(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicFetch(nil))
+
+	expectString(t, r, `typeof DOMException`, "function")
+}
@@ -1,9 +1,12 @@
 package js
 
 import (
+	"context"
 	"fmt"
+	"math/big"
 	"reflect"
 	"runtime"
+	"time"
 
 	"github.com/ssttevee/go-quickjs/internal"
 )
@@ -28,6 +31,10 @@ var (
 	functionType   = reflect.TypeOf((*Function)(nil))
 	typedValueType = reflect.TypeOf((*TypedValue)(nil)).Elem()
 	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+	jsValuerType   = reflect.TypeOf((*JSValuer)(nil)).Elem()
+	timeType       = reflect.TypeOf(time.Time{})
+	bigIntType     = reflect.TypeOf((*big.Int)(nil))
+	contextType    = reflect.TypeOf((*context.Context)(nil)).Elem()
 )
 
 // type Function func(r *Realm, thisValue *Value, args []*Value) (*Value, error)
@@ -61,23 +68,88 @@ func jsToReflect(r *Realm, v *Value, t reflect.Type) (reflect.Value, error) {
 		return argValue, nil
 	}
 
+	if goValue, ok := v.goValue(); ok {
+		// pass go values through as is
+		goReflectValue := reflect.ValueOf(goValue)
+		if goReflectValue.Type().AssignableTo(t) {
+			return goReflectValue, nil
+		}
+
+		if goReflectValue.Kind() == reflect.Ptr && goReflectValue.Elem().Type().AssignableTo(t) {
+			return goReflectValue.Elem(), nil
+		}
+	}
+
+	switch t {
+	case timeType:
+		if ok, err := r.isInstanceOfGlobal(v, "Date"); err != nil {
+			return reflect.Value{}, err
+		} else if !ok {
+			return reflect.Value{}, &InvalidTypeError{Type: t}
+		}
+
+		date, err := exportDate(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		return reflect.ValueOf(date), nil
+
+	case bigIntType:
+		if !v.IsBigInt() {
+			return reflect.Value{}, &InvalidTypeError{Type: t}
+		}
+
+		return reflect.ValueOf(v.ToBigInt()), nil
+	}
+
 	switch t.Kind() {
 	case reflect.String:
 		if !v.IsString() {
 			return reflect.Value{}, &InvalidTypeError{Type: t}
 		}
 
-		return reflect.ValueOf(v.ToString()), nil
+		return reflect.ValueOf(v.ToString()).Convert(t), nil
 
-	case reflect.Int:
-		if !v.IsNumber() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := reflect.New(t).Elem()
+		switch {
+		case v.IsInt():
+			n.SetInt(int64(v.ToInt()))
+
+		case v.IsNumber():
+			n.SetInt(int64(v.ToFloat()))
+
+		case v.IsBigInt():
+			n.SetInt(v.ToBigInt().Int64())
+
+		default:
 			return reflect.Value{}, &InvalidTypeError{Type: t}
 		}
 
-		return reflect.ValueOf(v.ToInt()), nil
+		return n, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := reflect.New(t).Elem()
+		switch {
+		case v.IsNumber():
+			n.SetUint(uint64(v.ToFloat()))
+
+		case v.IsBigInt():
+			n.SetUint(v.ToBigInt().Uint64())
+
+		default:
+			return reflect.Value{}, &InvalidTypeError{Type: t}
+		}
+
+		return n, nil
+
+	case reflect.Float32, reflect.Float64:
+		if !v.IsNumber() {
+			return reflect.Value{}, &InvalidTypeError{Type: t}
+		}
 
-	case reflect.Float64:
-		return reflect.ValueOf(v.ToFloat()), nil
+		return reflect.ValueOf(v.ToFloat()).Convert(t), nil
 
 	case reflect.Bool:
 		b, err := v.IsTruthy()
@@ -85,11 +157,19 @@ func jsToReflect(r *Realm, v *Value, t reflect.Type) (reflect.Value, error) {
 			return reflect.Value{}, err
 		}
 
-		return reflect.ValueOf(b), nil
+		return reflect.ValueOf(b).Convert(t), nil
 
 	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 && v.IsObject() {
+			if data, ok, err := v.bytes(); err != nil {
+				return reflect.Value{}, err
+			} else if ok {
+				return reflect.ValueOf(data).Convert(t), nil
+			}
+		}
+
 		if !v.IsArray() {
-			return reflect.Value{}, &InvalidTypeError{Type: t}
+			return iterableToSlice(r, v, t)
 		}
 
 		lengthValue, err := v.Get("length")
@@ -117,12 +197,123 @@ func jsToReflect(r *Realm, v *Value, t reflect.Type) (reflect.Value, error) {
 		}
 
 		return slice, nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			break
+		}
+
+		if !v.IsObject() {
+			return reflect.Value{}, &InvalidTypeError{Type: t}
+		}
+
+		m := reflect.MakeMap(t)
+		err := v.forEachOwnEnumerableProperty(func(key string, elem *Value) error {
+			elemValue, err := jsToReflect(r, elem, t.Elem())
+			if err != nil {
+				return err
+			}
+
+			m.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elemValue)
+
+			return nil
+		})
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		return m, nil
+
+	case reflect.Ptr:
+		switch v.Tag() {
+		case TagNull, TagUndefined:
+			return reflect.Zero(t), nil
+		}
+
+		elemValue, err := jsToReflect(r, v, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elemValue)
+
+		return ptr, nil
+
+	case reflect.Struct:
+		if !v.IsObject() {
+			return reflect.Value{}, &InvalidTypeError{Type: t}
+		}
+
+		s := reflect.New(t).Elem()
+		for _, field := range getStructInfo(t).fields {
+			prop, err := v.Get(field.name)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			if prop.Tag() == TagUndefined {
+				continue
+			}
+
+			fieldValue, err := jsToReflect(r, prop, field.typ)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			s.FieldByIndex(field.index).Set(fieldValue)
+		}
+
+		return s, nil
+
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			break
+		}
+
+		// symbols have no go representation, so they are passed as they are
+		var exported interface{} = v
+		if !v.IsSymbol() {
+			var err error
+			if exported, err = v.Export(); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+
+		return reflect.ValueOf(&exported).Elem(), nil
 	}
 
 	panic(fmt.Sprintf("unexpected arg type: %s", t))
 }
 
-func prepareReflectCallArgs(r *Realm, thisValue internal.Value, args []internal.Value, argTypes []reflect.Type, variadic bool) ([]reflect.Value, error) {
+// iterableToSlice collects the values of an iterable that is not an array,
+// such as a Set or a generator.
+func iterableToSlice(r *Realm, v *Value, t reflect.Type) (reflect.Value, error) {
+	if ok, err := v.isIterable(); err != nil {
+		return reflect.Value{}, err
+	} else if !ok {
+		return reflect.Value{}, &InvalidTypeError{Type: t}
+	}
+
+	slice := reflect.MakeSlice(t, 0, 0)
+	err := v.Iterate(func(elem *Value) (bool, error) {
+		elemValue, err := jsToReflect(r, elem, t.Elem())
+		if err != nil {
+			return false, err
+		}
+
+		slice = reflect.Append(slice, elemValue)
+
+		return true, nil
+	})
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	return slice, nil
+}
+
+func prepareReflectCallArgs(r *Realm, args []internal.Value, argTypes []reflect.Type, variadic bool, preArgs ...reflect.Value) ([]reflect.Value, error) {
 	minArgs := len(argTypes)
 	numCallArgs := len(argTypes)
 
@@ -141,7 +332,7 @@ func prepareReflectCallArgs(r *Realm, thisValue internal.Value, args []internal.
 		}
 	}
 
-	const argsOffset = 2
+	argsOffset := len(preArgs)
 	callArgs := make([]reflect.Value, numCallArgs+argsOffset)
 
 	for i, arg := range args {
@@ -166,43 +357,88 @@ func prepareReflectCallArgs(r *Realm, thisValue internal.Value, args []internal.
 		}
 	}
 
-	callArgs[0] = reflect.ValueOf(r)
-	callArgs[1] = reflect.ValueOf(r.createValue(internal.DupValue(r.context, thisValue)))
+	copy(callArgs, preArgs)
 
 	return callArgs, nil
 }
 
-func (r *Realm) NewFunction(f interface{}) (*Value, error) {
-	fValue := reflect.ValueOf(f)
-	fType := fValue.Type()
-	if fType.Kind() != reflect.Func {
-		panic(fmt.Errorf("f must be a function, got %s", fType.Kind()))
+// reflectValue converts the return values of a go function to a js value. A
+// trailing non-nil error is returned as is.
+func (r *Realm) reflectValue(out []reflect.Value) (*Value, error) {
+	if n := len(out); n > 0 && out[n-1].Type() == errorType {
+		if err, ok := out[n-1].Interface().(error); ok && err != nil {
+			return nil, err
+		}
+
+		out = out[:n-1]
 	}
 
-	const numPreArgs = 2
+	if len(out) == 0 || out[0].Kind() == reflect.Ptr && out[0].IsNil() {
+		return NewUndefined(), nil
+	}
+
+	return r.Convert(out[0].Interface())
+}
+
+// reflectResult converts the return values of a go function to the result of
+// a js function call. A trailing non-nil error is thrown.
+func (r *Realm) reflectResult(out []reflect.Value) internal.Value {
+	result, err := r.reflectValue(out)
+	if err != nil {
+		return r.throw(err)
+	}
+
+	defer runtime.KeepAlive(result)
+
+	return internal.DupValue(r.context, result.value)
+}
+
+// checkFunction returns an error if f is not of a form that is accepted by
+// NewFunction.
+func checkFunction(f reflect.Value) error {
+	if f.Kind() != reflect.Func {
+		return fmt.Errorf("f must be a function, got %s", f.Kind())
+	}
+
+	fType := f.Type()
 
 	numArgs := fType.NumIn()
-	if numArgs < numPreArgs {
-		panic(fmt.Errorf("f must have at least 2 args, got %d", numArgs))
+	if numArgs < 2 {
+		return fmt.Errorf("f must have at least 2 args, got %d", numArgs)
 	}
 
 	if fType.In(0) != realmType {
-		panic(fmt.Errorf("first arg of f must be *Realm, got %s", fType.In(0)))
+		return fmt.Errorf("first arg of f must be *Realm, got %s", fType.In(0))
 	}
 
 	if fType.In(1) != valueType {
-		panic(fmt.Errorf("second arg of f must be *Value, got %s", fType.In(1)))
+		return fmt.Errorf("second arg of f must be *Value, got %s", fType.In(1))
 	}
 
 	numOut := fType.NumOut()
 	if numOut > 2 {
-		panic(fmt.Errorf("f must have at most 2 return values, got %d", numOut))
+		return fmt.Errorf("f must have at most 2 return values, got %d", numOut)
 	}
 
 	if numOut > 0 && fType.Out(numOut-1) != errorType {
-		panic(fmt.Errorf("last return value f must be error, got %s", fType.Out(numOut-1)))
+		return fmt.Errorf("last return value f must be error, got %s", fType.Out(numOut-1))
+	}
+
+	return nil
+}
+
+func (r *Realm) NewFunction(f interface{}) (*Value, error) {
+	fValue := reflect.ValueOf(f)
+	if err := checkFunction(fValue); err != nil {
+		panic(err)
 	}
 
+	fType := fValue.Type()
+
+	const numPreArgs = 2
+
+	numArgs := fType.NumIn()
+
 	argTypes := make([]reflect.Type, numArgs-numPreArgs)
 	for i := numPreArgs; i < numArgs; i++ {
 		argTypes[i-numPreArgs] = fType.In(i)
@@ -210,29 +446,126 @@ func (r *Realm) NewFunction(f interface{}) (*Value, error) {
 
 	variadic := fType.IsVariadic()
 
-	return r.createAndResolveValue(internal.NewFunction(r.context, func(ctx *internal.Context, thisValue internal.Value, args []internal.Value) internal.Value {
-		callArgs, err := prepareReflectCallArgs(r, thisValue, args, argTypes, variadic)
+	return r.createAndResolveValue(internal.NewFunction(r.context, func(ctx *internal.Context, thisValue internal.Value, args []internal.Value) (result internal.Value) {
+		defer recoverPanic(func(err error) {
+			result = r.throw(err)
+		})
+
+		callArgs, err := prepareReflectCallArgs(r, args, argTypes, variadic, reflect.ValueOf(r), reflect.ValueOf(r.createValue(internal.DupValue(ctx, thisValue))))
 		if err != nil {
 			return r.throw(err)
 		}
 
-		out := fValue.Call(callArgs)
-		if numOut > 0 {
-			if err, ok := out[numOut-1].Interface().(error); ok && err != nil {
-				errObj := Must(r.Convert(err))
-				defer runtime.KeepAlive(errObj)
+		return r.reflectResult(fValue.Call(callArgs))
+	}))
+}
+
+// NewAsyncFunction creates a function that calls f on a new goroutine and
+// immediately returns a promise that settles with its result.
+//
+// f must be of the form func(ctx context.Context, r *Realm, this *Value, args...) (T, error)
+// or func(ctx context.Context, r *Realm, this *Value, args...) error. The
+// arguments are converted before f is called, as with NewFunction. The
+// context is cancelled when the runtime is closed.
+//
+// Since f runs outside of the event loop, it must not use the realm or the
+// values it is given other than through methods that are safe to call from
+// other goroutines, such as CallAsync.
+func (r *Realm) NewAsyncFunction(f interface{}) (*Value, error) {
+	fValue := reflect.ValueOf(f)
+	fType := fValue.Type()
+	if fType.Kind() != reflect.Func {
+		panic(fmt.Errorf("f must be a function, got %s", fType.Kind()))
+	}
 
-				return internal.Throw(ctx, errObj.value)
-			}
+	const numPreArgs = 3
+
+	numArgs := fType.NumIn()
+	if numArgs < numPreArgs {
+		panic(fmt.Errorf("f must have at least 3 args, got %d", numArgs))
+	}
+
+	if fType.In(0) != contextType {
+		panic(fmt.Errorf("first arg of f must be context.Context, got %s", fType.In(0)))
+	}
+
+	if fType.In(1) != realmType {
+		panic(fmt.Errorf("second arg of f must be *Realm, got %s", fType.In(1)))
+	}
+
+	if fType.In(2) != valueType {
+		panic(fmt.Errorf("third arg of f must be *Value, got %s", fType.In(2)))
+	}
+
+	numOut := fType.NumOut()
+	if numOut < 1 || numOut > 2 {
+		panic(fmt.Errorf("f must have 1 or 2 return values, got %d", numOut))
+	}
+
+	if fType.Out(numOut-1) != errorType {
+		panic(fmt.Errorf("last return value f must be error, got %s", fType.Out(numOut-1)))
+	}
+
+	argTypes := make([]reflect.Type, numArgs-numPreArgs)
+	for i := numPreArgs; i < numArgs; i++ {
+		argTypes[i-numPreArgs] = fType.In(i)
+	}
+
+	variadic := fType.IsVariadic()
+
+	return r.createAndResolveValue(internal.NewFunction(r.context, func(ctx *internal.Context, thisValue internal.Value, args []internal.Value) (result internal.Value) {
+		defer recoverPanic(func(err error) {
+			result = r.throw(err)
+		})
+
+		promise, resolve, reject := r.NewPromise()
+		if promise == nil {
+			// the settling functions return the error that prevented the
+			// promise from being created
+			return r.throw(resolve(nil))
 		}
 
-		if numOut < 2 || out[0].Kind() == reflect.Ptr && out[0].IsNil() {
-			return internal.Undefined
+		callArgs, err := prepareReflectCallArgs(r, args, argTypes, variadic, reflect.Value{}, reflect.ValueOf(r), reflect.ValueOf(r.createValue(internal.DupValue(ctx, thisValue))))
+		if err != nil {
+			if err := reject(r.errorValue(err)); err != nil {
+				return r.throw(err)
+			}
+
+			return internal.DupValue(ctx, promise.value)
 		}
 
-		result := Must(r.Convert(out[0].Interface()))
-		defer runtime.KeepAlive(result)
+		r.runtime.goAsync(func(ctx context.Context) func() error {
+			callArgs[0] = reflect.ValueOf(&ctx).Elem()
+
+			var (
+				out      []reflect.Value
+				panicErr error
+			)
+
+			func() {
+				defer recoverPanic(func(err error) {
+					panicErr = err
+				})
+
+				out = fValue.Call(callArgs)
+			}()
+
+			return func() error {
+				if panicErr != nil {
+					return reject(r.errorValue(panicErr))
+				}
+
+				result, err := r.reflectValue(out)
+				if err != nil {
+					return reject(r.errorValue(err))
+				}
+
+				return resolve(result)
+			}
+		})
+
+		defer runtime.KeepAlive(promise)
 
-		return internal.DupValue(ctx, result.value)
+		return internal.DupValue(ctx, promise.value)
 	}))
 }
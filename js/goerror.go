@@ -0,0 +1,86 @@
+package js
+
+import (
+	"runtime"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+// goErrorSymbolDescription is the description of the symbol that holds the go
+// error of thrown objects.
+const goErrorSymbolDescription = "go-quickjs.error"
+
+// newGoErrorSymbol creates the key of the hidden property that holds the go
+// error of thrown objects. The symbol is not registered, so scripts cannot
+// look it up with Symbol.for. It must be created before any script runs, since
+// scripts may replace the Symbol global.
+func (r *Realm) newGoErrorSymbol() (internal.Atom, error) {
+	symbol, err := r.globalProperty("Symbol")
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := symbol.Call(nil, goErrorSymbolDescription)
+	if err != nil {
+		return 0, err
+	}
+
+	defer runtime.KeepAlive(key)
+
+	atom := internal.ValueToAtom(r.context, key.value)
+	if atom == 0 {
+		return 0, r.getError()
+	}
+
+	return atom, nil
+}
+
+func (r *Realm) goErrorAtom() *Atom {
+	return r.createAtom(internal.DupAtom(r.context, r.goErrorSymbol))
+}
+
+// attachGoError stores err in a hidden property of v, if v is an error object.
+func (r *Realm) attachGoError(v *Value, err error) error {
+	if !internal.IsError(r.context, v.value) {
+		return nil
+	}
+
+	holder, attachErr := r.createAndResolveValue(internal.NewObjectWithOpaque(r.context, internal.Null, err))
+	if attachErr != nil {
+		return attachErr
+	}
+
+	_, attachErr = v.DefinePropertyAtom(r.goErrorAtom(), DefinePropertyValue(holder))
+	return attachErr
+}
+
+// goError returns the go error that was thrown as e, if any. Only own data
+// properties of error objects are looked up, which are never proxies, so that
+// looking up the go error does not run any script.
+func (e *Error) goError() error {
+	v := (*Value)(e)
+	if !internal.IsError(v.realm.context, v.value) {
+		return nil
+	}
+
+	defer runtime.KeepAlive(v)
+
+	ctx := v.realm.context
+	desc, result := internal.GetOwnProperty(ctx, v.value, v.realm.goErrorSymbol)
+	if result == -1 {
+		internal.FreeValue(ctx, internal.GetException(ctx))
+		return nil
+	}
+
+	defer internal.FreeValue(ctx, desc.Value)
+	defer internal.FreeValue(ctx, desc.Getter)
+	defer internal.FreeValue(ctx, desc.Setter)
+
+	if result != 1 || desc.IsAccessor() || desc.Value.Tag() != internal.TagObject {
+		return nil
+	}
+
+	goErr, _ := internal.GetOpaque(desc.Value)
+	err, _ := goErr.(error)
+	return err
+}
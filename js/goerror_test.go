@@ -0,0 +1,112 @@
+package js
+
+import (
+	"errors"
+	"testing"
+)
+
+var errSentinel = errors.New("sentinel")
+
+func TestGoErrorIdentity(t *testing.T) {
+	r := newTestRealm(t)
+
+	fail, err := r.NewFunction(func(r *Realm, this *Value) error {
+		return errSentinel
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "fail", fail)
+
+	for _, script := range []string{
+		`fail()`,
+		`try { fail() } catch (e) { throw e }`,
+		`(() => { try { fail() } catch (e) { globalThis.caught = e } })(); throw caught`,
+	} {
+		if _, err := r.Eval(script); !errors.Is(err, errSentinel) {
+			t.Errorf("%s: got error %v, want %v", script, err, errSentinel)
+		}
+	}
+
+	// errors that are created in js do not unwrap to a go error
+	if _, err := r.Eval(`throw new Error("sentinel")`); errors.Is(err, errSentinel) {
+		t.Error("expected a js error not to unwrap to the go error")
+	}
+}
+
+func TestGoErrorHidden(t *testing.T) {
+	r := newTestRealm(t)
+
+	fail, err := r.NewFunction(func(r *Realm, this *Value) error {
+		return errSentinel
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "fail", fail)
+
+	expectString(t, r, `try { fail() } catch (e) { e[Symbol.for("go-quickjs.error")] === undefined }`, "true")
+
+	// scripts cannot make their own errors unwrap to a go error
+	if _, err := r.Eval(`
+		const forged = new Error("forged");
+		forged[Symbol.for("go-quickjs.error")] = {};
+		throw forged
+	`); errors.Is(err, errSentinel) {
+		t.Error("expected the forged error not to unwrap to the go error")
+	}
+
+	// replacing the Symbol global does not affect the hidden property
+	mustEval(t, r, `globalThis.Symbol = undefined`)
+	if _, err := r.Eval(`fail()`); !errors.Is(err, errSentinel) {
+		t.Errorf("got error %v, want %v", err, errSentinel)
+	}
+}
+
+func TestGoErrorOwnProperty(t *testing.T) {
+	r := newTestRealm(t)
+
+	fail, err := r.NewFunction(func(r *Realm, this *Value) error {
+		return errSentinel
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "fail", fail)
+	mustEval(t, r, `try { fail() } catch (e) { globalThis.caught = e }`)
+
+	// objects that inherit from or wrap an error do not unwrap to its go error
+	for _, script := range []string{
+		`throw Object.create(caught)`,
+		`throw new Proxy(caught, {})`,
+	} {
+		if _, err := r.Eval(script); errors.Is(err, errSentinel) {
+			t.Errorf("%s: expected the error not to unwrap to the go error", script)
+		}
+	}
+
+	// looking up the go error does not run proxy traps
+	_, err = r.Eval(`
+		globalThis.trapped = false;
+		throw new Proxy(caught, {
+			get(target, key) { trapped = true; return Reflect.get(target, key) },
+			getOwnPropertyDescriptor(target, key) { trapped = true; return Reflect.getOwnPropertyDescriptor(target, key) },
+			getPrototypeOf(target) { trapped = true; return Reflect.getPrototypeOf(target) },
+		})
+	`)
+
+	var jsErr *Error
+	if !errors.As(err, &jsErr) {
+		t.Fatalf("got error %v, want an *Error", err)
+	}
+
+	mustEval(t, r, `trapped = false`)
+	if goErr := jsErr.goError(); goErr != nil {
+		t.Errorf("goError() = %v, want nil", goErr)
+	}
+
+	expectString(t, r, `trapped`, "false")
+}
@@ -0,0 +1,771 @@
+package js
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+type structField struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+type structInfo struct {
+	fields []*structField
+	byName map[string]*structField
+}
+
+var structInfos sync.Map
+
+// getStructInfo returns the fields of a struct type that are visible to js.
+//
+// Exported fields are visible by their go name unless renamed with a `js`
+// struct tag. Fields tagged with `js:"-"` are hidden. Fields of embedded
+// structs are promoted by the same rules as go selectors.
+func getStructInfo(t reflect.Type) *structInfo {
+	if info, ok := structInfos.Load(t); ok {
+		return info.(*structInfo)
+	}
+
+	info := &structInfo{
+		byName: map[string]*structField{},
+	}
+
+	info.addFields(t)
+
+	structInfos.Store(t, info)
+
+	return info
+}
+
+// addFields adds the fields of t one depth of embedding at a time. A field
+// shadows the fields of the same name at greater depths, while fields of the
+// same name at the same depth are ambiguous and hide each other along with
+// those at greater depths.
+func (info *structInfo) addFields(t reflect.Type) {
+	type embeddedStruct struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	visited := map[reflect.Type]bool{}
+
+	// names that are taken at a smaller depth
+	taken := map[string]bool{}
+
+	for current := []embeddedStruct{{typ: t}}; len(current) > 0; {
+		var next []embeddedStruct
+		var fields []*structField
+		count := map[string]int{}
+
+		for _, embedded := range current {
+			if visited[embedded.typ] {
+				continue
+			}
+
+			for i := 0; i < embedded.typ.NumField(); i++ {
+				field := embedded.typ.Field(i)
+				index := append(append([]int{}, embedded.index...), i)
+
+				name := field.Name
+				if tag, ok := field.Tag.Lookup("js"); ok {
+					name = strings.Split(tag, ",")[0]
+					if name == "-" {
+						continue
+					}
+				} else if field.Anonymous && field.Type.Kind() == reflect.Struct {
+					// the embedded struct itself is not visible, but it
+					// still shadows promoted fields of the same name
+					count[name]++
+					next = append(next, embeddedStruct{typ: field.Type, index: index})
+					continue
+				}
+
+				count[name]++
+
+				if field.PkgPath != "" {
+					// unexported
+					continue
+				}
+
+				fields = append(fields, &structField{
+					name:  name,
+					index: index,
+					typ:   field.Type,
+				})
+			}
+		}
+
+		for _, f := range fields {
+			if taken[f.name] || count[f.name] > 1 {
+				continue
+			}
+
+			info.fields = append(info.fields, f)
+			info.byName[f.name] = f
+		}
+
+		for name := range count {
+			taken[name] = true
+		}
+
+		for _, embedded := range current {
+			visited[embedded.typ] = true
+		}
+
+		current = next
+	}
+
+	// enumerate fields in the order that they are declared
+	sort.Slice(info.fields, func(i, j int) bool {
+		a, b := info.fields[i].index, info.fields[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+
+		return len(a) < len(b)
+	})
+}
+
+// goWrapperKey identifies a go value by its address and the type of pointer
+// to it, since a struct shares its address with its first field.
+type goWrapperKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// goWrapper removes the object that exposes a go value from the cache of its
+// realm when it is freed.
+type goWrapper struct {
+	realm *Realm
+	key   goWrapperKey
+
+	// writeBack, if not nil, stores the go value back to where it was copied
+	// from after js changes it, such as an entry of a map, which is not
+	// addressable.
+	writeBack func()
+}
+
+// changed is called after js changes the go value.
+func (w *goWrapper) changed() {
+	if w.writeBack != nil {
+		w.writeBack()
+	}
+}
+
+func (w *goWrapper) finalize() {
+	w.realm.goWrappersMutex.Lock()
+	defer w.realm.goWrappersMutex.Unlock()
+
+	delete(w.realm.goWrappers, w.key)
+}
+
+// goWrapper returns the object that exposes the go value that ptr points to,
+// calling create to create it if there is none yet.
+func (r *Realm) goWrapper(ptr reflect.Value, create func(w goWrapper) (*Value, error)) (*Value, error) {
+	key := goWrapperKey{
+		addr: ptr.Pointer(),
+		typ:  ptr.Type(),
+	}
+
+	r.goWrappersMutex.Lock()
+	if object, ok := r.goWrappers[key]; ok {
+		object = internal.DupValue(r.context, object)
+		r.goWrappersMutex.Unlock()
+
+		return r.createValue(object), nil
+	}
+	r.goWrappersMutex.Unlock()
+
+	object, err := create(goWrapper{
+		realm: r,
+		key:   key,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.goWrappersMutex.Lock()
+	r.goWrappers[key] = object.value
+	r.goWrappersMutex.Unlock()
+
+	return object, nil
+}
+
+// convertAddressable is like Convert, but exposes structs, slices and maps
+// with string keys such that changes made by js are written back to v.
+// writeBack, if not nil, is called after each such change, which is needed
+// when v is a copy.
+func (r *Realm) convertAddressable(v reflect.Value, writeBack func()) (*Value, error) {
+	value, err := r.convertAddressableValue(v)
+	if err != nil || writeBack == nil {
+		return value, err
+	}
+
+	if w, ok := value.goWrapper(); ok && w.writeBack == nil {
+		w.writeBack = writeBack
+	}
+
+	return value, nil
+}
+
+func (r *Realm) convertAddressableValue(v reflect.Value) (*Value, error) {
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() != timeType {
+			return r.Convert(v.Addr().Interface())
+		}
+
+	case reflect.Slice:
+		if !v.IsNil() && v.Type().Elem().Kind() != reflect.Uint8 && !hasConversion(v.Type()) {
+			return r.newGoSlice(v.Addr())
+		}
+
+	case reflect.Map:
+		if !v.IsNil() && v.Type().Key().Kind() == reflect.String && !hasConversion(v.Type()) {
+			return r.newGoMap(v.Addr())
+		}
+	}
+
+	return r.Convert(v.Interface())
+}
+
+// hasConversion reports whether values of type t are converted by Convert
+// before their kind is considered.
+func hasConversion(t reflect.Type) bool {
+	return t.Implements(jsValuerType) || t.Implements(errorType)
+}
+
+// goObject exposes the fields of a struct as properties that read from and
+// write to the struct directly. Fields cannot be deleted and are therefore not
+// configurable.
+type goObject struct {
+	goWrapper
+	ptr  reflect.Value
+	info *structInfo
+}
+
+func (o *goObject) field(key string) (reflect.Value, bool) {
+	f, ok := o.info.byName[key]
+	if !ok {
+		return reflect.Value{}, false
+	}
+
+	return o.ptr.Elem().FieldByIndex(f.index), true
+}
+
+func (o *goObject) get(key string) (*Value, error) {
+	field, ok := o.field(key)
+	if !ok {
+		return nil, nil
+	}
+
+	return o.realm.convertAddressable(field, o.writeBack)
+}
+
+func (o *goObject) has(key string) (bool, error) {
+	_, ok := o.info.byName[key]
+	return ok, nil
+}
+
+func (o *goObject) set(key string, value *Value) (bool, error) {
+	field, ok := o.field(key)
+	if !ok {
+		return false, nil
+	}
+
+	converted, err := jsToReflect(o.realm, value, field.Type())
+	if err != nil {
+		return false, err
+	}
+
+	field.Set(converted)
+	o.changed()
+
+	return true, nil
+}
+
+func (o *goObject) delete(key string) (bool, error) {
+	_, ok := o.info.byName[key]
+	return !ok, nil
+}
+
+func (o *goObject) keys() ([]string, error) {
+	keys := make([]string, len(o.info.fields))
+	for i, field := range o.info.fields {
+		keys[i] = field.name
+	}
+
+	return keys, nil
+}
+
+func (o *goObject) flags(string) internal.PropertyFlag {
+	return internal.PropertyFlagWritable | internal.PropertyFlagEnumerable
+}
+
+// maxGoSliceGrowth is the most elements by which a single write may grow a go
+// slice, so that scripts cannot make the host allocate arbitrary amounts of
+// memory with writes such as s[1e9] = 1.
+const maxGoSliceGrowth = 1 << 16
+
+// goSlice exposes a slice as an array-like object whose elements and length
+// read from and write to the slice directly. Assigning past the end or to the
+// length grows the slice as needed, by at most maxGoSliceGrowth elements at a
+// time, so that array methods such as push and splice work as they do on
+// arrays. Since slices cannot have holes, deleting an element sets it to the
+// zero value.
+type goSlice struct {
+	goWrapper
+	ptr reflect.Value
+}
+
+// index parses a canonical array index.
+func (s *goSlice) index(key string) (int, bool) {
+	n, err := strconv.Atoi(key)
+	if err != nil || n < 0 || strconv.Itoa(n) != key {
+		return 0, false
+	}
+
+	return n, true
+}
+
+func (s *goSlice) get(key string) (*Value, error) {
+	slice := s.ptr.Elem()
+	if key == "length" {
+		return s.realm.NewInt(slice.Len())
+	}
+
+	i, ok := s.index(key)
+	if !ok || i >= slice.Len() {
+		return nil, nil
+	}
+
+	return s.realm.convertAddressable(slice.Index(i), s.writeBack)
+}
+
+func (s *goSlice) has(key string) (bool, error) {
+	if key == "length" {
+		return true, nil
+	}
+
+	i, ok := s.index(key)
+	return ok && i < s.ptr.Elem().Len(), nil
+}
+
+// resize changes the length of the slice, zeroing removed elements so that
+// they may be garbage collected.
+func (s *goSlice) resize(n int) error {
+	slice := s.ptr.Elem()
+	if n < slice.Len() {
+		zero := reflect.Zero(slice.Type().Elem())
+		for i := n; i < slice.Len(); i++ {
+			slice.Index(i).Set(zero)
+		}
+
+		slice.SetLen(n)
+		return nil
+	}
+
+	if n-slice.Len() > maxGoSliceGrowth {
+		return NewRangeError("cannot grow a go slice by more than %d elements at once", maxGoSliceGrowth)
+	}
+
+	s.ptr.Elem().Set(reflect.AppendSlice(slice, reflect.MakeSlice(slice.Type(), n-slice.Len(), n-slice.Len())))
+
+	return nil
+}
+
+func (s *goSlice) set(key string, value *Value) (bool, error) {
+	if key == "length" {
+		// the length is validated as with ArraySetLength
+		n, err := s.realm.toNumber(value)
+		if err != nil {
+			return false, err
+		}
+
+		if n < 0 || n != math.Trunc(n) || n > math.MaxUint32 {
+			return false, NewRangeError("invalid array length")
+		}
+
+		if err := s.resize(int(n)); err != nil {
+			return false, err
+		}
+
+		s.changed()
+
+		return true, nil
+	}
+
+	i, ok := s.index(key)
+	if !ok {
+		return false, nil
+	}
+
+	converted, err := jsToReflect(s.realm, value, s.ptr.Type().Elem().Elem())
+	if err != nil {
+		return false, err
+	}
+
+	if i >= s.ptr.Elem().Len() {
+		if err := s.resize(i + 1); err != nil {
+			return false, err
+		}
+	}
+
+	s.ptr.Elem().Index(i).Set(converted)
+	s.changed()
+
+	return true, nil
+}
+
+func (s *goSlice) delete(key string) (bool, error) {
+	if key == "length" {
+		return false, nil
+	}
+
+	if i, ok := s.index(key); ok && i < s.ptr.Elem().Len() {
+		elem := s.ptr.Elem().Index(i)
+		elem.Set(reflect.Zero(elem.Type()))
+		s.changed()
+	}
+
+	return true, nil
+}
+
+func (s *goSlice) keys() ([]string, error) {
+	n := s.ptr.Elem().Len()
+
+	keys := make([]string, n, n+1)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	return append(keys, "length"), nil
+}
+
+func (s *goSlice) flags(key string) internal.PropertyFlag {
+	if key == "length" {
+		return internal.PropertyFlagWritable
+	}
+
+	return internal.PropertyFlagWritable | internal.PropertyFlagEnumerable | internal.PropertyFlagConfigurable
+}
+
+// goMap exposes a map with string keys as an object whose properties read
+// from and write to the map directly.
+type goMap struct {
+	goWrapper
+	ptr reflect.Value
+}
+
+func (m *goMap) mapKey(key string) reflect.Value {
+	return reflect.ValueOf(key).Convert(m.ptr.Type().Elem().Key())
+}
+
+func (m *goMap) get(key string) (*Value, error) {
+	mapKey := m.mapKey(key)
+
+	elem := m.ptr.Elem().MapIndex(mapKey)
+	if !elem.IsValid() {
+		return nil, nil
+	}
+
+	if elem.Kind() == reflect.Interface {
+		if elem.IsNil() {
+			return NewNull(), nil
+		}
+
+		elem = elem.Elem()
+	}
+
+	// entries of maps are not addressable, so structs and slices are exposed
+	// as copies that are stored back into the map whenever js changes them
+	entry := reflect.New(elem.Type()).Elem()
+	entry.Set(elem)
+
+	return m.realm.convertAddressable(entry, func() {
+		m.ptr.Elem().SetMapIndex(mapKey, entry)
+		m.changed()
+	})
+}
+
+func (m *goMap) has(key string) (bool, error) {
+	return m.ptr.Elem().MapIndex(m.mapKey(key)).IsValid(), nil
+}
+
+func (m *goMap) set(key string, value *Value) (bool, error) {
+	converted, err := jsToReflect(m.realm, value, m.ptr.Type().Elem().Elem())
+	if err != nil {
+		return false, err
+	}
+
+	m.ptr.Elem().SetMapIndex(m.mapKey(key), converted)
+	m.changed()
+
+	return true, nil
+}
+
+func (m *goMap) delete(key string) (bool, error) {
+	m.ptr.Elem().SetMapIndex(m.mapKey(key), reflect.Value{})
+	m.changed()
+
+	return true, nil
+}
+
+func (m *goMap) keys() ([]string, error) {
+	keys := make([]string, 0, m.ptr.Elem().Len())
+	for _, key := range m.ptr.Elem().MapKeys() {
+		keys = append(keys, key.String())
+	}
+
+	// go maps are unordered
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (m *goMap) flags(string) internal.PropertyFlag {
+	return internal.PropertyFlagWritable | internal.PropertyFlagEnumerable | internal.PropertyFlagConfigurable
+}
+
+// goWrapper returns the wrapper of the go value that is exposed by v, if any.
+func (v *Value) goWrapper() (*goWrapper, bool) {
+	object, ok := v.hostObject()
+	if !ok {
+		return nil, false
+	}
+
+	switch object := object.(type) {
+	case *goObject:
+		return &object.goWrapper, true
+
+	case *goSlice:
+		return &object.goWrapper, true
+
+	case *goMap:
+		return &object.goWrapper, true
+	}
+
+	return nil, false
+}
+
+// goValue returns the go value that is wrapped by v, if any.
+func (v *Value) goValue() (interface{}, bool) {
+	object, ok := v.hostObject()
+	if !ok {
+		return nil, false
+	}
+
+	switch object := object.(type) {
+	case *goObject:
+		return object.ptr.Interface(), true
+
+	case *goSlice:
+		return object.ptr.Elem().Interface(), true
+
+	case *goMap:
+		return object.ptr.Elem().Interface(), true
+
+	case *dynamicObject:
+		return object.object, true
+	}
+
+	return nil, false
+}
+
+// NewGoObject exposes a pointer to a struct as an object whose properties are
+// the fields of the struct and whose methods are the methods of the pointer.
+//
+// Property reads and writes go directly to the struct, so changes made by
+// either side are immediately visible to the other. Struct typed fields are
+// exposed the same way, slices as array-like objects and maps with string keys
+// as objects that also read from and write to the field directly, while other
+// values are converted with Convert. Since the entries of maps are not
+// addressable, structs and slices in maps are exposed as copies that are
+// stored back into the map whenever js changes them. The same struct is
+// otherwise always exposed as the same object for as long as that object is
+// alive.
+func (r *Realm) NewGoObject(ptr interface{}) (*Value, error) {
+	ptrValue := reflect.ValueOf(ptr)
+	if ptrValue.Kind() != reflect.Ptr || ptrValue.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("ptr must be a pointer to a struct, got %T", ptr))
+	}
+
+	return r.newGoObject(ptrValue)
+}
+
+func (r *Realm) newGoObject(ptr reflect.Value) (*Value, error) {
+	return r.goWrapper(ptr, func(w goWrapper) (*Value, error) {
+		proto, err := r.goObjectPrototype(ptr.Type())
+		if err != nil {
+			return nil, err
+		}
+
+		return r.newHostObject(proto, &goObject{
+			goWrapper: w,
+			ptr:       ptr,
+			info:      getStructInfo(ptr.Type().Elem()),
+		})
+	})
+}
+
+// goSlicePrototypeKey is the key of the prototype of go slices.
+type goSlicePrototypeKey struct{}
+
+func (r *Realm) newGoSlice(ptr reflect.Value) (*Value, error) {
+	return r.goWrapper(ptr, func(w goWrapper) (*Value, error) {
+		proto, err := r.goSlicePrototype()
+		if err != nil {
+			return nil, err
+		}
+
+		return r.newHostObject(proto, &goSlice{
+			goWrapper: w,
+			ptr:       ptr,
+		})
+	})
+}
+
+// goSlicePrototype returns the prototype of go slices, which inherits the
+// methods of arrays and makes go slices behave like arrays when they are
+// serialized or concatenated.
+func (r *Realm) goSlicePrototype() (*Value, error) {
+	if proto, ok := r.prototype(goSlicePrototypeKey{}); ok {
+		return proto, nil
+	}
+
+	arrayConstructor, err := r.globalProperty("Array")
+	if err != nil {
+		return nil, err
+	}
+
+	arrayProto, err := arrayConstructor.Get("prototype")
+	if err != nil {
+		return nil, err
+	}
+
+	proto, err := r.NewObjectProto(arrayProto)
+	if err != nil {
+		return nil, err
+	}
+
+	toJSON, err := r.NewFunction(func(r *Realm, this *Value) (*Value, error) {
+		return arrayConstructor.Invoke("from", this)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := proto.DefineProperty("toJSON", DefinePropertyValue(toJSON), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return nil, err
+	}
+
+	isConcatSpreadable, err := r.wellKnownSymbol("isConcatSpreadable")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := proto.DefinePropertyAtom(isConcatSpreadable, DefinePropertyValue(NewTrue()), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return nil, err
+	}
+
+	r.setPrototype(goSlicePrototypeKey{}, proto)
+
+	return proto, nil
+}
+
+func (r *Realm) newGoMap(ptr reflect.Value) (*Value, error) {
+	return r.goWrapper(ptr, func(w goWrapper) (*Value, error) {
+		objectConstructor, err := r.globalProperty("Object")
+		if err != nil {
+			return nil, err
+		}
+
+		proto, err := objectConstructor.Get("prototype")
+		if err != nil {
+			return nil, err
+		}
+
+		return r.newHostObject(proto, &goMap{
+			goWrapper: w,
+			ptr:       ptr,
+		})
+	})
+}
+
+// goObjectPrototype returns the prototype that holds the methods of the given
+// pointer type.
+func (r *Realm) goObjectPrototype(t reflect.Type) (*Value, error) {
+	if proto, ok := r.prototype(t); ok {
+		return proto, nil
+	}
+
+	proto, err := r.NewObject()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < t.NumMethod(); i++ {
+		method, err := r.newGoMethod(t, t.Method(i))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := proto.DefineProperty(t.Method(i).Name, DefinePropertyValue(method), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+			return nil, err
+		}
+	}
+
+	r.setPrototype(t, proto)
+
+	return proto, nil
+}
+
+func (r *Realm) newGoMethod(t reflect.Type, method reflect.Method) (*Value, error) {
+	numArgs := method.Type.NumIn()
+
+	// skip the receiver
+	argTypes := make([]reflect.Type, numArgs-1)
+	for i := 1; i < numArgs; i++ {
+		argTypes[i-1] = method.Type.In(i)
+	}
+
+	variadic := method.Type.IsVariadic()
+
+	return r.createAndResolveValue(internal.NewFunction(r.context, func(ctx *internal.Context, thisValue internal.Value, args []internal.Value) (result internal.Value) {
+		defer recoverPanic(func(err error) {
+			result = r.throw(err)
+		})
+
+		this := r.createValue(internal.DupValue(ctx, thisValue))
+
+		receiver, ok := this.goValue()
+		if !ok || reflect.TypeOf(receiver) != t {
+			return r.throw(NewTypeError("%s called on incompatible receiver", method.Name))
+		}
+
+		callArgs, err := prepareReflectCallArgs(r, args, argTypes, variadic, reflect.ValueOf(receiver))
+		if err != nil {
+			return r.throw(err)
+		}
+
+		results := method.Func.Call(callArgs)
+
+		// methods may change the receiver
+		if w, ok := this.goWrapper(); ok {
+			w.changed()
+		}
+
+		return r.reflectResult(results)
+	}))
+}
@@ -0,0 +1,332 @@
+package js
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testInner struct {
+	N int
+}
+
+type testPerson struct {
+	Name   string
+	Age    int    `js:"age"`
+	Secret string `js:"-"`
+	Tags   []string
+	Inner  testInner
+	Next   *testInner
+	Attrs  map[string]int
+	hidden int
+}
+
+func (p *testPerson) Greet(greeting string) string {
+	return greeting + ", " + p.Name
+}
+
+func setGlobal(t *testing.T, r *Realm, name string, value interface{}) {
+	t.Helper()
+
+	global, err := r.GlobalObject()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := global.Set(name, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGoObject(t *testing.T) {
+	r := newTestRealm(t)
+
+	p := &testPerson{Name: "a", Age: 1, Secret: "s", Tags: []string{"x"}, Attrs: map[string]int{"k": 1}}
+	setGlobal(t, r, "p", p)
+
+	expectString(t, r, `p.Name`, "a")
+	expectString(t, r, `p.age`, "1")
+	expectString(t, r, `[p.Secret, p.hidden, p.Age].join()`, ",,")
+	expectString(t, r, `Object.keys(p).join()`, "Name,age,Tags,Inner,Next,Attrs")
+	expectString(t, r, `p.Greet("hi")`, "hi, a")
+
+	mustEval(t, r, `p.Name = "b"; p.age = 2; p.Inner.N = 3; p.Attrs.j = 4; delete p.Attrs.k`)
+	if p.Name != "b" || p.Age != 2 || p.Inner.N != 3 || !reflect.DeepEqual(p.Attrs, map[string]int{"j": 4}) {
+		t.Errorf("writes are not visible in go: %+v", p)
+	}
+
+	p.Name = "c"
+	expectString(t, r, `p.Name`, "c")
+
+	if _, err := r.Eval(`p.age = "x"`); err == nil {
+		t.Error("expected assigning a value of the wrong type to fail")
+	}
+}
+
+func TestGoObjectFieldsNotConfigurable(t *testing.T) {
+	r := newTestRealm(t)
+
+	setGlobal(t, r, "p", &testPerson{Name: "a"})
+
+	expectString(t, r, `JSON.stringify(Object.getOwnPropertyDescriptor(p, "Name"))`, `{"value":"a","writable":true,"enumerable":true,"configurable":false}`)
+	expectString(t, r, `delete p.Name`, "false")
+	expectString(t, r, `p.Name`, "a")
+	expectString(t, r, `delete p.missing`, "true")
+
+	if _, err := r.Eval(`"use strict"; delete p.Name`); err == nil {
+		t.Error("expected delete to throw in strict mode")
+	}
+}
+
+func TestGoObjectRejectedWrites(t *testing.T) {
+	r := newTestRealm(t)
+
+	setGlobal(t, r, "p", &testPerson{})
+
+	expectString(t, r, `p.missing = 1; p.missing`, "undefined")
+
+	if _, err := r.Eval(`"use strict"; p.missing = 1`); err == nil {
+		t.Error("expected adding a property to throw in strict mode")
+	}
+}
+
+func TestGoObjectIdentity(t *testing.T) {
+	r := newTestRealm(t)
+
+	p := &testPerson{Tags: []string{}, Attrs: map[string]int{}, Next: &testInner{}}
+	setGlobal(t, r, "p", p)
+	setGlobal(t, r, "q", p)
+
+	expectString(t, r, `p === q`, "true")
+	expectString(t, r, `p.Inner === p.Inner`, "true")
+	expectString(t, r, `p.Tags === p.Tags`, "true")
+	expectString(t, r, `p.Attrs === p.Attrs`, "true")
+	expectString(t, r, `const next = p.Next; next === p.Next`, "true")
+
+	p.Next = &testInner{N: 1}
+	expectString(t, r, `next === p.Next`, "false")
+	expectString(t, r, `p.Next.N`, "1")
+}
+
+func TestGoSlice(t *testing.T) {
+	r := newTestRealm(t)
+
+	p := &testPerson{Tags: []string{"a"}}
+	setGlobal(t, r, "p", p)
+
+	expectString(t, r, `p.Tags.length`, "1")
+	expectString(t, r, `p.Tags.push("b", "c")`, "3")
+	if !reflect.DeepEqual(p.Tags, []string{"a", "b", "c"}) {
+		t.Errorf("push is not visible in go: %q", p.Tags)
+	}
+
+	expectString(t, r, `p.Tags.pop()`, "c")
+	expectString(t, r, `p.Tags.splice(0, 1, "x", "y").join()`, "a")
+	if !reflect.DeepEqual(p.Tags, []string{"x", "y", "b"}) {
+		t.Errorf("splice is not visible in go: %q", p.Tags)
+	}
+
+	expectString(t, r, `p.Tags.map(s => s.toUpperCase()).join()`, "X,Y,B")
+	expectString(t, r, `JSON.stringify(p.Tags)`, `["x","y","b"]`)
+	expectString(t, r, `[0].concat(p.Tags).join()`, "0,x,y,b")
+	expectString(t, r, `Object.keys(p.Tags).join()`, "0,1,2")
+
+	mustEval(t, r, `p.Tags[4] = "z"`)
+	if !reflect.DeepEqual(p.Tags, []string{"x", "y", "b", "", "z"}) {
+		t.Errorf("assigning past the end does not grow the slice: %q", p.Tags)
+	}
+
+	mustEval(t, r, `p.Tags.length = 1`)
+	if !reflect.DeepEqual(p.Tags, []string{"x"}) {
+		t.Errorf("assigning the length does not truncate the slice: %q", p.Tags)
+	}
+
+	if _, err := r.Eval(`p.Tags.length = -1`); err == nil || !strings.Contains(err.Error(), "RangeError") {
+		t.Errorf("expected a RangeError for an invalid length, got %v", err)
+	}
+
+	if _, err := r.Eval(`p.Tags.push(1)`); err == nil {
+		t.Error("expected pushing a value of the wrong type to fail")
+	}
+
+	// the length is converted to a number as with arrays
+	mustEval(t, r, `p.Tags.length = "2"`)
+	if !reflect.DeepEqual(p.Tags, []string{"x", ""}) {
+		t.Errorf("assigning a numeric string to the length: %q", p.Tags)
+	}
+
+	for _, script := range []string{
+		`p.Tags.length = "x"`,
+		`p.Tags.length = {}`,
+		`p.Tags.length = 1.5`,
+		`p.Tags.length = 2 ** 32`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { e.name }`, "RangeError")
+	}
+
+	expectString(t, r, `try { p.Tags.length = Symbol() } catch (e) { e.name }`, "TypeError")
+}
+
+func TestGoSliceGrowthLimit(t *testing.T) {
+	r := newTestRealm(t)
+
+	p := &testPerson{Tags: []string{"a"}}
+	setGlobal(t, r, "p", p)
+
+	for _, script := range []string{
+		`p.Tags[1e9] = "x"`,
+		`p.Tags.length = 2 ** 32 - 1`,
+		`p.Tags[p.Tags.length + 65536] = "x"`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { e.name }`, "RangeError")
+	}
+
+	if len(p.Tags) != 1 {
+		t.Errorf("rejected writes grew the slice to %d elements", len(p.Tags))
+	}
+
+	mustEval(t, r, `p.Tags[p.Tags.length + 65535] = "x"; p.Tags.length += 65536`)
+	if len(p.Tags) != 1+65536+65536 {
+		t.Errorf("got %d elements, want %d", len(p.Tags), 1+65536+65536)
+	}
+}
+
+type testWithMaps struct {
+	Inners map[string]testInner
+	Lists  map[string][]int
+	Values map[string]interface{}
+}
+
+func (i *testInner) Increment() {
+	i.N++
+}
+
+func TestGoMapEntries(t *testing.T) {
+	r := newTestRealm(t)
+
+	m := &testWithMaps{
+		Inners: map[string]testInner{"a": {N: 1}},
+		Lists:  map[string][]int{"l": {1}},
+		Values: map[string]interface{}{"s": testInner{N: 5}, "n": 1},
+	}
+	setGlobal(t, r, "m", m)
+
+	// changes to structs and slices in maps are stored back into the map
+	mustEval(t, r, `m.Inners.a.N = 2`)
+	if m.Inners["a"].N != 2 {
+		t.Errorf("writes to a struct in a map are not visible in go: %+v", m.Inners)
+	}
+
+	mustEval(t, r, `m.Inners.a.Increment()`)
+	if m.Inners["a"].N != 3 {
+		t.Errorf("methods of a struct in a map are not visible in go: %+v", m.Inners)
+	}
+
+	mustEval(t, r, `m.Lists.l.push(2)`)
+	if !reflect.DeepEqual(m.Lists["l"], []int{1, 2}) {
+		t.Errorf("writes to a slice in a map are not visible in go: %v", m.Lists)
+	}
+
+	mustEval(t, r, `m.Values.s.N = 6`)
+	if v, ok := m.Values["s"].(testInner); !ok || v.N != 6 {
+		t.Errorf("writes to a struct in an interface map are not visible in go: %+v", m.Values)
+	}
+
+	expectString(t, r, `[m.Inners.a.N, m.Lists.l.join(), m.Values.n, m.Values.s.N].join()`, "3,1,2,1,6")
+
+	// changes made by go are visible to js
+	m.Inners["a"] = testInner{N: 10}
+	expectString(t, r, `m.Inners.a.N`, "10")
+}
+
+type testSliceOfStructs struct {
+	Items []testInner
+}
+
+func TestGoSliceOfStructs(t *testing.T) {
+	r := newTestRealm(t)
+
+	s := &testSliceOfStructs{Items: []testInner{{N: 1}}}
+	setGlobal(t, r, "s", s)
+
+	mustEval(t, r, `s.Items[0].N = 2`)
+	if s.Items[0].N != 2 {
+		t.Errorf("writes to elements are not visible in go: %+v", s.Items)
+	}
+}
+
+type testBase struct {
+	ID   int
+	Name string
+}
+
+type testOther struct {
+	Name string
+	Kind string
+}
+
+type testDeep struct {
+	testBase
+}
+
+type testPromotion struct {
+	testBase
+	testOther
+
+	Kind string
+}
+
+type testDepth struct {
+	testDeep
+	testOther
+}
+
+func TestGoObjectPromotion(t *testing.T) {
+	r := newTestRealm(t)
+
+	setGlobal(t, r, "p", &testPromotion{
+		testBase:  testBase{ID: 1, Name: "base"},
+		testOther: testOther{Name: "other", Kind: "promoted"},
+		Kind:      "outer",
+	})
+
+	// Name is ambiguous at depth 1 and Kind is shadowed by the outer field
+	expectString(t, r, `Object.keys(p).join()`, "ID,Kind")
+	expectString(t, r, `[p.ID, p.Name, p.Kind].join()`, "1,,outer")
+
+	setGlobal(t, r, "d", &testDepth{
+		testDeep:  testDeep{testBase{ID: 2, Name: "deep"}},
+		testOther: testOther{Name: "shallow", Kind: "k"},
+	})
+
+	// the shallower Name wins
+	expectString(t, r, `Object.keys(d).join()`, "ID,Name,Kind")
+	expectString(t, r, `[d.ID, d.Name, d.Kind].join()`, "2,shallow,k")
+}
+
+func TestGoValuePassThrough(t *testing.T) {
+	r := newTestRealm(t)
+
+	p := &testPerson{Tags: []string{"a"}}
+	setGlobal(t, r, "p", p)
+
+	v := mustEval(t, r, `p.Tags`)
+
+	exported, err := v.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(exported, []string{"a"}) {
+		t.Errorf("Export() = %#v, want the go slice", exported)
+	}
+
+	exported, err = mustEval(t, r, `p`).Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exported != p {
+		t.Errorf("Export() = %#v, want the go pointer", exported)
+	}
+}
@@ -0,0 +1,81 @@
+package js
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// newTestRuntime creates a runtime that is owned by the goroutine of the test,
+// which is locked to its thread until the test ends.
+func newTestRuntime(t *testing.T, opts ...RuntimeOption) *Runtime {
+	t.Helper()
+
+	runtime.LockOSThread()
+
+	rt := NewRuntimeWithOptions(opts...)
+	t.Cleanup(func() {
+		rt.Close()
+		runtime.UnlockOSThread()
+	})
+
+	return rt
+}
+
+// newTestRealm creates a realm in a new runtime that is owned by the
+// goroutine of the test.
+func newTestRealm(t *testing.T, opts ...RealmOption) *Realm {
+	t.Helper()
+
+	r, err := newTestRuntime(t).NewRealm(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func mustEval(t *testing.T, r *Realm, script string) *Value {
+	t.Helper()
+
+	v, err := r.Eval(script)
+	if err != nil {
+		t.Fatalf("eval %q: %v", script, err)
+	}
+
+	return v
+}
+
+// mustAwait evaluates script and waits for the promise that it results in.
+func mustAwait(t *testing.T, r *Realm, script string) *Value {
+	t.Helper()
+
+	v, err := await(r, script)
+	if err != nil {
+		t.Fatalf("eval %q: %v", script, err)
+	}
+
+	return v
+}
+
+func await(r *Realm, script string) (*Value, error) {
+	v, err := r.Eval(script)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return v.Await(ctx)
+}
+
+// expectString evaluates script and compares the string form of its result.
+func expectString(t *testing.T, r *Realm, script, want string) {
+	t.Helper()
+
+	if got := mustEval(t, r, script).String(); got != want {
+		t.Errorf("%s = %q, want %q", script, got, want)
+	}
+}
@@ -0,0 +1,265 @@
+package js
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+// hostObject is implemented by go values that provide the own properties of
+// objects created with newHostObject. Symbol keyed properties are not
+// forwarded and behave as if they did not exist.
+type hostObject interface {
+	// get returns the value of the property or nil if it does not exist.
+	get(key string) (*Value, error)
+	has(key string) (bool, error)
+	set(key string, value *Value) (bool, error)
+	delete(key string) (bool, error)
+	keys() ([]string, error)
+
+	// flags returns the attributes of an existing property, which must agree
+	// with the results of set and delete.
+	flags(key string) internal.PropertyFlag
+}
+
+// hostObjectFinalizer may be implemented by a hostObject to be notified when
+// its object is freed.
+type hostObjectFinalizer interface {
+	finalize()
+}
+
+type hostObjectHandler struct {
+	realm  *Realm
+	object hostObject
+}
+
+func (r *Realm) newHostObject(proto *Value, object hostObject) (*Value, error) {
+	defer runtime.KeepAlive(proto)
+
+	return r.createAndResolveValue(internal.NewHostObject(r.context, proto.value, &hostObjectHandler{
+		realm:  r,
+		object: object,
+	}))
+}
+
+// hostObject returns the go value that provides the properties of v, if v
+// was created with newHostObject.
+func (v *Value) hostObject() (hostObject, bool) {
+	if !v.IsObject() {
+		return nil, false
+	}
+
+	defer runtime.KeepAlive(v)
+
+	h, ok := internal.GetHostObject(v.value).(*hostObjectHandler)
+	if !ok {
+		return nil, false
+	}
+
+	return h.object, true
+}
+
+// propertyKey returns the string form of a property key, or false if the
+// property key is a symbol.
+func (r *Realm) propertyKey(atom internal.Atom) (string, bool, error) {
+	value, err := r.createAndResolveValue(internal.AtomToValue(r.context, atom))
+	if err != nil {
+		return "", false, err
+	}
+
+	switch {
+	case value.IsString():
+		return value.ToString(), true, nil
+
+	case value.IsSymbol():
+		return "", false, nil
+	}
+
+	key, err := r.atomToString(atom)
+	if err != nil {
+		return "", false, err
+	}
+
+	return key, true, nil
+}
+
+func (h *hostObjectHandler) Finalize() {
+	if f, ok := h.object.(hostObjectFinalizer); ok {
+		f.finalize()
+	}
+}
+
+func (h *hostObjectHandler) fail(err error) int {
+	h.realm.throw(err)
+	return -1
+}
+
+// reject signals that a property could not be written, throwing a TypeError if
+// the flags require it. The property is reported as read-only if it exists or
+// as not addable otherwise.
+func (h *hostObjectHandler) reject(ctx *internal.Context, prop internal.Atom, flags internal.PropertyFlag) int {
+	key, ok, err := h.realm.propertyKey(prop)
+	if err != nil {
+		return h.fail(err)
+	}
+
+	if ok {
+		exists, err := h.object.has(key)
+		if err != nil {
+			return h.fail(err)
+		}
+
+		if exists {
+			return internal.RejectPropertyWrite(ctx, prop, flags)
+		}
+	}
+
+	return h.rejectWithMessage(ctx, prop, flags, "cannot add property '%s'")
+}
+
+// rejectWithMessage is like reject, but throws a TypeError with a message
+// that is formatted with the name of the property.
+func (h *hostObjectHandler) rejectWithMessage(ctx *internal.Context, prop internal.Atom, flags internal.PropertyFlag, format string) int {
+	name, err := h.realm.atomToString(prop)
+	if err != nil {
+		return h.fail(err)
+	}
+
+	return internal.RejectProperty(ctx, prop, flags, fmt.Sprintf(format, name))
+}
+
+func (h *hostObjectHandler) HasOwnProperty(ctx *internal.Context, prop internal.Atom) (result int) {
+	defer recoverPanic(func(err error) {
+		result = h.fail(err)
+	})
+
+	key, ok, err := h.realm.propertyKey(prop)
+	if err != nil {
+		return h.fail(err)
+	}
+
+	if !ok {
+		return 0
+	}
+
+	has, err := h.object.has(key)
+	if err != nil {
+		return h.fail(err)
+	}
+
+	return boolToInt(has)
+}
+
+func (h *hostObjectHandler) GetOwnProperty(ctx *internal.Context, prop internal.Atom) (propValue internal.Value, propFlags internal.PropertyFlag, result int) {
+	defer recoverPanic(func(err error) {
+		propValue, propFlags, result = internal.Undefined, 0, h.fail(err)
+	})
+
+	key, ok, err := h.realm.propertyKey(prop)
+	if err != nil {
+		return internal.Undefined, 0, h.fail(err)
+	}
+
+	if !ok {
+		return internal.Undefined, 0, 0
+	}
+
+	value, err := h.object.get(key)
+	if err != nil {
+		return internal.Undefined, 0, h.fail(err)
+	}
+
+	if value == nil {
+		return internal.Undefined, 0, 0
+	}
+
+	defer runtime.KeepAlive(value)
+
+	return internal.DupValue(ctx, value.value), h.object.flags(key), 1
+}
+
+func (h *hostObjectHandler) GetOwnPropertyNames(ctx *internal.Context) (atoms []internal.Atom, result int) {
+	defer recoverPanic(func(err error) {
+		atoms, result = nil, h.fail(err)
+	})
+
+	keys, err := h.object.keys()
+	if err != nil {
+		return nil, h.fail(err)
+	}
+
+	atoms = make([]internal.Atom, len(keys))
+	for i, key := range keys {
+		atoms[i] = internal.NewAtom(ctx, key)
+	}
+
+	return atoms, 0
+}
+
+func (h *hostObjectHandler) DefineOwnProperty(ctx *internal.Context, prop internal.Atom, value, getter, setter internal.Value, flags internal.PropertyFlag) (result int) {
+	defer recoverPanic(func(err error) {
+		result = h.fail(err)
+	})
+
+	if flags&(internal.PropertyFlagHasGet|internal.PropertyFlagHasSet) != 0 {
+		// properties of host objects are always data properties
+		return h.rejectWithMessage(ctx, prop, flags, "cannot define property '%s'")
+	}
+
+	if flags&internal.PropertyFlagHasValue == 0 {
+		// attributes of host object properties cannot be changed
+		return 1
+	}
+
+	return h.SetProperty(ctx, prop, value, flags)
+}
+
+func (h *hostObjectHandler) SetProperty(ctx *internal.Context, prop internal.Atom, value internal.Value, flags internal.PropertyFlag) (result int) {
+	defer recoverPanic(func(err error) {
+		result = h.fail(err)
+	})
+
+	key, ok, err := h.realm.propertyKey(prop)
+	if err != nil {
+		return h.fail(err)
+	}
+
+	if !ok {
+		return h.reject(ctx, prop, flags)
+	}
+
+	ok, err = h.object.set(key, h.realm.createValue(internal.DupValue(ctx, value)))
+	if err != nil {
+		return h.fail(err)
+	}
+
+	if !ok {
+		return h.reject(ctx, prop, flags)
+	}
+
+	return 1
+}
+
+func (h *hostObjectHandler) DeleteProperty(ctx *internal.Context, prop internal.Atom) (result int) {
+	defer recoverPanic(func(err error) {
+		result = h.fail(err)
+	})
+
+	key, ok, err := h.realm.propertyKey(prop)
+	if err != nil {
+		return h.fail(err)
+	}
+
+	if !ok {
+		// nothing to delete
+		return 1
+	}
+
+	ok, err = h.object.delete(key)
+	if err != nil {
+		return h.fail(err)
+	}
+
+	return boolToInt(ok)
+}
@@ -0,0 +1,867 @@
+package js
+
+import (
+	"math"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+// InspectOptions control how values are rendered by Value.Inspect.
+type InspectOptions struct {
+	// Depth is the number of nested objects to recurse into, after which
+	// objects are abbreviated, such as [Object]. Zero means the default of 2
+	// unless DepthSet is true and a negative depth means that there is no
+	// limit.
+	Depth int
+
+	// DepthSet makes Depth apply even if it is zero, in which case only the
+	// properties of the inspected value itself are shown.
+	DepthSet bool
+
+	// Colors enables ANSI color codes in the style of node.
+	Colors bool
+
+	// MaxArrayLength is the number of elements of arrays, typed arrays, maps
+	// and sets that are shown. Zero means the default of 100 and a negative
+	// length means that there is no limit.
+	MaxArrayLength int
+
+	// BreakLength is the length after which objects are split over multiple
+	// lines. Zero means the default of 80.
+	BreakLength int
+}
+
+const (
+	defaultInspectDepth          = 2
+	defaultInspectMaxArrayLength = 100
+	defaultInspectBreakLength    = 80
+)
+
+// inspectStyle is the color of a part of an inspected value.
+type inspectStyle int
+
+const (
+	inspectStyleSpecial inspectStyle = iota
+	inspectStyleNumber
+	inspectStyleBoolean
+	inspectStyleUndefined
+	inspectStyleNull
+	inspectStyleString
+	inspectStyleSymbol
+	inspectStyleDate
+	inspectStyleRegExp
+)
+
+// inspectColors holds the ANSI codes that set and reset each style.
+var inspectColors = map[inspectStyle][2]int{
+	inspectStyleSpecial:   {36, 39},
+	inspectStyleNumber:    {33, 39},
+	inspectStyleBoolean:   {33, 39},
+	inspectStyleUndefined: {90, 39},
+	inspectStyleNull:      {1, 22},
+	inspectStyleString:    {32, 39},
+	inspectStyleSymbol:    {32, 39},
+	inspectStyleDate:      {35, 39},
+	inspectStyleRegExp:    {31, 39},
+}
+
+var (
+	identifierRegexp = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	ansiRegexp       = regexp.MustCompile("\x1b\\[[0-9;]*m")
+)
+
+type inspector struct {
+	options        InspectOptions
+	depth          int
+	maxArrayLength int
+	breakLength    int
+
+	// seen holds the objects that are being rendered to detect cycles
+	seen []uintptr
+
+	// circular numbers the objects that are referenced by their descendants
+	circular map[uintptr]int
+
+	// toString and getPrototypeOf are looked up once per call to Inspect
+	toString       *Value
+	getPrototypeOf *Value
+}
+
+// Inspect renders v for debugging in the style of node's util.inspect.
+//
+// Strings are quoted, nested objects are rendered up to the configured
+// depth, objects that reference themselves are marked as such and accessors
+// are shown as [Getter], [Setter] or [Getter/Setter] without calling them.
+func (v *Value) Inspect(opts InspectOptions) string {
+	depth := opts.Depth
+	if depth == 0 && !opts.DepthSet {
+		depth = defaultInspectDepth
+	}
+
+	return v.inspect(opts, depth)
+}
+
+// inspect is like Inspect, except that depth is used as is.
+func (v *Value) inspect(opts InspectOptions, depth int) string {
+	in := &inspector{
+		options:        opts,
+		depth:          depth,
+		maxArrayLength: opts.MaxArrayLength,
+		breakLength:    opts.BreakLength,
+		circular:       map[uintptr]int{},
+	}
+
+	if in.maxArrayLength == 0 {
+		in.maxArrayLength = defaultInspectMaxArrayLength
+	}
+
+	if in.breakLength == 0 {
+		in.breakLength = defaultInspectBreakLength
+	}
+
+	if object, err := v.realm.globalProperty("Object"); err == nil {
+		in.getPrototypeOf, _ = object.Get("getPrototypeOf")
+		if proto, err := object.Get("prototype"); err == nil {
+			in.toString, _ = proto.Get("toString")
+		}
+	}
+
+	return in.value(v, 0)
+}
+
+func (in *inspector) stylize(s string, style inspectStyle) string {
+	if !in.options.Colors {
+		return s
+	}
+
+	codes := inspectColors[style]
+
+	return "\x1b[" + strconv.Itoa(codes[0]) + "m" + s + "\x1b[" + strconv.Itoa(codes[1]) + "m"
+}
+
+func (in *inspector) value(v *Value, level int) string {
+	if !v.IsObject() {
+		return in.primitive(v)
+	}
+
+	defer runtime.KeepAlive(v)
+
+	ptr := v.value.Ptr()
+	for _, seen := range in.seen {
+		if seen != ptr {
+			continue
+		}
+
+		index, ok := in.circular[ptr]
+		if !ok {
+			index = len(in.circular) + 1
+			in.circular[ptr] = index
+		}
+
+		return in.stylize("[Circular *"+strconv.Itoa(index)+"]", inspectStyleSpecial)
+	}
+
+	in.seen = append(in.seen, ptr)
+	result := in.object(v, level)
+	in.seen = in.seen[:len(in.seen)-1]
+
+	if index, ok := in.circular[ptr]; ok {
+		return in.stylize("<ref *"+strconv.Itoa(index)+">", inspectStyleSpecial) + " " + result
+	}
+
+	return result
+}
+
+func (in *inspector) primitive(v *Value) string {
+	switch v.Tag() {
+	case TagString:
+		return in.stylize(quoteString(v.ToString()), inspectStyleString)
+
+	case TagInt, TagFloat64:
+		if f := v.ToFloat(); f == 0 && math.Signbit(f) {
+			return in.stylize("-0", inspectStyleNumber)
+		}
+
+		return in.stylize(primitiveString(v), inspectStyleNumber)
+
+	case TagBigInt:
+		return in.stylize(v.ToBigInt().String()+"n", inspectStyleNumber)
+
+	case TagBool:
+		return in.stylize(primitiveString(v), inspectStyleBoolean)
+
+	case TagUndefined:
+		return in.stylize("undefined", inspectStyleUndefined)
+
+	case TagNull:
+		return in.stylize("null", inspectStyleNull)
+
+	case TagSymbol:
+		return in.stylize(symbolString(v), inspectStyleSymbol)
+	}
+
+	return primitiveString(v)
+}
+
+// builtinTag returns the tag of v as reported by Object.prototype.toString,
+// such as Map for [object Map].
+func (in *inspector) builtinTag(v *Value) string {
+	if in.toString == nil {
+		return ""
+	}
+
+	tag, err := in.toString.Call(v)
+	if err != nil || !tag.IsString() {
+		return ""
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(tag.ToString(), "[object "), "]")
+}
+
+// constructorName returns the name of the constructor of the prototype of v
+// or an empty string if v has no prototype.
+func (in *inspector) constructorName(v *Value) string {
+	if in.getPrototypeOf == nil {
+		return "Object"
+	}
+
+	proto, err := in.getPrototypeOf.Call(nil, v)
+	if err != nil || !proto.IsObject() {
+		return ""
+	}
+
+	ctor, err := proto.Get("constructor")
+	if err != nil || !ctor.IsFunction() {
+		return "Object"
+	}
+
+	name, err := ctor.Get("name")
+	if err != nil || !name.IsString() || name.ToString() == "" {
+		return "Object"
+	}
+
+	return name.ToString()
+}
+
+// objectFormat is the layout of a rendered object.
+type objectFormat struct {
+	// base is rendered instead of the braces if there are no properties,
+	// such as [Function: f]
+	base string
+
+	// prefix is the name of the object, such as Map(1)
+	prefix string
+
+	braces [2]string
+
+	// entries are rendered before the properties, such as the elements of an
+	// array
+	entries []string
+
+	// numeric is set if all entries are numbers
+	numeric bool
+
+	// isIndexed is set if index properties are rendered as entries
+	isIndexed bool
+}
+
+func (in *inspector) object(v *Value, level int) string {
+	ctorName := in.constructorName(v)
+	tag := in.builtinTag(v)
+
+	f := objectFormat{
+		braces: [2]string{"{", "}"},
+	}
+
+	if ctorName == "" {
+		f.prefix = "[Object: null prototype]"
+	} else if ctorName != "Object" {
+		f.prefix = ctorName
+	}
+
+	beyondDepth := in.depth >= 0 && level > in.depth
+
+	switch {
+	case v.IsArray():
+		f.braces = [2]string{"[", "]"}
+		f.isIndexed = true
+		if ctorName == "Array" {
+			f.prefix = ""
+		}
+
+		if !beyondDepth {
+			f.entries, f.numeric = in.indexedEntries(v, level)
+		}
+
+	case v.IsFunction():
+		f.base = in.stylize(functionString(v, ctorName), inspectStyleSpecial)
+
+	case v.IsError():
+		f.base = (*Error)(v).Stack()
+
+	case tag == "Map" || tag == "Set":
+		size, err := v.Get("size")
+		if err != nil || !size.IsNumber() {
+			break
+		}
+
+		f.prefix = withBuiltinName(ctorName, tag, size.ToInt())
+		if !beyondDepth {
+			f.entries = in.collectionEntries(v, level, tag == "Map")
+		}
+
+	case tag == "WeakMap" || tag == "WeakSet":
+		f.prefix = withBuiltinName(ctorName, tag, -1)
+		f.entries = []string{in.stylize("<items unknown>", inspectStyleSpecial)}
+
+	case tag == "Promise" && v.IsPromise():
+		f.prefix = withBuiltinName(ctorName, tag, -1)
+		if !beyondDepth {
+			f.entries = in.promiseEntries(v, level)
+		}
+
+	case tag == "Date":
+		f.base = in.stylize(dateString(v), inspectStyleDate)
+
+	case tag == "RegExp":
+		source, err := v.Invoke("toString")
+		if err != nil || !source.IsString() {
+			break
+		}
+
+		f.base = in.stylize(source.ToString(), inspectStyleRegExp)
+
+	case tag == "Number" || tag == "String" || tag == "Boolean":
+		primitive, err := v.Invoke("valueOf")
+		if err != nil || primitive.IsObject() {
+			break
+		}
+
+		f.base = in.stylize("["+tag+": "+in.primitive(primitive)+"]", inspectStyleSpecial)
+		f.isIndexed = tag == "String"
+
+	case tag == "ArrayBuffer":
+		f.prefix = withBuiltinName(ctorName, tag, -1)
+		if !beyondDepth {
+			f.entries = in.arrayBufferEntries(v)
+		}
+
+	case isTypedArrayTag(tag):
+		length, err := v.Get("length")
+		if err != nil || !length.IsNumber() {
+			break
+		}
+
+		f.braces = [2]string{"[", "]"}
+		f.isIndexed = true
+		f.prefix = withBuiltinName(ctorName, tag, length.ToInt())
+		if !beyondDepth {
+			f.entries, f.numeric = in.indexedEntries(v, level)
+		}
+	}
+
+	keys := in.ownEnumerableKeys(v, f.isIndexed)
+
+	if f.base != "" && (len(keys) == 0 || beyondDepth) {
+		return f.base
+	}
+
+	if beyondDepth {
+		name := f.prefix
+		if name == "" {
+			name = "Object"
+			if f.braces[0] == "[" {
+				name = "Array"
+			}
+		}
+
+		return in.stylize("["+strings.TrimSuffix(strings.TrimPrefix(name, "["), "]")+"]", inspectStyleSpecial)
+	}
+
+	grouped := false
+	if len(keys) == 0 && len(f.entries) > 6 && f.braces[0] == "[" {
+		n := len(f.entries)
+		f.entries = groupArrayElements(f.entries, f.numeric, in.breakLength)
+		grouped = len(f.entries) != n
+	}
+
+	entries := f.entries
+	for _, key := range keys {
+		entries = append(entries, in.property(v, key, level))
+	}
+
+	start := f.braces[0]
+	if f.base != "" {
+		start = f.base + " " + start
+	} else if f.prefix != "" {
+		start = f.prefix + " " + start
+	}
+
+	return reduceEntries(start, f.braces[1], entries, grouped, in.breakLength)
+}
+
+// withBuiltinName renders the prefix of a builtin object such as Map(2), or
+// Foo(2) [Map] for a subclass. A negative size is omitted.
+func withBuiltinName(ctorName, tag string, size int) string {
+	name := ctorName
+	if name == "" {
+		name = "[" + tag + ": null prototype]"
+	}
+
+	if size >= 0 {
+		name += "(" + strconv.Itoa(size) + ")"
+	}
+
+	if ctorName != tag && ctorName != "" {
+		name += " [" + tag + "]"
+	}
+
+	return name
+}
+
+func isTypedArrayTag(tag string) bool {
+	switch tag {
+	case "Int8Array", "Uint8Array", "Uint8ClampedArray", "Int16Array", "Uint16Array", "Int32Array", "Uint32Array", "Float32Array", "Float64Array", "BigInt64Array", "BigUint64Array":
+		return true
+	}
+
+	return false
+}
+
+// property renders an own property, without calling accessors.
+func (in *inspector) property(v *Value, key propertyKey, level int) string {
+	defer runtime.KeepAlive(v)
+	defer runtime.KeepAlive(key.atom)
+
+	r := v.realm
+	desc, result := internal.GetOwnProperty(r.context, v.value, key.atom.atom)
+	if result == -1 {
+		return key.name + ": " + in.stylize("["+r.getError().Error()+"]", inspectStyleSpecial)
+	}
+
+	defer internal.FreeValue(r.context, desc.Value)
+	defer internal.FreeValue(r.context, desc.Getter)
+	defer internal.FreeValue(r.context, desc.Setter)
+
+	if !desc.IsAccessor() {
+		value := r.createValue(internal.DupValue(r.context, desc.Value))
+		return key.name + ": " + in.value(value, level+1)
+	}
+
+	hasGetter := desc.Getter.Tag() == internal.TagObject
+	hasSetter := desc.Setter.Tag() == internal.TagObject
+
+	accessor := "[Getter/Setter]"
+	if !hasSetter {
+		accessor = "[Getter]"
+	} else if !hasGetter {
+		accessor = "[Setter]"
+	}
+
+	return key.name + ": " + in.stylize(accessor, inspectStyleSpecial)
+}
+
+// indexedEntries renders the elements of an array or typed array and reports
+// whether they are all numbers.
+func (in *inspector) indexedEntries(v *Value, level int) ([]string, bool) {
+	length, err := v.Get("length")
+	if err != nil || !length.IsNumber() {
+		return nil, false
+	}
+
+	n := length.ToInt()
+	shown := n
+	if in.maxArrayLength >= 0 && shown > in.maxArrayLength {
+		shown = in.maxArrayLength
+	}
+
+	var entries []string
+	numeric := true
+	for i := 0; i < shown; i++ {
+		elem, err := v.Index(i)
+		if err != nil {
+			entries = append(entries, in.stylize("["+err.Error()+"]", inspectStyleSpecial))
+			numeric = false
+			continue
+		}
+
+		numeric = numeric && (elem.IsNumber() || elem.IsBigInt())
+		entries = append(entries, in.value(elem, level+1))
+	}
+
+	return appendMoreItems(entries, n-shown), numeric
+}
+
+// collectionEntries renders the entries of a map or set.
+func (in *inspector) collectionEntries(v *Value, level int, isMap bool) []string {
+	method := "values"
+	if isMap {
+		method = "entries"
+	}
+
+	it, err := v.Invoke(method)
+	if err != nil {
+		return nil
+	}
+
+	var entries []string
+	more := 0
+	if err := it.Iterate(func(entry *Value) (bool, error) {
+		if in.maxArrayLength >= 0 && len(entries) >= in.maxArrayLength {
+			more++
+			return true, nil
+		}
+
+		if !isMap {
+			entries = append(entries, in.value(entry, level+1))
+			return true, nil
+		}
+
+		key, err := entry.Index(0)
+		if err != nil {
+			return false, err
+		}
+
+		value, err := entry.Index(1)
+		if err != nil {
+			return false, err
+		}
+
+		entries = append(entries, in.value(key, level+1)+" => "+in.value(value, level+1))
+		return true, nil
+	}); err != nil {
+		entries = append(entries, in.stylize("["+err.Error()+"]", inspectStyleSpecial))
+	}
+
+	return appendMoreItems(entries, more)
+}
+
+// promiseEntries renders the state of a promise without waiting for it.
+func (in *inspector) promiseEntries(v *Value, level int) []string {
+	state, _ := v.PromiseState()
+	if state == PromisePending {
+		return []string{in.stylize("<pending>", inspectStyleSpecial)}
+	}
+
+	defer runtime.KeepAlive(v)
+
+	result := in.value(v.realm.createValue(internal.GetPromiseResult(v.realm.context, v.value)), level+1)
+	if state == PromiseRejected {
+		return []string{in.stylize("<rejected>", inspectStyleSpecial) + " " + result}
+	}
+
+	return []string{result}
+}
+
+// arrayBufferEntries renders the contents of an array buffer as hex bytes.
+func (in *inspector) arrayBufferEntries(v *Value) []string {
+	defer runtime.KeepAlive(v)
+
+	data := internal.GetArrayBuffer(v.realm.context, v.value)
+
+	shown := len(data)
+	if in.maxArrayLength >= 0 && shown > in.maxArrayLength {
+		shown = in.maxArrayLength
+	}
+
+	contents := make([]string, shown)
+	for i, b := range data[:shown] {
+		contents[i] = strconv.FormatInt(int64(b)|0x100, 16)[1:]
+	}
+
+	if more := len(data) - shown; more > 0 {
+		contents = append(contents, "... "+strconv.Itoa(more)+" more "+pluralize(more, "byte", "bytes"))
+	}
+
+	return []string{
+		"[Uint8Contents]: <" + strings.Join(contents, " ") + ">",
+		"byteLength: " + in.stylize(strconv.Itoa(len(data)), inspectStyleNumber),
+	}
+}
+
+func appendMoreItems(entries []string, more int) []string {
+	if more <= 0 {
+		return entries
+	}
+
+	return append(entries, "... "+strconv.Itoa(more)+" more "+pluralize(more, "item", "items"))
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+
+	return plural
+}
+
+func dateString(v *Value) string {
+	s, err := v.Invoke("toISOString")
+	if err != nil || !s.IsString() {
+		return "Invalid Date"
+	}
+
+	return s.ToString()
+}
+
+// displayWidth returns the number of characters of s that are displayed,
+// excluding color codes.
+func displayWidth(s string) int {
+	if strings.IndexByte(s, '\x1b') >= 0 {
+		s = ansiRegexp.ReplaceAllString(s, "")
+	}
+
+	return utf8.RuneCountInString(s)
+}
+
+// reduceEntries joins entries on a single line if they are short enough and
+// multiline is not set, or otherwise on separate indented lines.
+func reduceEntries(start, end string, entries []string, multiline bool, breakLength int) string {
+	if len(entries) == 0 {
+		return start + end
+	}
+
+	single := start + " " + strings.Join(entries, ", ") + " " + end
+	if !multiline && displayWidth(single) <= breakLength && !strings.Contains(single, "\n") {
+		return single
+	}
+
+	var b strings.Builder
+	b.WriteString(start)
+	for i, entry := range entries {
+		b.WriteString("\n  ")
+		b.WriteString(strings.ReplaceAll(entry, "\n", "\n  "))
+		if i < len(entries)-1 {
+			b.WriteString(",")
+		}
+	}
+
+	b.WriteString("\n")
+	b.WriteString(end)
+
+	return b.String()
+}
+
+// groupArrayElements arranges short array elements in aligned columns, as
+// node does. Numbers are aligned to the right and other values to the left.
+func groupArrayElements(entries []string, numeric bool, breakLength int) []string {
+	const separatorSpace = 2
+
+	n := len(entries)
+	if strings.HasPrefix(entries[n-1], "... ") {
+		// the number of remaining items is not part of the grid
+		n--
+	}
+
+	widths := make([]int, n)
+	totalLength, maxLength := 0, 0
+	for i, entry := range entries[:n] {
+		if strings.Contains(entry, "\n") {
+			return entries
+		}
+
+		widths[i] = displayWidth(entry)
+		totalLength += widths[i] + separatorSpace
+		if widths[i] > maxLength {
+			maxLength = widths[i]
+		}
+	}
+
+	actualMax := maxLength + separatorSpace
+	if actualMax*3 >= breakLength || (float64(totalLength)/float64(actualMax) <= 5 && maxLength > 6) {
+		return entries
+	}
+
+	averageBias := math.Sqrt(float64(actualMax) - float64(totalLength)/float64(len(entries)))
+	biasedMax := math.Max(float64(actualMax)-3-averageBias, 1)
+	columns := int(math.Round(math.Sqrt(2.5*biasedMax*float64(n)) / biasedMax))
+	if c := breakLength / actualMax; c < columns {
+		columns = c
+	}
+
+	if columns > 15 {
+		columns = 15
+	}
+
+	if columns <= 1 {
+		return entries
+	}
+
+	columnWidths := make([]int, columns)
+	for i := range columnWidths {
+		for j := i; j < n; j += columns {
+			if widths[j] > columnWidths[i] {
+				columnWidths[i] = widths[j]
+			}
+		}
+
+		columnWidths[i] += separatorSpace
+	}
+
+	var grouped []string
+	for i := 0; i < n; i += columns {
+		var b strings.Builder
+		for j := i; j < i+columns && j < n; j++ {
+			last := j == i+columns-1 || j == n-1
+
+			cell := entries[j]
+			width := columnWidths[j-i]
+			if last {
+				width -= separatorSpace
+			} else {
+				cell += ", "
+			}
+
+			padding := strings.Repeat(" ", width-displayWidth(cell))
+			switch {
+			case numeric:
+				b.WriteString(padding + cell)
+			case last:
+				b.WriteString(cell)
+			default:
+				b.WriteString(cell + padding)
+			}
+		}
+
+		grouped = append(grouped, b.String())
+	}
+
+	return append(grouped, entries[n:]...)
+}
+
+type propertyKey struct {
+	atom *Atom
+	name string
+}
+
+// ownEnumerableKeys returns the own enumerable string and symbol keys of v
+// as they are rendered by inspect, optionally without indices.
+func (in *inspector) ownEnumerableKeys(v *Value, skipIndices bool) []propertyKey {
+	defer runtime.KeepAlive(v)
+
+	r := v.realm
+	props := internal.GetOwnPropertyNames(r.context, v.value, internal.GetOwnPropertyNamesStringMask|internal.GetOwnPropertyNamesSymbolMask|internal.GetOwnPropertyNamesEnumOnly)
+	defer internal.FreePropertyEnum(r.context, props)
+
+	keys := make([]propertyKey, 0, len(props))
+	for _, prop := range props {
+		key := r.createValue(internal.AtomToValue(r.context, prop.Atom()))
+
+		var name string
+		if key.IsSymbol() {
+			name = "[" + in.stylize(symbolString(key), inspectStyleSymbol) + "]"
+		} else {
+			name = key.ToString()
+
+			_, err := strconv.ParseUint(name, 10, 32)
+			if err == nil && skipIndices {
+				continue
+			}
+
+			if !identifierRegexp.MatchString(name) {
+				name = in.stylize(quoteString(name), inspectStyleString)
+			}
+		}
+
+		keys = append(keys, propertyKey{
+			atom: r.createAtom(internal.DupAtom(r.context, prop.Atom())),
+			name: name,
+		})
+	}
+
+	return keys
+}
+
+// functionString renders a function such as [Function: f] or [class C].
+func functionString(v *Value, ctorName string) string {
+	name := ""
+	if nameValue, err := v.Get("name"); err == nil && nameValue.IsString() {
+		name = nameValue.ToString()
+	}
+
+	kind := ctorName
+	if kind == "" {
+		kind = "Function"
+	}
+
+	if source, err := v.Invoke("toString"); err == nil && source.IsString() && strings.HasPrefix(source.ToString(), "class") {
+		if name == "" {
+			return "[class (anonymous)]"
+		}
+
+		return "[class " + name + "]"
+	}
+
+	if name == "" {
+		return "[" + kind + " (anonymous)]"
+	}
+
+	return "[" + kind + ": " + name + "]"
+}
+
+func symbolString(v *Value) string {
+	s, err := v.Invoke("toString")
+	if err != nil || !s.IsString() {
+		return "Symbol()"
+	}
+
+	return s.ToString()
+}
+
+// primitiveString converts a primitive value to a string as with String(v).
+func primitiveString(v *Value) string {
+	if v.IsSymbol() {
+		return symbolString(v)
+	}
+
+	defer runtime.KeepAlive(v)
+
+	return internal.ToString(v.realm.context, v.value)
+}
+
+// quoteString quotes s with single quotes unless it contains single quotes
+// and no double quotes.
+func quoteString(s string) string {
+	quote := byte('\'')
+	if strings.IndexByte(s, '\'') >= 0 && strings.IndexByte(s, '"') < 0 {
+		quote = '"'
+	}
+
+	var b strings.Builder
+	b.WriteByte(quote)
+	for _, c := range s {
+		switch c {
+		case rune(quote), '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\v':
+			b.WriteString(`\v`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				b.WriteString(`\x`)
+				b.WriteString(strconv.FormatInt(int64(c)|0x100, 16)[1:])
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+
+	b.WriteByte(quote)
+
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package js
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	r := newTestRealm(t)
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`"s"`, "'s'"},
+		{`-0`, "-0"},
+		{`10n`, "10n"},
+		{`undefined`, "undefined"},
+		{`null`, "null"},
+		{`Symbol("x")`, "Symbol(x)"},
+		{`({a: {b: {c: {d: 1}}}})`, "{ a: { b: { c: [Object] } } }"},
+		{`[1, [2, [3, [4]]]]`, "[ 1, [ 2, [ 3, [Array] ] ] ]"},
+		{`(() => { const o = {name: "o"}; o.self = o; return o })()`, "<ref *1> { name: 'o', self: [Circular *1] }"},
+		{`({get a() { return 1 }, set b(v) {}, get c() { return 1 }, set c(v) {}})`, "{ a: [Getter], b: [Setter], c: [Getter/Setter] }"},
+		{`new Map([["k", {v: 1}]])`, "Map(1) { 'k' => { v: 1 } }"},
+		{`new Set([1, "a"])`, "Set(2) { 1, 'a' }"},
+		{`(function named() {})`, "[Function: named]"},
+		{`(() => {})`, "[Function (anonymous)]"},
+		{`(class Foo {})`, "[class Foo]"},
+		{`Object.assign(function f() {}, {p: 1})`, "[Function: f] { p: 1 }"},
+		{`new (class Foo { constructor() { this.x = 1 } })`, "Foo { x: 1 }"},
+		{`Promise.resolve(1)`, "Promise { 1 }"},
+		{`new Uint8Array([1, 2])`, "Uint8Array(2) [ 1, 2 ]"},
+		{`/re/g`, "/re/g"},
+		{`new Date(0)`, "1970-01-01T00:00:00.000Z"},
+		{`({"a-b": 1, [Symbol("s")]: 2})`, "{ 'a-b': 1, [Symbol(s)]: 2 }"},
+		{`Object.create(null)`, "[Object: null prototype] {}"},
+		{`({long: "x".repeat(40), other: "y".repeat(40)})`, "{\n  long: '" + strings.Repeat("x", 40) + "',\n  other: '" + strings.Repeat("y", 40) + "'\n}"},
+	} {
+		if got := mustEval(t, r, test.script).Inspect(InspectOptions{}); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.script, got, test.want)
+		}
+	}
+
+	if got := mustEval(t, r, `new Error("boom")`).Inspect(InspectOptions{}); !strings.HasPrefix(got, "Error: boom\n    at ") {
+		t.Errorf("got %q, want the stack of the error", got)
+	}
+}
+
+func TestInspectOptions(t *testing.T) {
+	r := newTestRealm(t)
+
+	for _, test := range []struct {
+		script string
+		opts   InspectOptions
+		want   string
+	}{
+		// a zero depth is the default unless it is set explicitly
+		{`({a: {b: {c: {d: 1}}}, c: [1]})`, InspectOptions{}, "{ a: { b: { c: [Object] } }, c: [ 1 ] }"},
+		{`({a: {b: 1}, c: [1]})`, InspectOptions{DepthSet: true}, "{ a: [Object], c: [Array] }"},
+		{`[{a: 1}]`, InspectOptions{Depth: 0, DepthSet: true}, "[ [Object] ]"},
+		{`({a: {b: {c: 1}}})`, InspectOptions{Depth: 1}, "{ a: { b: [Object] } }"},
+		{`({a: {b: {c: {d: {}}}}})`, InspectOptions{Depth: -1}, "{ a: { b: { c: { d: {} } } } }"},
+		{`[1, 2, 3]`, InspectOptions{MaxArrayLength: 2}, "[ 1, 2, ... 1 more item ]"},
+		{`new Set([1, 2, 3])`, InspectOptions{MaxArrayLength: 1}, "Set(3) { 1, ... 2 more items }"},
+		{`({a: 1, b: 2})`, InspectOptions{BreakLength: 5}, "{\n  a: 1,\n  b: 2\n}"},
+		{`[1, "s", null, undefined, true]`, InspectOptions{Colors: true}, "[ \x1b[33m1\x1b[39m, \x1b[32m's'\x1b[39m, \x1b[1mnull\x1b[22m, \x1b[90mundefined\x1b[39m, \x1b[33mtrue\x1b[39m ]"},
+	} {
+		if got := mustEval(t, r, test.script).Inspect(test.opts); got != test.want {
+			t.Errorf("%s %+v: got %q, want %q", test.script, test.opts, got, test.want)
+		}
+	}
+}
+
+func TestConsoleInspectOptions(t *testing.T) {
+	h := &recordingHandler{}
+	r := newTestRealm(t, AddIntrinsicConsoleWithInspectOptions(h, InspectOptions{DepthSet: true}))
+
+	mustEval(t, r, `console.log({a: {b: 1}})`)
+	expectMessages(t, h, "{ a: [Object] }")
+}
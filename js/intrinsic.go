@@ -2,6 +2,8 @@ package js
 
 import (
 	"log"
+	"log/slog"
+	"net/http"
 )
 
 type realmConfig struct {
@@ -10,36 +12,27 @@ type realmConfig struct {
 
 type RealmOption func(r realmConfig) error
 
-func makeLoggingFunc(logger *log.Logger, preArgs ...interface{}) interface{} {
-	numPreArgs := len(preArgs)
-	return func(r *Realm, _ *Value, args ...*Value) {
-		values := make([]interface{}, len(args)+numPreArgs)
-		for i, arg := range args {
-			values[i+numPreArgs] = arg.String()
-		}
-
-		copy(values, preArgs)
-
-		if logger == nil {
-			log.Println(values...)
-		} else {
-			logger.Println(values...)
-		}
-	}
+// AddIntrinsicConsoleWithHandler adds a console whose output is passed to h.
+//
+// Each call to a console method produces a record whose message is the
+// formatted output and whose "method" attribute is the name of the method.
+// log, info, dir, dirxml, table, count, time, timeLog, timeEnd and group
+// produce info records, debug and trace produce debug records, warn produces
+// warn records and error and failed assertions produce error records.
+func AddIntrinsicConsoleWithHandler(h slog.Handler) RealmOption {
+	return AddIntrinsicConsoleWithInspectOptions(h, InspectOptions{})
 }
 
-func AddIntrinsicConsoleWithLogger(l *log.Logger) RealmOption {
+// AddIntrinsicConsoleWithInspectOptions is like AddIntrinsicConsoleWithHandler,
+// but values are rendered with the given options, such as to enable colors.
+func AddIntrinsicConsoleWithInspectOptions(h slog.Handler, opts InspectOptions) RealmOption {
 	return func(r realmConfig) error {
 		consoleObj, err := r.NewObject()
 		if err != nil {
 			return err
 		}
 
-		if _, err := consoleObj.Set("log", makeLoggingFunc(l, "INFO:")); err != nil {
-			return err
-		}
-
-		if _, err := consoleObj.Set("warn", makeLoggingFunc(l, "WARN:")); err != nil {
+		if err := newConsole(h, opts).install(consoleObj); err != nil {
 			return err
 		}
 
@@ -56,6 +49,12 @@ func AddIntrinsicConsoleWithLogger(l *log.Logger) RealmOption {
 	}
 }
 
+// AddIntrinsicConsoleWithLogger adds a console that writes lines such as
+// "INFO: message" to l, or to the standard logger if l is nil.
+func AddIntrinsicConsoleWithLogger(l *log.Logger) RealmOption {
+	return AddIntrinsicConsoleWithHandler(logHandler{logger: l})
+}
+
 func AddIntrinsicConsole(r realmConfig) error {
 	return AddIntrinsicConsoleWithLogger(nil)(r)
 }
@@ -84,3 +83,85 @@ func AddIntrinsicTimeout(r realmConfig) error {
 
 	return nil
 }
+
+// AddIntrinsicTextEncoding adds TextEncoder and TextDecoder. TextDecoder
+// supports utf-8, utf-16le, utf-16be and single byte encodings such as
+// latin1.
+func AddIntrinsicTextEncoding(r realmConfig) error {
+	if _, err := r.defineGlobalClass(textEncoderSpec); err != nil {
+		return err
+	}
+
+	if _, err := r.defineGlobalClass(textDecoderSpec); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AddIntrinsicURL adds URL and URLSearchParams, which implement the WHATWG URL
+// standard.
+func AddIntrinsicURL(r realmConfig) error {
+	return r.defineURLClasses()
+}
+
+// AddIntrinsicFetch adds fetch, Headers, Request and Response. Requests are
+// sent with rt, or http.DefaultTransport if rt is nil, and their promises
+// are settled by the event loop. Response.body is a ReadableStream if the
+// realm also has AddIntrinsicStreams and undefined otherwise.
+func AddIntrinsicFetch(rt http.RoundTripper) RealmOption {
+	return AddIntrinsicFetchWithOptions(rt, FetchOptions{})
+}
+
+// AddIntrinsicFetchWithOptions is like AddIntrinsicFetch, but requests are
+// restricted to the hosts that are allowed by opts.
+func AddIntrinsicFetchWithOptions(rt http.RoundTripper, opts FetchOptions) RealmOption {
+	return func(r realmConfig) error {
+		return r.defineFetch(rt, opts)
+	}
+}
+
+// AddIntrinsicCrypto adds crypto with getRandomValues, randomUUID and a
+// subset of crypto.subtle: digest with SHA-1, SHA-256, SHA-384 and SHA-512,
+// and importKey, sign and verify with HMAC and ECDSA on the P-256 curve.
+func AddIntrinsicCrypto(r realmConfig) error {
+	return r.defineCrypto()
+}
+
+// AddIntrinsicDOMException adds DOMException, which is a subclass of Error
+// with a name and a legacy code. It is also added by each intrinsic that
+// throws DOMExceptions.
+func AddIntrinsicDOMException(r realmConfig) error {
+	return r.defineDOMException()
+}
+
+// AddIntrinsicWebGlobals adds atob, btoa, queueMicrotask and structuredClone.
+// structuredClone uses the serializer of quickjs, so values such as Map, Set
+// and RegExp cannot be cloned and only ArrayBuffers can be transferred.
+func AddIntrinsicWebGlobals(r realmConfig) error {
+	return r.defineWebGlobals()
+}
+
+// AddIntrinsicEvents adds EventTarget, Event, CustomEvent, AbortController and
+// AbortSignal. Events are dispatched without a path, as there is no tree of
+// targets. The timers of AbortSignal.timeout, like signals created with
+// Realm.NewAbortSignal, do not keep the event loop running.
+func AddIntrinsicEvents(r realmConfig) error {
+	return r.defineEvents()
+}
+
+// AddIntrinsicStreams adds ReadableStream, WritableStream, TransformStream,
+// their readers, writers and controllers, ByteLengthQueuingStrategy and
+// CountQueuingStrategy. Byte streams and BYOB readers are not supported.
+// WritableStreamDefaultController.signal is only available if the realm also
+// has AddIntrinsicEvents, which must be added first.
+func AddIntrinsicStreams(r realmConfig) error {
+	return r.defineStreams()
+}
+
+// AddIntrinsicPerformance adds performance, Performance, PerformanceEntry,
+// PerformanceMark and PerformanceMeasure. The recorded marks and measures
+// can be read with Realm.PerformanceEntries.
+func AddIntrinsicPerformance(r realmConfig) error {
+	return r.definePerformance()
+}
@@ -0,0 +1,290 @@
+package js
+
+import (
+	"context"
+)
+
+// iteratorMethod returns the method of v that is keyed by the given well-known
+// symbol, or nil if there is no such method.
+func (v *Value) iteratorMethod(symbolName string) (*Value, error) {
+	atom, err := v.realm.wellKnownSymbol(symbolName)
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := v.GetAtom(atom)
+	if err != nil {
+		return nil, err
+	}
+
+	switch method.Tag() {
+	case TagUndefined, TagNull:
+		return nil, nil
+	}
+
+	if !method.IsFunction() {
+		return nil, NewTypeError("%s is not a function", symbolName)
+	}
+
+	return method, nil
+}
+
+// isIterable reports whether v is an object with a Symbol.iterator method.
+func (v *Value) isIterable() (bool, error) {
+	if !v.IsObject() {
+		return false, nil
+	}
+
+	method, err := v.iteratorMethod("iterator")
+	if err != nil {
+		return false, err
+	}
+
+	return method != nil, nil
+}
+
+type iterator struct {
+	iterator *Value
+	next     *Value
+
+	// await is used to resolve results of async iterators
+	await func(*Value) (*Value, error)
+
+	// awaitValues is set when iterating a sync iterator asynchronously
+	awaitValues bool
+}
+
+func (v *Value) getIterator(method *Value) (*iterator, error) {
+	if method == nil {
+		return nil, NewTypeError("value is not iterable")
+	}
+
+	it, err := method.CallValues(v, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if !it.IsObject() {
+		return nil, NewTypeError("iterator is not an object")
+	}
+
+	next, err := it.Get("next")
+	if err != nil {
+		return nil, err
+	}
+
+	return &iterator{
+		iterator: it,
+		next:     next,
+	}, nil
+}
+
+func (it *iterator) resolve(v *Value) (*Value, error) {
+	if it.await == nil {
+		return v, nil
+	}
+
+	return it.await(v)
+}
+
+// step returns the next value of the iterator or false if it is done.
+func (it *iterator) step() (*Value, bool, error) {
+	result, err := it.next.CallValues(it.iterator, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	result, err = it.resolve(result)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !result.IsObject() {
+		return nil, false, NewTypeError("iterator result is not an object")
+	}
+
+	doneValue, err := result.Get("done")
+	if err != nil {
+		return nil, false, err
+	}
+
+	done, err := doneValue.IsTruthy()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if done {
+		return nil, false, nil
+	}
+
+	value, err := result.Get("value")
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+// close calls the return method of the iterator, if any.
+func (it *iterator) close() error {
+	method, err := it.iterator.Get("return")
+	if err != nil {
+		return err
+	}
+
+	switch method.Tag() {
+	case TagUndefined, TagNull:
+		return nil
+	}
+
+	result, err := method.CallValues(it.iterator, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = it.resolve(result)
+	return err
+}
+
+// each calls f with each value of the iterator until f returns false or an
+// error, in which case the iterator is closed.
+func (it *iterator) each(f func(*Value) (bool, error)) error {
+	for {
+		value, ok, err := it.step()
+		if err != nil || !ok {
+			return err
+		}
+
+		if it.awaitValues {
+			value, err = it.resolve(value)
+			if err != nil {
+				return err
+			}
+		}
+
+		ok, err = f(value)
+		if err != nil {
+			// the original error takes precedence
+			it.close()
+			return err
+		}
+
+		if !ok {
+			return it.close()
+		}
+	}
+}
+
+// Iterate calls f with each value produced by the iterator of v, such as the
+// elements of an array or the values yielded by a generator, until f returns
+// false or an error.
+//
+// The iterator is closed if iteration stops early.
+func (v *Value) Iterate(f func(v *Value) (bool, error)) error {
+	method, err := v.iteratorMethod("iterator")
+	if err != nil {
+		return err
+	}
+
+	it, err := v.getIterator(method)
+	if err != nil {
+		return err
+	}
+
+	return it.each(f)
+}
+
+// IterateAsync is like Iterate, but for async iterables such as async
+// generators. The event loop is run while waiting for each value, as with
+// Await.
+//
+// Values that are only iterable synchronously are iterated as with
+// for await, so promises produced by the iterator are awaited.
+func (v *Value) IterateAsync(ctx context.Context, f func(v *Value) (bool, error)) error {
+	method, err := v.iteratorMethod("asyncIterator")
+	if err != nil {
+		return err
+	}
+
+	awaitValues := false
+	if method == nil {
+		method, err = v.iteratorMethod("iterator")
+		if err != nil {
+			return err
+		}
+
+		awaitValues = true
+	}
+
+	it, err := v.getIterator(method)
+	if err != nil {
+		return err
+	}
+
+	it.awaitValues = awaitValues
+	it.await = func(v *Value) (*Value, error) {
+		return v.Await(ctx)
+	}
+
+	return it.each(f)
+}
+
+// newListIterator returns an iterator that yields at(i) for each index of a
+// list. length is called on each step, so changes to the list during
+// iteration are observed.
+func (r *Realm) newListIterator(length func() int, at func(i int) interface{}) (*Value, error) {
+	iterator, err := r.NewObject()
+	if err != nil {
+		return nil, err
+	}
+
+	index := 0
+	next, err := r.NewFunction(func(r *Realm, _ *Value) (*Value, error) {
+		result, err := r.NewObject()
+		if err != nil {
+			return nil, err
+		}
+
+		done := index >= length()
+		if !done {
+			if _, err := result.Set("value", at(index)); err != nil {
+				return nil, err
+			}
+
+			index++
+		} else if _, err := result.Set("value", NewUndefined()); err != nil {
+			return nil, err
+		}
+
+		if _, err := result.Set("done", done); err != nil {
+			return nil, err
+		}
+
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	self, err := r.NewFunction(func(r *Realm, this *Value) (*Value, error) {
+		return this, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	symbol, err := r.wellKnownSymbol("iterator")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := iterator.DefineProperty("next", DefinePropertyValue(next), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return nil, err
+	}
+
+	if _, err := iterator.DefinePropertyAtom(symbol, DefinePropertyValue(self), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return nil, err
+	}
+
+	return iterator, nil
+}
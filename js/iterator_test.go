@@ -0,0 +1,129 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func collect(t *testing.T, v *Value, limit int) []string {
+	t.Helper()
+
+	var values []string
+	err := v.Iterate(func(v *Value) (bool, error) {
+		values = append(values, v.String())
+		return len(values) < limit, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return values
+}
+
+func TestIterate(t *testing.T) {
+	r := newTestRealm(t)
+
+	for _, test := range []struct {
+		script string
+		want   []string
+	}{
+		{`[1, 2, 3]`, []string{"1", "2", "3"}},
+		{`new Set(["a", "b"])`, []string{"a", "b"}},
+		{`new Map([["k", 1]]).keys()`, []string{"k"}},
+		{`"ab"`, []string{"a", "b"}},
+		{`(function* () { yield 1; yield 2 })()`, []string{"1", "2"}},
+	} {
+		if got := collect(t, mustEval(t, r, test.script), 10); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.script, got, test.want)
+		}
+	}
+}
+
+func TestIterateStopsEarly(t *testing.T) {
+	r := newTestRealm(t)
+
+	g := mustEval(t, r, `globalThis.closed = false; (function* () { try { yield 1; yield 2; yield 3 } finally { closed = true } })()`)
+
+	if got := collect(t, g, 2); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("got %q", got)
+	}
+
+	expectString(t, r, `closed`, "true")
+
+	// the error of the callback takes precedence and also closes the iterator
+	g = mustEval(t, r, `closed = false; (function* () { try { yield 1 } finally { closed = true } })()`)
+
+	boom := errors.New("boom")
+	if err := g.Iterate(func(*Value) (bool, error) { return false, boom }); err != boom {
+		t.Errorf("Iterate() = %v, want %v", err, boom)
+	}
+
+	expectString(t, r, `closed`, "true")
+}
+
+func TestIterateErrors(t *testing.T) {
+	r := newTestRealm(t)
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`({})`, "not iterable"},
+		{`({[Symbol.iterator]: 1})`, "not a function"},
+		{`({[Symbol.iterator]() { return 1 }})`, "iterator is not an object"},
+		{`({[Symbol.iterator]() { return {next() { return 1 }} }})`, "iterator result is not an object"},
+		{`(function* () { throw new Error("thrown") })()`, "thrown"},
+	} {
+		err := mustEval(t, r, test.script).Iterate(func(*Value) (bool, error) { return true, nil })
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want %q", test.script, err, test.want)
+		}
+	}
+}
+
+func TestIterateAsync(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicTimeout)
+
+	for _, test := range []struct {
+		script string
+		want   []string
+	}{
+		{`(async function* () { yield 1; await new Promise(r => setTimeout(r, 1)); yield 2 })()`, []string{"1", "2"}},
+		// sync iterables are iterated as with for await
+		{`[Promise.resolve("a"), "b"]`, []string{"a", "b"}},
+	} {
+		var got []string
+		err := mustEval(t, r, test.script).IterateAsync(context.Background(), func(v *Value) (bool, error) {
+			got = append(got, v.String())
+			return true, nil
+		})
+		if err != nil {
+			t.Errorf("%s: %v", test.script, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.script, got, test.want)
+		}
+	}
+
+	err := mustEval(t, r, `(async function* () { yield 1; throw new RangeError("async") })()`).IterateAsync(context.Background(), func(*Value) (bool, error) {
+		return true, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "RangeError: async") {
+		t.Errorf("expected the error of the generator, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = mustEval(t, r, `(async function* () { await new Promise(() => {}) })()`).IterateAsync(ctx, func(*Value) (bool, error) {
+		return true, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("IterateAsync() = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,153 @@
+package js
+
+import (
+	"encoding/json"
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+var (
+	_ json.Marshaler   = (*Value)(nil)
+	_ json.Unmarshaler = (*Value)(nil)
+)
+
+// Stringify serializes v using the engine's JSON.stringify, so toJSON methods
+// are honoured. An empty indent produces compact output.
+//
+// An empty string is returned if v has no JSON representation, such as
+// undefined, functions and symbols.
+func (r *Realm) Stringify(v interface{}, indent string) (string, error) {
+	return r.StringifyWithReplacer(v, nil, indent)
+}
+
+// StringifyWithReplacer is like Stringify, but also takes a replacer function
+// or array of allowed property names as in JSON.stringify.
+func (r *Realm) StringifyWithReplacer(v, replacer interface{}, indent string) (string, error) {
+	value, err := r.Convert(v)
+	if err != nil {
+		return "", err
+	}
+
+	replacerValue := NewUndefined()
+	if replacer != nil {
+		replacerValue, err = r.Convert(replacer)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	spaceValue := NewUndefined()
+	if indent != "" {
+		spaceValue, err = r.NewString(indent)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	defer runtime.KeepAlive(value)
+	defer runtime.KeepAlive(replacerValue)
+	defer runtime.KeepAlive(spaceValue)
+
+	result, err := r.createAndResolveValue(internal.JSONStringify(r.context, value.value, replacerValue.value, spaceValue.value))
+	if err != nil {
+		return "", err
+	}
+
+	if !result.IsString() {
+		return "", nil
+	}
+
+	return result.ToString(), nil
+}
+
+// MarshalJSON implements json.Marshaler. Values without a JSON representation
+// are encoded as null.
+func (v *Value) MarshalJSON() ([]byte, error) {
+	if v.realm == nil {
+		// realm-less values are always primitives
+		return json.Marshal(v.Interface())
+	}
+
+	s, err := v.realm.Stringify(v, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if s == "" {
+		return []byte("null"), nil
+	}
+
+	return []byte(s), nil
+}
+
+// decodingRealms holds the realms of the calls to Realm.DecodeJSON that are
+// in progress by thread.
+var decodingRealms sync.Map
+
+// UnmarshalJSON implements json.Unmarshaler by replacing v with the parsed
+// value.
+//
+// Note that encoding/json allocates new values for *Value fields, elements of
+// slices and the like, which do not belong to a realm. Unmarshaling into
+// those fails with json.Unmarshal; use Realm.DecodeJSON instead. Values
+// that already belong to a realm, such as those returned by Realm.NewObject,
+// may be unmarshaled into with either.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	r := v.realm
+	if r == nil {
+		decoding, ok := decodingRealms.Load(currentThreadID())
+		if !ok {
+			return errors.New("js: cannot unmarshal into a value that does not belong to a realm, use Realm.DecodeJSON")
+		}
+
+		r = decoding.(*Realm)
+	}
+
+	parsed, err := r.ParseJSON(string(data), r.runtime.nextVMName())
+	if err != nil {
+		return err
+	}
+
+	if v.realm == nil || v.ref != nil {
+		// v has no finalizer, so parsed is kept alive instead
+		v.realm, v.value, v.ref = r, parsed.value, parsed
+		return nil
+	}
+
+	defer runtime.KeepAlive(parsed)
+
+	internal.FreeValue(r.context, v.value)
+	v.value = internal.DupValue(r.context, parsed.value)
+
+	return nil
+}
+
+// DecodeJSON is like json.Unmarshal, except that values of v that do not
+// belong to a realm, such as *Value fields that are allocated by
+// encoding/json, are unmarshaled into r.
+//
+// It must be called from the goroutine that owns the runtime.
+func (r *Realm) DecodeJSON(data []byte, v interface{}) error {
+	if !r.runtime.isSync() {
+		return errors.New("js: DecodeJSON must be called from the goroutine that owns the runtime")
+	}
+
+	id := currentThreadID()
+
+	// calls may be nested by the UnmarshalJSON methods of other types
+	prev, nested := decodingRealms.Load(id)
+	decodingRealms.Store(id, r)
+
+	defer func() {
+		if nested {
+			decodingRealms.Store(id, prev)
+		} else {
+			decodingRealms.Delete(id)
+		}
+	}()
+
+	return json.Unmarshal(data, v)
+}
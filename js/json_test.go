@@ -0,0 +1,138 @@
+package js
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStringify(t *testing.T) {
+	r := newTestRealm(t)
+
+	obj := mustEval(t, r, `({b: 1, a: [true, null], d: new Date(0), f() {}, u: undefined})`)
+
+	for _, test := range []struct {
+		indent   string
+		replacer interface{}
+		want     string
+	}{
+		{"", nil, `{"b":1,"a":[true,null],"d":"1970-01-01T00:00:00.000Z"}`},
+		{"  ", []interface{}{"a"}, "{\n  \"a\": [\n    true,\n    null\n  ]\n}"},
+		{"", mustEval(t, r, `(k, v) => typeof v === "number" ? v * 2 : v`), `{"b":2,"a":[true,null],"d":"1970-01-01T00:00:00.000Z"}`},
+	} {
+		got, err := r.StringifyWithReplacer(obj, test.replacer, test.indent)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+
+	if got, err := r.Stringify(mustEval(t, r, `undefined`), ""); err != nil || got != "" {
+		t.Errorf("undefined = %q, %v, want empty string", got, err)
+	}
+
+	if _, err := r.Stringify(mustEval(t, r, `var c = {}; c.c = c; c`), ""); err == nil {
+		t.Error("expected an error for a cyclic object")
+	}
+}
+
+func TestValueMarshalJSON(t *testing.T) {
+	r := newTestRealm(t)
+
+	data, err := json.Marshal(map[string]interface{}{
+		"obj":   mustEval(t, r, `({toJSON() { return "custom" }})`),
+		"fn":    mustEval(t, r, `(function () {})`),
+		"plain": NewNull(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"fn":null,"obj":"custom","plain":null}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
+
+func TestValueUnmarshalJSON(t *testing.T) {
+	r := newTestRealm(t)
+
+	v := Must(r.NewObject())
+	if err := json.Unmarshal([]byte(`{"a": [1, 2]}`), v); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Must(v.Get("a")).String(); got != "[1 2]" {
+		t.Errorf("a = %s", got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"a":`), v); err == nil {
+		t.Error("expected a syntax error")
+	}
+
+	// encoding/json allocates values without a realm
+	var s struct{ V *Value }
+	if err := json.Unmarshal([]byte(`{"V": {}}`), &s); err == nil || !strings.Contains(err.Error(), "Realm.DecodeJSON") {
+		t.Errorf("got %v, want an error that refers to Realm.DecodeJSON", err)
+	}
+}
+
+func TestRealmDecodeJSON(t *testing.T) {
+	r := newTestRealm(t)
+
+	var s struct {
+		Ptr   *Value
+		Plain Value
+		List  []*Value
+		Map   map[string]*Value
+		Name  string
+	}
+
+	if err := r.DecodeJSON([]byte(`{"Ptr": {"x": 1}, "Plain": "p", "List": [1, "two"], "Map": {"k": [3]}, "Name": "n"}`), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	// the values must stay alive after the parsed values are unreachable
+	runtime.GC()
+	runtime.GC()
+
+	if got := Must(s.Ptr.Get("x")).ToInt(); got != 1 {
+		t.Errorf("Ptr.x = %d", got)
+	}
+
+	if got := s.Plain.String(); got != "p" {
+		t.Errorf("Plain = %s", got)
+	}
+
+	if len(s.List) != 2 || s.List[1].String() != "two" {
+		t.Errorf("List = %v", s.List)
+	}
+
+	if !s.Map["k"].IsArray() {
+		t.Errorf("Map.k = %v", s.Map["k"])
+	}
+
+	if s.Name != "n" {
+		t.Errorf("Name = %s", s.Name)
+	}
+
+	// values may be unmarshaled into again
+	if err := r.DecodeJSON([]byte(`{"Ptr": [true]}`), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Ptr.IsArray() {
+		t.Errorf("Ptr = %v", s.Ptr)
+	}
+
+	if err := r.DecodeJSON([]byte(`{"Ptr": `), &s); err == nil {
+		t.Error("expected a syntax error")
+	}
+
+	if _, ok := decodingRealms.Load(currentThreadID()); ok {
+		t.Error("the realm is still registered after DecodeJSON returned")
+	}
+}
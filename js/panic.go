@@ -0,0 +1,64 @@
+package js
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is thrown as an InternalError when a go function that is called
+// from js panics.
+type PanicError struct {
+	// Value is the value that was passed to panic.
+	Value interface{}
+
+	// Stack is the go stack trace of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) isInternalError() {}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverPanic recovers from a panic in a go function that is called from js
+// and passes it to throw as a *PanicError. It must be deferred directly.
+func recoverPanic(throw func(err error)) {
+	p := recover()
+	if p == nil {
+		return
+	}
+
+	err, ok := p.(*PanicError)
+	if !ok {
+		err = &PanicError{
+			Value: p,
+			Stack: debug.Stack(),
+		}
+	}
+
+	throw(err)
+}
+
+// SetRepanic sets whether panics in go functions that are called from js are
+// raised again in go once the exception that they were converted to
+// propagates out of js. The panic value is the *PanicError.
+func (rt *Runtime) SetRepanic(b bool) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	rt.repanic = b
+}
+
+func (rt *Runtime) shouldRepanic() bool {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	return rt.repanic
+}
@@ -0,0 +1,130 @@
+package js
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// panickingStore is a dynamic object that panics when a property is read.
+type panickingStore struct {
+	testStore
+}
+
+func (s *panickingStore) Get(key string) (interface{}, bool, error) {
+	panic("get " + key)
+}
+
+type panickingObject struct{}
+
+func (panickingObject) Explode() {
+	panic(io.EOF)
+}
+
+func TestPanicRecovered(t *testing.T) {
+	r := newTestRealm(t)
+
+	explode, err := r.NewFunction(func(r *Realm, this *Value) {
+		panic("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := r.NewDynamicObject(&panickingStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	class, err := r.runtime.DefineClass(ClassSpec{
+		Name: "Exploding",
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			panic("construct")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctor, err := class.Constructor(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "explode", explode)
+	setGlobal(t, r, "store", store)
+	setGlobal(t, r, "Exploding", ctor)
+	setGlobal(t, r, "object", panickingObject{})
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`explode()`, "panic: boom"},
+		{`store.key`, "panic: get key"},
+		{`new Exploding()`, "panic: construct"},
+		{`object.Explode()`, "panic: EOF"},
+	} {
+		// the panic is catchable as an InternalError
+		expectString(t, r, `try { `+test.script+` } catch (e) { e.name + ": " + e.message }`, "InternalError: "+test.want)
+
+		_, err := r.Eval(test.script)
+
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) {
+			t.Errorf("%s: got error %v, want a *PanicError", test.script, err)
+			continue
+		}
+
+		if panicErr.Error() != test.want || len(panicErr.Stack) == 0 {
+			t.Errorf("%s: got %q with stack %q", test.script, panicErr.Error(), panicErr.Stack)
+		}
+	}
+
+	// panics with an error value unwrap to that error
+	if _, err := r.Eval(`object.Explode()`); !errors.Is(err, io.EOF) {
+		t.Errorf("got error %v, want %v", err, io.EOF)
+	}
+}
+
+func TestPanicRepanic(t *testing.T) {
+	r := newTestRealm(t)
+
+	explode, err := r.NewFunction(func(r *Realm, this *Value) {
+		panic("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "explode", explode)
+
+	r.runtime.SetRepanic(true)
+
+	// panics that are caught in js are not raised again
+	expectString(t, r, `try { explode() } catch { "caught" }`, "caught")
+
+	func() {
+		defer func() {
+			panicErr, ok := recover().(*PanicError)
+			if !ok || panicErr.Value != "boom" || !strings.Contains(string(panicErr.Stack), "panic_test.go") {
+				t.Errorf("recovered %v, want the *PanicError", panicErr)
+			}
+		}()
+
+		r.Eval(`explode()`)
+		t.Error("expected the panic to be raised again")
+	}()
+
+	// errors that are not panics are returned as usual
+	if _, err := r.Eval(`throw new Error("plain")`); err == nil {
+		t.Error("expected an error")
+	}
+
+	r.runtime.SetRepanic(false)
+
+	if _, err := r.Eval(`explode()`); err == nil {
+		t.Error("expected an error")
+	}
+}
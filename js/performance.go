@@ -0,0 +1,699 @@
+package js
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PerformanceEntry is a mark or measure that was recorded with
+// performance.mark or performance.measure.
+type PerformanceEntry struct {
+	Name string
+
+	// EntryType is either "mark" or "measure".
+	EntryType string
+
+	// StartTime is relative to the time origin of the realm.
+	StartTime time.Duration
+	Duration  time.Duration
+
+	// Detail is the detail of the entry, or nil if it is null. Like other
+	// values, it may only be used on the goroutine that owns the runtime.
+	Detail *Value
+}
+
+// performanceEntry is the opaque value of PerformanceEntry and its
+// subclasses.
+type performanceEntry struct {
+	name      string
+	entryType string
+	startTime float64
+	duration  float64
+	detail    *Value
+
+	// value is the object of the entry if it was recorded in the timeline
+	value *Value
+}
+
+func thisPerformanceEntry(this *Value) (*performanceEntry, error) {
+	e, ok := this.Opaque().(*performanceEntry)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return e, nil
+}
+
+// performanceTimeline holds the entries that were recorded in a realm, which
+// may be read from any goroutine.
+type performanceTimeline struct {
+	mark    *Class
+	measure *Class
+
+	mutex   sync.Mutex
+	entries []*performanceEntry
+}
+
+// performanceObject is the opaque value of the performance global.
+type performanceObject struct{}
+
+func thisPerformance(this *Value) error {
+	if _, ok := this.Opaque().(*performanceObject); !ok {
+		return NewTypeError("Illegal invocation")
+	}
+
+	return nil
+}
+
+// now returns the number of milliseconds since the time origin of r, which is
+// measured with the monotonic clock.
+func (r *Realm) now() float64 {
+	return float64(time.Since(r.timeOrigin)) / float64(time.Millisecond)
+}
+
+func (t *performanceTimeline) add(e *performanceEntry) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.entries = append(t.entries, e)
+}
+
+// filter returns the entries with the given name and type in chronological
+// order. Empty strings match any name or type.
+func (t *performanceTimeline) filter(name, entryType string) []*performanceEntry {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var entries []*performanceEntry
+	for _, e := range t.entries {
+		if (name == "" || e.name == name) && (entryType == "" || e.entryType == entryType) {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].startTime < entries[j].startTime
+	})
+
+	return entries
+}
+
+// clear removes the entries of the given type with the given name, or any
+// name if it is empty.
+func (t *performanceTimeline) clear(name, entryType string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	entries := t.entries[:0]
+	for _, e := range t.entries {
+		if e.entryType != entryType || (name != "" && e.name != name) {
+			entries = append(entries, e)
+		}
+	}
+
+	// the removed entries are released
+	for i := len(entries); i < len(t.entries); i++ {
+		t.entries[i] = nil
+	}
+
+	t.entries = entries
+}
+
+// markTime returns the start time of the most recent mark with the given
+// name.
+func (t *performanceTimeline) markTime(name string) (float64, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for i := len(t.entries) - 1; i >= 0; i-- {
+		if e := t.entries[i]; e.entryType == "mark" && e.name == name {
+			return e.startTime, nil
+		}
+	}
+
+	return 0, newDOMException("SyntaxError", "The mark '%s' does not exist.", name)
+}
+
+// timestamp converts the start or end of a measure, which is either the name
+// of a mark or a time, to a time.
+func (t *performanceTimeline) timestamp(r *Realm, v *Value) (float64, error) {
+	if v.IsNumber() {
+		ts := v.ToFloat()
+		if ts < 0 {
+			return 0, NewTypeError("'%v' cannot have a negative time stamp.", ts)
+		}
+
+		return ts, nil
+	}
+
+	name, err := r.toString(v)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.markTime(name)
+}
+
+// entryName returns the name argument of mark and measure.
+func entryName(r *Realm, args []*Value) (string, error) {
+	if len(args) == 0 {
+		return "", NewTypeError("1 argument required, but only 0 present")
+	}
+
+	return r.toString(args[0])
+}
+
+// cloneDetail structurally clones the detail of an entry. nil is returned for
+// undefined and null.
+func cloneDetail(r *Realm, detail *Value) (*Value, error) {
+	if detail == nil || detail.Tag() == TagUndefined || detail.Tag() == TagNull {
+		return nil, nil
+	}
+
+	return structuredClone(r, nil, detail)
+}
+
+// optionalMember returns the member of an options object, or nil if it is
+// undefined.
+func optionalMember(options *Value, name string) (*Value, error) {
+	member, err := options.Get(name)
+	if err != nil || member.Tag() == TagUndefined {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// newMark creates a mark from the arguments of the PerformanceMark
+// constructor and performance.mark.
+func newMark(r *Realm, args []*Value) (*performanceEntry, error) {
+	name, err := entryName(r, args)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &performanceEntry{
+		name:      name,
+		entryType: "mark",
+	}
+
+	var startTime, detail *Value
+	if options := optionalArg(args, 1); options.IsObject() {
+		if startTime, err = optionalMember(options, "startTime"); err != nil {
+			return nil, err
+		}
+
+		if detail, err = optionalMember(options, "detail"); err != nil {
+			return nil, err
+		}
+	}
+
+	if startTime == nil {
+		e.startTime = r.now()
+	} else if e.startTime, err = r.toNumber(startTime); err != nil {
+		return nil, err
+	} else if e.startTime < 0 || math.IsNaN(e.startTime) {
+		return nil, NewTypeError("'%s' cannot have a negative start time.", name)
+	}
+
+	if e.detail, err = cloneDetail(r, detail); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// measureOptions are the members of the PerformanceMeasureOptions argument
+// of performance.measure, which are nil if they are undefined.
+type measureOptions struct {
+	start    *Value
+	end      *Value
+	duration *Value
+	detail   *Value
+}
+
+func (o measureOptions) empty() bool {
+	return o.start == nil && o.end == nil && o.duration == nil && o.detail == nil
+}
+
+// newMeasure creates a measure from the arguments of performance.measure.
+func (t *performanceTimeline) newMeasure(r *Realm, args []*Value) (*performanceEntry, error) {
+	name, err := entryName(r, args)
+	if err != nil {
+		return nil, err
+	}
+
+	startOrOptions, endMark := optionalArg(args, 1), optionalArg(args, 2)
+
+	var options measureOptions
+	if startOrOptions.IsObject() {
+		for _, member := range []struct {
+			name  string
+			value **Value
+		}{
+			{"start", &options.start},
+			{"end", &options.end},
+			{"duration", &options.duration},
+			{"detail", &options.detail},
+		} {
+			if *member.value, err = optionalMember(startOrOptions, member.name); err != nil {
+				return nil, err
+			}
+		}
+
+		if !options.empty() {
+			switch {
+			case endMark.Tag() != TagUndefined:
+				return nil, NewTypeError("If a non-empty PerformanceMeasureOptions object was passed, |end_mark| must not be passed.")
+			case options.start == nil && options.end == nil:
+				return nil, NewTypeError("If a non-empty PerformanceMeasureOptions object was passed, at least one of its 'start' or 'end' properties must be present.")
+			case options.start != nil && options.duration != nil && options.end != nil:
+				return nil, NewTypeError("If a non-empty PerformanceMeasureOptions object was passed, it must not have all of its 'start', 'duration', and 'end' properties defined")
+			}
+		}
+	}
+
+	var duration float64
+	if options.duration != nil {
+		if duration, err = r.toNumber(options.duration); err != nil {
+			return nil, err
+		}
+	}
+
+	var end float64
+	switch {
+	case endMark.Tag() != TagUndefined:
+		endName, err := r.toString(endMark)
+		if err != nil {
+			return nil, err
+		}
+
+		if end, err = t.markTime(endName); err != nil {
+			return nil, err
+		}
+	case options.end != nil:
+		if end, err = t.timestamp(r, options.end); err != nil {
+			return nil, err
+		}
+	case options.start != nil && options.duration != nil:
+		start, err := t.timestamp(r, options.start)
+		if err != nil {
+			return nil, err
+		}
+
+		end = start + duration
+	default:
+		end = r.now()
+	}
+
+	var start float64
+	switch {
+	case options.start != nil:
+		if start, err = t.timestamp(r, options.start); err != nil {
+			return nil, err
+		}
+	case options.duration != nil && options.end != nil:
+		start = end - duration
+	case !startOrOptions.IsObject() && startOrOptions.Tag() != TagUndefined:
+		startName, err := r.toString(startOrOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		if start, err = t.markTime(startName); err != nil {
+			return nil, err
+		}
+	}
+
+	detail, err := cloneDetail(r, options.detail)
+	if err != nil {
+		return nil, err
+	}
+
+	return &performanceEntry{
+		name:      name,
+		entryType: "measure",
+		startTime: start,
+		duration:  end - start,
+		detail:    detail,
+	}, nil
+}
+
+// record adds e to the timeline and returns its object.
+func (t *performanceTimeline) record(r *Realm, class *Class, e *performanceEntry) (*Value, error) {
+	value, err := class.NewInstance(r, e)
+	if err != nil {
+		return nil, err
+	}
+
+	e.value = value
+	t.add(e)
+
+	return value, nil
+}
+
+// entryValues returns the objects of entries.
+func entryValues(entries []*performanceEntry) []*Value {
+	values := make([]*Value, len(entries))
+	for i, e := range entries {
+		values[i] = e.value
+	}
+
+	return values
+}
+
+// optionalStringArg converts the argument at i to a string, or returns an
+// empty string if it is undefined.
+func optionalStringArg(r *Realm, args []*Value, i int) (string, error) {
+	if i >= len(args) || args[i].Tag() == TagUndefined {
+		return "", nil
+	}
+
+	return r.toString(args[i])
+}
+
+func performanceEntryProperty(get func(e *performanceEntry) interface{}) ClassProperty {
+	return ClassProperty{
+		Get: func(r *Realm, this *Value) (interface{}, error) {
+			e, err := thisPerformanceEntry(this)
+			if err != nil {
+				return nil, err
+			}
+
+			return get(e), nil
+		},
+	}
+}
+
+var performanceEntrySpec = ClassSpec{
+	Name: "PerformanceEntry",
+	Methods: map[string]interface{}{
+		"toJSON": func(r *Realm, this *Value) (*Value, error) {
+			e, err := thisPerformanceEntry(this)
+			if err != nil {
+				return nil, err
+			}
+
+			obj, err := r.NewObject()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, member := range []struct {
+				name  string
+				value interface{}
+			}{
+				{"name", e.name},
+				{"entryType", e.entryType},
+				{"startTime", e.startTime},
+				{"duration", e.duration},
+				{"detail", nullable(e.detail)},
+			} {
+				if _, err := obj.Set(member.name, member.value); err != nil {
+					return nil, err
+				}
+			}
+
+			return obj, nil
+		},
+	},
+	Properties: map[string]ClassProperty{
+		"name": performanceEntryProperty(func(e *performanceEntry) interface{} {
+			return e.name
+		}),
+		"entryType": performanceEntryProperty(func(e *performanceEntry) interface{} {
+			return e.entryType
+		}),
+		"startTime": performanceEntryProperty(func(e *performanceEntry) interface{} {
+			return e.startTime
+		}),
+		"duration": performanceEntryProperty(func(e *performanceEntry) interface{} {
+			return e.duration
+		}),
+	},
+}
+
+// detailProperty is the detail property of PerformanceMark and
+// PerformanceMeasure.
+var detailProperty = performanceEntryProperty(func(e *performanceEntry) interface{} {
+	return nullable(e.detail)
+})
+
+func newPerformanceMarkSpec(entry *Class) ClassSpec {
+	return ClassSpec{
+		Name:    "PerformanceMark",
+		Extends: entry,
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			return newMark(r, args)
+		},
+		Length: 1,
+		Properties: map[string]ClassProperty{
+			"detail": detailProperty,
+		},
+	}
+}
+
+func newPerformanceMeasureSpec(entry *Class) ClassSpec {
+	return ClassSpec{
+		Name:    "PerformanceMeasure",
+		Extends: entry,
+		Properties: map[string]ClassProperty{
+			"detail": detailProperty,
+		},
+	}
+}
+
+func (t *performanceTimeline) performanceSpec() ClassSpec {
+	return ClassSpec{
+		Name: "Performance",
+		Methods: map[string]interface{}{
+			"now": func(r *Realm, this *Value) (float64, error) {
+				if err := thisPerformance(this); err != nil {
+					return 0, err
+				}
+
+				return r.now(), nil
+			},
+			"mark": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+				if err := thisPerformance(this); err != nil {
+					return nil, err
+				}
+
+				e, err := newMark(r, args)
+				if err != nil {
+					return nil, err
+				}
+
+				return t.record(r, t.mark, e)
+			},
+			"measure": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+				if err := thisPerformance(this); err != nil {
+					return nil, err
+				}
+
+				e, err := t.newMeasure(r, args)
+				if err != nil {
+					return nil, err
+				}
+
+				return t.record(r, t.measure, e)
+			},
+			"getEntries": func(r *Realm, this *Value) ([]*Value, error) {
+				if err := thisPerformance(this); err != nil {
+					return nil, err
+				}
+
+				return entryValues(t.filter("", "")), nil
+			},
+			"getEntriesByType": func(r *Realm, this *Value, args ...*Value) ([]*Value, error) {
+				if err := thisPerformance(this); err != nil {
+					return nil, err
+				}
+
+				if len(args) == 0 {
+					return nil, NewTypeError("1 argument required, but only 0 present")
+				}
+
+				entryType, err := r.toString(args[0])
+				if err != nil {
+					return nil, err
+				}
+
+				if entryType == "" {
+					return []*Value{}, nil
+				}
+
+				return entryValues(t.filter("", entryType)), nil
+			},
+			"getEntriesByName": func(r *Realm, this *Value, args ...*Value) ([]*Value, error) {
+				if err := thisPerformance(this); err != nil {
+					return nil, err
+				}
+
+				if len(args) == 0 {
+					return nil, NewTypeError("1 argument required, but only 0 present")
+				}
+
+				name, err := r.toString(args[0])
+				if err != nil {
+					return nil, err
+				}
+
+				entryType, err := optionalStringArg(r, args, 1)
+				if err != nil {
+					return nil, err
+				}
+
+				if name == "" {
+					return []*Value{}, nil
+				}
+
+				return entryValues(t.filter(name, entryType)), nil
+			},
+			"clearMarks": func(r *Realm, this *Value, args ...*Value) error {
+				if err := thisPerformance(this); err != nil {
+					return err
+				}
+
+				name, err := optionalStringArg(r, args, 0)
+				if err != nil {
+					return err
+				}
+
+				t.clear(name, "mark")
+
+				return nil
+			},
+			"clearMeasures": func(r *Realm, this *Value, args ...*Value) error {
+				if err := thisPerformance(this); err != nil {
+					return err
+				}
+
+				name, err := optionalStringArg(r, args, 0)
+				if err != nil {
+					return err
+				}
+
+				t.clear(name, "measure")
+
+				return nil
+			},
+			"toJSON": func(r *Realm, this *Value) (*Value, error) {
+				if err := thisPerformance(this); err != nil {
+					return nil, err
+				}
+
+				obj, err := r.NewObject()
+				if err != nil {
+					return nil, err
+				}
+
+				if _, err := obj.Set("timeOrigin", r.timeOriginMillis()); err != nil {
+					return nil, err
+				}
+
+				return obj, nil
+			},
+		},
+		Properties: map[string]ClassProperty{
+			"timeOrigin": {
+				Get: func(r *Realm, this *Value) (float64, error) {
+					if err := thisPerformance(this); err != nil {
+						return 0, err
+					}
+
+					return r.timeOriginMillis(), nil
+				},
+			},
+		},
+	}
+}
+
+// timeOriginMillis returns the time origin of r as the number of
+// milliseconds since the Unix epoch.
+func (r *Realm) timeOriginMillis() float64 {
+	return float64(r.timeOrigin.UnixNano()) / float64(time.Millisecond)
+}
+
+// definePerformance defines performance, Performance, PerformanceEntry,
+// PerformanceMark and PerformanceMeasure as globals of r.
+func (r *Realm) definePerformance() error {
+	if err := r.defineDOMException(); err != nil {
+		return err
+	}
+
+	t := &performanceTimeline{}
+
+	entry, err := r.defineGlobalClass(performanceEntrySpec)
+	if err != nil {
+		return err
+	}
+
+	if t.mark, err = r.defineGlobalClass(newPerformanceMarkSpec(entry)); err != nil {
+		return err
+	}
+
+	if t.measure, err = r.defineGlobalClass(newPerformanceMeasureSpec(entry)); err != nil {
+		return err
+	}
+
+	performanceClass, err := r.defineGlobalClass(t.performanceSpec())
+	if err != nil {
+		return err
+	}
+
+	if err := r.defineClassToStringTags(entry, t.mark, t.measure, performanceClass); err != nil {
+		return err
+	}
+
+	instance, err := performanceClass.NewInstance(r, &performanceObject{})
+	if err != nil {
+		return err
+	}
+
+	global, err := r.GlobalObject()
+	if err != nil {
+		return err
+	}
+
+	if _, err := global.DefineProperty("performance", DefinePropertyValue(instance), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return err
+	}
+
+	r.performance = t
+
+	return nil
+}
+
+// TimeOrigin returns the time at which r was created, which performance.now
+// and the start times of performance entries are relative to.
+func (r *Realm) TimeOrigin() time.Time {
+	return r.timeOrigin
+}
+
+// PerformanceEntries returns the marks and measures that are recorded in the
+// performance timeline of r in chronological order. It may be called from any
+// goroutine, such as after the script has finished, and returns nil if the
+// realm was not created with AddIntrinsicPerformance.
+func (r *Realm) PerformanceEntries() []PerformanceEntry {
+	if r.performance == nil {
+		return nil
+	}
+
+	entries := r.performance.filter("", "")
+
+	result := make([]PerformanceEntry, len(entries))
+	for i, e := range entries {
+		result[i] = PerformanceEntry{
+			Name:      e.name,
+			EntryType: e.entryType,
+			StartTime: time.Duration(e.startTime * float64(time.Millisecond)),
+			Duration:  time.Duration(e.duration * float64(time.Millisecond)),
+			Detail:    e.detail,
+		}
+	}
+
+	return result
+}
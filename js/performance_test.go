@@ -0,0 +1,179 @@
+package js
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPerformanceNow(t *testing.T) {
+	before := time.Now()
+	r := newTestRealm(t, AddIntrinsicPerformance)
+	after := time.Now()
+
+	if origin := r.TimeOrigin(); origin.Before(before) || origin.After(after) {
+		t.Errorf("TimeOrigin() = %v, want between %v and %v", origin, before, after)
+	}
+
+	expectString(t, r, `{ const a = performance.now(); const b = performance.now(); a >= 0 && b >= a }`, "true")
+	expectString(t, r, `performance.timeOrigin === performance.toJSON().timeOrigin`, "true")
+	expectString(t, r, `Math.abs(performance.timeOrigin - Date.now()) < 60000`, "true")
+	expectString(t, r, `performance instanceof Performance && String(performance)`, "[object Performance]")
+
+	for _, script := range []string{
+		`new Performance()`,
+		`new PerformanceEntry()`,
+		`new PerformanceMeasure()`,
+		`Reflect.apply(performance.now, {}, [])`,
+		`Reflect.apply(performance.mark, {}, ["m"])`,
+		`Reflect.apply(Object.getOwnPropertyDescriptor(Performance.prototype, "timeOrigin").get, {}, [])`,
+		`Reflect.apply(Object.getOwnPropertyDescriptor(PerformanceEntry.prototype, "name").get, {}, [])`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { e.name }`, "TypeError")
+	}
+}
+
+func TestPerformanceMark(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicPerformance)
+
+	mustEval(t, r, `globalThis.m = performance.mark("a", {startTime: 5, detail: {n: 1}})`)
+
+	expectString(t, r, `m instanceof PerformanceMark && m instanceof PerformanceEntry && String(m)`, "[object PerformanceMark]")
+	expectString(t, r, `[m.name, m.entryType, m.startTime, m.duration, m.detail.n].join()`, "a,mark,5,0,1")
+	expectString(t, r, `JSON.stringify(m)`, `{"name":"a","entryType":"mark","startTime":5,"duration":0,"detail":{"n":1}}`)
+
+	// the detail is cloned
+	expectString(t, r, `{ const detail = {n: 1}; const m = performance.mark("b", {detail}); detail.n = 2; m.detail !== detail && m.detail.n }`, "1")
+
+	expectString(t, r, `performance.mark("c").detail`, "null")
+	expectString(t, r, `{ const start = performance.now(); const m = performance.mark("d"); m.startTime >= start && m.startTime <= performance.now() }`, "true")
+
+	// marks that are constructed directly are not recorded
+	expectString(t, r, `{ const m = new PerformanceMark("e", {startTime: 1}); [m.name, m.startTime, performance.getEntriesByName("e").length].join() }`, "e,1,0")
+
+	for _, script := range []string{
+		`performance.mark()`,
+		`performance.mark("x", {startTime: -1})`,
+		`performance.mark("x", {startTime: NaN})`,
+		`PerformanceMark("x")`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { e.name }`, "TypeError")
+	}
+
+	expectString(t, r, `try { performance.mark("x", {detail: () => {}}) } catch (e) { e.name }`, "DataCloneError")
+}
+
+func TestPerformanceMeasure(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicPerformance)
+
+	mustEval(t, r, `
+		performance.mark("start", {startTime: 10});
+		performance.mark("end", {startTime: 25});
+	`)
+
+	measure := func(args string) string {
+		return `{ const m = performance.measure(` + args + `); [m.name, m.entryType, m.startTime, m.duration].join() }`
+	}
+
+	expectString(t, r, measure(`"m", "start", "end"`), "m,measure,10,15")
+	expectString(t, r, measure(`"m", {start: "start", end: "end"}`), "m,measure,10,15")
+	expectString(t, r, measure(`"m", {start: 2, end: 7}`), "m,measure,2,5")
+	expectString(t, r, measure(`"m", {start: "start", duration: 3}`), "m,measure,10,3")
+	expectString(t, r, measure(`"m", {duration: 3, end: "end"}`), "m,measure,22,3")
+	expectString(t, r, measure(`"m", undefined, "end"`), "m,measure,0,25")
+
+	// without a start the measure starts at the time origin and without an
+	// end it ends now
+	expectString(t, r, `{ const m = performance.measure("m", {}); m.startTime === 0 && m.duration > 0 }`, "true")
+	expectString(t, r, `{ const m = performance.measure("m", "end"); m.startTime === 25 && m.startTime + m.duration <= performance.now() }`, "true")
+
+	// the most recent mark with a name is used
+	expectString(t, r, `performance.mark("start", {startTime: 20}); performance.measure("m", "start", "end").duration`, "5")
+
+	expectString(t, r, `{ const m = performance.measure("m", {start: 1, end: 2, detail: [1]}); m instanceof PerformanceMeasure && m.detail[0] }`, "1")
+	expectString(t, r, `performance.measure("m", "start", "end").detail`, "null")
+
+	for _, script := range []string{
+		`performance.measure()`,
+		`performance.measure("m", {start: 1}, "end")`,
+		`performance.measure("m", {duration: 1})`,
+		`performance.measure("m", {detail: 1})`,
+		`performance.measure("m", {start: 1, duration: 1, end: 2})`,
+		`performance.measure("m", {start: -1, end: 2})`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { e.name }`, "TypeError")
+	}
+
+	for _, script := range []string{
+		`performance.measure("m", "missing")`,
+		`performance.measure("m", "start", "missing")`,
+		`performance.measure("m", {start: "missing", end: 1})`,
+		`performance.measure("m", {end: "missing"})`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { [e instanceof DOMException, e.name, e.message].join() }`, "true,SyntaxError,The mark 'missing' does not exist.")
+	}
+}
+
+func TestPerformanceEntries(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicPerformance)
+
+	mustEval(t, r, `
+		performance.mark("b", {startTime: 20});
+		performance.mark("a", {startTime: 10, detail: "first"});
+		performance.measure("a", {start: 5, end: 30});
+		performance.mark("c", {startTime: 30});
+	`)
+
+	names := func(list string) string {
+		return list + `.map((e) => e.entryType + ":" + e.name).join()`
+	}
+
+	// entries are sorted by their start time
+	expectString(t, r, names(`performance.getEntries()`), "measure:a,mark:a,mark:b,mark:c")
+	expectString(t, r, names(`performance.getEntriesByType("mark")`), "mark:a,mark:b,mark:c")
+	expectString(t, r, names(`performance.getEntriesByType("measure")`), "measure:a")
+	expectString(t, r, names(`performance.getEntriesByType("")`), "")
+	expectString(t, r, names(`performance.getEntriesByType("resource")`), "")
+	expectString(t, r, names(`performance.getEntriesByName("a")`), "measure:a,mark:a")
+	expectString(t, r, names(`performance.getEntriesByName("a", "mark")`), "mark:a")
+	expectString(t, r, names(`performance.getEntriesByName("")`), "")
+	expectString(t, r, `performance.getEntriesByName("a", "mark")[0] === performance.getEntriesByType("mark")[0]`, "true")
+
+	entries := r.PerformanceEntries()
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+
+	if e := entries[0]; e.Name != "a" || e.EntryType != "measure" || e.StartTime != 5*time.Millisecond || e.Duration != 25*time.Millisecond || e.Detail != nil {
+		t.Errorf("entries[0] = %+v", e)
+	}
+
+	if e := entries[1]; e.Name != "a" || e.EntryType != "mark" || e.StartTime != 10*time.Millisecond || e.Duration != 0 || e.Detail == nil || e.Detail.String() != "first" {
+		t.Errorf("entries[1] = %+v", e)
+	}
+
+	expectString(t, r, `performance.clearMarks("a"); `+names(`performance.getEntries()`), "measure:a,mark:b,mark:c")
+	expectString(t, r, `performance.clearMeasures(); `+names(`performance.getEntries()`), "mark:b,mark:c")
+	expectString(t, r, `performance.clearMarks(); performance.getEntries().length`, "0")
+
+	if entries := r.PerformanceEntries(); len(entries) != 0 {
+		t.Errorf("got %d entries after clearing, want 0", len(entries))
+	}
+
+	for _, script := range []string{
+		`performance.getEntriesByType()`,
+		`performance.getEntriesByName()`,
+		`Reflect.apply(performance.getEntries, {}, [])`,
+		`Reflect.apply(performance.clearMarks, {}, [])`,
+		`Reflect.apply(PerformanceEntry.prototype.toJSON, {}, [])`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { e.name }`, "TypeError")
+	}
+}
+
+func TestPerformanceEntriesWithoutIntrinsic(t *testing.T) {
+	r := newTestRealm(t)
+
+	if entries := r.PerformanceEntries(); entries != nil {
+		t.Errorf("PerformanceEntries() = %v, want nil", entries)
+	}
+}
@@ -0,0 +1,165 @@
+package js
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+type PromiseState int
+
+const (
+	PromisePending   = PromiseState(internal.PromiseStatePending)
+	PromiseFulfilled = PromiseState(internal.PromiseStateFulfilled)
+	PromiseRejected  = PromiseState(internal.PromiseStateRejected)
+)
+
+func (s PromiseState) String() string {
+	switch s {
+	case PromisePending:
+		return "pending"
+
+	case PromiseFulfilled:
+		return "fulfilled"
+
+	case PromiseRejected:
+		return "rejected"
+	}
+
+	return "unknown"
+}
+
+// NewPromise creates a pending promise along with the functions that settle
+// it. Values passed to resolve and reject are converted with Convert.
+//
+// The settling functions may be called from any goroutine. When called
+// outside of the goroutine that owns the runtime, the promise is settled by
+// the event loop and nil is returned.
+func (r *Realm) NewPromise() (promise *Value, resolve, reject func(interface{}) error) {
+	promiseValue, resolveValue, rejectValue := internal.NewPromiseCapability(r.context)
+
+	promise, err := r.createAndResolveValue(promiseValue)
+	if err != nil {
+		settleError := func(interface{}) error {
+			return err
+		}
+
+		return nil, settleError, settleError
+	}
+
+	return promise, r.promiseSettler(r.createValue(resolveValue)), r.promiseSettler(r.createValue(rejectValue))
+}
+
+func (r *Realm) promiseSettler(fn *Value) func(interface{}) error {
+	return func(value interface{}) error {
+		if !r.runtime.isSync() {
+			fn.CallAsync(nil, value)
+			return nil
+		}
+
+		_, err := fn.Call(nil, value)
+		return err
+	}
+}
+
+// IsPromise reports whether v is a promise.
+func (v *Value) IsPromise() bool {
+	_, ok := v.PromiseState()
+	return ok
+}
+
+// PromiseState returns the state of a promise or false if v is not a
+// promise.
+func (v *Value) PromiseState() (PromiseState, bool) {
+	defer runtime.KeepAlive(v)
+
+	state := internal.GetPromiseState(v.realm.context, v.value)
+	if state == internal.PromiseStateNone {
+		return 0, false
+	}
+
+	return PromiseState(state), true
+}
+
+// Await waits for v to settle and returns its value, or its rejection reason
+// as an *Error. Values that are not thenable are returned as is.
+//
+// When called from the goroutine that owns the runtime, the event loop is
+// run until v settles, waiting for tasks from other goroutines if necessary.
+// Otherwise, the event loop must be running elsewhere.
+func (v *Value) Await(ctx context.Context) (*Value, error) {
+	if !v.IsObject() {
+		return v, nil
+	}
+
+	r := v.realm
+
+	if !r.runtime.isSync() {
+		result := make(chan *AsyncResult, 1)
+		r.runtime.enqueueTask(func() error {
+			if err := v.then(func(res *AsyncResult) {
+				result <- res
+			}); err != nil {
+				result <- &AsyncResult{Error: err}
+			}
+
+			return nil
+		})
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
+		case res := <-result:
+			return res.Value, res.Error
+		}
+	}
+
+	var result *AsyncResult
+	if err := v.then(func(res *AsyncResult) {
+		result = res
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := r.runtime.runEventLoop(ctx, true, func() bool {
+		return result != nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return result.Value, result.Error
+}
+
+// then calls settled once v settles.
+func (v *Value) then(settled func(*AsyncResult)) error {
+	r := v.realm
+
+	promiseConstructor, err := r.globalProperty("Promise")
+	if err != nil {
+		return err
+	}
+
+	promise, err := promiseConstructor.Invoke("resolve", v)
+	if err != nil {
+		return err
+	}
+
+	onFulfilled, err := r.NewFunction(func(r *Realm, _ *Value, value *Value) {
+		settled(&AsyncResult{Value: value})
+	})
+	if err != nil {
+		return err
+	}
+
+	onRejected, err := r.NewFunction(func(r *Realm, _ *Value, reason *Value) {
+		settled(&AsyncResult{Error: (*Error)(reason)})
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = promise.Invoke("then", onFulfilled, onRejected)
+	return err
+}
@@ -0,0 +1,124 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewPromise(t *testing.T) {
+	r := newTestRealm(t)
+
+	promise, resolve, _ := r.NewPromise()
+
+	if state, ok := promise.PromiseState(); !ok || state != PromisePending {
+		t.Errorf("PromiseState() = %v, %v, want pending", state, ok)
+	}
+
+	if err := resolve("done"); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, _ := promise.PromiseState(); state != PromiseFulfilled {
+		t.Errorf("PromiseState() = %v, want fulfilled", state)
+	}
+
+	value, err := promise.Await(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value.String() != "done" {
+		t.Errorf("Await() = %q, want %q", value.String(), "done")
+	}
+}
+
+func TestNewPromiseReject(t *testing.T) {
+	r := newTestRealm(t)
+
+	promise, _, reject := r.NewPromise()
+	if err := reject(errors.New("nope")); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, _ := promise.PromiseState(); state != PromiseRejected {
+		t.Errorf("PromiseState() = %v, want rejected", state)
+	}
+
+	_, err := promise.Await(context.Background())
+
+	var jsErr *Error
+	if !errors.As(err, &jsErr) || jsErr.Name() != "Error" || jsErr.Message() != "nope" {
+		t.Errorf("Await() error = %v, want the rejection as an *Error", err)
+	}
+}
+
+func TestNewPromiseSettleFromGoroutine(t *testing.T) {
+	r := newTestRealm(t)
+
+	promise, resolve, _ := r.NewPromise()
+
+	// settle once Await is running the event loop
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if err := resolve(42); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := promise.Await(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value.ToInt() != 42 {
+		t.Errorf("Await() = %d, want 42", value.ToInt())
+	}
+}
+
+func TestAwait(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicTimeout)
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`1 + 1`, "2"},
+		{`({then(resolve) { resolve("thenable") }})`, "thenable"},
+		{`(async () => { await null; return "async" })()`, "async"},
+		{`new Promise(resolve => setTimeout(() => resolve("timer"), 1))`, "timer"},
+	} {
+		if got := mustAwait(t, r, test.script).String(); got != test.want {
+			t.Errorf("%s = %q, want %q", test.script, got, test.want)
+		}
+	}
+
+	if _, err := await(r, `Promise.reject(new RangeError("bad"))`); err == nil || err.Error() != "RangeError: bad" {
+		t.Errorf("expected the rejection reason, got %v", err)
+	}
+}
+
+func TestAwaitContext(t *testing.T) {
+	r := newTestRealm(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := mustEval(t, r, `new Promise(() => {})`).Await(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Await() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPromiseStateNotPromise(t *testing.T) {
+	r := newTestRealm(t)
+
+	for _, script := range []string{`1`, `({then() {}})`} {
+		if mustEval(t, r, script).IsPromise() {
+			t.Errorf("%s: IsPromise() = true", script)
+		}
+	}
+}
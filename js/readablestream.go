@@ -0,0 +1,1453 @@
+package js
+
+// streamState is the state of a ReadableStream or WritableStream. Only
+// WritableStreams can be erroring.
+type streamState int
+
+const (
+	streamOpen streamState = iota
+	streamClosed
+	streamErroring
+	streamErrored
+)
+
+// readableSource holds the algorithms of the underlying source of a
+// ReadableStream, which may be nil. pull and cancel return promises.
+type readableSource struct {
+	start  func(c *readableStreamController) (*Value, error)
+	pull   func(c *readableStreamController) *Value
+	cancel func(reason *Value) *Value
+}
+
+// readableStream is the opaque value of ReadableStream.
+type readableStream struct {
+	realm       *Realm
+	state       streamState
+	storedError *Value
+	disturbed   bool
+	reader      *readableStreamReader
+	controller  *readableStreamController
+}
+
+func thisReadableStream(this *Value) (*readableStream, error) {
+	s, ok := this.Opaque().(*readableStream)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return s, nil
+}
+
+func (s *readableStream) locked() bool {
+	return s.reader != nil
+}
+
+func (s *readableStream) close() {
+	s.state = streamClosed
+
+	reader := s.reader
+	if reader == nil {
+		return
+	}
+
+	reader.closed.resolve(NewUndefined())
+
+	requests := reader.readRequests
+	reader.readRequests = nil
+
+	for _, request := range requests {
+		request.close()
+	}
+}
+
+func (s *readableStream) error(e *Value) {
+	s.state = streamErrored
+	s.storedError = e
+
+	reader := s.reader
+	if reader == nil {
+		return
+	}
+
+	reader.closed.reject(e)
+	reader.closed.markHandled()
+
+	reader.errorReadRequests(e)
+}
+
+// cancel cancels the stream and returns a promise that is fulfilled with
+// undefined once the underlying source has been cancelled.
+func (s *readableStream) cancel(reason *Value) *Value {
+	r := s.realm
+
+	s.disturbed = true
+
+	switch s.state {
+	case streamClosed:
+		return r.resolvedStreamPromise(NewUndefined()).value
+	case streamErrored:
+		return r.rejectedStreamPromise(s.storedError).value
+	}
+
+	s.close()
+
+	promise := r.newStreamPromise()
+	uponPromise(s.controller.cancelSteps(reason), func(*Value) {
+		promise.resolve(NewUndefined())
+	}, promise.reject)
+
+	return promise.value
+}
+
+// readRequest holds the steps that are run when a read request of a reader
+// is fulfilled.
+type readRequest struct {
+	chunk func(chunk *Value)
+	close func()
+	error func(e *Value)
+}
+
+// readableStreamReader is the opaque value of ReadableStreamDefaultReader.
+type readableStreamReader struct {
+	realm        *Realm
+	stream       *readableStream
+	closed       *streamPromise
+	readRequests []readRequest
+}
+
+func thisReadableStreamReader(this *Value) (*readableStreamReader, error) {
+	reader, ok := this.Opaque().(*readableStreamReader)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return reader, nil
+}
+
+func errReleasedReader() error {
+	return NewTypeError("The reader has been released")
+}
+
+// acquireReader locks s to a new reader.
+func (api *streamsAPI) acquireReader(r *Realm, s *readableStream) (*readableStreamReader, error) {
+	if s.locked() {
+		return nil, NewTypeError("ReadableStream is locked")
+	}
+
+	reader := &readableStreamReader{
+		realm:  r,
+		stream: s,
+	}
+
+	s.reader = reader
+
+	switch s.state {
+	case streamOpen:
+		reader.closed = r.newStreamPromise()
+	case streamClosed:
+		reader.closed = r.resolvedStreamPromise(NewUndefined())
+	case streamErrored:
+		reader.closed = r.rejectedStreamPromise(s.storedError)
+		reader.closed.markHandled()
+	}
+
+	return reader, nil
+}
+
+// read reads a chunk from the stream of the reader with request.
+func (reader *readableStreamReader) read(request readRequest) {
+	s := reader.stream
+	if s == nil {
+		request.error(reader.realm.errorValue(errReleasedReader()))
+		return
+	}
+
+	s.disturbed = true
+
+	switch s.state {
+	case streamClosed:
+		request.close()
+	case streamErrored:
+		request.error(s.storedError)
+	default:
+		s.controller.pullSteps(request)
+	}
+}
+
+func (reader *readableStreamReader) errorReadRequests(e *Value) {
+	requests := reader.readRequests
+	reader.readRequests = nil
+
+	for _, request := range requests {
+		request.error(e)
+	}
+}
+
+// release unlocks the stream of the reader, whose pending read requests are
+// rejected.
+func (reader *readableStreamReader) release() {
+	s := reader.stream
+	if s == nil {
+		return
+	}
+
+	r := reader.realm
+	e := r.errorValue(errReleasedReader())
+
+	if s.state == streamOpen {
+		reader.closed.reject(e)
+	} else {
+		reader.closed = r.rejectedStreamPromise(e)
+	}
+
+	reader.closed.markHandled()
+
+	s.reader = nil
+	reader.stream = nil
+
+	reader.errorReadRequests(e)
+}
+
+// cancelReader cancels the stream of reader.
+func (api *streamsAPI) cancelReader(r *Realm, reader *readableStreamReader, reason *Value) *Value {
+	if reader.stream == nil {
+		return r.rejectedStreamPromise(r.errorValue(errReleasedReader())).value
+	}
+
+	return reader.stream.cancel(reason)
+}
+
+// newReadResult creates the result of a read, which is also an iterator
+// result.
+func newReadResult(r *Realm, value *Value, done bool) (*Value, error) {
+	result, err := r.NewObject()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := result.Set("value", value); err != nil {
+		return nil, err
+	}
+
+	if _, err := result.Set("done", done); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// readPromise reads a chunk with reader and returns a promise of the result.
+func (reader *readableStreamReader) readPromise() *Value {
+	r := reader.realm
+	promise := r.newStreamPromise()
+
+	settle := func(value *Value, done bool) {
+		result, err := newReadResult(r, value, done)
+		if err != nil {
+			promise.reject(r.errorValue(err))
+			return
+		}
+
+		promise.resolve(result)
+	}
+
+	reader.read(readRequest{
+		chunk: func(chunk *Value) {
+			settle(chunk, false)
+		},
+		close: func() {
+			settle(NewUndefined(), true)
+		},
+		error: promise.reject,
+	})
+
+	return promise.value
+}
+
+func (api *streamsAPI) readableStreamReaderSpec() ClassSpec {
+	return ClassSpec{
+		Name: "ReadableStreamDefaultReader",
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			s, ok := optionalArg(args, 0).Opaque().(*readableStream)
+			if !ok {
+				return nil, NewTypeError("The provided value is not of type 'ReadableStream'")
+			}
+
+			return api.acquireReader(r, s)
+		},
+		Length: 1,
+		Methods: map[string]interface{}{
+			"read": func(r *Realm, this *Value) (*Value, error) {
+				reader, err := thisReadableStreamReader(this)
+				if err != nil {
+					return nil, err
+				}
+
+				return reader.readPromise(), nil
+			},
+			"releaseLock": func(r *Realm, this *Value) error {
+				reader, err := thisReadableStreamReader(this)
+				if err != nil {
+					return err
+				}
+
+				reader.release()
+
+				return nil
+			},
+			"cancel": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+				reader, err := thisReadableStreamReader(this)
+				if err != nil {
+					return nil, err
+				}
+
+				return api.cancelReader(r, reader, optionalArg(args, 0)), nil
+			},
+		},
+		Properties: map[string]ClassProperty{
+			"closed": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					reader, err := thisReadableStreamReader(this)
+					if err != nil {
+						return nil, err
+					}
+
+					return reader.closed.value, nil
+				},
+			},
+		},
+	}
+}
+
+// readableStreamController is the opaque value of
+// ReadableStreamDefaultController.
+type readableStreamController struct {
+	stream   *readableStream
+	value    *Value
+	queue    sizeQueue
+	strategy queuingStrategy
+	source   readableSource
+
+	started        bool
+	closeRequested bool
+	pullAgain      bool
+	pulling        bool
+}
+
+func thisReadableStreamController(this *Value) (*readableStreamController, error) {
+	c, ok := this.Opaque().(*readableStreamController)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return c, nil
+}
+
+func (c *readableStreamController) canCloseOrEnqueue() bool {
+	return !c.closeRequested && c.stream.state == streamOpen
+}
+
+// desiredSize returns the desired size of the queue, or false if the stream
+// is errored.
+func (c *readableStreamController) desiredSize() (float64, bool) {
+	switch c.stream.state {
+	case streamErrored:
+		return 0, false
+	case streamClosed:
+		return 0, true
+	}
+
+	return c.strategy.highWaterMark - c.queue.totalSize, true
+}
+
+func (c *readableStreamController) shouldCallPull() bool {
+	if !c.canCloseOrEnqueue() || !c.started {
+		return false
+	}
+
+	if s := c.stream; s.locked() && len(s.reader.readRequests) > 0 {
+		return true
+	}
+
+	desiredSize, _ := c.desiredSize()
+	return desiredSize > 0
+}
+
+func (c *readableStreamController) callPullIfNeeded() {
+	if !c.shouldCallPull() {
+		return
+	}
+
+	if c.pulling {
+		c.pullAgain = true
+		return
+	}
+
+	c.pulling = true
+
+	var promise *Value
+	if c.source.pull != nil {
+		promise = c.source.pull(c)
+	} else {
+		promise = c.stream.realm.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	uponPromise(promise, func(*Value) {
+		c.pulling = false
+
+		if c.pullAgain {
+			c.pullAgain = false
+			c.callPullIfNeeded()
+		}
+	}, c.error)
+}
+
+func (c *readableStreamController) clearAlgorithms() {
+	c.source = readableSource{}
+	c.strategy.size = countChunk
+}
+
+func (c *readableStreamController) close() {
+	if !c.canCloseOrEnqueue() {
+		return
+	}
+
+	c.closeRequested = true
+
+	if c.queue.empty() {
+		c.clearAlgorithms()
+		c.stream.close()
+	}
+}
+
+func (c *readableStreamController) enqueue(chunk *Value) error {
+	if !c.canCloseOrEnqueue() {
+		return nil
+	}
+
+	if s := c.stream; s.locked() && len(s.reader.readRequests) > 0 {
+		request := s.reader.readRequests[0]
+		s.reader.readRequests = s.reader.readRequests[1:]
+		request.chunk(chunk)
+	} else {
+		size, err := c.strategy.size(chunk)
+		if err == nil {
+			err = c.queue.enqueue(chunk, size)
+		}
+
+		if err != nil {
+			c.error(c.stream.realm.errorValue(err))
+			return err
+		}
+	}
+
+	c.callPullIfNeeded()
+
+	return nil
+}
+
+func (c *readableStreamController) error(e *Value) {
+	if c.stream.state != streamOpen {
+		return
+	}
+
+	c.queue.reset()
+	c.clearAlgorithms()
+	c.stream.error(e)
+}
+
+func (c *readableStreamController) cancelSteps(reason *Value) *Value {
+	c.queue.reset()
+
+	var result *Value
+	if c.source.cancel != nil {
+		result = c.source.cancel(reason)
+	} else {
+		result = c.stream.realm.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	c.clearAlgorithms()
+
+	return result
+}
+
+func (c *readableStreamController) pullSteps(request readRequest) {
+	s := c.stream
+
+	if !c.queue.empty() {
+		chunk := c.queue.dequeue()
+
+		if c.closeRequested && c.queue.empty() {
+			c.clearAlgorithms()
+			s.close()
+		} else {
+			c.callPullIfNeeded()
+		}
+
+		request.chunk(chunk)
+		return
+	}
+
+	s.reader.readRequests = append(s.reader.readRequests, request)
+	c.callPullIfNeeded()
+}
+
+var readableStreamControllerSpec = ClassSpec{
+	Name: "ReadableStreamDefaultController",
+	Methods: map[string]interface{}{
+		"close": func(r *Realm, this *Value) error {
+			c, err := thisReadableStreamController(this)
+			if err != nil {
+				return err
+			}
+
+			if !c.canCloseOrEnqueue() {
+				return NewTypeError("The stream is not in a state that permits close")
+			}
+
+			c.close()
+
+			return nil
+		},
+		"enqueue": func(r *Realm, this *Value, args ...*Value) error {
+			c, err := thisReadableStreamController(this)
+			if err != nil {
+				return err
+			}
+
+			if !c.canCloseOrEnqueue() {
+				return NewTypeError("The stream is not in a state that permits enqueue")
+			}
+
+			return c.enqueue(optionalArg(args, 0))
+		},
+		"error": func(r *Realm, this *Value, args ...*Value) error {
+			c, err := thisReadableStreamController(this)
+			if err != nil {
+				return err
+			}
+
+			c.error(optionalArg(args, 0))
+
+			return nil
+		},
+	},
+	Properties: map[string]ClassProperty{
+		"desiredSize": {
+			Get: func(r *Realm, this *Value) (interface{}, error) {
+				c, err := thisReadableStreamController(this)
+				if err != nil {
+					return nil, err
+				}
+
+				desiredSize, ok := c.desiredSize()
+				if !ok {
+					return NewNull(), nil
+				}
+
+				return desiredSize, nil
+			},
+		},
+	},
+}
+
+// setupReadableStream sets up the controller of s, which calls the start
+// algorithm of source.
+func (api *streamsAPI) setupReadableStream(r *Realm, s *readableStream, source readableSource, strategy queuingStrategy) error {
+	c := &readableStreamController{
+		stream:   s,
+		strategy: strategy,
+		source:   source,
+	}
+
+	var err error
+	if c.value, err = api.readableStreamController.NewInstance(r, c); err != nil {
+		return err
+	}
+
+	s.controller = c
+
+	startResult := NewUndefined()
+	if source.start != nil {
+		if startResult, err = source.start(c); err != nil {
+			return err
+		}
+	}
+
+	uponPromise(r.resolvedStreamPromise(startResult).value, func(*Value) {
+		c.started = true
+		c.callPullIfNeeded()
+	}, c.error)
+
+	return nil
+}
+
+// newReadableStream creates a ReadableStream with an underlying source that
+// is implemented in go.
+func (api *streamsAPI) newReadableStream(r *Realm, source readableSource, strategy queuingStrategy) (*Value, *readableStream, error) {
+	s := &readableStream{realm: r}
+	if err := api.setupReadableStream(r, s, source, strategy); err != nil {
+		return nil, nil, err
+	}
+
+	value, err := api.readableStream.NewInstance(r, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return value, s, nil
+}
+
+// newUnderlyingSource converts the underlyingSource argument of the
+// ReadableStream constructor.
+func newUnderlyingSource(r *Realm, underlyingSource *Value) (readableSource, error) {
+	var source readableSource
+
+	if underlyingSource.Tag() != TagUndefined && underlyingSource.Tag() != TagNull && !underlyingSource.IsObject() {
+		return source, NewTypeError("The provided value is not of type 'UnderlyingSource'")
+	}
+
+	cancel, err := sourceMethod(underlyingSource, "cancel")
+	if err != nil {
+		return source, err
+	}
+
+	pull, err := sourceMethod(underlyingSource, "pull")
+	if err != nil {
+		return source, err
+	}
+
+	start, err := sourceMethod(underlyingSource, "start")
+	if err != nil {
+		return source, err
+	}
+
+	if underlyingSource.IsObject() {
+		typ, err := underlyingSource.Get("type")
+		if err != nil {
+			return source, err
+		}
+
+		if typ.Tag() != TagUndefined {
+			s, err := r.toString(typ)
+			if err != nil {
+				return source, err
+			}
+
+			if s == "bytes" {
+				return source, NewRangeError("Readable byte streams are not supported")
+			}
+
+			return source, NewTypeError("The provided value '%s' is not a valid enum value of type ReadableStreamType", s)
+		}
+	}
+
+	if start != nil {
+		source.start = func(c *readableStreamController) (*Value, error) {
+			return start.Call(underlyingSource, c.value)
+		}
+	}
+
+	if pull != nil {
+		source.pull = func(c *readableStreamController) *Value {
+			return r.promiseCall(pull, underlyingSource, c.value)
+		}
+	}
+
+	if cancel != nil {
+		source.cancel = func(reason *Value) *Value {
+			return r.promiseCall(cancel, underlyingSource, reason)
+		}
+	}
+
+	return source, nil
+}
+
+// tee splits s into two branches that read the same chunks.
+func (api *streamsAPI) tee(r *Realm, s *readableStream) (*Value, *Value, error) {
+	reader, err := api.acquireReader(r, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		reading, readAgain     bool
+		canceled               [2]bool
+		reasons                [2]*Value
+		branches               [2]*readableStream
+		branchValues           [2]*Value
+		cancelPromise          = r.newStreamPromise()
+		pullAlgorithm          func(*readableStreamController) *Value
+		resolvedPromise        = func() *Value { return r.resolvedStreamPromise(NewUndefined()).value }
+		resolveCancelIfPending = func() {
+			if !canceled[0] || !canceled[1] {
+				cancelPromise.resolve(NewUndefined())
+			}
+		}
+	)
+
+	pullAlgorithm = func(*readableStreamController) *Value {
+		if reading {
+			readAgain = true
+			return resolvedPromise()
+		}
+
+		reading = true
+
+		reader.read(readRequest{
+			chunk: func(chunk *Value) {
+				// the chunk is enqueued in a microtask, so that errors
+				// of the stream are propagated to the branches first
+				r.queueMicrotask(func() {
+					readAgain = false
+
+					for i, branch := range branches {
+						if !canceled[i] {
+							_ = branch.controller.enqueue(chunk)
+						}
+					}
+
+					reading = false
+
+					if readAgain {
+						pullAlgorithm(nil)
+					}
+				})
+			},
+			close: func() {
+				reading = false
+
+				for i, branch := range branches {
+					if !canceled[i] {
+						branch.controller.close()
+					}
+				}
+
+				resolveCancelIfPending()
+			},
+			error: func(*Value) {
+				reading = false
+			},
+		})
+
+		return resolvedPromise()
+	}
+
+	for i := range branches {
+		i := i
+		branchValues[i], branches[i], err = api.newReadableStream(r, readableSource{
+			pull: pullAlgorithm,
+			cancel: func(reason *Value) *Value {
+				canceled[i] = true
+				reasons[i] = reason
+
+				if canceled[1-i] {
+					composite, err := r.NewArray()
+					if err != nil {
+						return r.rejectedStreamPromise(r.errorValue(err)).value
+					}
+
+					for j, reason := range reasons {
+						if _, err := composite.SetIndex(j, reason); err != nil {
+							return r.rejectedStreamPromise(r.errorValue(err)).value
+						}
+					}
+
+					cancelPromise.resolve(s.cancel(composite))
+				}
+
+				return cancelPromise.value
+			},
+		}, queuingStrategy{highWaterMark: 1, size: countChunk})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	uponPromise(reader.closed.value, func(*Value) {}, func(e *Value) {
+		for _, branch := range branches {
+			branch.controller.error(e)
+		}
+
+		resolveCancelIfPending()
+	})
+
+	return branchValues[0], branchValues[1], nil
+}
+
+// from creates a ReadableStream of the values of an async or sync iterable.
+func (api *streamsAPI) from(r *Realm, iterable *Value) (*Value, error) {
+	method, err := iterable.iteratorMethod("asyncIterator")
+	if err != nil {
+		return nil, err
+	}
+
+	awaitValues := false
+	if method == nil {
+		if method, err = iterable.iteratorMethod("iterator"); err != nil {
+			return nil, err
+		}
+
+		awaitValues = true
+	}
+
+	it, err := iterable.getIterator(method)
+	if err != nil {
+		return nil, err
+	}
+
+	value, _, err := api.newReadableStream(r, readableSource{
+		pull: func(c *readableStreamController) *Value {
+			promise := r.newStreamPromise()
+
+			uponPromise(r.promiseCall(it.next, it.iterator), func(result *Value) {
+				if !result.IsObject() {
+					promise.reject(r.errorValue(NewTypeError("The iterator result is not an object")))
+					return
+				}
+
+				done, err := booleanOption(result, "done")
+				if err != nil {
+					promise.reject(r.errorValue(err))
+					return
+				}
+
+				if done {
+					c.close()
+					promise.resolve(NewUndefined())
+					return
+				}
+
+				chunk, err := result.Get("value")
+				if err != nil {
+					promise.reject(r.errorValue(err))
+					return
+				}
+
+				enqueue := func(chunk *Value) {
+					if err := c.enqueue(chunk); err != nil {
+						promise.reject(r.errorValue(err))
+						return
+					}
+
+					promise.resolve(NewUndefined())
+				}
+
+				if !awaitValues {
+					enqueue(chunk)
+					return
+				}
+
+				uponPromise(chunk, enqueue, promise.reject)
+			}, promise.reject)
+
+			return promise.value
+		},
+		cancel: func(reason *Value) *Value {
+			ret, err := sourceMethod(it.iterator, "return")
+			if err != nil {
+				return r.rejectedStreamPromise(r.errorValue(err)).value
+			}
+
+			if ret == nil {
+				return r.resolvedStreamPromise(NewUndefined()).value
+			}
+
+			promise := r.newStreamPromise()
+			uponPromise(r.promiseCall(ret, it.iterator, reason), func(result *Value) {
+				if !result.IsObject() {
+					promise.reject(r.errorValue(NewTypeError("The iterator result is not an object")))
+					return
+				}
+
+				promise.resolve(NewUndefined())
+			}, promise.reject)
+
+			return promise.value
+		},
+	}, queuingStrategy{highWaterMark: 0, size: countChunk})
+
+	return value, err
+}
+
+// newAsyncIterator creates an async iterator of the chunks of s, which is
+// locked until the iterator is done.
+func (api *streamsAPI) newAsyncIterator(r *Realm, s *readableStream, preventCancel bool) (*Value, error) {
+	reader, err := api.acquireReader(r, s)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ongoing  *Value
+		finished bool
+	)
+
+	// steps run after the previous call to next or return has settled
+	chain := func(steps func() *Value) *Value {
+		if ongoing == nil {
+			ongoing = steps()
+			return ongoing
+		}
+
+		promise := r.newStreamPromise()
+		run := func(*Value) {
+			uponPromise(steps(), func(v *Value) {
+				promise.resolve(v)
+			}, promise.reject)
+		}
+
+		uponPromise(ongoing, run, run)
+		ongoing = promise.value
+
+		return ongoing
+	}
+
+	result := func(value *Value, done bool) *Value {
+		result, err := newReadResult(r, value, done)
+		if err != nil {
+			return r.rejectedStreamPromise(r.errorValue(err)).value
+		}
+
+		return r.resolvedStreamPromise(result).value
+	}
+
+	iterator, err := r.NewObject()
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := r.NewFunction(func(r *Realm, _ *Value) (*Value, error) {
+		return chain(func() *Value {
+			if finished {
+				return result(NewUndefined(), true)
+			}
+
+			promise := r.newStreamPromise()
+			reader.read(readRequest{
+				chunk: func(chunk *Value) {
+					promise.resolve(result(chunk, false))
+				},
+				close: func() {
+					finished = true
+					reader.release()
+					promise.resolve(result(NewUndefined(), true))
+				},
+				error: func(e *Value) {
+					finished = true
+					reader.release()
+					promise.reject(e)
+				},
+			})
+
+			return promise.value
+		}), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := r.NewFunction(func(r *Realm, _ *Value, args ...*Value) (*Value, error) {
+		value := optionalArg(args, 0)
+
+		return chain(func() *Value {
+			if finished {
+				return result(value, true)
+			}
+
+			finished = true
+
+			if preventCancel {
+				reader.release()
+				return result(value, true)
+			}
+
+			cancelled := api.cancelReader(r, reader, value)
+			reader.release()
+
+			promise := r.newStreamPromise()
+			uponPromise(cancelled, func(*Value) {
+				promise.resolve(result(value, true))
+			}, promise.reject)
+
+			return promise.value
+		}), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	self, err := r.NewFunction(func(r *Realm, this *Value) (*Value, error) {
+		return this, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	asyncIterator, err := r.wellKnownSymbol("asyncIterator")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, method := range []struct {
+		name  *Atom
+		value *Value
+	}{
+		{r.NewStringAtom("next"), next},
+		{r.NewStringAtom("return"), ret},
+		{asyncIterator, self},
+	} {
+		if _, err := iterator.DefinePropertyAtom(method.name, DefinePropertyValue(method.value), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+			return nil, err
+		}
+	}
+
+	return iterator, nil
+}
+
+// pipeOptions are the options of pipeTo and pipeThrough.
+type pipeOptions struct {
+	preventClose  bool
+	preventAbort  bool
+	preventCancel bool
+	signal        *Value
+}
+
+func newPipeOptions(options *Value) (pipeOptions, error) {
+	var opts pipeOptions
+
+	switch {
+	case options.Tag() == TagUndefined || options.Tag() == TagNull:
+		return opts, nil
+	case !options.IsObject():
+		return opts, NewTypeError("The provided value is not of type 'StreamPipeOptions'")
+	}
+
+	var err error
+	if opts.preventAbort, err = booleanOption(options, "preventAbort"); err != nil {
+		return opts, err
+	}
+
+	if opts.preventCancel, err = booleanOption(options, "preventCancel"); err != nil {
+		return opts, err
+	}
+
+	if opts.preventClose, err = booleanOption(options, "preventClose"); err != nil {
+		return opts, err
+	}
+
+	signal, err := options.Get("signal")
+	if err != nil {
+		return opts, err
+	}
+
+	if signal.Tag() != TagUndefined {
+		if _, ok := signal.Opaque().(*abortSignal); !ok {
+			return opts, NewTypeError("Failed to read the 'signal' property from 'StreamPipeOptions': The provided value is not of type 'AbortSignal'")
+		}
+
+		opts.signal = signal
+	}
+
+	return opts, nil
+}
+
+// pipeTo pipes source to dest, which must both be unlocked, and returns a
+// promise that settles once piping has finished.
+func (api *streamsAPI) pipeTo(r *Realm, source *readableStream, dest *writableStream, opts pipeOptions) *Value {
+	reader, _ := api.acquireReader(r, source)
+	writer, _ := api.acquireWriter(r, dest)
+
+	source.disturbed = true
+
+	var (
+		promise      = r.newStreamPromise()
+		shuttingDown bool
+		finalized    bool
+		currentWrite = r.resolvedStreamPromise(NewUndefined()).value
+	)
+
+	// err is nil if piping has finished without an error
+	finalize := func(err *Value) {
+		finalized = true
+
+		writer.release(r)
+		reader.release()
+
+		if err != nil {
+			promise.reject(err)
+		} else {
+			promise.resolve(NewUndefined())
+		}
+	}
+
+	// waitForWrites calls then once every chunk that has been read has been
+	// written
+	var waitForWrites func(then func())
+	waitForWrites = func(then func()) {
+		pending := currentWrite
+		done := func(*Value) {
+			if pending != currentWrite {
+				waitForWrites(then)
+			} else {
+				then()
+			}
+		}
+
+		uponPromise(pending, done, done)
+	}
+
+	shutdown := func(action func() *Value, err *Value) {
+		if shuttingDown {
+			return
+		}
+
+		shuttingDown = true
+
+		rest := func() {
+			if action == nil {
+				finalize(err)
+				return
+			}
+
+			uponPromise(action(), func(*Value) {
+				finalize(err)
+			}, finalize)
+		}
+
+		if dest.state == streamOpen && !dest.closeQueuedOrInFlight() {
+			waitForWrites(rest)
+		} else {
+			rest()
+		}
+	}
+
+	sourceErrored := func(e *Value) {
+		if !opts.preventAbort {
+			shutdown(func() *Value {
+				return dest.abort(e)
+			}, e)
+		} else {
+			shutdown(nil, e)
+		}
+	}
+
+	destErrored := func(e *Value) {
+		if !opts.preventCancel {
+			shutdown(func() *Value {
+				return source.cancel(e)
+			}, e)
+		} else {
+			shutdown(nil, e)
+		}
+	}
+
+	sourceClosed := func() {
+		if !opts.preventClose {
+			shutdown(func() *Value {
+				return writer.closeWithErrorPropagation(r).value
+			}, nil)
+		} else {
+			shutdown(nil, nil)
+		}
+	}
+
+	destClosed := func() {
+		e := r.errorValue(NewTypeError("The destination stream is closed"))
+		if !opts.preventCancel {
+			shutdown(func() *Value {
+				return source.cancel(e)
+			}, e)
+		} else {
+			shutdown(nil, e)
+		}
+	}
+
+	if opts.signal != nil {
+		signal := opts.signal.Opaque().(*abortSignal)
+		abort := func() {
+			if finalized {
+				return
+			}
+
+			e := signal.reason
+
+			shutdown(func() *Value {
+				var actions []*Value
+				if !opts.preventAbort {
+					if dest.state == streamOpen {
+						actions = append(actions, dest.abort(e))
+					}
+				}
+
+				if !opts.preventCancel {
+					if source.state == streamOpen {
+						actions = append(actions, source.cancel(e))
+					}
+				}
+
+				return allPromises(r, actions)
+			}, e)
+		}
+
+		if signal.aborted {
+			abort()
+			return promise.value
+		}
+
+		signal.algorithms = append(signal.algorithms, abort)
+	}
+
+	var pipeStep func()
+	pipeStep = func() {
+		if shuttingDown {
+			return
+		}
+
+		uponPromise(writer.ready.value, func(*Value) {
+			if shuttingDown {
+				return
+			}
+
+			reader.read(readRequest{
+				chunk: func(chunk *Value) {
+					write := writer.write(r, chunk)
+					write.markHandled()
+					currentWrite = write.value
+
+					pipeStep()
+				},
+				// closing and errors are handled by the closed promises
+				close: func() {},
+				error: func(*Value) {},
+			})
+		}, func(*Value) {})
+	}
+
+	switch {
+	case source.state == streamErrored:
+		sourceErrored(source.storedError)
+	case dest.state == streamErrored || dest.state == streamErroring:
+		destErrored(dest.storedError)
+	case source.state == streamClosed:
+		sourceClosed()
+	case dest.closeQueuedOrInFlight() || dest.state == streamClosed:
+		destClosed()
+	}
+
+	uponPromise(reader.closed.value, func(*Value) {
+		sourceClosed()
+	}, sourceErrored)
+
+	uponPromise(writer.closed.value, func(*Value) {}, destErrored)
+
+	pipeStep()
+
+	return promise.value
+}
+
+// allPromises returns a promise that is fulfilled once all promises are
+// fulfilled, or rejected with the first rejection.
+func allPromises(r *Realm, promises []*Value) *Value {
+	promise := r.newStreamPromise()
+
+	remaining := len(promises)
+	if remaining == 0 {
+		promise.resolve(NewUndefined())
+	}
+
+	for _, p := range promises {
+		uponPromise(p, func(*Value) {
+			if remaining--; remaining == 0 {
+				promise.resolve(NewUndefined())
+			}
+		}, promise.reject)
+	}
+
+	return promise.value
+}
+
+func (api *streamsAPI) readableStreamSpec() ClassSpec {
+	return ClassSpec{
+		Name: "ReadableStream",
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			source, err := newUnderlyingSource(r, optionalArg(args, 0))
+			if err != nil {
+				return nil, err
+			}
+
+			strategy, err := extractQueuingStrategy(r, optionalArg(args, 1), 1)
+			if err != nil {
+				return nil, err
+			}
+
+			s := &readableStream{realm: r}
+			if err := api.setupReadableStream(r, s, source, strategy); err != nil {
+				return nil, err
+			}
+
+			return s, nil
+		},
+		Methods: map[string]interface{}{
+			"cancel": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+				s, err := thisReadableStream(this)
+				if err != nil {
+					return nil, err
+				}
+
+				if s.locked() {
+					return r.rejectedStreamPromise(r.errorValue(NewTypeError("Cannot cancel a locked ReadableStream"))).value, nil
+				}
+
+				return s.cancel(optionalArg(args, 0)), nil
+			},
+			"getReader": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+				s, err := thisReadableStream(this)
+				if err != nil {
+					return nil, err
+				}
+
+				if options := optionalArg(args, 0); options.IsObject() {
+					mode, ok, err := stringOption(r, options, "mode")
+					if err != nil {
+						return nil, err
+					}
+
+					if ok {
+						if mode == "byob" {
+							return nil, NewTypeError("Cannot get a BYOB reader for a stream that is not a byte stream")
+						}
+
+						return nil, NewTypeError("The provided value '%s' is not a valid enum value of type ReadableStreamReaderMode", mode)
+					}
+				}
+
+				reader, err := api.acquireReader(r, s)
+				if err != nil {
+					return nil, err
+				}
+
+				return api.readableStreamReader.NewInstance(r, reader)
+			},
+			"pipeThrough": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+				s, err := thisReadableStream(this)
+				if err != nil {
+					return nil, err
+				}
+
+				transform := optionalArg(args, 0)
+				if !transform.IsObject() {
+					return nil, NewTypeError("The provided value is not of type 'ReadableWritablePair'")
+				}
+
+				readable, err := transform.Get("readable")
+				if err != nil {
+					return nil, err
+				}
+
+				if _, ok := readable.Opaque().(*readableStream); !ok {
+					return nil, NewTypeError("The \"readable\" member must be a ReadableStream")
+				}
+
+				writable, err := transform.Get("writable")
+				if err != nil {
+					return nil, err
+				}
+
+				dest, ok := writable.Opaque().(*writableStream)
+				if !ok {
+					return nil, NewTypeError("The \"writable\" member must be a WritableStream")
+				}
+
+				opts, err := newPipeOptions(optionalArg(args, 1))
+				if err != nil {
+					return nil, err
+				}
+
+				if s.locked() {
+					return nil, NewTypeError("Cannot pipe a locked ReadableStream")
+				}
+
+				if dest.locked() {
+					return nil, NewTypeError("Cannot pipe to a locked WritableStream")
+				}
+
+				promise := api.pipeTo(r, s, dest, opts)
+				uponPromise(promise, func(*Value) {}, func(*Value) {})
+
+				return readable, nil
+			},
+			"pipeTo": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+				s, err := thisReadableStream(this)
+				if err != nil {
+					return nil, err
+				}
+
+				dest, ok := optionalArg(args, 0).Opaque().(*writableStream)
+				if !ok {
+					return r.rejectedStreamPromise(r.errorValue(NewTypeError("The provided value is not of type 'WritableStream'"))).value, nil
+				}
+
+				opts, err := newPipeOptions(optionalArg(args, 1))
+				if err != nil {
+					return r.rejectedStreamPromise(r.errorValue(err)).value, nil
+				}
+
+				if s.locked() {
+					return r.rejectedStreamPromise(r.errorValue(NewTypeError("Cannot pipe a locked ReadableStream"))).value, nil
+				}
+
+				if dest.locked() {
+					return r.rejectedStreamPromise(r.errorValue(NewTypeError("Cannot pipe to a locked WritableStream"))).value, nil
+				}
+
+				return api.pipeTo(r, s, dest, opts), nil
+			},
+			"tee": func(r *Realm, this *Value) ([]*Value, error) {
+				s, err := thisReadableStream(this)
+				if err != nil {
+					return nil, err
+				}
+
+				branch1, branch2, err := api.tee(r, s)
+				if err != nil {
+					return nil, err
+				}
+
+				return []*Value{branch1, branch2}, nil
+			},
+			"values": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+				s, err := thisReadableStream(this)
+				if err != nil {
+					return nil, err
+				}
+
+				var preventCancel bool
+				if options := optionalArg(args, 0); options.IsObject() {
+					if preventCancel, err = booleanOption(options, "preventCancel"); err != nil {
+						return nil, err
+					}
+				}
+
+				return api.newAsyncIterator(r, s, preventCancel)
+			},
+		},
+		Properties: map[string]ClassProperty{
+			"locked": {
+				Get: func(r *Realm, this *Value) (bool, error) {
+					s, err := thisReadableStream(this)
+					if err != nil {
+						return false, err
+					}
+
+					return s.locked(), nil
+				},
+			},
+		},
+		StaticMethods: map[string]interface{}{
+			"from": func(r *Realm, _ *Value, args ...*Value) (*Value, error) {
+				return api.from(r, optionalArg(args, 0))
+			},
+		},
+	}
+}
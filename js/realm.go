@@ -1,7 +1,11 @@
 package js
 
 import (
+	"math/big"
 	"runtime"
+	"sync"
+	"time"
+	"unsafe"
 
 	"github.com/ssttevee/go-quickjs/internal"
 )
@@ -12,21 +16,74 @@ import (
 type Realm struct {
 	runtime *Runtime
 	context *internal.Context
+
+	// prototypes shared by objects created from go, such as the methods of
+	// go objects
+	prototypes map[interface{}]internal.Value
+
+	// goWrappers holds the objects that currently expose go values by
+	// their address so that the same go value is always exposed as the same
+	// object. The values are not owned, since the objects remove themselves
+	// when they are freed, which may happen on any goroutine.
+	goWrappersMutex sync.Mutex
+	goWrappers      map[goWrapperKey]internal.Value
+
+	// goErrorSymbol is the key of the hidden property that holds the go error
+	// of thrown objects
+	goErrorSymbol internal.Atom
+
+	// timeOrigin is the time at which the realm was created, which the
+	// timestamps of events and performance entries are relative to
+	timeOrigin time.Time
+
+	// events holds the classes of the events intrinsic, if it was added
+	events *eventAPI
+
+	// streams holds the classes of the streams intrinsic, if it was added
+	streams *streamsAPI
+
+	// performance holds the timeline of the performance intrinsic, if it was
+	// added
+	performance *performanceTimeline
 }
 
 func freeRealm(r *Realm) {
+	internal.SetContextOpaque(r.context, 0)
+
+	for _, proto := range r.prototypes {
+		internal.FreeValue(r.context, proto)
+	}
+
+	if r.goErrorSymbol != 0 {
+		internal.FreeAtom(r.context, r.goErrorSymbol)
+	}
+
 	internal.FreeContext(r.context)
 	runtime.KeepAlive(r.runtime)
 }
 
 func (rt *Runtime) NewRealm(opts ...RealmOption) (*Realm, error) {
 	r := &Realm{
-		runtime: rt,
-		context: internal.NewContext(rt.runtime),
+		runtime:    rt,
+		context:    internal.NewContext(rt.runtime),
+		prototypes: map[interface{}]internal.Value{},
+		goWrappers: map[goWrapperKey]internal.Value{},
+		timeOrigin: time.Now(),
 	}
 
 	runtime.SetFinalizer(r, freeRealm)
 
+	// the realm is found by its context without being kept alive, since it
+	// refers to the runtime and would otherwise never be freed
+	internal.SetContextOpaque(r.context, uintptr(unsafe.Pointer(r)))
+
+	goErrorSymbol, err := r.newGoErrorSymbol()
+	if err != nil {
+		return nil, err
+	}
+
+	r.goErrorSymbol = goErrorSymbol
+
 	for _, option := range rt.defaultRealmOptions {
 		if err := option(realmConfig{r}); err != nil {
 			return nil, err
@@ -39,6 +96,14 @@ func (rt *Runtime) NewRealm(opts ...RealmOption) (*Realm, error) {
 		}
 	}
 
+	if err := r.installErrorClasses(); err != nil {
+		return nil, err
+	}
+
+	if err := r.installClasses(); err != nil {
+		return nil, err
+	}
+
 	return r, nil
 }
 
@@ -54,12 +119,57 @@ func (r *Realm) NewObjectWithFinalizer(f func()) (*Value, error) {
 	return r.createAndResolveValue(internal.NewObjectWithFinalizer(r.context, f))
 }
 
+func (r *Realm) NewArray() (*Value, error) {
+	return r.createAndResolveValue(internal.NewArray(r.context))
+}
+
+func (r *Realm) prototype(key interface{}) (*Value, bool) {
+	proto, ok := r.prototypes[key]
+	if !ok {
+		return nil, false
+	}
+
+	return r.createValue(internal.DupValue(r.context, proto)), true
+}
+
+func (r *Realm) setPrototype(key interface{}, proto *Value) {
+	defer runtime.KeepAlive(proto)
+
+	if old, ok := r.prototypes[key]; ok {
+		internal.FreeValue(r.context, old)
+	}
+
+	r.prototypes[key] = internal.DupValue(r.context, proto.value)
+}
+
 func (r *Realm) NewString(s string) (*Value, error) {
 	return r.createAndResolveValue(internal.NewString(r.context, s))
 }
 
 func (r *Realm) NewInt(n int) (*Value, error) {
-	return r.createAndResolveValue(internal.NewInt(r.context, n))
+	return r.createAndResolveValue(internal.NewInt64(r.context, int64(n)))
+}
+
+func (r *Realm) NewBigInt(n *big.Int) (*Value, error) {
+	if n.IsInt64() {
+		return r.createAndResolveValue(internal.NewBigInt64(r.context, n.Int64()))
+	}
+
+	bigIntFunc, err := r.globalProperty("BigInt")
+	if err != nil {
+		return nil, err
+	}
+
+	return bigIntFunc.Call(nil, n.String())
+}
+
+func (r *Realm) NewDate(t time.Time) (*Value, error) {
+	dateConstructor, err := r.globalProperty("Date")
+	if err != nil {
+		return nil, err
+	}
+
+	return dateConstructor.Construct(float64(t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)))
 }
 
 func (r *Realm) NewFloat(n float64) (*Value, error) {
@@ -74,11 +184,43 @@ func (r *Realm) NewArrayBuffer(data []byte) (*Value, error) {
 	return r.createAndResolveValue(internal.NewArrayBuffer(r.context, data))
 }
 
+func (r *Realm) newUint8Array(data []byte) (*Value, error) {
+	buffer, err := r.NewArrayBuffer(data)
+	if err != nil {
+		return nil, err
+	}
+
+	uint8ArrayConstructor, err := r.globalProperty("Uint8Array")
+	if err != nil {
+		return nil, err
+	}
+
+	return uint8ArrayConstructor.Construct(buffer)
+}
+
 func (r *Realm) SetConstructor(funcObj, proto *Value) {
 	internal.SetConstructor(r.context, funcObj.value, proto.value)
 }
 
+// throw throws err as a js exception. Unless err is already a js value, the
+// thrown object holds err so that it can be unwrapped if it propagates back to
+// go.
 func (r *Realm) throw(err error) internal.Value {
+	result := r.throwError(err)
+	if _, ok := err.(*Error); ok {
+		return result
+	}
+
+	exception := r.createValue(internal.GetException(r.context))
+	defer runtime.KeepAlive(exception)
+
+	// the exception is thrown regardless of whether err could be attached
+	_ = r.attachGoError(exception, err)
+
+	return internal.Throw(r.context, exception.value)
+}
+
+func (r *Realm) throwError(err error) internal.Value {
 	switch err := err.(type) {
 	case SyntaxError:
 		return internal.ThrowSyntaxError(r.context, err.Error())
@@ -91,16 +233,36 @@ func (r *Realm) throw(err error) internal.Value {
 		return internal.ThrowReferenceError(r.context, err.Error())
 	case RangeError:
 		return internal.ThrowRangeError(r.context, err.Error())
-	case InternalError:
+	case InternalError, interface {
+		error
+		isInternalError()
+	}:
 		return internal.ThrowInternalError(r.context, err.Error())
 	}
 
-	errObj := Must(r.Convert(err))
+	errObj, convErr := r.Convert(err)
+	if convErr != nil {
+		// the conversion error is thrown instead, such as an exception that
+		// was thrown while looking up the class of a named error
+		jsErr, ok := convErr.(*Error)
+		if !ok {
+			return internal.ThrowInternalError(r.context, convErr.Error())
+		}
+
+		errObj = jsErr.Value()
+	}
+
 	defer runtime.KeepAlive(errObj)
 
 	return internal.Throw(r.context, errObj.value)
 }
 
+// errorValue returns the value that would be thrown for err.
+func (r *Realm) errorValue(err error) *Value {
+	r.throw(err)
+	return r.createValue(internal.GetException(r.context))
+}
+
 func (r *Realm) SetConstructorBit(obj *Value, val bool) {
 	internal.SetConstructorBit(r.context, obj.value, val)
 }
@@ -109,6 +271,24 @@ func (r *Realm) GlobalObject() (*Value, error) {
 	return r.createAndResolveValue(internal.GetGlobalObject(r.context))
 }
 
+func (r *Realm) globalProperty(name string) (*Value, error) {
+	globalObj, err := r.GlobalObject()
+	if err != nil {
+		return nil, err
+	}
+
+	return globalObj.Get(name)
+}
+
+func (r *Realm) isInstanceOfGlobal(v *Value, constructorName string) (bool, error) {
+	constructor, err := r.globalProperty(constructorName)
+	if err != nil {
+		return false, err
+	}
+
+	return v.InstanceOf(constructor)
+}
+
 func (r *Realm) ParseJSON(data string, filename string) (*Value, error) {
 	return r.createAndResolveValue(internal.ParseJSON(r.context, data, filename))
 }
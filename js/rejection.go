@@ -0,0 +1,115 @@
+package js
+
+import (
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+// UnhandledRejectionError is returned by the event loop when a promise was
+// rejected without a handler. See WithFailOnUnhandledRejection.
+type UnhandledRejectionError struct {
+	Reason  *Value
+	Promise *Value
+}
+
+func (e *UnhandledRejectionError) Error() string {
+	return "unhandled promise rejection: " + (*Error)(e.Reason).Error()
+}
+
+func (e *UnhandledRejectionError) Unwrap() error {
+	return (*Error)(e.Reason)
+}
+
+// WithPromiseRejectionHandler sets a function that is called when a promise
+// is rejected without a handler, and again with handled set if a handler is
+// added later. If f panics, the event loop stops with the *PanicError.
+func WithPromiseRejectionHandler(f func(reason, promise *Value, handled bool)) RuntimeOption {
+	return func(rt *Runtime) {
+		rt.onPromiseRejection = f
+	}
+}
+
+// WithFailOnUnhandledRejection makes the event loop stop with an
+// *UnhandledRejectionError once there are no more pending jobs and a promise
+// was rejected without a handler.
+func WithFailOnUnhandledRejection() RuntimeOption {
+	return func(rt *Runtime) {
+		rt.failOnUnhandledRejection = true
+	}
+}
+
+// trackPromiseRejection is the promise rejection tracker of runtimes. It finds
+// the runtime through the realm of ctx, since trackers are saved globally and
+// must not keep their runtimes alive.
+func trackPromiseRejection(ctx *internal.Context, promiseValue, reasonValue internal.Value, handled bool) {
+	r, ok := contextRealm(ctx)
+	if !ok {
+		return
+	}
+
+	rt := r.runtime
+	if rt.ctx.Err() != nil {
+		// the runtime is closed
+		return
+	}
+
+	promise := r.createValue(internal.DupValue(ctx, promiseValue))
+	reason := r.createValue(internal.DupValue(ctx, reasonValue))
+
+	if rt.failOnUnhandledRejection {
+		rt.mutex.Lock()
+		if !handled {
+			rt.unhandledRejections = append(rt.unhandledRejections, &UnhandledRejectionError{
+				Reason:  reason,
+				Promise: promise,
+			})
+		} else {
+			for i, rejection := range rt.unhandledRejections {
+				if rejection.Promise.value.Ptr() == promiseValue.Ptr() {
+					// clear the last entry so that the backing array does
+					// not keep the realm of the rejection alive
+					last := len(rt.unhandledRejections) - 1
+					copy(rt.unhandledRejections[i:], rt.unhandledRejections[i+1:])
+					rt.unhandledRejections[last] = nil
+					rt.unhandledRejections = rt.unhandledRejections[:last]
+					break
+				}
+			}
+		}
+		rt.mutex.Unlock()
+	}
+
+	if rt.onPromiseRejection != nil {
+		// the tracker is called from c, so panics must not escape
+		defer recoverPanic(func(err error) {
+			rt.mutex.Lock()
+			rt.rejectionHandlerPanics = append(rt.rejectionHandlerPanics, err)
+			rt.mutex.Unlock()
+		})
+
+		rt.onPromiseRejection(reason, promise, handled)
+	}
+}
+
+// takeRejectionError removes and returns the earliest panic of the promise
+// rejection handler, or otherwise the earliest unhandled rejection, if any.
+func (rt *Runtime) takeRejectionError() error {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	if len(rt.rejectionHandlerPanics) > 0 {
+		err := rt.rejectionHandlerPanics[0]
+		rt.rejectionHandlerPanics = rt.rejectionHandlerPanics[1:]
+
+		return err
+	}
+
+	if len(rt.unhandledRejections) == 0 {
+		return nil
+	}
+
+	err := rt.unhandledRejections[0]
+	rt.unhandledRejections[0] = nil
+	rt.unhandledRejections = rt.unhandledRejections[1:]
+
+	return err
+}
@@ -0,0 +1,175 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testRejection struct {
+	realm   *Realm
+	reason  string
+	handled bool
+}
+
+func TestPromiseRejectionHandler(t *testing.T) {
+	var rejections []testRejection
+	rt := newTestRuntime(t, WithPromiseRejectionHandler(func(reason, promise *Value, handled bool) {
+		if !promise.IsPromise() {
+			t.Error("expected the rejected promise")
+		}
+
+		rejections = append(rejections, testRejection{realm: reason.realm, reason: reason.String(), handled: handled})
+	}))
+
+	r1, err := rt.NewRealm()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r2, err := rt.NewRealm()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustEval(t, r2, `globalThis.p = Promise.reject("a")`)
+	mustEval(t, r1, `Promise.reject("b").catch(() => {})`)
+
+	if err := rt.StartEventLoop(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	mustEval(t, r2, `p.catch(() => {})`)
+
+	want := []testRejection{
+		{realm: r2, reason: "a"},
+		{realm: r1, reason: "b"},
+		{realm: r1, reason: "b", handled: true},
+		{realm: r2, reason: "a", handled: true},
+	}
+
+	if len(rejections) != len(want) {
+		t.Fatalf("got %d rejections, want %d: %+v", len(rejections), len(want), rejections)
+	}
+
+	for i, rejection := range rejections {
+		if rejection != want[i] {
+			t.Errorf("rejection %d = %+v, want %+v", i, rejection, want[i])
+		}
+	}
+}
+
+func TestPromiseRejectionHandlerPanic(t *testing.T) {
+	rt := newTestRuntime(t, WithPromiseRejectionHandler(func(reason, promise *Value, handled bool) {
+		panic("boom")
+	}))
+
+	r, err := rt.NewRealm()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustEval(t, r, `Promise.reject(1)`)
+
+	var panicErr *PanicError
+	if err := rt.StartEventLoop(context.Background(), false); !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+		t.Errorf("StartEventLoop() = %v, want the panic", err)
+	}
+}
+
+func TestFailOnUnhandledRejection(t *testing.T) {
+	rt := newTestRuntime(t, WithFailOnUnhandledRejection())
+
+	r, err := rt.NewRealm()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rejections that are handled before the jobs run out are not reported
+	mustEval(t, r, `const p = Promise.reject(new Error("handled")); Promise.resolve().then(() => p.catch(() => {}))`)
+	if err := rt.StartEventLoop(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	mustEval(t, r, `Promise.reject(new TypeError("unhandled"))`)
+
+	var rejectionErr *UnhandledRejectionError
+	if err := rt.StartEventLoop(context.Background(), false); !errors.As(err, &rejectionErr) {
+		t.Fatalf("StartEventLoop() = %v, want an *UnhandledRejectionError", err)
+	}
+
+	if got := rejectionErr.Error(); got != "unhandled promise rejection: TypeError: unhandled" {
+		t.Errorf("Error() = %q", got)
+	}
+
+	var jsErr *Error
+	if !errors.As(rejectionErr, &jsErr) || jsErr.Name() != "TypeError" {
+		t.Errorf("expected the reason to be unwrapped, got %v", jsErr)
+	}
+
+	// reported rejections are not reported again
+	if err := rt.StartEventLoop(context.Background(), false); err != nil {
+		t.Errorf("StartEventLoop() = %v, want nil", err)
+	}
+}
+
+func TestPromiseRejectionAfterClose(t *testing.T) {
+	called := false
+	rt := newTestRuntime(t, WithPromiseRejectionHandler(func(reason, promise *Value, handled bool) {
+		called = true
+	}))
+
+	r, err := rt.NewRealm()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt.Close()
+
+	mustEval(t, r, `Promise.reject(1)`)
+
+	if called {
+		t.Error("expected rejections to be ignored after the runtime is closed")
+	}
+}
+
+func TestRealmsAreFreedWithoutClose(t *testing.T) {
+	scripts := []string{
+		``,
+		`1 + 1`,
+		`Promise.resolve(1).then(() => {})`,
+		`Promise.reject(1).catch(() => {})`,
+	}
+
+	var freed, total int32
+	for _, script := range scripts {
+		// realms of runtimes with and without rejection tracking
+		for _, rt := range []*Runtime{NewRuntime(), NewRuntimeWithOptions(WithFailOnUnhandledRejection())} {
+			r, err := rt.NewRealm()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			runtime.SetFinalizer(r, nil)
+			runtime.SetFinalizer(r, func(r *Realm) {
+				atomic.AddInt32(&freed, 1)
+				freeRealm(r)
+			})
+
+			mustEval(t, r, script)
+			total++
+		}
+	}
+
+	for i := 0; i < 50 && atomic.LoadInt32(&freed) < total; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if freed := atomic.LoadInt32(&freed); freed != total {
+		t.Errorf("%d of %d realms were freed without Close", freed, total)
+	}
+}
@@ -2,6 +2,7 @@ package js
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"runtime"
@@ -20,23 +21,77 @@ type Runtime struct {
 
 	taskQueue chan func() error
 
-	mutex  sync.Mutex
-	timers map[int]*time.Timer
+	mutex   sync.Mutex
+	timers  map[int]*time.Timer
+	classes []*Class
+
+	// unrefTimers are timers that the event loop does not wait for, such as
+	// those of AbortSignal.timeout. They share their ids with timers.
+	unrefTimers map[int]*time.Timer
+
+	errorClasses []errorClass
+
+	// number of goroutines started by goAsync that have not settled yet
+	pendingAsync int
+
+	onPromiseRejection       func(reason, promise *Value, handled bool)
+	failOnUnhandledRejection bool
+	unhandledRejections      []*UnhandledRejectionError
+	rejectionHandlerPanics   []error
+
+	repanic bool
+
+	onException func(err *Error, goStack []byte)
+
+	// ctx is cancelled when the runtime is closed
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	threadID threadID
 }
 
 func freeRuntime(rt *Runtime) {
+	rt.cancel()
+	internal.SetHostPromiseRejectionTracker(rt.runtime, nil)
 	internal.FreeRuntime(rt.runtime)
 }
 
+// RuntimeOption configures a runtime that is created with
+// NewRuntimeWithOptions.
+type RuntimeOption func(rt *Runtime)
+
+// WithDefaultRealmOptions sets options that are applied to every realm of the
+// runtime before the options that are passed to Runtime.NewRealm.
+func WithDefaultRealmOptions(opts ...RealmOption) RuntimeOption {
+	return func(rt *Runtime) {
+		rt.defaultRealmOptions = append(rt.defaultRealmOptions, opts...)
+	}
+}
+
+// NewRuntime creates a runtime whose realms are created with the given
+// default options.
 func NewRuntime(defaultRealmOptions ...RealmOption) *Runtime {
+	return NewRuntimeWithOptions(WithDefaultRealmOptions(defaultRealmOptions...))
+}
+
+// NewRuntimeWithOptions creates a runtime that is configured with opts.
+func NewRuntimeWithOptions(opts ...RuntimeOption) *Runtime {
 	rt := &Runtime{
-		runtime:             internal.NewRuntime(),
-		defaultRealmOptions: defaultRealmOptions,
-		timers:              map[int]*time.Timer{},
-		taskQueue:           make(chan func() error, 512),
-		threadID:            currentThreadID(),
+		runtime:     internal.NewRuntime(),
+		timers:      map[int]*time.Timer{},
+		unrefTimers: map[int]*time.Timer{},
+		taskQueue:   make(chan func() error, 512),
+		threadID:    currentThreadID(),
+	}
+
+	rt.ctx, rt.cancel = context.WithCancel(context.Background())
+
+	for _, option := range opts {
+		option(rt)
+	}
+
+	if rt.onPromiseRejection != nil || rt.failOnUnhandledRejection {
+		internal.SetHostPromiseRejectionTracker(rt.runtime, trackPromiseRejection)
 	}
 
 	runtime.SetFinalizer(rt, freeRuntime)
@@ -44,6 +99,43 @@ func NewRuntime(defaultRealmOptions ...RealmOption) *Runtime {
 	return rt
 }
 
+// Close cancels the context of async functions that are still running, stops
+// tracking promise rejections and the timers that the event loop does not
+// wait for.
+func (rt *Runtime) Close() error {
+	rt.cancel()
+	internal.SetHostPromiseRejectionTracker(rt.runtime, nil)
+
+	rt.mutex.Lock()
+	for id, timer := range rt.unrefTimers {
+		timer.Stop()
+		delete(rt.unrefTimers, id)
+	}
+	rt.mutex.Unlock()
+
+	return nil
+}
+
+// contextRealm returns the realm of a context, which is needed by callbacks
+// such as the promise rejection tracker that only receive the context.
+func contextRealm(ctx *internal.Context) (*Realm, bool) {
+	r := (*Realm)(internal.GetContextOpaque(ctx))
+	if r == nil {
+		return nil, false
+	}
+
+	return r, true
+}
+
+// realm is like contextRealm, but returns false if the runtime is closed.
+func (rt *Runtime) realm(ctx *internal.Context) (*Realm, bool) {
+	if rt.ctx.Err() != nil {
+		return nil, false
+	}
+
+	return contextRealm(ctx)
+}
+
 func (rt *Runtime) isSync() bool {
 	return rt.threadID == currentThreadID()
 }
@@ -55,13 +147,44 @@ func (rt *Runtime) hasPendingTimer() bool {
 	return len(rt.timers) > 0
 }
 
+func (rt *Runtime) hasPendingAsync() bool {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	return rt.pendingAsync > 0
+}
+
+// goAsync runs work on a new goroutine with the context of the runtime. The
+// function returned by work is then run by the event loop, which waits for
+// it as long as it is pending.
+func (rt *Runtime) goAsync(work func(ctx context.Context) func() error) {
+	rt.mutex.Lock()
+	rt.pendingAsync++
+	rt.mutex.Unlock()
+
+	go func() {
+		settle := work(rt.ctx)
+
+		rt.enqueueTask(func() error {
+			rt.mutex.Lock()
+			rt.pendingAsync--
+			rt.mutex.Unlock()
+
+			return settle()
+		})
+	}()
+}
+
 func (rt *Runtime) executePendingJob() (bool, error) {
 	ctx, res := internal.ExecutePendingJob(rt.runtime)
 	if res < 0 {
-		return false, (&Realm{
-			runtime: rt,
-			context: ctx,
-		}).getError()
+		r, ok := rt.realm(ctx)
+		if !ok {
+			internal.FreeValue(ctx, internal.GetException(ctx))
+			return false, errors.New("js: exception in a job of a closed runtime")
+		}
+
+		return false, r.getError()
 	}
 
 	return res != 0, nil
@@ -71,7 +194,11 @@ func (rt *Runtime) allocateTimerID() int {
 	var id int
 	for {
 		id = int(rand.Int31())
-		if _, ok := rt.timers[id]; !ok {
+		if _, ok := rt.timers[id]; ok {
+			continue
+		}
+
+		if _, ok := rt.unrefTimers[id]; !ok {
 			return id
 		}
 	}
@@ -139,6 +266,15 @@ func (rt *Runtime) enqueueTask(f func() error) {
 	rt.taskQueue <- f
 }
 
+// enqueueUnrefTask enqueues f from a goroutine other than the one of the event
+// loop, which does not wait for it, unless the runtime is closed first.
+func (rt *Runtime) enqueueUnrefTask(f func() error) {
+	select {
+	case rt.taskQueue <- f:
+	case <-rt.ctx.Done():
+	}
+}
+
 func (rt *Runtime) setTimer(r *Realm, fn *Function, ms float64, args []*Value, afterTask func(int)) (int, error) {
 	rt.mutex.Lock()
 	defer rt.mutex.Unlock()
@@ -166,6 +302,31 @@ func (rt *Runtime) setTimer(r *Realm, fn *Function, ms float64, args []*Value, a
 	return id, nil
 }
 
+// setUnrefTimer runs f on the event loop after d, which does not wait for it.
+// The timer is stopped when the runtime is closed, so that f and what it
+// holds, such as values of a realm, are released.
+func (rt *Runtime) setUnrefTimer(d time.Duration, f func() error) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	if rt.ctx.Err() != nil {
+		return
+	}
+
+	id := rt.allocateTimerID()
+	rt.unrefTimers[id] = time.AfterFunc(d, func() {
+		rt.mutex.Lock()
+		_, ok := rt.unrefTimers[id]
+		delete(rt.unrefTimers, id)
+		rt.mutex.Unlock()
+
+		// the timer may have fired while it was stopped by Close
+		if ok {
+			rt.enqueueUnrefTask(f)
+		}
+	})
+}
+
 func (rt *Runtime) clearTimer(r *Realm, _ *Value, id int) {
 	defer func() {
 		rt.taskQueue <- func() error {
@@ -186,11 +347,25 @@ func (rt *Runtime) clearTimer(r *Realm, _ *Value, id int) {
 }
 
 func (rt *Runtime) HasAsyncTasks() bool {
-	return internal.IsJobPending(rt.runtime) || rt.hasPendingTimer()
+	return internal.IsJobPending(rt.runtime) || rt.hasPendingTimer() || rt.hasPendingAsync()
 }
 
 func (rt *Runtime) StartEventLoop(ctx context.Context, waitForever bool) error {
+	return rt.runEventLoop(ctx, waitForever, nil)
+}
+
+// runEventLoop runs pending jobs and tasks until there are none left, unless
+// waitForever is set, or until done returns true.
+func (rt *Runtime) runEventLoop(ctx context.Context, waitForever bool, done func() bool) error {
+	isDone := func() bool {
+		return done != nil && done()
+	}
+
 	for {
+		if isDone() {
+			return nil
+		}
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -211,7 +386,15 @@ func (rt *Runtime) StartEventLoop(ctx context.Context, waitForever bool) error {
 		runtime.GC()
 
 		if !ok {
-			if !waitForever && !rt.hasPendingTimer() {
+			if err := rt.takeRejectionError(); err != nil {
+				return err
+			}
+
+			if isDone() {
+				return nil
+			}
+
+			if !waitForever && !rt.hasPendingTimer() && !rt.hasPendingAsync() {
 				return nil
 			}
 
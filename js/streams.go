@@ -0,0 +1,764 @@
+package js
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+// streamPromise is a promise that is settled by the algorithms of streams,
+// which need to know whether it is still pending.
+type streamPromise struct {
+	value   *Value
+	settle  [2]func(interface{}) error
+	pending bool
+}
+
+// newStreamPromise creates a pending promise. Like Must, it panics if the
+// promise cannot be created.
+func (r *Realm) newStreamPromise() *streamPromise {
+	promise, resolve, reject := r.NewPromise()
+	if promise == nil {
+		panic(resolve(nil))
+	}
+
+	return &streamPromise{
+		value:   promise,
+		settle:  [2]func(interface{}) error{resolve, reject},
+		pending: true,
+	}
+}
+
+func (r *Realm) resolvedStreamPromise(value interface{}) *streamPromise {
+	p := r.newStreamPromise()
+	p.resolve(value)
+	return p
+}
+
+func (r *Realm) rejectedStreamPromise(reason *Value) *streamPromise {
+	p := r.newStreamPromise()
+	p.reject(reason)
+	return p
+}
+
+func (p *streamPromise) resolve(value interface{}) {
+	if p.pending {
+		p.pending = false
+		_ = p.settle[0](value)
+	}
+}
+
+func (p *streamPromise) reject(reason *Value) {
+	if p.pending {
+		p.pending = false
+		_ = p.settle[1](reason)
+	}
+}
+
+// markHandled prevents the rejection of p from being reported as unhandled.
+func (p *streamPromise) markHandled() {
+	uponPromise(p.value, func(*Value) {}, func(*Value) {})
+}
+
+// uponPromise calls onFulfilled or onRejected once v, which is resolved as
+// with Promise.resolve, settles. Like Must, it panics if the reactions cannot
+// be added.
+func uponPromise(v *Value, onFulfilled, onRejected func(*Value)) {
+	if err := v.then(func(res *AsyncResult) {
+		if res.Error != nil {
+			onRejected(res.Error.(*Error).Value())
+		} else {
+			onFulfilled(res.Value)
+		}
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// promiseCall calls method with args and returns a promise of its result,
+// which is rejected if the call throws. A nil method results in undefined.
+func (r *Realm) promiseCall(method, this *Value, args ...*Value) *Value {
+	if method == nil {
+		return r.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	result, err := method.CallValues(this, args)
+	if err != nil {
+		return r.rejectedStreamPromise(r.errorValue(err)).value
+	}
+
+	return r.resolvedStreamPromise(result).value
+}
+
+// queueMicrotask calls f once the current job has finished.
+func (r *Realm) queueMicrotask(f func()) {
+	job := Must(r.NewFunction(func(*Realm, *Value) {
+		f()
+	}))
+
+	defer runtime.KeepAlive(job)
+
+	internal.EnqueueJob(r.context, job.value, internal.Undefined, nil)
+}
+
+// sizeQueue is a queue of chunks with their sizes.
+type sizeQueue struct {
+	values    []*Value
+	sizes     []float64
+	totalSize float64
+}
+
+func (q *sizeQueue) enqueue(value *Value, size float64) error {
+	if math.IsNaN(size) || math.IsInf(size, 0) || size < 0 {
+		return NewRangeError("The return value of a queuing strategy's size function must be a finite, non-NaN, non-negative number")
+	}
+
+	q.values = append(q.values, value)
+	q.sizes = append(q.sizes, size)
+	q.totalSize += size
+
+	return nil
+}
+
+func (q *sizeQueue) dequeue() *Value {
+	value, size := q.values[0], q.sizes[0]
+	q.values, q.sizes = q.values[1:], q.sizes[1:]
+
+	// rounding errors may make the total negative
+	if q.totalSize -= size; q.totalSize < 0 {
+		q.totalSize = 0
+	}
+
+	return value
+}
+
+func (q *sizeQueue) peek() *Value {
+	return q.values[0]
+}
+
+func (q *sizeQueue) empty() bool {
+	return len(q.values) == 0
+}
+
+func (q *sizeQueue) reset() {
+	q.values, q.sizes, q.totalSize = nil, nil, 0
+}
+
+// queuingStrategy is the high water mark and size algorithm of a queuing
+// strategy.
+type queuingStrategy struct {
+	highWaterMark float64
+	size          func(chunk *Value) (float64, error)
+}
+
+func countChunk(*Value) (float64, error) {
+	return 1, nil
+}
+
+// extractQueuingStrategy reads the highWaterMark and size members of a
+// queuing strategy. defaultHighWaterMark is used if highWaterMark is missing.
+func extractQueuingStrategy(r *Realm, strategy *Value, defaultHighWaterMark float64) (queuingStrategy, error) {
+	s := queuingStrategy{
+		highWaterMark: defaultHighWaterMark,
+		size:          countChunk,
+	}
+
+	switch {
+	case strategy.Tag() == TagUndefined || strategy.Tag() == TagNull:
+		return s, nil
+	case !strategy.IsObject():
+		return s, NewTypeError("The provided value is not of type 'QueuingStrategy'")
+	}
+
+	highWaterMark, err := strategy.Get("highWaterMark")
+	if err != nil {
+		return s, err
+	}
+
+	if highWaterMark.Tag() != TagUndefined {
+		if s.highWaterMark, err = r.toNumber(highWaterMark); err != nil {
+			return s, err
+		}
+
+		if math.IsNaN(s.highWaterMark) || s.highWaterMark < 0 {
+			return s, NewRangeError("Invalid highWaterMark")
+		}
+	}
+
+	size, err := strategy.Get("size")
+	if err != nil {
+		return s, err
+	}
+
+	if size.Tag() != TagUndefined {
+		if !size.IsFunction() {
+			return s, NewTypeError("The \"size\" member of a queuing strategy must be a function")
+		}
+
+		s.size = func(chunk *Value) (float64, error) {
+			result, err := size.Call(nil, chunk)
+			if err != nil {
+				return 0, err
+			}
+
+			return r.toNumber(result)
+		}
+	}
+
+	return s, nil
+}
+
+// sourceMethod returns the method of an underlying source, sink or
+// transformer, or nil if it is undefined.
+func sourceMethod(source *Value, name string) (*Value, error) {
+	if !source.IsObject() {
+		return nil, nil
+	}
+
+	method, err := source.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case method.Tag() == TagUndefined:
+		return nil, nil
+	case !method.IsFunction():
+		return nil, NewTypeError("The \"%s\" member must be a function", name)
+	}
+
+	return method, nil
+}
+
+// queuingStrategyInit returns the high water mark of the init argument of the
+// constructors of ByteLengthQueuingStrategy and CountQueuingStrategy.
+func queuingStrategyInit(r *Realm, args []*Value) (float64, error) {
+	init := optionalArg(args, 0)
+	if !init.IsObject() {
+		return 0, NewTypeError("The provided value is not of type 'QueuingStrategyInit'")
+	}
+
+	highWaterMark, err := init.Get("highWaterMark")
+	if err != nil {
+		return 0, err
+	}
+
+	if highWaterMark.Tag() == TagUndefined {
+		return 0, NewTypeError("Required member \"highWaterMark\" is undefined")
+	}
+
+	return r.toNumber(highWaterMark)
+}
+
+// builtinQueuingStrategy is the opaque value of ByteLengthQueuingStrategy and
+// CountQueuingStrategy.
+type builtinQueuingStrategy struct {
+	highWaterMark float64
+}
+
+func newQueuingStrategySpec(name string, size *Value) ClassSpec {
+	return ClassSpec{
+		Name: name,
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			highWaterMark, err := queuingStrategyInit(r, args)
+			if err != nil {
+				return nil, err
+			}
+
+			return &builtinQueuingStrategy{highWaterMark: highWaterMark}, nil
+		},
+		Properties: map[string]ClassProperty{
+			"highWaterMark": {
+				Get: func(r *Realm, this *Value) (float64, error) {
+					s, ok := this.Opaque().(*builtinQueuingStrategy)
+					if !ok {
+						return 0, NewTypeError("Illegal invocation")
+					}
+
+					return s.highWaterMark, nil
+				},
+			},
+			"size": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					if _, ok := this.Opaque().(*builtinQueuingStrategy); !ok {
+						return nil, NewTypeError("Illegal invocation")
+					}
+
+					return size, nil
+				},
+			},
+		},
+	}
+}
+
+// streamsAPI holds the classes of the streams intrinsic of a realm, which
+// refer to each other.
+type streamsAPI struct {
+	readableStream           *Class
+	readableStreamReader     *Class
+	readableStreamController *Class
+	writableStream           *Class
+	writableStreamWriter     *Class
+	writableStreamController *Class
+	transformStream          *Class
+	transformController      *Class
+}
+
+// defineStreams defines ReadableStream, WritableStream, TransformStream, the
+// classes of their readers, writers and controllers, and the queuing
+// strategies as globals of r.
+func (r *Realm) defineStreams() error {
+	api := &streamsAPI{}
+
+	byteLength, err := r.NewFunction(func(r *Realm, _ *Value, chunk *Value) (*Value, error) {
+		return chunk.Get("byteLength")
+	})
+	if err != nil {
+		return err
+	}
+
+	count, err := r.NewFunction(func(*Realm, *Value) (int, error) {
+		return 1, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, f := range []struct {
+		fn   *Value
+		name string
+	}{
+		{byteLength, "size"},
+		{count, "size"},
+	} {
+		if _, err := f.fn.DefineProperty("name", DefinePropertyValue(f.name), DefinePropertyConfigurable(true)); err != nil {
+			return err
+		}
+	}
+
+	classes := make([]*Class, 0, 10)
+	for _, spec := range []struct {
+		class **Class
+		spec  ClassSpec
+	}{
+		{nil, newQueuingStrategySpec("ByteLengthQueuingStrategy", byteLength)},
+		{nil, newQueuingStrategySpec("CountQueuingStrategy", count)},
+		{&api.readableStreamController, readableStreamControllerSpec},
+		{&api.readableStreamReader, api.readableStreamReaderSpec()},
+		{&api.readableStream, api.readableStreamSpec()},
+		{&api.writableStreamController, writableStreamControllerSpec},
+		{&api.writableStreamWriter, writableStreamWriterSpec},
+		{&api.writableStream, api.writableStreamSpec()},
+		{&api.transformController, transformStreamControllerSpec},
+		{&api.transformStream, api.transformStreamSpec()},
+	} {
+		class, err := r.defineGlobalClass(spec.spec)
+		if err != nil {
+			return err
+		}
+
+		if spec.class != nil {
+			*spec.class = class
+		}
+
+		classes = append(classes, class)
+	}
+
+	if err := r.defineClassToStringTags(classes...); err != nil {
+		return err
+	}
+
+	readableStreamCtor, err := api.readableStream.Constructor(r)
+	if err != nil {
+		return err
+	}
+
+	readableStreamProto, err := readableStreamCtor.Get("prototype")
+	if err != nil {
+		return err
+	}
+
+	values, err := readableStreamProto.Get("values")
+	if err != nil {
+		return err
+	}
+
+	asyncIterator, err := r.wellKnownSymbol("asyncIterator")
+	if err != nil {
+		return err
+	}
+
+	if _, err := readableStreamProto.DefinePropertyAtom(asyncIterator, DefinePropertyValue(values), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return err
+	}
+
+	r.streams = api
+
+	return nil
+}
+
+// readerSource is the underlying source of a stream that is read from an
+// io.Reader.
+type readerSource struct {
+	reader io.Reader
+
+	// mutex guards closed, but is not held while the reader is read or
+	// closed, so that closing the reader can interrupt a blocked read
+	mutex  sync.Mutex
+	closed bool
+}
+
+// readerChunkSize is the maximum size of the chunks of a stream that is read
+// from an io.Reader.
+const readerChunkSize = 64 * 1024
+
+func (s *readerSource) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.closed
+}
+
+func (s *readerSource) read(ctx context.Context) ([]byte, error) {
+	if s.isClosed() {
+		return nil, io.EOF
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, readerChunkSize)
+	for {
+		n, err := s.reader.Read(buf)
+		if n > 0 {
+			return buf[:n], nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *readerSource) close() {
+	s.mutex.Lock()
+	closed := s.closed
+	s.closed = true
+	s.mutex.Unlock()
+
+	if closed {
+		return
+	}
+
+	if closer, ok := s.reader.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// NewReadableStream creates a ReadableStream of Uint8Arrays that are read
+// from reader on demand, so that reader is only read while the queue of the
+// stream is not full. reader is closed once it is exhausted or the stream is
+// cancelled if it is an io.Closer.
+//
+// The realm must have been created with AddIntrinsicStreams.
+func (r *Realm) NewReadableStream(reader io.Reader) (*Value, error) {
+	if r.streams == nil {
+		return nil, errors.New("ReadableStream is not available in this realm")
+	}
+
+	value, _, err := r.streams.newReaderStream(r, reader, r.errorValue, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// newReaderStream is like NewReadableStream, but the stream is errored with
+// errorValue(err) when reading fails and done is called once the stream is
+// closed, errored or cancelled. Either may be nil.
+func (api *streamsAPI) newReaderStream(r *Realm, reader io.Reader, errorValue func(err error) *Value, done func()) (*Value, *readableStream, error) {
+	if errorValue == nil {
+		errorValue = r.errorValue
+	}
+
+	if done == nil {
+		done = func() {}
+	}
+
+	// a read that is interrupted by cancel settles after done was called
+	done = sync.OnceFunc(done)
+
+	source := &readerSource{reader: reader}
+
+	return api.newReadableStream(r, readableSource{
+		pull: func(c *readableStreamController) *Value {
+			promise := r.newStreamPromise()
+
+			r.runtime.goAsync(func(ctx context.Context) func() error {
+				chunk, err := source.read(ctx)
+
+				return func() error {
+					switch {
+					case err == io.EOF:
+						source.close()
+						c.close()
+						done()
+					case err != nil:
+						source.close()
+						c.error(errorValue(err))
+						done()
+					default:
+						array, err := r.newUint8Array(chunk)
+						if err != nil {
+							return err
+						}
+
+						if err := c.enqueue(array); err != nil {
+							return err
+						}
+					}
+
+					promise.resolve(NewUndefined())
+
+					return nil
+				}
+			})
+
+			return promise.value
+		},
+		cancel: func(*Value) *Value {
+			// closing interrupts a pending read of readers such as network
+			// connections and pipes, but may block itself
+			go source.close()
+			done()
+
+			return r.resolvedStreamPromise(NewUndefined()).value
+		},
+	}, queuingStrategy{highWaterMark: 0, size: countChunk})
+}
+
+// streamReader reads the chunks of a ReadableStream as bytes.
+type streamReader struct {
+	realm  *Realm
+	reader *readableStreamReader
+	buf    []byte
+	err    error
+}
+
+// AsReader locks the ReadableStream v and returns an io.ReadCloser of its
+// chunks, which must be ArrayBuffers or ArrayBufferViews. Closing the reader
+// cancels the stream.
+//
+// Read and Close may be called from any goroutine. When called outside of the
+// goroutine that owns the runtime, the event loop must be running elsewhere.
+func (v *Value) AsReader() (io.ReadCloser, error) {
+	s, ok := v.Opaque().(*readableStream)
+	if !ok {
+		return nil, NewTypeError("value is not a ReadableStream")
+	}
+
+	reader, err := v.realm.streams.acquireReader(v.realm, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{
+		realm:  v.realm,
+		reader: reader,
+	}, nil
+}
+
+// do runs f on the event loop and waits for it to call done.
+func (r *Realm) do(ctx context.Context, f func(done func())) error {
+	finished := make(chan struct{})
+	task := func() error {
+		f(func() {
+			close(finished)
+		})
+
+		return nil
+	}
+
+	if !r.runtime.isSync() {
+		r.runtime.enqueueTask(task)
+
+		select {
+		case <-finished:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	task()
+
+	return r.runtime.runEventLoop(ctx, true, func() bool {
+		select {
+		case <-finished:
+			return true
+		default:
+			return false
+		}
+	})
+}
+
+func (sr *streamReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for len(sr.buf) == 0 && sr.err == nil {
+		if err := sr.realm.do(sr.realm.runtime.ctx, func(done func()) {
+			sr.reader.read(readRequest{
+				chunk: func(chunk *Value) {
+					defer done()
+
+					data, ok, err := chunk.bufferSource()
+					switch {
+					case err != nil:
+						sr.err = err
+					case !ok:
+						sr.err = errors.New("chunk is not an ArrayBuffer or ArrayBufferView")
+					default:
+						sr.buf = bytes.Clone(data)
+					}
+
+					if sr.err != nil {
+						sr.realm.streams.cancelReader(sr.realm, sr.reader, sr.realm.errorValue(NewTypeError("%s", sr.err.Error())))
+					}
+				},
+				close: func() {
+					defer done()
+
+					sr.err = io.EOF
+				},
+				error: func(e *Value) {
+					defer done()
+
+					sr.err = (*Error)(e)
+				},
+			})
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(sr.buf) == 0 {
+		return 0, sr.err
+	}
+
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+
+	return n, nil
+}
+
+func (sr *streamReader) Close() error {
+	return sr.realm.do(sr.realm.runtime.ctx, func(done func()) {
+		if sr.err == nil {
+			sr.err = errors.New("reader is closed")
+		}
+
+		if sr.reader.stream == nil {
+			done()
+			return
+		}
+
+		sr.realm.streams.cancelReader(sr.realm, sr.reader, NewUndefined())
+		sr.reader.release()
+
+		done()
+	})
+}
+
+// streamWriter writes bytes to a WritableStream as Uint8Arrays.
+type streamWriter struct {
+	realm  *Realm
+	writer *writableStreamWriter
+}
+
+// AsWriter locks the WritableStream v and returns an io.WriteCloser that
+// writes each slice as a Uint8Array. Write waits until the chunk has been
+// written by the underlying sink, so that a slow sink slows down the writer.
+// Close closes the stream and waits for it to be closed.
+//
+// Write and Close may be called from any goroutine. When called outside of
+// the goroutine that owns the runtime, the event loop must be running
+// elsewhere.
+func (v *Value) AsWriter() (io.WriteCloser, error) {
+	s, ok := v.Opaque().(*writableStream)
+	if !ok {
+		return nil, NewTypeError("value is not a WritableStream")
+	}
+
+	writer, err := v.realm.streams.acquireWriter(v.realm, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{
+		realm:  v.realm,
+		writer: writer,
+	}, nil
+}
+
+// wait waits for the promise that is returned by f to settle.
+func (sw *streamWriter) wait(f func() *Value) error {
+	var result error
+	if err := sw.realm.do(sw.realm.runtime.ctx, func(done func()) {
+		if sw.writer.stream == nil {
+			result = errors.New("writer is closed")
+			done()
+			return
+		}
+
+		uponPromise(f(), func(*Value) {
+			done()
+		}, func(reason *Value) {
+			result = (*Error)(reason)
+			done()
+		})
+	}); err != nil {
+		return err
+	}
+
+	return result
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// the slice is copied before Write returns, as it may be reused
+	data := bytes.Clone(p)
+
+	if err := sw.wait(func() *Value {
+		chunk, err := sw.realm.newUint8Array(data)
+		if err != nil {
+			return sw.realm.rejectedStreamPromise(sw.realm.errorValue(err)).value
+		}
+
+		return sw.writer.write(sw.realm, chunk).value
+	}); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (sw *streamWriter) Close() error {
+	return sw.wait(func() *Value {
+		promise := sw.writer.closeWithErrorPropagation(sw.realm)
+		sw.writer.release(sw.realm)
+		return promise.value
+	})
+}
@@ -0,0 +1,510 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestStreamsRealm(t *testing.T) *Realm {
+	t.Helper()
+
+	r := newTestRealm(t, AddIntrinsicEvents, AddIntrinsicStreams, AddIntrinsicTextEncoding)
+	mustEval(t, r, `
+		globalThis.collect = async (stream) => {
+			const chunks = [];
+			for await (const chunk of stream) {
+				chunks.push(chunk);
+			}
+			return chunks;
+		};
+		globalThis.text = async (stream) => {
+			const decoder = new TextDecoder();
+			return (await collect(stream)).map((chunk) => decoder.decode(chunk)).join("");
+		};
+	`)
+
+	return r
+}
+
+func TestReadableStream(t *testing.T) {
+	r := newTestStreamsRealm(t)
+
+	expectAwait(t, r, `(async () => {
+		const stream = new ReadableStream({
+			start(c) { c.enqueue("a") },
+			pull(c) { c.enqueue("b"); c.close() },
+		});
+		const reader = stream.getReader();
+		const results = [stream.locked];
+		for (let i = 0; i < 3; i++) {
+			const {value, done} = await reader.read();
+			results.push(value + ":" + done);
+		}
+		await reader.closed;
+		return results.join();
+	})()`, "true,a:false,b:false,undefined:true")
+
+	expectAwait(t, r, `(async () => {
+		let cancelled;
+		const stream = new ReadableStream({ cancel(reason) { cancelled = reason } });
+		await stream.cancel("why");
+		return cancelled;
+	})()`, "why")
+
+	expectAwait(t, r, `(async () => {
+		const stream = new ReadableStream({ start(c) { c.error(new RangeError("broken")) } });
+		return stream.getReader().read().then(() => "resolved", (e) => e.name + ":" + e.message);
+	})()`, "RangeError:broken")
+
+	expectAwait(t, r, `(async () => {
+		const [a, b] = new ReadableStream({ start(c) { c.enqueue(1); c.enqueue(2); c.close() } }).tee();
+		return (await collect(a)).join() + "|" + (await collect(b)).join();
+	})()`, "1,2|1,2")
+
+	expectAwait(t, r, `collect(ReadableStream.from([1, 2, 3])).then(String)`, "1,2,3")
+	expectAwait(t, r, `collect(ReadableStream.from((async function* () { yield "x"; yield "y" })())).then(String)`, "x,y")
+
+	expectAwait(t, r, `(async () => {
+		const stream = new ReadableStream({ start(c) { c.enqueue(1) } });
+		stream.getReader();
+		return [stream.locked, await stream.cancel().then(() => "resolved", (e) => e.name)].join();
+	})()`, "true,TypeError")
+
+	for _, script := range []string{
+		`ReadableStream()`,
+		`{ const s = new ReadableStream(); s.getReader(); s.getReader() }`,
+		`new ReadableStream().getReader({mode: "byob"})`,
+		`new ReadableStream({ start(c) { c.close(); c.enqueue(1) } })`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { e.name }`, "TypeError")
+	}
+
+	// byte streams are not supported
+	expectString(t, r, `try { new ReadableStream({type: "bytes"}) } catch (e) { e.name }`, "RangeError")
+}
+
+func TestWritableStream(t *testing.T) {
+	r := newTestStreamsRealm(t)
+
+	expectAwait(t, r, `(async () => {
+		const written = [];
+		const stream = new WritableStream({
+			write(chunk) { written.push(chunk) },
+			close() { written.push("closed") },
+		});
+		const writer = stream.getWriter();
+		await writer.write(1);
+		await writer.write(2);
+		await writer.close();
+		return [stream.locked, written.join()].join();
+	})()`, "true,1,2,closed")
+
+	expectAwait(t, r, `(async () => {
+		let aborted;
+		const stream = new WritableStream({ abort(reason) { aborted = reason } });
+		await stream.abort("stop");
+		return aborted;
+	})()`, "stop")
+
+	expectAwait(t, r, `(async () => {
+		const stream = new WritableStream({ write() { throw new Error("sink failed") } });
+		const writer = stream.getWriter();
+		const result = await writer.write(1).then(() => "resolved", (e) => e.message);
+		return result + ":" + await writer.closed.then(() => "resolved", (e) => e.message);
+	})()`, "sink failed:sink failed")
+
+	expectAwait(t, r, `(async () => {
+		const stream = new WritableStream({}, new CountQueuingStrategy({highWaterMark: 2}));
+		const writer = stream.getWriter();
+		const before = writer.desiredSize;
+		writer.write(1);
+		return before + ":" + writer.desiredSize;
+	})()`, "2:1")
+
+	expectAwait(t, r, `(async () => {
+		let signal;
+		const stream = new WritableStream({ start(c) { signal = c.signal } });
+		await stream.abort("reason");
+		return [signal.aborted, signal.reason].join();
+	})()`, "true,reason")
+
+	expectString(t, r, `try { const s = new WritableStream(); s.getWriter(); s.getWriter() } catch (e) { e.name }`, "TypeError")
+}
+
+func TestTransformStream(t *testing.T) {
+	r := newTestStreamsRealm(t)
+
+	expectAwait(t, r, `(async () => {
+		const upper = new TransformStream({
+			transform(chunk, c) { c.enqueue(chunk.toUpperCase()) },
+			flush(c) { c.enqueue("!") },
+		});
+		const result = ReadableStream.from(["a", "b"]).pipeThrough(upper);
+		return (await collect(result)).join("");
+	})()`, "AB!")
+
+	expectAwait(t, r, `(async () => {
+		const {readable, writable} = new TransformStream();
+		const writer = writable.getWriter();
+		writer.write("same");
+		writer.close();
+		return (await collect(readable)).join();
+	})()`, "same")
+
+	expectAwait(t, r, `(async () => {
+		const t = new TransformStream({ transform(chunk, c) { c.terminate() } });
+		const writer = t.writable.getWriter();
+		writer.write("x").catch(() => {});
+		return (await collect(t.readable)).length;
+	})()`, "0")
+
+	expectAwait(t, r, `(async () => {
+		const t = new TransformStream({ transform() { throw new Error("transform failed") } });
+		t.writable.getWriter().write("x").catch(() => {});
+		return collect(t.readable).then(() => "resolved", (e) => e.message);
+	})()`, "transform failed")
+
+	// pipeTo writes every chunk and closes the destination
+	expectAwait(t, r, `(async () => {
+		const written = [];
+		await ReadableStream.from([1, 2]).pipeTo(new WritableStream({
+			write(chunk) { written.push(chunk) },
+			close() { written.push("closed") },
+		}));
+		return written.join();
+	})()`, "1,2,closed")
+}
+
+func TestQueuingStrategies(t *testing.T) {
+	r := newTestStreamsRealm(t)
+
+	expectString(t, r, `{ const s = new ByteLengthQueuingStrategy({highWaterMark: 16}); [s.highWaterMark, s.size(new Uint8Array(3))].join() }`, "16,3")
+	expectString(t, r, `{ const s = new CountQueuingStrategy({highWaterMark: 4}); [s.highWaterMark, s.size("anything")].join() }`, "4,1")
+	expectString(t, r, `try { new CountQueuingStrategy() } catch (e) { e.name }`, "TypeError")
+}
+
+// closeRecorder records whether its reader was closed.
+type closeRecorder struct {
+	io.Reader
+
+	mutex  sync.Mutex
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closed = true
+	return nil
+}
+
+func (c *closeRecorder) isClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.closed
+}
+
+func TestNewReadableStream(t *testing.T) {
+	r := newTestStreamsRealm(t)
+
+	reader := &closeRecorder{Reader: strings.NewReader("hello from go")}
+
+	stream, err := r.NewReadableStream(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "stream", stream)
+
+	expectAwait(t, r, `text(stream)`, "hello from go")
+	expectString(t, r, `stream instanceof ReadableStream`, "true")
+
+	if !reader.isClosed() {
+		t.Error("expected the reader to be closed once it is exhausted")
+	}
+
+	// errors of the reader error the stream
+	stream, err = r.NewReadableStream(io.MultiReader(strings.NewReader("partial"), iotestErrReader{errors.New("read failed")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobal(t, r, "stream", stream)
+
+	expectAwait(t, r, `(async () => {
+		const reader = stream.getReader();
+		const first = await reader.read();
+		const second = await reader.read().then(() => "resolved", (e) => e.message);
+		return new TextDecoder().decode(first.value) + ":" + second;
+	})()`, "partial:read failed")
+
+	if _, err := newTestRealm(t).NewReadableStream(strings.NewReader("")); err == nil {
+		t.Error("expected an error for a realm without streams")
+	}
+}
+
+type iotestErrReader struct {
+	err error
+}
+
+func (r iotestErrReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// blockingReader blocks until it is closed, like a network connection that
+// does not receive any data.
+type blockingReader struct {
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (b *blockingReader) Read([]byte) (int, error) {
+	<-b.closed
+	return 0, io.ErrClosedPipe
+}
+
+func (b *blockingReader) Close() error {
+	b.once.Do(func() {
+		close(b.closed)
+	})
+
+	return nil
+}
+
+// readCloseRecorder records when its reader is first read and closed.
+type readCloseRecorder struct {
+	io.ReadCloser
+
+	readOnce  sync.Once
+	reading   chan struct{}
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newReadCloseRecorder(rc io.ReadCloser) *readCloseRecorder {
+	return &readCloseRecorder{
+		ReadCloser: rc,
+		reading:    make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+}
+
+func (rc *readCloseRecorder) Read(p []byte) (int, error) {
+	rc.readOnce.Do(func() {
+		close(rc.reading)
+	})
+
+	return rc.ReadCloser.Read(p)
+}
+
+func (rc *readCloseRecorder) Close() error {
+	rc.closeOnce.Do(func() {
+		close(rc.closed)
+	})
+
+	return rc.ReadCloser.Close()
+}
+
+func TestNewReadableStreamCancelBlockedRead(t *testing.T) {
+	pr, _ := io.Pipe()
+
+	for name, reader := range map[string]io.ReadCloser{
+		"blocking": &blockingReader{closed: make(chan struct{})},
+		"pipe":     pr,
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := newTestStreamsRealm(t)
+
+			recorder := newReadCloseRecorder(reader)
+
+			stream, err := r.NewReadableStream(recorder)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			setGlobal(t, r, "stream", stream)
+
+			mustEval(t, r, `
+				globalThis.reader = stream.getReader();
+				globalThis.result = "pending";
+				reader.read().then(({done}) => result = "done:" + done);
+			`)
+
+			// run the jobs that start pulling, and wait until the read blocks
+			for {
+				if ok, err := r.runtime.executePendingJob(); err != nil {
+					t.Fatal(err)
+				} else if !ok {
+					break
+				}
+			}
+
+			select {
+			case <-recorder.reading:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the reader was not read")
+			}
+
+			// the cancellation must close the reader, which interrupts the
+			// pending read
+			expectAwait(t, r, `reader.cancel("stop").then(() => "cancelled")`, "cancelled")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			if err := r.runtime.StartEventLoop(ctx, false); err != nil {
+				t.Fatalf("expected the pending read to settle, got %v", err)
+			}
+
+			select {
+			case <-recorder.closed:
+			default:
+				t.Error("expected the reader to be closed")
+			}
+
+			expectString(t, r, `result`, "done:true")
+		})
+	}
+}
+
+func TestAsReader(t *testing.T) {
+	r := newTestStreamsRealm(t)
+
+	stream := mustEval(t, r, `new ReadableStream({
+		start(c) {
+			const encoder = new TextEncoder();
+			c.enqueue(encoder.encode("hello "));
+			c.enqueue(encoder.encode("world").buffer);
+			c.enqueue(new DataView(encoder.encode("!").buffer));
+			c.close();
+		},
+	})`)
+
+	reader, err := stream.AsReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil || string(data) != "hello world!" {
+		t.Errorf("ReadAll() = %q, %v", data, err)
+	}
+
+	if _, err := stream.AsReader(); err == nil {
+		t.Error("expected an error for a locked stream")
+	}
+
+	if err := reader.Close(); err != nil {
+		t.Error(err)
+	}
+
+	// chunks that are not buffers fail the read and cancel the stream
+	stream = mustEval(t, r, `globalThis.cancelled = undefined; new ReadableStream({
+		start(c) { c.enqueue("text") },
+		cancel(reason) { cancelled = reason.name },
+	})`)
+
+	reader, err = stream.AsReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.ReadAll(reader); err == nil || !strings.Contains(err.Error(), "not an ArrayBuffer") {
+		t.Errorf("expected an error for a string chunk, got %v", err)
+	}
+
+	expectString(t, r, `cancelled`, "TypeError")
+
+	// errors of the stream are returned as *Error
+	stream = mustEval(t, r, `new ReadableStream({ start(c) { c.error(new RangeError("broken")) } })`)
+
+	reader, err = stream.AsReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jsErr *Error
+	if _, err := reader.Read(make([]byte, 1)); !errors.As(err, &jsErr) || jsErr.Name() != "RangeError" {
+		t.Errorf("Read() error = %v, want a RangeError", err)
+	}
+
+	// closing the reader cancels the stream
+	stream = mustEval(t, r, `globalThis.cancelled = "no"; new ReadableStream({ cancel() { cancelled = "yes" } })`)
+
+	reader, err = stream.AsReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectString(t, r, `cancelled`, "yes")
+
+	if _, err := reader.Read(make([]byte, 1)); err == nil {
+		t.Error("expected an error when reading a closed reader")
+	}
+
+	if _, err := mustEval(t, r, `({})`).AsReader(); err == nil {
+		t.Error("expected an error for a value that is not a stream")
+	}
+}
+
+func TestAsWriter(t *testing.T) {
+	r := newTestStreamsRealm(t)
+
+	stream := mustEval(t, r, `globalThis.written = []; new WritableStream({
+		write(chunk) { written.push(chunk instanceof Uint8Array ? new TextDecoder().decode(chunk) : typeof chunk) },
+		close() { written.push("closed") },
+	})`)
+
+	writer, err := stream.AsWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"a", "", "bc"} {
+		if n, err := io.WriteString(writer, s); n != len(s) || err != nil {
+			t.Errorf("WriteString(%q) = %d, %v", s, n, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectString(t, r, `written.join()`, "a,bc,closed")
+
+	if _, err := writer.Write([]byte("late")); err == nil {
+		t.Error("expected an error when writing to a closed writer")
+	}
+
+	// errors of the sink are returned by Write
+	stream = mustEval(t, r, `new WritableStream({ write() { throw new TypeError("sink failed") } })`)
+
+	writer, err = stream.AsWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jsErr *Error
+	if _, err := writer.Write([]byte("x")); !errors.As(err, &jsErr) || jsErr.Message() != "sink failed" {
+		t.Errorf("Write() error = %v, want the error of the sink", err)
+	}
+
+	if _, err := stream.AsWriter(); err == nil {
+		t.Error("expected an error for a locked stream")
+	}
+
+	if _, err := mustEval(t, r, `new ReadableStream()`).AsWriter(); err == nil {
+		t.Error("expected an error for a value that is not a WritableStream")
+	}
+}
@@ -0,0 +1,610 @@
+package js
+
+import (
+	"runtime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// textDecoding decodes bytes of an encoding in chunks.
+type textDecoding interface {
+	// decode appends the characters of data to b. If flush is not set, an
+	// incomplete sequence at the end of data is kept until the next call.
+	// Invalid sequences are replaced with U+FFFD unless fatal is set, in
+	// which case decode returns false.
+	decode(b *strings.Builder, data []byte, flush, fatal bool) bool
+
+	// bom returns the byte order mark of the encoding, if any.
+	bom() []byte
+}
+
+// utf8Decoding implements the utf-8 decoder of the WHATWG encoding standard,
+// which replaces each maximal invalid subsequence with a single U+FFFD.
+type utf8Decoding struct {
+	codePoint   rune
+	bytesNeeded int
+	bytesSeen   int
+	lower       byte
+	upper       byte
+}
+
+func (d *utf8Decoding) reset() {
+	d.codePoint, d.bytesNeeded, d.bytesSeen = 0, 0, 0
+	d.lower, d.upper = 0x80, 0xBF
+}
+
+func (d *utf8Decoding) decode(b *strings.Builder, data []byte, flush, fatal bool) bool {
+	if d.lower == 0 {
+		d.reset()
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		if d.bytesNeeded == 0 {
+			switch {
+			case c <= 0x7F:
+				b.WriteByte(c)
+
+			case c >= 0xC2 && c <= 0xDF:
+				d.bytesNeeded = 1
+				d.codePoint = rune(c & 0x1F)
+
+			case c >= 0xE0 && c <= 0xEF:
+				if c == 0xE0 {
+					d.lower = 0xA0
+				} else if c == 0xED {
+					d.upper = 0x9F
+				}
+
+				d.bytesNeeded = 2
+				d.codePoint = rune(c & 0xF)
+
+			case c >= 0xF0 && c <= 0xF4:
+				if c == 0xF0 {
+					d.lower = 0x90
+				} else if c == 0xF4 {
+					d.upper = 0x8F
+				}
+
+				d.bytesNeeded = 3
+				d.codePoint = rune(c & 0x7)
+
+			default:
+				if fatal {
+					return false
+				}
+
+				b.WriteRune(utf8.RuneError)
+			}
+
+			continue
+		}
+
+		if c < d.lower || c > d.upper {
+			// the byte is processed again as the start of a new sequence
+			d.reset()
+			i--
+
+			if fatal {
+				return false
+			}
+
+			b.WriteRune(utf8.RuneError)
+			continue
+		}
+
+		d.lower, d.upper = 0x80, 0xBF
+		d.codePoint = d.codePoint<<6 | rune(c&0x3F)
+		d.bytesSeen++
+
+		if d.bytesSeen == d.bytesNeeded {
+			b.WriteRune(d.codePoint)
+			d.reset()
+		}
+	}
+
+	if flush && d.bytesNeeded != 0 {
+		d.reset()
+
+		if fatal {
+			return false
+		}
+
+		b.WriteRune(utf8.RuneError)
+	}
+
+	return true
+}
+
+func (d *utf8Decoding) bom() []byte {
+	return []byte{0xEF, 0xBB, 0xBF}
+}
+
+// utf16Decoding implements the utf-16le and utf-16be decoders of the WHATWG
+// encoding standard.
+type utf16Decoding struct {
+	bigEndian bool
+
+	hasLeadByte   bool
+	leadByte      byte
+	leadSurrogate rune
+}
+
+func (d *utf16Decoding) decode(b *strings.Builder, data []byte, flush, fatal bool) bool {
+	for _, c := range data {
+		if !d.hasLeadByte {
+			d.hasLeadByte = true
+			d.leadByte = c
+			continue
+		}
+
+		d.hasLeadByte = false
+
+		codeUnit := rune(d.leadByte) | rune(c)<<8
+		if d.bigEndian {
+			codeUnit = rune(d.leadByte)<<8 | rune(c)
+		}
+
+		if d.leadSurrogate != 0 {
+			leadSurrogate := d.leadSurrogate
+			d.leadSurrogate = 0
+
+			if codeUnit >= 0xDC00 && codeUnit <= 0xDFFF {
+				b.WriteRune(0x10000 + (leadSurrogate-0xD800)<<10 + (codeUnit - 0xDC00))
+				continue
+			}
+
+			if fatal {
+				return false
+			}
+
+			b.WriteRune(utf8.RuneError)
+		}
+
+		switch {
+		case codeUnit >= 0xD800 && codeUnit <= 0xDBFF:
+			d.leadSurrogate = codeUnit
+
+		case codeUnit >= 0xDC00 && codeUnit <= 0xDFFF:
+			if fatal {
+				return false
+			}
+
+			b.WriteRune(utf8.RuneError)
+
+		default:
+			b.WriteRune(codeUnit)
+		}
+	}
+
+	if flush && (d.hasLeadByte || d.leadSurrogate != 0) {
+		d.hasLeadByte, d.leadSurrogate = false, 0
+
+		if fatal {
+			return false
+		}
+
+		b.WriteRune(utf8.RuneError)
+	}
+
+	return true
+}
+
+func (d *utf16Decoding) bom() []byte {
+	if d.bigEndian {
+		return []byte{0xFE, 0xFF}
+	}
+
+	return []byte{0xFF, 0xFE}
+}
+
+// charmapDecoding decodes single byte encodings, such as windows-1252, which
+// is what latin1 refers to.
+type charmapDecoding struct {
+	charmap *charmap.Charmap
+}
+
+func (d *charmapDecoding) decode(b *strings.Builder, data []byte, flush, fatal bool) bool {
+	for _, c := range data {
+		r := d.charmap.DecodeByte(c)
+		if r == utf8.RuneError && fatal {
+			return false
+		}
+
+		b.WriteRune(r)
+	}
+
+	return true
+}
+
+func (d *charmapDecoding) bom() []byte {
+	return nil
+}
+
+// newTextDecoding returns the decoder of the encoding with the given label
+// along with the name of the encoding. Multi-byte encodings other than utf-8
+// and utf-16 are not supported.
+func newTextDecoding(label string) (textDecoding, string, bool) {
+	enc, err := htmlindex.Get(strings.TrimSpace(label))
+	if err != nil {
+		return nil, "", false
+	}
+
+	name, err := htmlindex.Name(enc)
+	if err != nil {
+		return nil, "", false
+	}
+
+	switch name {
+	case "utf-8":
+		return &utf8Decoding{}, name, true
+
+	case "utf-16le":
+		return &utf16Decoding{}, name, true
+
+	case "utf-16be":
+		return &utf16Decoding{bigEndian: true}, name, true
+	}
+
+	cm, ok := enc.(*charmap.Charmap)
+	if !ok {
+		return nil, "", false
+	}
+
+	return &charmapDecoding{charmap: cm}, name, true
+}
+
+// textDecoder is the opaque value of TextDecoder instances.
+type textDecoder struct {
+	label     string
+	encoding  string
+	fatal     bool
+	ignoreBOM bool
+
+	decoding textDecoding
+
+	// bomChecked is set once the start of a stream has been checked for a
+	// byte order mark, and pending holds its first bytes until then
+	bomChecked bool
+	pending    []byte
+}
+
+func (d *textDecoder) reset() {
+	d.decoding, _, _ = newTextDecoding(d.label)
+	d.bomChecked = false
+	d.pending = nil
+}
+
+func (d *textDecoder) decode(data []byte, stream bool) (string, error) {
+	if !d.bomChecked {
+		data = append(d.pending, data...)
+		d.pending = nil
+
+		bom := d.decoding.bom()
+		if len(bom) > 0 && !d.ignoreBOM {
+			if len(data) < len(bom) && stream && strings.HasPrefix(string(bom), string(data)) {
+				// wait for more bytes to tell whether there is a bom
+				d.pending = data
+				return "", nil
+			}
+
+			if strings.HasPrefix(string(data), string(bom)) {
+				data = data[len(bom):]
+			}
+		}
+
+		d.bomChecked = true
+	}
+
+	var b strings.Builder
+	ok := d.decoding.decode(&b, data, !stream, d.fatal)
+
+	if !stream || !ok {
+		d.reset()
+	}
+
+	if !ok {
+		return "", NewTypeError("The encoded data was not valid for encoding %s", d.encoding)
+	}
+
+	return b.String(), nil
+}
+
+func thisTextDecoder(this *Value) (*textDecoder, error) {
+	d, ok := this.Opaque().(*textDecoder)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return d, nil
+}
+
+func newTextDecoderInstance(r *Realm, args []*Value) (interface{}, error) {
+	label := "utf-8"
+	if len(args) > 0 && args[0].Tag() != TagUndefined {
+		s, err := r.toString(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		label = s
+	}
+
+	decoding, name, ok := newTextDecoding(label)
+	if !ok {
+		return nil, NewRangeError("The \"%s\" encoding is not supported", label)
+	}
+
+	d := &textDecoder{
+		label:    label,
+		encoding: name,
+		decoding: decoding,
+	}
+
+	if len(args) > 1 && args[1].IsObject() {
+		var err error
+		if d.fatal, err = booleanOption(args[1], "fatal"); err != nil {
+			return nil, err
+		}
+
+		if d.ignoreBOM, err = booleanOption(args[1], "ignoreBOM"); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+var textDecoderSpec = ClassSpec{
+	Name:        "TextDecoder",
+	Constructor: newTextDecoderInstance,
+	Methods: map[string]interface{}{
+		"decode": func(r *Realm, this *Value, args ...*Value) (string, error) {
+			d, err := thisTextDecoder(this)
+			if err != nil {
+				return "", err
+			}
+
+			var data []byte
+			if len(args) > 0 && args[0].Tag() != TagUndefined {
+				source, ok, err := args[0].bufferSource()
+				if err != nil {
+					return "", err
+				}
+
+				if !ok {
+					return "", NewTypeError("The \"input\" argument must be an instance of ArrayBuffer or ArrayBufferView")
+				}
+
+				defer runtime.KeepAlive(args[0])
+
+				data = source
+			}
+
+			stream := false
+			if len(args) > 1 && args[1].IsObject() {
+				if stream, err = booleanOption(args[1], "stream"); err != nil {
+					return "", err
+				}
+			}
+
+			return d.decode(data, stream)
+		},
+	},
+	Properties: map[string]ClassProperty{
+		"encoding": {
+			Get: func(r *Realm, this *Value) (string, error) {
+				d, err := thisTextDecoder(this)
+				if err != nil {
+					return "", err
+				}
+
+				return d.encoding, nil
+			},
+		},
+		"fatal": {
+			Get: func(r *Realm, this *Value) (bool, error) {
+				d, err := thisTextDecoder(this)
+				if err != nil {
+					return false, err
+				}
+
+				return d.fatal, nil
+			},
+		},
+		"ignoreBOM": {
+			Get: func(r *Realm, this *Value) (bool, error) {
+				d, err := thisTextDecoder(this)
+				if err != nil {
+					return false, err
+				}
+
+				return d.ignoreBOM, nil
+			},
+		},
+	},
+}
+
+// textEncoder is the opaque value of TextEncoder instances.
+type textEncoder struct{}
+
+func thisTextEncoder(this *Value) error {
+	if _, ok := this.Opaque().(*textEncoder); !ok {
+		return NewTypeError("Illegal invocation")
+	}
+
+	return nil
+}
+
+var textEncoderSpec = ClassSpec{
+	Name: "TextEncoder",
+	Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+		return &textEncoder{}, nil
+	},
+	Methods: map[string]interface{}{
+		"encode": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+			if err := thisTextEncoder(this); err != nil {
+				return nil, err
+			}
+
+			s := ""
+			if len(args) > 0 && args[0].Tag() != TagUndefined {
+				var err error
+				if s, err = r.toUSVString(args[0]); err != nil {
+					return nil, err
+				}
+			}
+
+			return r.newUint8Array([]byte(s))
+		},
+		"encodeInto": func(r *Realm, this *Value, source, destination *Value) (*Value, error) {
+			if err := thisTextEncoder(this); err != nil {
+				return nil, err
+			}
+
+			s, err := r.toUSVString(source)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok, err := r.isInstanceOfGlobal(destination, "Uint8Array"); err != nil {
+				return nil, err
+			} else if !ok {
+				return nil, NewTypeError("The \"dest\" argument must be an instance of Uint8Array")
+			}
+
+			dest, _, err := destination.bufferSource()
+			if err != nil {
+				return nil, err
+			}
+
+			defer runtime.KeepAlive(destination)
+
+			read, written := 0, 0
+			for _, c := range s {
+				n := utf8.RuneLen(c)
+				if written+n > len(dest) {
+					break
+				}
+
+				utf8.EncodeRune(dest[written:], c)
+				written += n
+
+				// read is counted in utf-16 code units
+				read++
+				if c >= 0x10000 {
+					read++
+				}
+			}
+
+			result, err := r.NewObject()
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := result.Set("read", read); err != nil {
+				return nil, err
+			}
+
+			if _, err := result.Set("written", written); err != nil {
+				return nil, err
+			}
+
+			return result, nil
+		},
+	},
+	Properties: map[string]ClassProperty{
+		"encoding": {
+			Get: func(r *Realm, this *Value) (string, error) {
+				if err := thisTextEncoder(this); err != nil {
+					return "", err
+				}
+
+				return "utf-8", nil
+			},
+		},
+	},
+}
+
+// booleanOption returns the truthiness of a property of an options object.
+func booleanOption(options *Value, name string) (bool, error) {
+	value, err := options.Get(name)
+	if err != nil {
+		return false, err
+	}
+
+	return value.IsTruthy()
+}
+
+// toString converts v to a string as with String(v).
+func (r *Realm) toString(v *Value) (string, error) {
+	if v.IsString() {
+		return v.ToString(), nil
+	}
+
+	if v.IsSymbol() {
+		return "", NewTypeError("Cannot convert a Symbol value to a string")
+	}
+
+	stringConstructor, err := r.globalProperty("String")
+	if err != nil {
+		return "", err
+	}
+
+	s, err := stringConstructor.Call(nil, v)
+	if err != nil {
+		return "", err
+	}
+
+	return s.ToString(), nil
+}
+
+// toNumber converts v to a number as with Number(v).
+func (r *Realm) toNumber(v *Value) (float64, error) {
+	if v.IsNumber() {
+		return v.ToFloat(), nil
+	}
+
+	numberConstructor, err := r.globalProperty("Number")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := numberConstructor.Call(nil, v)
+	if err != nil {
+		return 0, err
+	}
+
+	return n.ToFloat(), nil
+}
+
+// toUSVString is like toString, but lone surrogates are replaced with U+FFFD.
+func (r *Realm) toUSVString(v *Value) (string, error) {
+	s, err := r.toString(v)
+	if err != nil {
+		return "", err
+	}
+
+	if utf8.ValidString(s) {
+		return s, nil
+	}
+
+	// quickjs encodes lone surrogates as if they were code points, which is
+	// invalid utf-8
+	var b strings.Builder
+	for len(s) > 0 {
+		c, n := utf8.DecodeRuneInString(s)
+		if c == utf8.RuneError && n == 1 && len(s) >= 3 && s[0] == 0xED && s[1] >= 0xA0 && s[1] <= 0xBF {
+			n = 3
+		}
+
+		b.WriteRune(c)
+		s = s[n:]
+	}
+
+	return b.String(), nil
+}
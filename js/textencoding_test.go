@@ -0,0 +1,91 @@
+package js
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextEncoder(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicTextEncoding)
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`new TextEncoder().encoding`, "utf-8"},
+		{`Array.from(new TextEncoder().encode("aé€😀")).join()`, "97,195,169,226,130,172,240,159,152,128"},
+		{`new TextEncoder().encode() instanceof Uint8Array`, "true"},
+		{`new TextEncoder().encode().length`, "0"},
+		// lone surrogates are replaced
+		{`Array.from(new TextEncoder().encode("\ud800")).join()`, "239,191,189"},
+		{`Array.from(new TextEncoder().encode(123)).join()`, "49,50,51"},
+		// characters that do not fit are not written partially
+		{`(() => { const u = new Uint8Array(4); const {read, written} = new TextEncoder().encodeInto("a€b", u); return [read, written, u.join()].join() })()`, "2,4,97,226,130,172"},
+		{`(() => { const u = new Uint8Array(2); const {read, written} = new TextEncoder().encodeInto("😀", u); return [read, written].join() })()`, "0,0"},
+		{`(() => { const u = new Uint8Array(8); return new TextEncoder().encodeInto("😀", u.subarray(2)).read + ":" + u.join() })()`, "2:0,0,240,159,152,128,0,0"},
+	} {
+		expectString(t, r, test.script, test.want)
+	}
+
+	for _, script := range []string{
+		`TextEncoder()`,
+		`new TextEncoder().encodeInto("a", [])`,
+		`TextEncoder.prototype.encode.call({}, "a")`,
+	} {
+		if _, err := r.Eval(script); err == nil || !strings.Contains(err.Error(), "TypeError") {
+			t.Errorf("%s: got error %v, want a TypeError", script, err)
+		}
+	}
+}
+
+func TestTextDecoder(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicTextEncoding)
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`new TextDecoder().encoding`, "utf-8"},
+		{`new TextDecoder("UTF8").encoding`, "utf-8"},
+		{`new TextDecoder(" latin1 ").encoding`, "windows-1252"},
+		{`new TextDecoder("utf-16").encoding`, "utf-16le"},
+		{`[new TextDecoder().fatal, new TextDecoder("utf-8", {fatal: true, ignoreBOM: true}).ignoreBOM].join()`, "false,true"},
+		{`new TextDecoder().decode(new Uint8Array([97, 195, 169, 240, 159, 152, 128]))`, "aé😀"},
+		{`new TextDecoder().decode(new Uint8Array([97, 98]).buffer)`, "ab"},
+		{`new TextDecoder().decode(new DataView(new Uint8Array([0, 97, 0]).buffer, 1, 1))`, "a"},
+		{`new TextDecoder().decode()`, ""},
+		// the bom is removed unless it is ignored
+		{`new TextDecoder().decode(new Uint8Array([0xef, 0xbb, 0xbf, 97])).length`, "1"},
+		{`new TextDecoder("utf-8", {ignoreBOM: true}).decode(new Uint8Array([0xef, 0xbb, 0xbf, 97])).length`, "2"},
+		// each maximal invalid subsequence is replaced by a single U+FFFD
+		{`new TextDecoder().decode(new Uint8Array([0xf0, 0x9f, 0x41, 0xff, 0x42]))`, "�A�B"},
+		{`new TextDecoder().decode(new Uint8Array([0xed, 0xa0, 0x80]))`, "���"},
+		// sequences that are split across chunks are decoded when streaming
+		{`(() => { const d = new TextDecoder(); return d.decode(new Uint8Array([226, 130]), {stream: true}) + d.decode(new Uint8Array([172])) })()`, "€"},
+		{`(() => { const d = new TextDecoder(); return d.decode(new Uint8Array([226, 130]), {stream: true}) + "|" + d.decode() })()`, "|�"},
+		{`new TextDecoder("utf-16le").decode(new Uint8Array([0x61, 0, 0x3d, 0xd8, 0, 0xde]))`, "a😀"},
+		{`new TextDecoder("utf-16be").decode(new Uint8Array([0xfe, 0xff, 0, 0x61]))`, "a"},
+		{`new TextDecoder("utf-16le").decode(new Uint8Array([0x61]))`, "�"},
+		{`new TextDecoder("latin1").decode(new Uint8Array([0x41, 0xe9, 0x80]))`, "Aé€"},
+	} {
+		expectString(t, r, test.script, test.want)
+	}
+
+	for _, test := range []struct {
+		script string
+		want   string
+	}{
+		{`new TextDecoder("utf-8", {fatal: true}).decode(new Uint8Array([0xff]))`, "TypeError"},
+		{`new TextDecoder("nope")`, "RangeError"},
+		{`new TextDecoder("shift_jis")`, "RangeError"},
+		{`new TextDecoder().decode("not a buffer")`, "TypeError"},
+		{`TextDecoder()`, "TypeError"},
+	} {
+		if _, err := r.Eval(test.script); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want a %s", test.script, err, test.want)
+		}
+	}
+
+	// a fatal error resets the decoder
+	expectString(t, r, `(() => { const d = new TextDecoder("utf-8", {fatal: true}); try { d.decode(new Uint8Array([226]), {stream: true}); d.decode() } catch {} return d.decode(new Uint8Array([97])) })()`, "a")
+}
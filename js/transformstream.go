@@ -0,0 +1,475 @@
+package js
+
+// transformer holds the algorithms of the transformer of a TransformStream,
+// which may be nil. Each returns a promise.
+type transformer struct {
+	transform func(c *transformStreamController, chunk *Value) *Value
+	flush     func(c *transformStreamController) *Value
+	cancel    func(reason *Value) *Value
+}
+
+// transformStream is the opaque value of TransformStream.
+type transformStream struct {
+	readableValue *Value
+	writableValue *Value
+	readable      *readableStream
+	writable      *writableStream
+
+	backpressure              bool
+	backpressureChangePromise *streamPromise
+	controller                *transformStreamController
+}
+
+func thisTransformStream(this *Value) (*transformStream, error) {
+	s, ok := this.Opaque().(*transformStream)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return s, nil
+}
+
+func (s *transformStream) setBackpressure(backpressure bool) {
+	if s.backpressureChangePromise != nil {
+		s.backpressureChangePromise.resolve(NewUndefined())
+	}
+
+	s.backpressureChangePromise = s.readable.realm.newStreamPromise()
+	s.backpressure = backpressure
+}
+
+func (s *transformStream) error(e *Value) {
+	s.readable.controller.error(e)
+	s.errorWritableAndUnblockWrite(e)
+}
+
+func (s *transformStream) errorWritableAndUnblockWrite(e *Value) {
+	s.controller.clearAlgorithms()
+	s.writable.controller.errorIfNeeded(e)
+	s.unblockWrite()
+}
+
+func (s *transformStream) unblockWrite() {
+	if s.backpressure {
+		s.setBackpressure(false)
+	}
+}
+
+// transformStreamController is the opaque value of
+// TransformStreamDefaultController.
+type transformStreamController struct {
+	stream        *transformStream
+	value         *Value
+	transformer   transformer
+	finishPromise *streamPromise
+}
+
+func thisTransformStreamController(this *Value) (*transformStreamController, error) {
+	c, ok := this.Opaque().(*transformStreamController)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return c, nil
+}
+
+func (c *transformStreamController) clearAlgorithms() {
+	c.transformer = transformer{}
+}
+
+func (c *transformStreamController) enqueue(chunk *Value) error {
+	s := c.stream
+	readableController := s.readable.controller
+
+	if !readableController.canCloseOrEnqueue() {
+		return NewTypeError("Readable side is not in a state that permits enqueue")
+	}
+
+	if err := readableController.enqueue(chunk); err != nil {
+		s.errorWritableAndUnblockWrite(s.readable.realm.errorValue(err))
+		return (*Error)(s.readable.storedError)
+	}
+
+	if backpressure := !readableController.shouldCallPull(); backpressure != s.backpressure {
+		s.setBackpressure(true)
+	}
+
+	return nil
+}
+
+func (c *transformStreamController) terminate() {
+	s := c.stream
+	s.readable.controller.close()
+	s.errorWritableAndUnblockWrite(s.readable.realm.errorValue(NewTypeError("TransformStream terminated")))
+}
+
+func (c *transformStreamController) performTransform(chunk *Value) *Value {
+	r := c.stream.readable.realm
+
+	var promise *Value
+	if c.transformer.transform != nil {
+		promise = c.transformer.transform(c, chunk)
+	} else if err := c.enqueue(chunk); err != nil {
+		promise = r.rejectedStreamPromise(r.errorValue(err)).value
+	} else {
+		promise = r.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	result := r.newStreamPromise()
+	uponPromise(promise, func(*Value) {
+		result.resolve(NewUndefined())
+	}, func(e *Value) {
+		c.stream.error(e)
+		result.reject(e)
+	})
+
+	return result.value
+}
+
+var transformStreamControllerSpec = ClassSpec{
+	Name: "TransformStreamDefaultController",
+	Methods: map[string]interface{}{
+		"enqueue": func(r *Realm, this *Value, args ...*Value) error {
+			c, err := thisTransformStreamController(this)
+			if err != nil {
+				return err
+			}
+
+			return c.enqueue(optionalArg(args, 0))
+		},
+		"error": func(r *Realm, this *Value, args ...*Value) error {
+			c, err := thisTransformStreamController(this)
+			if err != nil {
+				return err
+			}
+
+			c.stream.error(optionalArg(args, 0))
+
+			return nil
+		},
+		"terminate": func(r *Realm, this *Value) error {
+			c, err := thisTransformStreamController(this)
+			if err != nil {
+				return err
+			}
+
+			c.terminate()
+
+			return nil
+		},
+	},
+	Properties: map[string]ClassProperty{
+		"desiredSize": {
+			Get: func(r *Realm, this *Value) (interface{}, error) {
+				c, err := thisTransformStreamController(this)
+				if err != nil {
+					return nil, err
+				}
+
+				desiredSize, ok := c.stream.readable.controller.desiredSize()
+				if !ok {
+					return NewNull(), nil
+				}
+
+				return desiredSize, nil
+			},
+		},
+	},
+}
+
+// sinkWrite is the write algorithm of the writable side.
+func (s *transformStream) sinkWrite(chunk *Value) *Value {
+	if !s.backpressure {
+		return s.controller.performTransform(chunk)
+	}
+
+	r := s.readable.realm
+	result := r.newStreamPromise()
+
+	uponPromise(s.backpressureChangePromise.value, func(*Value) {
+		if s.writable.state == streamErroring {
+			result.reject(s.writable.storedError)
+			return
+		}
+
+		uponPromise(s.controller.performTransform(chunk), func(*Value) {
+			result.resolve(NewUndefined())
+		}, result.reject)
+	}, result.reject)
+
+	return result.value
+}
+
+// finish sets the finish promise of the controller, which settles once
+// promise settles. onFulfilled returns a reason to reject the finish promise
+// with or nil, and onRejected is called before it is rejected.
+func (s *transformStream) finish(promise *Value, onFulfilled func() *Value, onRejected func(e *Value)) *Value {
+	c := s.controller
+	c.finishPromise = s.readable.realm.newStreamPromise()
+	finish := c.finishPromise
+
+	c.clearAlgorithms()
+
+	uponPromise(promise, func(*Value) {
+		if e := onFulfilled(); e != nil {
+			finish.reject(e)
+			return
+		}
+
+		finish.resolve(NewUndefined())
+	}, func(e *Value) {
+		onRejected(e)
+		finish.reject(e)
+	})
+
+	return finish.value
+}
+
+// sinkAbort is the abort algorithm of the writable side.
+func (s *transformStream) sinkAbort(reason *Value) *Value {
+	c := s.controller
+	if c.finishPromise != nil {
+		return c.finishPromise.value
+	}
+
+	r := s.readable.realm
+
+	var promise *Value
+	if c.transformer.cancel != nil {
+		promise = c.transformer.cancel(reason)
+	} else {
+		promise = r.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	return s.finish(promise, func() *Value {
+		if s.readable.state == streamErrored {
+			return s.readable.storedError
+		}
+
+		s.readable.controller.error(reason)
+		return nil
+	}, s.readable.controller.error)
+}
+
+// sinkClose is the close algorithm of the writable side.
+func (s *transformStream) sinkClose() *Value {
+	c := s.controller
+	if c.finishPromise != nil {
+		return c.finishPromise.value
+	}
+
+	r := s.readable.realm
+
+	var promise *Value
+	if c.transformer.flush != nil {
+		promise = c.transformer.flush(c)
+	} else {
+		promise = r.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	return s.finish(promise, func() *Value {
+		if s.readable.state == streamErrored {
+			return s.readable.storedError
+		}
+
+		s.readable.controller.close()
+		return nil
+	}, s.readable.controller.error)
+}
+
+// sourceCancel is the cancel algorithm of the readable side.
+func (s *transformStream) sourceCancel(reason *Value) *Value {
+	c := s.controller
+	if c.finishPromise != nil {
+		return c.finishPromise.value
+	}
+
+	r := s.readable.realm
+
+	var promise *Value
+	if c.transformer.cancel != nil {
+		promise = c.transformer.cancel(reason)
+	} else {
+		promise = r.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	return s.finish(promise, func() *Value {
+		defer s.unblockWrite()
+
+		if s.writable.state == streamErrored {
+			return s.writable.storedError
+		}
+
+		s.writable.controller.errorIfNeeded(reason)
+		return nil
+	}, func(e *Value) {
+		s.writable.controller.errorIfNeeded(e)
+		s.unblockWrite()
+	})
+}
+
+// newTransformer converts the transformer argument of the TransformStream
+// constructor.
+func newTransformer(r *Realm, value *Value) (transformer, *Value, error) {
+	var t transformer
+
+	if value.Tag() != TagUndefined && value.Tag() != TagNull && !value.IsObject() {
+		return t, nil, NewTypeError("The provided value is not of type 'Transformer'")
+	}
+
+	cancel, err := sourceMethod(value, "cancel")
+	if err != nil {
+		return t, nil, err
+	}
+
+	flush, err := sourceMethod(value, "flush")
+	if err != nil {
+		return t, nil, err
+	}
+
+	if value.IsObject() {
+		for _, name := range []string{"readableType", "writableType"} {
+			typ, err := value.Get(name)
+			if err != nil {
+				return t, nil, err
+			}
+
+			if typ.Tag() != TagUndefined {
+				return t, nil, NewRangeError("Invalid %s is specified", name)
+			}
+		}
+	}
+
+	start, err := sourceMethod(value, "start")
+	if err != nil {
+		return t, nil, err
+	}
+
+	transform, err := sourceMethod(value, "transform")
+	if err != nil {
+		return t, nil, err
+	}
+
+	if transform != nil {
+		t.transform = func(c *transformStreamController, chunk *Value) *Value {
+			return r.promiseCall(transform, value, chunk, c.value)
+		}
+	}
+
+	if flush != nil {
+		t.flush = func(c *transformStreamController) *Value {
+			return r.promiseCall(flush, value, c.value)
+		}
+	}
+
+	if cancel != nil {
+		t.cancel = func(reason *Value) *Value {
+			return r.promiseCall(cancel, value, reason)
+		}
+	}
+
+	return t, start, nil
+}
+
+func (api *streamsAPI) transformStreamSpec() ClassSpec {
+	return ClassSpec{
+		Name: "TransformStream",
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			transformerValue := optionalArg(args, 0)
+
+			t, start, err := newTransformer(r, transformerValue)
+			if err != nil {
+				return nil, err
+			}
+
+			writableStrategy, err := extractQueuingStrategy(r, optionalArg(args, 1), 1)
+			if err != nil {
+				return nil, err
+			}
+
+			readableStrategy, err := extractQueuingStrategy(r, optionalArg(args, 2), 0)
+			if err != nil {
+				return nil, err
+			}
+
+			startPromise := r.newStreamPromise()
+
+			s := &transformStream{}
+
+			if s.writableValue, s.writable, err = api.newWritableStream(r, writableSink{
+				start: func(*writableStreamController) (*Value, error) {
+					return startPromise.value, nil
+				},
+				write: func(_ *writableStreamController, chunk *Value) *Value {
+					return s.sinkWrite(chunk)
+				},
+				close: s.sinkClose,
+				abort: s.sinkAbort,
+			}, writableStrategy); err != nil {
+				return nil, err
+			}
+
+			if s.readableValue, s.readable, err = api.newReadableStream(r, readableSource{
+				start: func(*readableStreamController) (*Value, error) {
+					return startPromise.value, nil
+				},
+				pull: func(*readableStreamController) *Value {
+					s.setBackpressure(false)
+					return s.backpressureChangePromise.value
+				},
+				cancel: s.sourceCancel,
+			}, readableStrategy); err != nil {
+				return nil, err
+			}
+
+			s.setBackpressure(true)
+
+			c := &transformStreamController{
+				stream:      s,
+				transformer: t,
+			}
+
+			if c.value, err = api.transformController.NewInstance(r, c); err != nil {
+				return nil, err
+			}
+
+			s.controller = c
+
+			if start == nil {
+				startPromise.resolve(NewUndefined())
+				return s, nil
+			}
+
+			result, err := start.Call(transformerValue, c.value)
+			if err != nil {
+				return nil, err
+			}
+
+			startPromise.resolve(result)
+
+			return s, nil
+		},
+		Properties: map[string]ClassProperty{
+			"readable": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					s, err := thisTransformStream(this)
+					if err != nil {
+						return nil, err
+					}
+
+					return s.readableValue, nil
+				},
+			},
+			"writable": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					s, err := thisTransformStream(this)
+					if err != nil {
+						return nil, err
+					}
+
+					return s.writableValue, nil
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,862 @@
+package js
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// urlObject is the opaque value of URL instances.
+type urlObject struct {
+	record *urlRecord
+
+	// searchParams is created when it is first accessed and is updated when
+	// the query changes
+	searchParams      *urlSearchParams
+	searchParamsValue *Value
+}
+
+func thisURL(this *Value) (*urlObject, error) {
+	u, ok := this.Opaque().(*urlObject)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return u, nil
+}
+
+// parseURLArgs parses the url and optional base arguments of the URL
+// constructor. invalid is set instead of err if the arguments are strings
+// that are not valid urls.
+func parseURLArgs(r *Realm, input, base *Value) (record *urlRecord, invalid error, err error) {
+	s, err := r.toUSVString(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var baseRecord *urlRecord
+	if base.Tag() != TagUndefined {
+		baseString, err := r.toUSVString(base)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var ok bool
+		if baseRecord, ok = parseURL(baseString, nil); !ok {
+			return nil, NewTypeError("Invalid base URL: %s", baseString), nil
+		}
+	}
+
+	record, ok := parseURL(s, baseRecord)
+	if !ok {
+		return nil, NewTypeError("Invalid URL: %s", s), nil
+	}
+
+	return record, nil, nil
+}
+
+func optionalArg(args []*Value, i int) *Value {
+	if i < len(args) {
+		return args[i]
+	}
+
+	return NewUndefined()
+}
+
+// updateSearchParams replaces the list of searchParams after the query of u
+// has changed.
+func (u *urlObject) updateSearchParams() {
+	if u.searchParams == nil {
+		return
+	}
+
+	u.searchParams.list = nil
+	if u.record.query != nil {
+		u.searchParams.list = parseURLEncoded(*u.record.query)
+	}
+}
+
+// urlProperty returns an accessor of a URL component. A nil set makes the
+// component read-only.
+func urlProperty(get func(u *urlRecord) string, set func(u *urlObject, value string)) ClassProperty {
+	property := ClassProperty{
+		Get: func(r *Realm, this *Value) (string, error) {
+			u, err := thisURL(this)
+			if err != nil {
+				return "", err
+			}
+
+			return get(u.record), nil
+		},
+	}
+
+	if set != nil {
+		property.Set = func(r *Realm, this *Value, value *Value) error {
+			u, err := thisURL(this)
+			if err != nil {
+				return err
+			}
+
+			s, err := r.toUSVString(value)
+			if err != nil {
+				return err
+			}
+
+			set(u, s)
+
+			return nil
+		}
+	}
+
+	return property
+}
+
+func newURLSpec(searchParamsClass *Class) ClassSpec {
+	href := func(r *Realm, this *Value) (string, error) {
+		u, err := thisURL(this)
+		if err != nil {
+			return "", err
+		}
+
+		return u.record.String(), nil
+	}
+
+	return ClassSpec{
+		Name: "URL",
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			if len(args) == 0 {
+				return nil, NewTypeError("The \"url\" argument must be specified")
+			}
+
+			record, invalid, err := parseURLArgs(r, args[0], optionalArg(args, 1))
+			if err != nil {
+				return nil, err
+			}
+
+			if invalid != nil {
+				return nil, invalid
+			}
+
+			return &urlObject{record: record}, nil
+		},
+		Length: 1,
+		Methods: map[string]interface{}{
+			"toString": href,
+			"toJSON":   href,
+		},
+		StaticMethods: map[string]interface{}{
+			"canParse": func(r *Realm, _ *Value, args ...*Value) (bool, error) {
+				if len(args) == 0 {
+					return false, NewTypeError("The \"url\" argument must be specified")
+				}
+
+				_, invalid, err := parseURLArgs(r, args[0], optionalArg(args, 1))
+				if err != nil {
+					return false, err
+				}
+
+				return invalid == nil, nil
+			},
+		},
+		Properties: map[string]ClassProperty{
+			"href": {
+				Get: href,
+				Set: func(r *Realm, this *Value, value *Value) error {
+					u, err := thisURL(this)
+					if err != nil {
+						return err
+					}
+
+					s, err := r.toUSVString(value)
+					if err != nil {
+						return err
+					}
+
+					record, ok := parseURL(s, nil)
+					if !ok {
+						return NewTypeError("Invalid URL: %s", s)
+					}
+
+					u.record = record
+					u.updateSearchParams()
+
+					return nil
+				},
+			},
+			"origin": urlProperty((*urlRecord).origin, nil),
+			"protocol": urlProperty(func(u *urlRecord) string {
+				return u.scheme + ":"
+			}, func(u *urlObject, value string) {
+				u.record.setComponent(value+":", schemeStartState)
+			}),
+			"username": urlProperty(func(u *urlRecord) string {
+				return u.username
+			}, func(u *urlObject, value string) {
+				if !u.record.cannotHaveUsernamePasswordPort() {
+					u.record.username = percentEncodeString(value, isUserinfoPercentEncoded)
+				}
+			}),
+			"password": urlProperty(func(u *urlRecord) string {
+				return u.password
+			}, func(u *urlObject, value string) {
+				if !u.record.cannotHaveUsernamePasswordPort() {
+					u.record.password = percentEncodeString(value, isUserinfoPercentEncoded)
+				}
+			}),
+			"host": urlProperty(func(u *urlRecord) string {
+				if u.host == nil {
+					return ""
+				}
+
+				if u.port < 0 {
+					return *u.host
+				}
+
+				return *u.host + ":" + strconv.Itoa(u.port)
+			}, func(u *urlObject, value string) {
+				if !u.record.hasOpaquePath {
+					u.record.setComponent(value, hostState)
+				}
+			}),
+			"hostname": urlProperty(func(u *urlRecord) string {
+				if u.host == nil {
+					return ""
+				}
+
+				return *u.host
+			}, func(u *urlObject, value string) {
+				if !u.record.hasOpaquePath {
+					u.record.setComponent(value, hostnameState)
+				}
+			}),
+			"port": urlProperty(func(u *urlRecord) string {
+				if u.port < 0 {
+					return ""
+				}
+
+				return strconv.Itoa(u.port)
+			}, func(u *urlObject, value string) {
+				if u.record.cannotHaveUsernamePasswordPort() {
+					return
+				}
+
+				if value == "" {
+					u.record.port = -1
+				} else {
+					u.record.setComponent(value, portState)
+				}
+			}),
+			"pathname": urlProperty((*urlRecord).pathString, func(u *urlObject, value string) {
+				if u.record.hasOpaquePath {
+					return
+				}
+
+				c := u.record.clone()
+				c.path = nil
+				if c.parse(value, nil, pathStartState) {
+					u.record = c
+				}
+			}),
+			"search": urlProperty(func(u *urlRecord) string {
+				if u.query == nil || *u.query == "" {
+					return ""
+				}
+
+				return "?" + *u.query
+			}, func(u *urlObject, value string) {
+				if value == "" {
+					u.record.query = nil
+				} else {
+					c := u.record.clone()
+					c.query = new(string)
+					if c.parse(strings.TrimPrefix(value, "?"), nil, queryState) {
+						u.record = c
+					}
+				}
+
+				u.updateSearchParams()
+			}),
+			"hash": urlProperty(func(u *urlRecord) string {
+				if u.fragment == nil || *u.fragment == "" {
+					return ""
+				}
+
+				return "#" + *u.fragment
+			}, func(u *urlObject, value string) {
+				if value == "" {
+					u.record.fragment = nil
+					return
+				}
+
+				c := u.record.clone()
+				c.fragment = new(string)
+				if c.parse(strings.TrimPrefix(value, "#"), nil, fragmentState) {
+					u.record = c
+				}
+			}),
+			"searchParams": {
+				Get: func(r *Realm, this *Value) (*Value, error) {
+					u, err := thisURL(this)
+					if err != nil {
+						return nil, err
+					}
+
+					if u.searchParamsValue == nil {
+						params := &urlSearchParams{url: u}
+						if u.record.query != nil {
+							params.list = parseURLEncoded(*u.record.query)
+						}
+
+						value, err := searchParamsClass.NewInstance(r, params)
+						if err != nil {
+							return nil, err
+						}
+
+						u.searchParams = params
+						u.searchParamsValue = value
+					}
+
+					return u.searchParamsValue, nil
+				},
+			},
+		},
+	}
+}
+
+type urlSearchParam struct {
+	name  string
+	value string
+}
+
+// urlSearchParams is the opaque value of URLSearchParams instances.
+type urlSearchParams struct {
+	list []urlSearchParam
+
+	// url is the url whose query is updated when the list changes
+	url *urlObject
+}
+
+func thisURLSearchParams(this *Value) (*urlSearchParams, error) {
+	p, ok := this.Opaque().(*urlSearchParams)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return p, nil
+}
+
+func (p *urlSearchParams) update() {
+	if p.url == nil {
+		return
+	}
+
+	if query := p.String(); query != "" {
+		p.url.record.query = &query
+	} else {
+		p.url.record.query = nil
+	}
+}
+
+// String serializes the list as application/x-www-form-urlencoded.
+func (p *urlSearchParams) String() string {
+	var b strings.Builder
+	for i, param := range p.list {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+
+		b.WriteString(formEncode(param.name))
+		b.WriteByte('=')
+		b.WriteString(formEncode(param.value))
+	}
+
+	return b.String()
+}
+
+func formEncode(s string) string {
+	return strings.ReplaceAll(percentEncodeString(s, func(c byte) bool {
+		return c != ' ' && isFormPercentEncoded(c)
+	}), " ", "+")
+}
+
+// parseURLEncoded parses application/x-www-form-urlencoded data.
+func parseURLEncoded(s string) []urlSearchParam {
+	var list []urlSearchParam
+	for _, sequence := range strings.Split(s, "&") {
+		if sequence == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(sequence, "=")
+		list = append(list, urlSearchParam{
+			name:  formDecode(name),
+			value: formDecode(value),
+		})
+	}
+
+	return list
+}
+
+func formDecode(s string) string {
+	var b strings.Builder
+	(&utf8Decoding{}).decode(&b, percentDecode(strings.ReplaceAll(s, "+", " ")), true, false)
+	return b.String()
+}
+
+func newURLSearchParamsInstance(r *Realm, args []*Value) (interface{}, error) {
+	p := &urlSearchParams{}
+	if len(args) == 0 || args[0].Tag() == TagUndefined || args[0].Tag() == TagNull {
+		return p, nil
+	}
+
+	init := args[0]
+	if !init.IsObject() {
+		s, err := r.toUSVString(init)
+		if err != nil {
+			return nil, err
+		}
+
+		p.list = parseURLEncoded(strings.TrimPrefix(s, "?"))
+		return p, nil
+	}
+
+	if other, ok := init.Opaque().(*urlSearchParams); ok {
+		p.list = append([]urlSearchParam(nil), other.list...)
+		return p, nil
+	}
+
+	pairs, err := r.namesAndValues(init)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pair := range pairs {
+		p.list = append(p.list, urlSearchParam{name: pair[0], value: pair[1]})
+	}
+
+	return p, nil
+}
+
+// namesAndValues converts init, which is either an iterable of [name, value]
+// pairs or a record of names to values, into a list of pairs, as with the
+// constructors of URLSearchParams and Headers.
+func (r *Realm) namesAndValues(init *Value) ([][2]string, error) {
+	iterable, err := init.isIterable()
+	if err != nil {
+		return nil, err
+	}
+
+	if !iterable {
+		object, err := r.globalProperty("Object")
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := object.Invoke("entries", init)
+		if err != nil {
+			return nil, err
+		}
+
+		init = entries
+	}
+
+	var pairs [][2]string
+	if err := init.Iterate(func(pair *Value) (bool, error) {
+		var values []string
+		if pair.IsObject() {
+			if err := pair.Iterate(func(v *Value) (bool, error) {
+				s, err := r.toUSVString(v)
+				if err != nil {
+					return false, err
+				}
+
+				values = append(values, s)
+				return true, nil
+			}); err != nil {
+				return false, err
+			}
+		}
+
+		if len(values) != 2 {
+			return false, NewTypeError("Each pair must be an iterable [name, value] tuple")
+		}
+
+		pairs = append(pairs, [2]string{values[0], values[1]})
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return pairs, nil
+}
+
+// compareUTF16 compares strings by their utf-16 code units, as js does.
+func compareUTF16(a, b string) int {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return int(ua[i]) - int(ub[i])
+		}
+	}
+
+	return len(ua) - len(ub)
+}
+
+// searchParamsIteratorMethod returns a method of URLSearchParams that returns
+// an iterator of values created by f.
+func searchParamsIteratorMethod(f func(param urlSearchParam) interface{}) func(r *Realm, this *Value) (*Value, error) {
+	return func(r *Realm, this *Value) (*Value, error) {
+		p, err := thisURLSearchParams(this)
+		if err != nil {
+			return nil, err
+		}
+
+		return r.newListIterator(func() int {
+			return len(p.list)
+		}, func(i int) interface{} {
+			return f(p.list[i])
+		})
+	}
+}
+
+// searchParamsNameArgs converts the name and optional value arguments of
+// delete and has. value is nil if it is not given.
+func searchParamsNameArgs(r *Realm, args []*Value) (string, *string, error) {
+	if len(args) == 0 {
+		return "", nil, NewTypeError("The \"name\" argument must be specified")
+	}
+
+	name, err := r.toUSVString(args[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(args) < 2 || args[1].Tag() == TagUndefined {
+		return name, nil, nil
+	}
+
+	value, err := r.toUSVString(args[1])
+	if err != nil {
+		return "", nil, err
+	}
+
+	return name, &value, nil
+}
+
+var urlSearchParamsSpec = ClassSpec{
+	Name:        "URLSearchParams",
+	Constructor: newURLSearchParamsInstance,
+	Methods: map[string]interface{}{
+		"append": func(r *Realm, this *Value, name, value *Value) error {
+			p, err := thisURLSearchParams(this)
+			if err != nil {
+				return err
+			}
+
+			param, err := searchParamArgs(r, name, value)
+			if err != nil {
+				return err
+			}
+
+			p.list = append(p.list, param)
+			p.update()
+
+			return nil
+		},
+		"delete": func(r *Realm, this *Value, args ...*Value) error {
+			p, err := thisURLSearchParams(this)
+			if err != nil {
+				return err
+			}
+
+			name, value, err := searchParamsNameArgs(r, args)
+			if err != nil {
+				return err
+			}
+
+			list := p.list[:0]
+			for _, param := range p.list {
+				if param.name != name || (value != nil && param.value != *value) {
+					list = append(list, param)
+				}
+			}
+
+			p.list = list
+			p.update()
+
+			return nil
+		},
+		"get": func(r *Realm, this *Value, name *Value) (*Value, error) {
+			p, err := thisURLSearchParams(this)
+			if err != nil {
+				return nil, err
+			}
+
+			s, err := r.toUSVString(name)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, param := range p.list {
+				if param.name == s {
+					return r.NewString(param.value)
+				}
+			}
+
+			return NewNull(), nil
+		},
+		"getAll": func(r *Realm, this *Value, name *Value) ([]string, error) {
+			p, err := thisURLSearchParams(this)
+			if err != nil {
+				return nil, err
+			}
+
+			s, err := r.toUSVString(name)
+			if err != nil {
+				return nil, err
+			}
+
+			values := []string{}
+			for _, param := range p.list {
+				if param.name == s {
+					values = append(values, param.value)
+				}
+			}
+
+			return values, nil
+		},
+		"has": func(r *Realm, this *Value, args ...*Value) (bool, error) {
+			p, err := thisURLSearchParams(this)
+			if err != nil {
+				return false, err
+			}
+
+			name, value, err := searchParamsNameArgs(r, args)
+			if err != nil {
+				return false, err
+			}
+
+			for _, param := range p.list {
+				if param.name == name && (value == nil || param.value == *value) {
+					return true, nil
+				}
+			}
+
+			return false, nil
+		},
+		"set": func(r *Realm, this *Value, name, value *Value) error {
+			p, err := thisURLSearchParams(this)
+			if err != nil {
+				return err
+			}
+
+			param, err := searchParamArgs(r, name, value)
+			if err != nil {
+				return err
+			}
+
+			// replace the first param with the name and remove the others
+			found := false
+			list := p.list[:0]
+			for _, existing := range p.list {
+				if existing.name != param.name {
+					list = append(list, existing)
+				} else if !found {
+					list = append(list, param)
+					found = true
+				}
+			}
+
+			if !found {
+				list = append(list, param)
+			}
+
+			p.list = list
+			p.update()
+
+			return nil
+		},
+		"sort": func(r *Realm, this *Value) error {
+			p, err := thisURLSearchParams(this)
+			if err != nil {
+				return err
+			}
+
+			sort.SliceStable(p.list, func(i, j int) bool {
+				return compareUTF16(p.list[i].name, p.list[j].name) < 0
+			})
+
+			p.update()
+
+			return nil
+		},
+		"toString": func(r *Realm, this *Value) (string, error) {
+			p, err := thisURLSearchParams(this)
+			if err != nil {
+				return "", err
+			}
+
+			return p.String(), nil
+		},
+		"forEach": func(r *Realm, this *Value, callback *Value, args ...*Value) error {
+			p, err := thisURLSearchParams(this)
+			if err != nil {
+				return err
+			}
+
+			if !callback.IsFunction() {
+				return NewTypeError("The \"callback\" argument must be of type function")
+			}
+
+			thisArg := optionalArg(args, 0)
+			for i := 0; i < len(p.list); i++ {
+				param := p.list[i]
+				if _, err := callback.Call(thisArg, param.value, param.name, this); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		"entries": searchParamsIteratorMethod(func(param urlSearchParam) interface{} {
+			return []string{param.name, param.value}
+		}),
+		"keys": searchParamsIteratorMethod(func(param urlSearchParam) interface{} {
+			return param.name
+		}),
+		"values": searchParamsIteratorMethod(func(param urlSearchParam) interface{} {
+			return param.value
+		}),
+	},
+	Properties: map[string]ClassProperty{
+		"size": {
+			Get: func(r *Realm, this *Value) (int, error) {
+				p, err := thisURLSearchParams(this)
+				if err != nil {
+					return 0, err
+				}
+
+				return len(p.list), nil
+			},
+		},
+	},
+}
+
+func searchParamArgs(r *Realm, name, value *Value) (urlSearchParam, error) {
+	n, err := r.toUSVString(name)
+	if err != nil {
+		return urlSearchParam{}, err
+	}
+
+	v, err := r.toUSVString(value)
+	if err != nil {
+		return urlSearchParam{}, err
+	}
+
+	return urlSearchParam{name: n, value: v}, nil
+}
+
+// defineURLClasses defines URL and URLSearchParams as globals of r.
+func (r *Realm) defineURLClasses() error {
+	searchParams, err := r.defineGlobalClass(urlSearchParamsSpec)
+	if err != nil {
+		return err
+	}
+
+	searchParamsCtor, err := searchParams.Constructor(r)
+	if err != nil {
+		return err
+	}
+
+	searchParamsProto, err := searchParamsCtor.Get("prototype")
+	if err != nil {
+		return err
+	}
+
+	entries, err := searchParamsProto.Get("entries")
+	if err != nil {
+		return err
+	}
+
+	iterator, err := r.wellKnownSymbol("iterator")
+	if err != nil {
+		return err
+	}
+
+	if _, err := searchParamsProto.DefinePropertyAtom(iterator, DefinePropertyValue(entries), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return err
+	}
+
+	url, err := r.defineGlobalClass(newURLSpec(searchParams))
+	if err != nil {
+		return err
+	}
+
+	// parse is defined separately since it creates instances of the class
+	// itself
+	parse, err := r.NewFunction(func(r *Realm, _ *Value, args ...*Value) (*Value, error) {
+		if len(args) == 0 {
+			return nil, NewTypeError("The \"url\" argument must be specified")
+		}
+
+		record, invalid, err := parseURLArgs(r, args[0], optionalArg(args, 1))
+		if err != nil {
+			return nil, err
+		}
+
+		if invalid != nil {
+			return NewNull(), nil
+		}
+
+		return url.NewInstance(r, &urlObject{record: record})
+	})
+	if err != nil {
+		return err
+	}
+
+	urlCtor, err := url.Constructor(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := urlCtor.DefineProperty("parse", DefinePropertyValue(parse), DefinePropertyWritable(true), DefinePropertyConfigurable(true)); err != nil {
+		return err
+	}
+
+	return r.defineClassToStringTags(searchParams, url)
+}
+
+// defineToStringTag sets the Symbol.toStringTag of obj, which is used by
+// Object.prototype.toString.
+func (r *Realm) defineToStringTag(obj *Value, tag string) error {
+	toStringTag, err := r.wellKnownSymbol("toStringTag")
+	if err != nil {
+		return err
+	}
+
+	_, err = obj.DefinePropertyAtom(toStringTag, DefinePropertyValue(tag), DefinePropertyConfigurable(true))
+	return err
+}
+
+// defineClassToStringTags sets the Symbol.toStringTag of the prototypes of
+// classes to their names.
+func (r *Realm) defineClassToStringTags(classes ...*Class) error {
+	for _, c := range classes {
+		ctor, err := c.Constructor(r)
+		if err != nil {
+			return err
+		}
+
+		proto, err := ctor.Get("prototype")
+		if err != nil {
+			return err
+		}
+
+		if err := r.defineToStringTag(proto, c.Name()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
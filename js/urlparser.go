@@ -0,0 +1,1081 @@
+package js
+
+import (
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// urlRecord is a url as defined by the WHATWG URL standard. Components are
+// stored percent-encoded, as they are serialized.
+//
+// net/url is not used to parse urls, since it implements RFC 3986, whose
+// results differ from those of browsers. For example, it does not treat
+// backslashes as slashes in http urls, normalize hosts such as 0x7f.1,
+// remove dot segments or percent-encode the same characters, and it has no
+// equivalent of the state overrides that the setters of URL rely on.
+type urlRecord struct {
+	scheme   string
+	username string
+	password string
+
+	// host is nil if the url has no host, which is distinct from an empty
+	// host such as that of file:///path
+	host *string
+
+	// port is -1 if the url has no port or the port is the default port of
+	// the scheme
+	port int
+
+	// path is used unless hasOpaquePath is set, in which case opaquePath is
+	// the whole path, as with mailto: or data: urls
+	path          []string
+	opaquePath    string
+	hasOpaquePath bool
+
+	query    *string
+	fragment *string
+}
+
+func (u *urlRecord) clone() *urlRecord {
+	c := *u
+	c.path = append([]string(nil), u.path...)
+	return &c
+}
+
+var specialSchemes = map[string]int{
+	"ftp":   21,
+	"file":  -1,
+	"http":  80,
+	"https": 443,
+	"ws":    80,
+	"wss":   443,
+}
+
+func isSpecialScheme(scheme string) bool {
+	_, ok := specialSchemes[scheme]
+	return ok
+}
+
+func defaultPort(scheme string) int {
+	if port, ok := specialSchemes[scheme]; ok {
+		return port
+	}
+
+	return -1
+}
+
+func (u *urlRecord) isSpecial() bool {
+	return isSpecialScheme(u.scheme)
+}
+
+func (u *urlRecord) includesCredentials() bool {
+	return u.username != "" || u.password != ""
+}
+
+func (u *urlRecord) cannotHaveUsernamePasswordPort() bool {
+	return u.host == nil || *u.host == "" || u.scheme == "file"
+}
+
+func (u *urlRecord) shortenPath() {
+	if u.scheme == "file" && len(u.path) == 1 && isNormalizedWindowsDriveLetter(u.path[0]) {
+		return
+	}
+
+	if len(u.path) > 0 {
+		u.path = u.path[:len(u.path)-1]
+	}
+}
+
+// pathString returns the serialized path of u.
+func (u *urlRecord) pathString() string {
+	if u.hasOpaquePath {
+		return u.opaquePath
+	}
+
+	var b strings.Builder
+	for _, segment := range u.path {
+		b.WriteByte('/')
+		b.WriteString(segment)
+	}
+
+	return b.String()
+}
+
+// String serializes u.
+func (u *urlRecord) String() string {
+	var b strings.Builder
+	b.WriteString(u.scheme)
+	b.WriteByte(':')
+
+	if u.host != nil {
+		b.WriteString("//")
+		if u.includesCredentials() {
+			b.WriteString(u.username)
+			if u.password != "" {
+				b.WriteByte(':')
+				b.WriteString(u.password)
+			}
+
+			b.WriteByte('@')
+		}
+
+		b.WriteString(*u.host)
+		if u.port >= 0 {
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(u.port))
+		}
+	} else if !u.hasOpaquePath && len(u.path) > 1 && u.path[0] == "" {
+		// prevent the path from being parsed as a host
+		b.WriteString("/.")
+	}
+
+	b.WriteString(u.pathString())
+
+	if u.query != nil {
+		b.WriteByte('?')
+		b.WriteString(*u.query)
+	}
+
+	if u.fragment != nil {
+		b.WriteByte('#')
+		b.WriteString(*u.fragment)
+	}
+
+	return b.String()
+}
+
+// origin returns the serialized origin of u.
+func (u *urlRecord) origin() string {
+	switch u.scheme {
+	case "blob":
+		if u.hasOpaquePath || len(u.path) > 0 {
+			inner, ok := parseURL(u.pathString(), nil)
+			if ok && (inner.scheme == "http" || inner.scheme == "https") {
+				return inner.origin()
+			}
+		}
+
+		return "null"
+
+	case "ftp", "http", "https", "ws", "wss":
+		origin := u.scheme + "://" + *u.host
+		if u.port >= 0 {
+			origin += ":" + strconv.Itoa(u.port)
+		}
+
+		return origin
+	}
+
+	return "null"
+}
+
+type urlParserState int
+
+const (
+	noStateOverride urlParserState = iota
+	schemeStartState
+	schemeState
+	noSchemeState
+	specialRelativeOrAuthorityState
+	pathOrAuthorityState
+	relativeState
+	relativeSlashState
+	specialAuthoritySlashesState
+	specialAuthorityIgnoreSlashesState
+	authorityState
+	hostState
+	hostnameState
+	portState
+	fileState
+	fileSlashState
+	fileHostState
+	pathStartState
+	pathState
+	opaquePathState
+	queryState
+	fragmentState
+)
+
+// parseURL parses input relative to base, which may be nil.
+func parseURL(input string, base *urlRecord) (*urlRecord, bool) {
+	input = strings.TrimFunc(input, func(c rune) bool {
+		return c <= ' '
+	})
+
+	u := &urlRecord{port: -1}
+	if !u.parse(input, base, noStateOverride) {
+		return nil, false
+	}
+
+	return u, true
+}
+
+// setComponent parses input into u starting from the given state, as the
+// setters of URL do. u is not modified if parsing fails.
+func (u *urlRecord) setComponent(input string, state urlParserState) {
+	c := u.clone()
+	if c.parse(input, nil, state) {
+		*u = *c
+	}
+}
+
+// parse implements the basic url parser of the WHATWG URL standard. It
+// returns false if input is not a valid url.
+func (u *urlRecord) parse(s string, base *urlRecord, override urlParserState) bool {
+	s = strings.Map(func(c rune) rune {
+		if c == '\t' || c == '\n' || c == '\r' {
+			return -1
+		}
+
+		return c
+	}, s)
+
+	input := []rune(s)
+
+	state := override
+	if state == noStateOverride {
+		state = schemeStartState
+	}
+
+	var (
+		buffer            strings.Builder
+		atSignSeen        bool
+		insideBrackets    bool
+		passwordTokenSeen bool
+	)
+
+	remainingStartsWith := func(p int, prefix string) bool {
+		return strings.HasPrefix(string(input[min(p+1, len(input)):]), prefix)
+	}
+
+	for p := 0; p <= len(input); p++ {
+		c := rune(-1)
+		if p < len(input) {
+			c = input[p]
+		}
+
+		switch state {
+		case schemeStartState:
+			if isASCIIAlpha(c) {
+				buffer.WriteRune(toASCIILower(c))
+				state = schemeState
+			} else if override == noStateOverride {
+				state = noSchemeState
+				p--
+			} else {
+				return false
+			}
+
+		case schemeState:
+			if isASCIIAlphanumeric(c) || c == '+' || c == '-' || c == '.' {
+				buffer.WriteRune(toASCIILower(c))
+			} else if c == ':' {
+				scheme := buffer.String()
+				if override != noStateOverride {
+					if u.isSpecial() != isSpecialScheme(scheme) {
+						return true
+					}
+
+					if (u.includesCredentials() || u.port >= 0) && scheme == "file" {
+						return true
+					}
+
+					if u.scheme == "file" && u.host != nil && *u.host == "" {
+						return true
+					}
+				}
+
+				u.scheme = scheme
+
+				if override != noStateOverride {
+					if u.port == defaultPort(u.scheme) {
+						u.port = -1
+					}
+
+					return true
+				}
+
+				buffer.Reset()
+
+				if u.scheme == "file" {
+					state = fileState
+				} else if u.isSpecial() && base != nil && base.scheme == u.scheme {
+					state = specialRelativeOrAuthorityState
+				} else if u.isSpecial() {
+					state = specialAuthoritySlashesState
+				} else if remainingStartsWith(p, "/") {
+					state = pathOrAuthorityState
+					p++
+				} else {
+					u.hasOpaquePath = true
+					state = opaquePathState
+				}
+			} else if override == noStateOverride {
+				buffer.Reset()
+				state = noSchemeState
+				p = -1
+			} else {
+				return false
+			}
+
+		case noSchemeState:
+			if base == nil || (base.hasOpaquePath && c != '#') {
+				return false
+			} else if base.hasOpaquePath && c == '#' {
+				u.scheme = base.scheme
+				u.opaquePath = base.opaquePath
+				u.hasOpaquePath = true
+				u.query = base.query
+				u.fragment = new(string)
+				state = fragmentState
+			} else if base.scheme != "file" {
+				state = relativeState
+				p--
+			} else {
+				state = fileState
+				p--
+			}
+
+		case specialRelativeOrAuthorityState:
+			if c == '/' && remainingStartsWith(p, "/") {
+				state = specialAuthorityIgnoreSlashesState
+				p++
+			} else {
+				state = relativeState
+				p--
+			}
+
+		case pathOrAuthorityState:
+			if c == '/' {
+				state = authorityState
+			} else {
+				state = pathState
+				p--
+			}
+
+		case relativeState:
+			u.scheme = base.scheme
+			if c == '/' || (u.isSpecial() && c == '\\') {
+				state = relativeSlashState
+			} else {
+				u.username = base.username
+				u.password = base.password
+				u.host = base.host
+				u.port = base.port
+				u.path = append([]string(nil), base.path...)
+				u.query = base.query
+
+				if c == '?' {
+					u.query = new(string)
+					state = queryState
+				} else if c == '#' {
+					u.fragment = new(string)
+					state = fragmentState
+				} else if c != -1 {
+					u.query = nil
+					u.shortenPath()
+					state = pathState
+					p--
+				}
+			}
+
+		case relativeSlashState:
+			if u.isSpecial() && (c == '/' || c == '\\') {
+				state = specialAuthorityIgnoreSlashesState
+			} else if c == '/' {
+				state = authorityState
+			} else {
+				u.username = base.username
+				u.password = base.password
+				u.host = base.host
+				u.port = base.port
+				state = pathState
+				p--
+			}
+
+		case specialAuthoritySlashesState:
+			state = specialAuthorityIgnoreSlashesState
+			if c == '/' && remainingStartsWith(p, "/") {
+				p++
+			} else {
+				p--
+			}
+
+		case specialAuthorityIgnoreSlashesState:
+			if c != '/' && c != '\\' {
+				state = authorityState
+				p--
+			}
+
+		case authorityState:
+			if c == '@' {
+				if atSignSeen {
+					s := buffer.String()
+					buffer.Reset()
+					buffer.WriteString("%40")
+					buffer.WriteString(s)
+				}
+
+				atSignSeen = true
+
+				for _, c := range buffer.String() {
+					if c == ':' && !passwordTokenSeen {
+						passwordTokenSeen = true
+						continue
+					}
+
+					encoded := percentEncodeRune(c, isUserinfoPercentEncoded)
+					if passwordTokenSeen {
+						u.password += encoded
+					} else {
+						u.username += encoded
+					}
+				}
+
+				buffer.Reset()
+			} else if c == -1 || c == '/' || c == '?' || c == '#' || (u.isSpecial() && c == '\\') {
+				if atSignSeen && buffer.Len() == 0 {
+					return false
+				}
+
+				p -= utf8.RuneCountInString(buffer.String()) + 1
+				buffer.Reset()
+				state = hostState
+			} else {
+				buffer.WriteRune(c)
+			}
+
+		case hostState, hostnameState:
+			if override != noStateOverride && u.scheme == "file" {
+				state = fileHostState
+				p--
+			} else if c == ':' && !insideBrackets {
+				if buffer.Len() == 0 {
+					return false
+				}
+
+				if override == hostnameState {
+					return false
+				}
+
+				host, ok := parseHost(buffer.String(), !u.isSpecial())
+				if !ok {
+					return false
+				}
+
+				u.host = &host
+				buffer.Reset()
+				state = portState
+			} else if c == -1 || c == '/' || c == '?' || c == '#' || (u.isSpecial() && c == '\\') {
+				p--
+
+				if u.isSpecial() && buffer.Len() == 0 {
+					return false
+				} else if override != noStateOverride && buffer.Len() == 0 && (u.includesCredentials() || u.port >= 0) {
+					return false
+				}
+
+				host, ok := parseHost(buffer.String(), !u.isSpecial())
+				if !ok {
+					return false
+				}
+
+				u.host = &host
+				buffer.Reset()
+				state = pathStartState
+
+				if override != noStateOverride {
+					return true
+				}
+			} else {
+				if c == '[' {
+					insideBrackets = true
+				} else if c == ']' {
+					insideBrackets = false
+				}
+
+				buffer.WriteRune(c)
+			}
+
+		case portState:
+			if isASCIIDigit(c) {
+				buffer.WriteRune(c)
+			} else if c == -1 || c == '/' || c == '?' || c == '#' || (u.isSpecial() && c == '\\') || override != noStateOverride {
+				if buffer.Len() > 0 {
+					port, err := strconv.ParseUint(buffer.String(), 10, 16)
+					if err != nil {
+						return false
+					}
+
+					u.port = int(port)
+					if u.port == defaultPort(u.scheme) {
+						u.port = -1
+					}
+
+					buffer.Reset()
+				}
+
+				if override != noStateOverride {
+					return true
+				}
+
+				state = pathStartState
+				p--
+			} else {
+				return false
+			}
+
+		case fileState:
+			u.scheme = "file"
+			u.host = new(string)
+
+			if c == '/' || c == '\\' {
+				state = fileSlashState
+			} else if base != nil && base.scheme == "file" {
+				u.host = base.host
+				u.path = append([]string(nil), base.path...)
+				u.query = base.query
+
+				if c == '?' {
+					u.query = new(string)
+					state = queryState
+				} else if c == '#' {
+					u.fragment = new(string)
+					state = fragmentState
+				} else if c != -1 {
+					u.query = nil
+					if !startsWithWindowsDriveLetter(input[p:]) {
+						u.shortenPath()
+					} else {
+						u.path = nil
+					}
+
+					state = pathState
+					p--
+				}
+			} else {
+				state = pathState
+				p--
+			}
+
+		case fileSlashState:
+			if c == '/' || c == '\\' {
+				state = fileHostState
+			} else {
+				if base != nil && base.scheme == "file" {
+					u.host = base.host
+					if !startsWithWindowsDriveLetter(input[p:]) && len(base.path) > 0 && isNormalizedWindowsDriveLetter(base.path[0]) {
+						u.path = append(u.path, base.path[0])
+					}
+				}
+
+				state = pathState
+				p--
+			}
+
+		case fileHostState:
+			if c == -1 || c == '/' || c == '\\' || c == '?' || c == '#' {
+				p--
+
+				if override == noStateOverride && isWindowsDriveLetter(buffer.String()) {
+					// the buffer is used as the first segment of the path
+					state = pathState
+				} else if buffer.Len() == 0 {
+					u.host = new(string)
+					if override != noStateOverride {
+						return true
+					}
+
+					state = pathStartState
+				} else {
+					host, ok := parseHost(buffer.String(), !u.isSpecial())
+					if !ok {
+						return false
+					}
+
+					if host == "localhost" {
+						host = ""
+					}
+
+					u.host = &host
+					if override != noStateOverride {
+						return true
+					}
+
+					buffer.Reset()
+					state = pathStartState
+				}
+			} else {
+				buffer.WriteRune(c)
+			}
+
+		case pathStartState:
+			if u.isSpecial() {
+				state = pathState
+				if c != '/' && c != '\\' {
+					p--
+				}
+			} else if override == noStateOverride && c == '?' {
+				u.query = new(string)
+				state = queryState
+			} else if override == noStateOverride && c == '#' {
+				u.fragment = new(string)
+				state = fragmentState
+			} else if c != -1 {
+				state = pathState
+				if c != '/' {
+					p--
+				}
+			} else if override != noStateOverride && u.host == nil {
+				u.path = append(u.path, "")
+			}
+
+		case pathState:
+			slash := c == '/' || (u.isSpecial() && c == '\\')
+			if c == -1 || slash || (override == noStateOverride && (c == '?' || c == '#')) {
+				segment := buffer.String()
+				if isDoubleDotSegment(segment) {
+					u.shortenPath()
+					if !slash {
+						u.path = append(u.path, "")
+					}
+				} else if isSingleDotSegment(segment) {
+					if !slash {
+						u.path = append(u.path, "")
+					}
+				} else {
+					if u.scheme == "file" && len(u.path) == 0 && isWindowsDriveLetter(segment) {
+						segment = segment[:1] + ":"
+					}
+
+					u.path = append(u.path, segment)
+				}
+
+				buffer.Reset()
+
+				if c == '?' {
+					u.query = new(string)
+					state = queryState
+				} else if c == '#' {
+					u.fragment = new(string)
+					state = fragmentState
+				}
+			} else {
+				buffer.WriteString(percentEncodeRune(c, isPathPercentEncoded))
+			}
+
+		case opaquePathState:
+			if c == '?' {
+				u.query = new(string)
+				state = queryState
+			} else if c == '#' {
+				u.fragment = new(string)
+				state = fragmentState
+			} else if c != -1 {
+				u.opaquePath += percentEncodeRune(c, isC0ControlPercentEncoded)
+			}
+
+		case queryState:
+			if (override == noStateOverride && c == '#') || c == -1 {
+				encode := isQueryPercentEncoded
+				if u.isSpecial() {
+					encode = isSpecialQueryPercentEncoded
+				}
+
+				*u.query += percentEncodeString(buffer.String(), encode)
+				buffer.Reset()
+
+				if c == '#' {
+					u.fragment = new(string)
+					state = fragmentState
+				}
+			} else {
+				buffer.WriteRune(c)
+			}
+
+		case fragmentState:
+			if c != -1 {
+				*u.fragment += percentEncodeRune(c, isFragmentPercentEncoded)
+			}
+		}
+	}
+
+	return true
+}
+
+func isASCIIAlpha(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isASCIIDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isASCIIAlphanumeric(c rune) bool {
+	return isASCIIAlpha(c) || isASCIIDigit(c)
+}
+
+func isASCIIHexDigit(c rune) bool {
+	return isASCIIDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func toASCIILower(c rune) rune {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+
+	return c
+}
+
+func isWindowsDriveLetter(s string) bool {
+	return len(s) == 2 && isASCIIAlpha(rune(s[0])) && (s[1] == ':' || s[1] == '|')
+}
+
+func isNormalizedWindowsDriveLetter(s string) bool {
+	return isWindowsDriveLetter(s) && s[1] == ':'
+}
+
+func startsWithWindowsDriveLetter(input []rune) bool {
+	if len(input) < 2 || !isASCIIAlpha(input[0]) || (input[1] != ':' && input[1] != '|') {
+		return false
+	}
+
+	if len(input) == 2 {
+		return true
+	}
+
+	switch input[2] {
+	case '/', '\\', '?', '#':
+		return true
+	}
+
+	return false
+}
+
+func isSingleDotSegment(s string) bool {
+	return s == "." || strings.EqualFold(s, "%2e")
+}
+
+func isDoubleDotSegment(s string) bool {
+	switch strings.ToLower(s) {
+	case "..", ".%2e", "%2e.", "%2e%2e":
+		return true
+	}
+
+	return false
+}
+
+// percent-encode sets of the WHATWG URL standard
+
+func isC0ControlPercentEncoded(c byte) bool {
+	return c < 0x20 || c > 0x7E
+}
+
+func isFragmentPercentEncoded(c byte) bool {
+	return isC0ControlPercentEncoded(c) || c == ' ' || c == '"' || c == '<' || c == '>' || c == '`'
+}
+
+func isQueryPercentEncoded(c byte) bool {
+	return isC0ControlPercentEncoded(c) || c == ' ' || c == '"' || c == '#' || c == '<' || c == '>'
+}
+
+func isSpecialQueryPercentEncoded(c byte) bool {
+	return isQueryPercentEncoded(c) || c == '\''
+}
+
+func isPathPercentEncoded(c byte) bool {
+	return isQueryPercentEncoded(c) || c == '?' || c == '^' || c == '`' || c == '{' || c == '}'
+}
+
+func isUserinfoPercentEncoded(c byte) bool {
+	return isPathPercentEncoded(c) || c == '/' || c == ':' || c == ';' || c == '=' || c == '@' || (c >= '[' && c <= ']') || c == '|'
+}
+
+func isComponentPercentEncoded(c byte) bool {
+	return isUserinfoPercentEncoded(c) || (c >= '$' && c <= '&') || c == '+' || c == ','
+}
+
+func isFormPercentEncoded(c byte) bool {
+	return isComponentPercentEncoded(c) || c == '!' || (c >= '\'' && c <= ')') || c == '~'
+}
+
+const upperHex = "0123456789ABCDEF"
+
+func percentEncodeString(s string, encode func(byte) bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; encode(c) {
+			b.WriteByte('%')
+			b.WriteByte(upperHex[c>>4])
+			b.WriteByte(upperHex[c&15])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+func percentEncodeRune(c rune, encode func(byte) bool) string {
+	return percentEncodeString(string(c), encode)
+}
+
+// percentDecode decodes percent-encoded bytes of s. Percent signs that are
+// not followed by two hex digits are kept as they are.
+func percentDecode(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isASCIIHexDigit(rune(s[i+1])) && isASCIIHexDigit(rune(s[i+2])) {
+			n, _ := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			b = append(b, byte(n))
+			i += 2
+		} else {
+			b = append(b, s[i])
+		}
+	}
+
+	return b
+}
+
+func isForbiddenHostCodePoint(c rune) bool {
+	switch c {
+	case 0, '\t', '\n', '\r', ' ', '#', '/', ':', '<', '>', '?', '@', '[', '\\', ']', '^', '|':
+		return true
+	}
+
+	return false
+}
+
+func isForbiddenDomainCodePoint(c rune) bool {
+	return isForbiddenHostCodePoint(c) || c <= 0x1F || c == '%' || c == 0x7F
+}
+
+// parseHost parses and serializes the host of a url. Hosts of urls with
+// special schemes are domains, which are converted to ascii, or ip
+// addresses. Other hosts are opaque and are only percent-encoded.
+func parseHost(input string, isOpaque bool) (string, bool) {
+	if strings.HasPrefix(input, "[") {
+		if !strings.HasSuffix(input, "]") {
+			return "", false
+		}
+
+		addr, err := netip.ParseAddr(input[1 : len(input)-1])
+		if err != nil || !addr.Is6() || addr.Zone() != "" {
+			return "", false
+		}
+
+		return "[" + serializeIPv6(addr) + "]", true
+	}
+
+	if isOpaque {
+		for _, c := range input {
+			if isForbiddenHostCodePoint(c) {
+				return "", false
+			}
+		}
+
+		return percentEncodeString(input, isC0ControlPercentEncoded), true
+	}
+
+	// invalid percent-encoded sequences are kept as they are and rejected
+	// below as forbidden domain code points
+	domain := input
+	if unescaped, err := url.PathUnescape(input); err == nil {
+		domain = strings.ToValidUTF8(unescaped, "�")
+	}
+
+	asciiDomain, ok := domainToASCII(domain)
+	if !ok {
+		return "", false
+	}
+
+	for _, c := range asciiDomain {
+		if isForbiddenDomainCodePoint(c) {
+			return "", false
+		}
+	}
+
+	if endsInANumber(asciiDomain) {
+		return parseIPv4(asciiDomain)
+	}
+
+	return asciiDomain, true
+}
+
+// idnaProfile implements domain to ascii with the options that are used by
+// the WHATWG URL standard.
+var idnaProfile = idna.New(
+	idna.MapForLookup(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+	idna.ValidateLabels(false),
+	idna.BidiRule(),
+	idna.CheckJoiners(true),
+)
+
+func domainToASCII(domain string) (string, bool) {
+	ascii := true
+	for i := 0; i < len(domain); i++ {
+		if domain[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+
+	lower := strings.ToLower(domain)
+	if ascii && !strings.HasPrefix(lower, "xn--") && !strings.Contains(lower, ".xn--") {
+		if lower == "" {
+			return "", false
+		}
+
+		return lower, true
+	}
+
+	result, err := idnaProfile.ToASCII(domain)
+	if err != nil || result == "" {
+		return "", false
+	}
+
+	return result, true
+}
+
+func endsInANumber(s string) bool {
+	parts := strings.Split(s, ".")
+	if parts[len(parts)-1] == "" {
+		if len(parts) == 1 {
+			return false
+		}
+
+		parts = parts[:len(parts)-1]
+	}
+
+	last := parts[len(parts)-1]
+	if last != "" && strings.TrimLeft(last, "0123456789") == "" {
+		return true
+	}
+
+	_, ok := parseIPv4Number(last)
+	return ok
+}
+
+func parseIPv4Number(s string) (uint64, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	base := 10
+	if len(s) >= 2 && (s[:2] == "0x" || s[:2] == "0X") {
+		base = 16
+		s = s[2:]
+	} else if len(s) >= 2 && s[0] == '0' {
+		base = 8
+		s = s[1:]
+	}
+
+	if s == "" {
+		return 0, true
+	}
+
+	n, err := strconv.ParseUint(s, base, 64)
+	if err != nil {
+		// numbers that are too large are still numbers, but they are not
+		// valid parts of an address
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			return 1 << 32, true
+		}
+
+		return 0, false
+	}
+
+	return n, true
+}
+
+func parseIPv4(s string) (string, bool) {
+	parts := strings.Split(s, ".")
+	if parts[len(parts)-1] == "" && len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+	}
+
+	if len(parts) > 4 {
+		return "", false
+	}
+
+	numbers := make([]uint64, len(parts))
+	for i, part := range parts {
+		n, ok := parseIPv4Number(part)
+		if !ok {
+			return "", false
+		}
+
+		if n > 255 && i != len(parts)-1 {
+			return "", false
+		}
+
+		numbers[i] = n
+	}
+
+	last := numbers[len(numbers)-1]
+	if last >= 1<<(8*(5-len(numbers))) {
+		return "", false
+	}
+
+	ipv4 := last
+	for i, n := range numbers[:len(numbers)-1] {
+		ipv4 += n << (8 * (3 - i))
+	}
+
+	return netip.AddrFrom4([4]byte{byte(ipv4 >> 24), byte(ipv4 >> 16), byte(ipv4 >> 8), byte(ipv4)}).String(), true
+}
+
+// serializeIPv6 serializes addr like netip.Addr.String, except that ipv4
+// mapped addresses are serialized in hex.
+func serializeIPv6(addr netip.Addr) string {
+	b := addr.As16()
+
+	var pieces [8]uint16
+	for i := range pieces {
+		pieces[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+
+	// find the first longest sequence of two or more zero pieces
+	compress, compressLen := -1, 1
+	for i := 0; i < 8; {
+		if pieces[i] != 0 {
+			i++
+			continue
+		}
+
+		j := i
+		for j < 8 && pieces[j] == 0 {
+			j++
+		}
+
+		if j-i > compressLen {
+			compress, compressLen = i, j-i
+		}
+
+		i = j
+	}
+
+	var s strings.Builder
+	for i := 0; i < 8; i++ {
+		if i == compress {
+			s.WriteString("::")
+			i += compressLen - 1
+			continue
+		}
+
+		if i > 0 && i != compress+compressLen {
+			s.WriteByte(':')
+		}
+
+		s.WriteString(strconv.FormatUint(uint64(pieces[i]), 16))
+	}
+
+	return s.String()
+}
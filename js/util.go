@@ -48,3 +48,11 @@ func ObjectAssign(dst *Value, src *Value) (*Value, error) {
 
 	return dst, nil
 }
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
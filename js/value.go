@@ -2,6 +2,7 @@ package js
 
 import (
 	"fmt"
+	"math/big"
 	"runtime"
 	"strconv"
 	"strings"
@@ -37,6 +38,13 @@ type Value struct {
 	realm *Realm
 	value internal.Value
 	// createStack []byte
+
+	// ref owns value if v was bound to a realm after it was allocated, such
+	// as by Realm.DecodeJSON, since v has no finalizer of its own
+	ref *Value
+
+	// causeCache is the cause of v as an error, see Error.Cause
+	causeCache *errorCause
 }
 
 func freeValue(v *Value) {
@@ -96,6 +104,28 @@ func (v *Value) IsFunction() bool {
 	return internal.IsFunction(v.realm.context, v.value)
 }
 
+func (v *Value) IsError() bool {
+	defer runtime.KeepAlive(v)
+
+	return internal.IsError(v.realm.context, v.value)
+}
+
+func (v *Value) IsBigInt() bool {
+	return v.value.Tag() == internal.TagBigInt
+}
+
+func (v *Value) InstanceOf(constructor *Value) (bool, error) {
+	defer runtime.KeepAlive(v)
+	defer runtime.KeepAlive(constructor)
+
+	result := internal.IsInstanceOf(v.realm.context, v.value, constructor.value)
+	if result == -1 {
+		return false, v.realm.getError()
+	}
+
+	return result != 0, nil
+}
+
 func (v *Value) IsInt() bool {
 	return v.value.Tag() == internal.TagInt
 }
@@ -217,6 +247,18 @@ func (v *Value) ToFloat() float64 {
 	panic("value is not number")
 }
 
+func (v *Value) ToBigInt() *big.Int {
+	defer runtime.KeepAlive(v)
+
+	if v.value.Tag() != internal.TagBigInt {
+		panic("value is not bigint")
+	}
+
+	n, _ := new(big.Int).SetString(internal.ToString(v.realm.context, v.value), 10)
+
+	return n
+}
+
 func (v *Value) ToBool() bool {
 	defer runtime.KeepAlive(v)
 
@@ -379,6 +421,50 @@ func (v *Value) Set(prop string, val interface{}) (bool, error) {
 	return result != 0, nil
 }
 
+func (v *Value) SetIndex(index int, val interface{}) (bool, error) {
+	convertedValue, err := v.realm.Convert(val)
+	if err != nil {
+		return false, err
+	}
+
+	defer runtime.KeepAlive(v)
+	defer runtime.KeepAlive(convertedValue)
+
+	result := internal.SetPropertyInt(v.realm.context, v.value, index, convertedValue.value)
+	if result == -1 {
+		return false, v.realm.getError()
+	}
+
+	return result != 0, nil
+}
+
+// forEachOwnEnumerableProperty calls f with each own enumerable string keyed
+// property in the same order as Object.keys.
+func (v *Value) forEachOwnEnumerableProperty(f func(key string, value *Value) error) error {
+	defer runtime.KeepAlive(v)
+
+	props := internal.GetOwnPropertyNames(v.realm.context, v.value, internal.GetOwnPropertyNamesStringMask|internal.GetOwnPropertyNamesEnumOnly)
+	defer internal.FreePropertyEnum(v.realm.context, props)
+
+	for _, prop := range props {
+		key, err := v.realm.atomToString(prop.Atom())
+		if err != nil {
+			return err
+		}
+
+		value, err := v.realm.createAndResolveValue(internal.GetProperty(v.realm.context, v.value, prop.Atom()))
+		if err != nil {
+			return err
+		}
+
+		if err := f(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // WriteTo writes a pre-compiled script to the writer
 func (v *Value) Bytes() []byte {
 	defer runtime.KeepAlive(v)
@@ -0,0 +1,182 @@
+package js
+
+import (
+	"encoding/base64"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ssttevee/go-quickjs/internal"
+)
+
+// atob decodes a base64 string to a string of bytes, which are code points
+// in the range of latin1.
+func atob(r *Realm, _ *Value, data *Value) (string, error) {
+	s, err := r.toString(data)
+	if err != nil {
+		return "", err
+	}
+
+	s = strings.Map(func(c rune) rune {
+		switch c {
+		case '\t', '\n', '\f', '\r', ' ':
+			return -1
+		}
+
+		return c
+	}, s)
+
+	if len(s)%4 == 0 {
+		if strings.HasSuffix(s, "==") {
+			s = s[:len(s)-2]
+		} else if strings.HasSuffix(s, "=") {
+			s = s[:len(s)-1]
+		}
+	}
+
+	if len(s)%4 == 1 {
+		return "", newDOMException("InvalidCharacterError", "The string to be decoded is not correctly encoded.")
+	}
+
+	decoded, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		return "", newDOMException("InvalidCharacterError", "The string to be decoded is not correctly encoded.")
+	}
+
+	var b strings.Builder
+	for _, c := range decoded {
+		b.WriteRune(rune(c))
+	}
+
+	return b.String(), nil
+}
+
+// btoa encodes a string whose code points are all in the range of latin1 to
+// base64.
+func btoa(r *Realm, _ *Value, data *Value) (string, error) {
+	s, err := r.toString(data)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 0, utf8.RuneCountInString(s))
+	for _, c := range s {
+		// lone surrogates are decoded as utf8.RuneError, which is also out
+		// of range
+		if c > 0xFF {
+			return "", newDOMException("InvalidCharacterError", "The string to be encoded contains characters outside of the Latin1 range.")
+		}
+
+		b = append(b, byte(c))
+	}
+
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func queueMicrotask(r *Realm, _ *Value, callback *Value) error {
+	if !callback.IsFunction() {
+		return NewTypeError("The \"callback\" argument must be of type function")
+	}
+
+	defer runtime.KeepAlive(callback)
+
+	internal.EnqueueJob(r.context, callback.value, internal.Undefined, nil)
+
+	return nil
+}
+
+// structuredClone deep copies a value with the serializer of quickjs, which
+// supports primitives, plain objects, arrays, array buffers, typed arrays,
+// dates and boxed primitives. Array buffers in the transfer list of the
+// options are detached.
+func structuredClone(r *Realm, _ *Value, value *Value, args ...*Value) (*Value, error) {
+	var transfer []*Value
+	if len(args) > 0 && args[0].IsObject() {
+		list, err := args[0].Get("transfer")
+		if err != nil {
+			return nil, err
+		}
+
+		if list.Tag() != TagUndefined {
+			if err := list.Iterate(func(v *Value) (bool, error) {
+				if ok, err := r.isInstanceOfGlobal(v, "ArrayBuffer"); err != nil {
+					return false, err
+				} else if !ok {
+					return false, newDOMException("DataCloneError", "Value not transferable")
+				}
+
+				for _, other := range transfer {
+					// objects are equal if they have the same pointer
+					if other.value == v.value {
+						return false, newDOMException("DataCloneError", "ArrayBuffer is duplicated in the transfer list")
+					}
+				}
+
+				if internal.GetArrayBuffer(r.context, v.value) == nil {
+					r.getError()
+					return false, newDOMException("DataCloneError", "ArrayBuffer is detached and could not be cloned")
+				}
+
+				transfer = append(transfer, v)
+				return true, nil
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	defer runtime.KeepAlive(value)
+
+	data := internal.WriteObject(r.context, value.value, internal.WriteObjectReference)
+	if data == nil {
+		err := r.getError()
+		if jsErr, ok := err.(*Error); ok {
+			return nil, newDOMException("DataCloneError", "%s", jsErr.Message())
+		}
+
+		return nil, err
+	}
+
+	clone, err := r.createAndResolveValue(internal.ReadObject(r.context, data, internal.ReadObjectReference))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, buffer := range transfer {
+		internal.DetachArrayBuffer(r.context, buffer.value)
+		runtime.KeepAlive(buffer)
+	}
+
+	return clone, nil
+}
+
+// defineWebGlobals defines atob, btoa, queueMicrotask and structuredClone as
+// globals of r.
+func (r *Realm) defineWebGlobals() error {
+	if err := r.defineDOMException(); err != nil {
+		return err
+	}
+
+	globalObj, err := r.GlobalObject()
+	if err != nil {
+		return err
+	}
+
+	if _, err := globalObj.Set("atob", atob); err != nil {
+		return err
+	}
+
+	if _, err := globalObj.Set("btoa", btoa); err != nil {
+		return err
+	}
+
+	if _, err := globalObj.Set("queueMicrotask", queueMicrotask); err != nil {
+		return err
+	}
+
+	if _, err := globalObj.Set("structuredClone", structuredClone); err != nil {
+		return err
+	}
+
+	return nil
+}
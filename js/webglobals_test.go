@@ -0,0 +1,93 @@
+package js
+
+import (
+	"testing"
+)
+
+func TestBase64(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicWebGlobals)
+
+	for script, want := range map[string]string{
+		`btoa("")`:                          "",
+		`btoa("hello")`:                     "aGVsbG8=",
+		`btoa("\xff\xfe\x00")`:              "//4A",
+		`btoa(12)`:                          "MTI=",
+		`atob("aGVsbG8=")`:                  "hello",
+		`atob("aGVsbG8")`:                   "hello",
+		`atob(" aGVs\tbG8=\n")`:             "hello",
+		`atob("//4A") === "\xff\xfe\x00"`:   "true",
+		`atob(btoa("\x80\xa9\xe9")).length`: "3",
+		`atob(null) === atob("null")`:       "true",
+	} {
+		expectString(t, r, script, want)
+	}
+
+	for _, script := range []string{
+		`btoa("€")`,
+		`btoa("\ud800")`,
+		`atob("a")`,
+		`atob("aGVsbG8==")`,
+		`atob("aGV=sbG8")`,
+		`atob("a*==")`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { [e instanceof DOMException, e.name, e.code].join() }`, "true,InvalidCharacterError,5")
+	}
+}
+
+func TestQueueMicrotask(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicWebGlobals)
+
+	expectAwait(t, r, `new Promise((resolve) => {
+		const order = [];
+		queueMicrotask(() => order.push("microtask"));
+		Promise.resolve().then(() => order.push("promise"));
+		order.push("sync");
+		queueMicrotask(() => resolve(order.join()));
+	})`, "sync,microtask,promise")
+
+	expectString(t, r, `typeof queueMicrotask(() => {})`, "undefined")
+	expectString(t, r, `try { queueMicrotask("code") } catch (e) { e.name }`, "TypeError")
+}
+
+func TestStructuredClone(t *testing.T) {
+	r := newTestRealm(t, AddIntrinsicWebGlobals)
+
+	for script, want := range map[string]string{
+		`JSON.stringify(structuredClone({a: [1, "two", {three: null}], b: true}))`:     `{"a":[1,"two",{"three":null}],"b":true}`,
+		`{ const o = {}; structuredClone(o) !== o }`:                                   "true",
+		`{ const d = structuredClone(new Date(0)); d instanceof Date && d.getTime() }`: "0",
+		`Array.from(structuredClone(new Uint16Array([1, 2]))).join()`:                  "1,2",
+		`structuredClone(new Uint16Array([1, 2])) instanceof Uint16Array`:              "true",
+		`String(structuredClone(1n) + 1n)`:                                             "2",
+		`structuredClone(undefined)`:                                                   "undefined",
+		`structuredClone(new Number(1)) instanceof Number`:                             "true",
+
+		// references in the graph are preserved
+		`{ const shared = {}; const c = structuredClone([shared, shared]); c[0] === c[1] && c[0] !== shared }`:                                "true",
+		`{ const buffer = new ArrayBuffer(4); const c = structuredClone({buffer}, {transfer: []}); c.buffer.byteLength + buffer.byteLength }`: "8",
+	} {
+		expectString(t, r, script, want)
+	}
+
+	// transferred buffers are detached
+	expectString(t, r, `{
+		const buffer = new Uint8Array([1, 2, 3]).buffer;
+		const c = structuredClone({buffer}, {transfer: [buffer]});
+		let detached = false;
+		try { new Uint8Array(buffer) } catch { detached = true }
+		[detached, c.buffer.byteLength, new Uint8Array(c.buffer).join("")].join()
+	}`, "true,3,123")
+
+	for _, script := range []string{
+		`structuredClone(() => {})`,
+		`structuredClone(Symbol())`,
+		`structuredClone({}, {transfer: [{}]})`,
+		`structuredClone({}, {transfer: [new Uint8Array(1)]})`,
+		`{ const b = new ArrayBuffer(1); structuredClone(b, {transfer: [b, b]}) }`,
+		`{ const b = new ArrayBuffer(1); structuredClone(b, {transfer: [b]}); structuredClone(b, {transfer: [b]}) }`,
+	} {
+		expectString(t, r, `try { `+script+`; "no error" } catch (e) { [e instanceof DOMException, e.name, e.code].join() }`, "true,DataCloneError,25")
+	}
+
+	expectString(t, r, `try { structuredClone({}, {transfer: 1}) } catch (e) { e.name }`, "TypeError")
+}
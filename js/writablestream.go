@@ -0,0 +1,912 @@
+package js
+
+// writableSink holds the algorithms of the underlying sink of a
+// WritableStream, which may be nil. write, close and abort return promises.
+type writableSink struct {
+	start func(c *writableStreamController) (*Value, error)
+	write func(c *writableStreamController, chunk *Value) *Value
+	close func() *Value
+	abort func(reason *Value) *Value
+}
+
+// pendingAbortRequest is an abort of a WritableStream that waits for the
+// operation in flight to finish.
+type pendingAbortRequest struct {
+	promise            *streamPromise
+	reason             *Value
+	wasAlreadyErroring bool
+}
+
+// writableStream is the opaque value of WritableStream.
+type writableStream struct {
+	realm       *Realm
+	state       streamState
+	storedError *Value
+	writer      *writableStreamWriter
+	controller  *writableStreamController
+
+	writeRequests        []*streamPromise
+	inFlightWriteRequest *streamPromise
+	closeRequest         *streamPromise
+	inFlightCloseRequest *streamPromise
+	pendingAbortRequest  *pendingAbortRequest
+
+	backpressure bool
+}
+
+func thisWritableStream(this *Value) (*writableStream, error) {
+	s, ok := this.Opaque().(*writableStream)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return s, nil
+}
+
+func (s *writableStream) locked() bool {
+	return s.writer != nil
+}
+
+func (s *writableStream) closeQueuedOrInFlight() bool {
+	return s.closeRequest != nil || s.inFlightCloseRequest != nil
+}
+
+func (s *writableStream) hasOperationMarkedInFlight() bool {
+	return s.inFlightWriteRequest != nil || s.inFlightCloseRequest != nil
+}
+
+// abort aborts the stream and returns a promise that is fulfilled once the
+// underlying sink has been aborted.
+func (s *writableStream) abort(reason *Value) *Value {
+	r := s.realm
+
+	if s.state == streamClosed || s.state == streamErrored {
+		return r.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	s.controller.signalAbort(reason)
+
+	// aborting the signal may have run listeners that changed the state
+	if s.state == streamClosed || s.state == streamErrored {
+		return r.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	if s.pendingAbortRequest != nil {
+		return s.pendingAbortRequest.promise.value
+	}
+
+	wasAlreadyErroring := s.state == streamErroring
+	if wasAlreadyErroring {
+		reason = NewUndefined()
+	}
+
+	promise := r.newStreamPromise()
+	s.pendingAbortRequest = &pendingAbortRequest{
+		promise:            promise,
+		reason:             reason,
+		wasAlreadyErroring: wasAlreadyErroring,
+	}
+
+	if !wasAlreadyErroring {
+		s.startErroring(reason)
+	}
+
+	return promise.value
+}
+
+// close closes the stream and returns a promise that is fulfilled once the
+// underlying sink has been closed.
+func (s *writableStream) close() *Value {
+	r := s.realm
+
+	if s.state == streamClosed || s.state == streamErrored {
+		return r.rejectedStreamPromise(r.errorValue(NewTypeError("The stream is closed or errored"))).value
+	}
+
+	promise := r.newStreamPromise()
+	s.closeRequest = promise
+
+	if s.writer != nil && s.backpressure && s.state == streamOpen {
+		s.writer.ready.resolve(NewUndefined())
+	}
+
+	s.controller.close()
+
+	return promise.value
+}
+
+func (s *writableStream) dealWithRejection(e *Value) {
+	if s.state == streamOpen {
+		s.startErroring(e)
+		return
+	}
+
+	s.finishErroring()
+}
+
+func (s *writableStream) startErroring(reason *Value) {
+	s.state = streamErroring
+	s.storedError = reason
+
+	if s.writer != nil {
+		s.writer.ensureReadyPromiseRejected(reason)
+	}
+
+	if !s.hasOperationMarkedInFlight() && s.controller.started {
+		s.finishErroring()
+	}
+}
+
+func (s *writableStream) finishErroring() {
+	s.state = streamErrored
+	s.controller.queue.reset()
+
+	requests := s.writeRequests
+	s.writeRequests = nil
+
+	for _, request := range requests {
+		request.reject(s.storedError)
+	}
+
+	abortRequest := s.pendingAbortRequest
+	if abortRequest == nil {
+		s.rejectCloseAndClosedPromiseIfNeeded()
+		return
+	}
+
+	s.pendingAbortRequest = nil
+
+	if abortRequest.wasAlreadyErroring {
+		abortRequest.promise.reject(s.storedError)
+		s.rejectCloseAndClosedPromiseIfNeeded()
+		return
+	}
+
+	uponPromise(s.controller.abortSteps(abortRequest.reason), func(*Value) {
+		abortRequest.promise.resolve(NewUndefined())
+		s.rejectCloseAndClosedPromiseIfNeeded()
+	}, func(e *Value) {
+		abortRequest.promise.reject(e)
+		s.rejectCloseAndClosedPromiseIfNeeded()
+	})
+}
+
+func (s *writableStream) finishInFlightWrite(err *Value) {
+	request := s.inFlightWriteRequest
+	s.inFlightWriteRequest = nil
+
+	if err == nil {
+		request.resolve(NewUndefined())
+		return
+	}
+
+	request.reject(err)
+	s.dealWithRejection(err)
+}
+
+func (s *writableStream) finishInFlightClose(err *Value) {
+	request := s.inFlightCloseRequest
+	s.inFlightCloseRequest = nil
+
+	if err != nil {
+		request.reject(err)
+
+		if s.pendingAbortRequest != nil {
+			s.pendingAbortRequest.promise.reject(err)
+			s.pendingAbortRequest = nil
+		}
+
+		s.dealWithRejection(err)
+		return
+	}
+
+	request.resolve(NewUndefined())
+
+	if s.state == streamErroring {
+		s.storedError = nil
+
+		if s.pendingAbortRequest != nil {
+			s.pendingAbortRequest.promise.resolve(NewUndefined())
+			s.pendingAbortRequest = nil
+		}
+	}
+
+	s.state = streamClosed
+
+	if s.writer != nil {
+		s.writer.closed.resolve(NewUndefined())
+	}
+}
+
+func (s *writableStream) rejectCloseAndClosedPromiseIfNeeded() {
+	if s.closeRequest != nil {
+		s.closeRequest.reject(s.storedError)
+		s.closeRequest = nil
+	}
+
+	if s.writer != nil {
+		s.writer.closed.reject(s.storedError)
+		s.writer.closed.markHandled()
+	}
+}
+
+func (s *writableStream) updateBackpressure(backpressure bool) {
+	if s.writer != nil && backpressure != s.backpressure {
+		if backpressure {
+			s.writer.ready = s.realm.newStreamPromise()
+		} else {
+			s.writer.ready.resolve(NewUndefined())
+		}
+	}
+
+	s.backpressure = backpressure
+}
+
+// writableStreamWriter is the opaque value of WritableStreamDefaultWriter.
+type writableStreamWriter struct {
+	stream *writableStream
+	closed *streamPromise
+	ready  *streamPromise
+}
+
+func thisWritableStreamWriter(this *Value) (*writableStreamWriter, error) {
+	w, ok := this.Opaque().(*writableStreamWriter)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return w, nil
+}
+
+func errReleasedWriter() error {
+	return NewTypeError("The writer has been released")
+}
+
+// acquireWriter locks s to a new writer.
+func (api *streamsAPI) acquireWriter(r *Realm, s *writableStream) (*writableStreamWriter, error) {
+	if s.locked() {
+		return nil, NewTypeError("WritableStream is locked")
+	}
+
+	w := &writableStreamWriter{stream: s}
+	s.writer = w
+
+	switch s.state {
+	case streamOpen:
+		if !s.closeQueuedOrInFlight() && s.backpressure {
+			w.ready = r.newStreamPromise()
+		} else {
+			w.ready = r.resolvedStreamPromise(NewUndefined())
+		}
+
+		w.closed = r.newStreamPromise()
+	case streamErroring:
+		w.ready = r.rejectedStreamPromise(s.storedError)
+		w.ready.markHandled()
+		w.closed = r.newStreamPromise()
+	case streamClosed:
+		w.ready = r.resolvedStreamPromise(NewUndefined())
+		w.closed = r.resolvedStreamPromise(NewUndefined())
+	case streamErrored:
+		w.ready = r.rejectedStreamPromise(s.storedError)
+		w.ready.markHandled()
+		w.closed = r.rejectedStreamPromise(s.storedError)
+		w.closed.markHandled()
+	}
+
+	return w, nil
+}
+
+func (w *writableStreamWriter) ensureReadyPromiseRejected(e *Value) {
+	if !w.ready.pending {
+		w.ready = w.stream.realm.newStreamPromise()
+	}
+
+	w.ready.reject(e)
+	w.ready.markHandled()
+}
+
+func (w *writableStreamWriter) ensureClosedPromiseRejected(e *Value) {
+	if !w.closed.pending {
+		w.closed = w.stream.realm.newStreamPromise()
+	}
+
+	w.closed.reject(e)
+	w.closed.markHandled()
+}
+
+// desiredSize returns the desired size of the queue of the stream, or false
+// if the stream is errored.
+func (w *writableStreamWriter) desiredSize() (float64, bool) {
+	s := w.stream
+
+	switch s.state {
+	case streamErrored, streamErroring:
+		return 0, false
+	case streamClosed:
+		return 0, true
+	}
+
+	return s.controller.desiredSize(), true
+}
+
+// release unlocks the stream of the writer.
+func (w *writableStreamWriter) release(r *Realm) {
+	s := w.stream
+	if s == nil {
+		return
+	}
+
+	e := r.errorValue(errReleasedWriter())
+	w.ensureReadyPromiseRejected(e)
+	w.ensureClosedPromiseRejected(e)
+
+	s.writer = nil
+	w.stream = nil
+}
+
+func (w *writableStreamWriter) write(r *Realm, chunk *Value) *streamPromise {
+	s := w.stream
+	if s == nil {
+		return r.rejectedStreamPromise(r.errorValue(errReleasedWriter()))
+	}
+
+	c := s.controller
+	size := c.chunkSize(chunk)
+
+	if s != w.stream {
+		return r.rejectedStreamPromise(r.errorValue(errReleasedWriter()))
+	}
+
+	switch {
+	case s.state == streamErrored:
+		return r.rejectedStreamPromise(s.storedError)
+	case s.closeQueuedOrInFlight() || s.state == streamClosed:
+		return r.rejectedStreamPromise(r.errorValue(NewTypeError("The stream is closing or closed")))
+	case s.state == streamErroring:
+		return r.rejectedStreamPromise(s.storedError)
+	}
+
+	promise := r.newStreamPromise()
+	s.writeRequests = append(s.writeRequests, promise)
+
+	c.write(chunk, size)
+
+	return promise
+}
+
+func (w *writableStreamWriter) closeWithErrorPropagation(r *Realm) *streamPromise {
+	s := w.stream
+	if s == nil {
+		return r.rejectedStreamPromise(r.errorValue(errReleasedWriter()))
+	}
+
+	switch {
+	case s.closeQueuedOrInFlight() || s.state == streamClosed:
+		return r.resolvedStreamPromise(NewUndefined())
+	case s.state == streamErrored:
+		return r.rejectedStreamPromise(s.storedError)
+	}
+
+	promise := r.newStreamPromise()
+	promise.resolve(s.close())
+
+	return promise
+}
+
+var writableStreamWriterSpec = ClassSpec{
+	Name: "WritableStreamDefaultWriter",
+	Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+		s, ok := optionalArg(args, 0).Opaque().(*writableStream)
+		if !ok {
+			return nil, NewTypeError("The provided value is not of type 'WritableStream'")
+		}
+
+		return r.streams.acquireWriter(r, s)
+	},
+	Length: 1,
+	Methods: map[string]interface{}{
+		"abort": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+			w, err := thisWritableStreamWriter(this)
+			if err != nil {
+				return nil, err
+			}
+
+			if w.stream == nil {
+				return r.rejectedStreamPromise(r.errorValue(errReleasedWriter())).value, nil
+			}
+
+			return w.stream.abort(optionalArg(args, 0)), nil
+		},
+		"close": func(r *Realm, this *Value) (*Value, error) {
+			w, err := thisWritableStreamWriter(this)
+			if err != nil {
+				return nil, err
+			}
+
+			s := w.stream
+			switch {
+			case s == nil:
+				return r.rejectedStreamPromise(r.errorValue(errReleasedWriter())).value, nil
+			case s.closeQueuedOrInFlight():
+				return r.rejectedStreamPromise(r.errorValue(NewTypeError("The stream is closing or closed"))).value, nil
+			}
+
+			return s.close(), nil
+		},
+		"releaseLock": func(r *Realm, this *Value) error {
+			w, err := thisWritableStreamWriter(this)
+			if err != nil {
+				return err
+			}
+
+			w.release(r)
+
+			return nil
+		},
+		"write": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+			w, err := thisWritableStreamWriter(this)
+			if err != nil {
+				return nil, err
+			}
+
+			return w.write(r, optionalArg(args, 0)).value, nil
+		},
+	},
+	Properties: map[string]ClassProperty{
+		"closed": {
+			Get: func(r *Realm, this *Value) (*Value, error) {
+				w, err := thisWritableStreamWriter(this)
+				if err != nil {
+					return nil, err
+				}
+
+				return w.closed.value, nil
+			},
+		},
+		"ready": {
+			Get: func(r *Realm, this *Value) (*Value, error) {
+				w, err := thisWritableStreamWriter(this)
+				if err != nil {
+					return nil, err
+				}
+
+				return w.ready.value, nil
+			},
+		},
+		"desiredSize": {
+			Get: func(r *Realm, this *Value) (interface{}, error) {
+				w, err := thisWritableStreamWriter(this)
+				if err != nil {
+					return nil, err
+				}
+
+				if w.stream == nil {
+					return nil, errReleasedWriter()
+				}
+
+				desiredSize, ok := w.desiredSize()
+				if !ok {
+					return NewNull(), nil
+				}
+
+				return desiredSize, nil
+			},
+		},
+	},
+}
+
+// writableStreamController is the opaque value of
+// WritableStreamDefaultController.
+type writableStreamController struct {
+	stream   *writableStream
+	value    *Value
+	queue    sizeQueue
+	strategy queuingStrategy
+	sink     writableSink
+	started  bool
+
+	// signal is the AbortSignal of the controller, which is only available
+	// if the realm has the events intrinsic
+	signal *Value
+}
+
+func thisWritableStreamController(this *Value) (*writableStreamController, error) {
+	c, ok := this.Opaque().(*writableStreamController)
+	if !ok {
+		return nil, NewTypeError("Illegal invocation")
+	}
+
+	return c, nil
+}
+
+// signalAbort aborts the signal of the controller with reason.
+func (c *writableStreamController) signalAbort(reason *Value) {
+	if c.signal == nil {
+		return
+	}
+
+	r := c.stream.realm
+	_ = r.events.abort(r, c.signal, c.signal.Opaque().(*abortSignal), reason)
+}
+
+func (c *writableStreamController) desiredSize() float64 {
+	return c.strategy.highWaterMark - c.queue.totalSize
+}
+
+func (c *writableStreamController) backpressure() bool {
+	return c.desiredSize() <= 0
+}
+
+func (c *writableStreamController) clearAlgorithms() {
+	c.sink = writableSink{}
+	c.strategy.size = countChunk
+}
+
+func (c *writableStreamController) close() {
+	// the close sentinel is nil
+	_ = c.queue.enqueue(nil, 0)
+	c.advanceQueueIfNeeded()
+}
+
+func (c *writableStreamController) chunkSize(chunk *Value) float64 {
+	size, err := c.strategy.size(chunk)
+	if err != nil {
+		c.errorIfNeeded(c.stream.realm.errorValue(err))
+		return 1
+	}
+
+	return size
+}
+
+func (c *writableStreamController) write(chunk *Value, size float64) {
+	s := c.stream
+
+	if err := c.queue.enqueue(chunk, size); err != nil {
+		c.errorIfNeeded(s.realm.errorValue(err))
+		return
+	}
+
+	if !s.closeQueuedOrInFlight() && s.state == streamOpen {
+		s.updateBackpressure(c.backpressure())
+	}
+
+	c.advanceQueueIfNeeded()
+}
+
+func (c *writableStreamController) advanceQueueIfNeeded() {
+	s := c.stream
+
+	if !c.started || s.inFlightWriteRequest != nil {
+		return
+	}
+
+	if s.state == streamErroring {
+		s.finishErroring()
+		return
+	}
+
+	if c.queue.empty() {
+		return
+	}
+
+	if chunk := c.queue.peek(); chunk != nil {
+		c.processWrite(chunk)
+	} else {
+		c.processClose()
+	}
+}
+
+func (c *writableStreamController) errorIfNeeded(e *Value) {
+	if c.stream.state == streamOpen {
+		c.error(e)
+	}
+}
+
+func (c *writableStreamController) error(e *Value) {
+	c.clearAlgorithms()
+	c.stream.startErroring(e)
+}
+
+func (c *writableStreamController) processClose() {
+	s := c.stream
+
+	s.inFlightCloseRequest = s.closeRequest
+	s.closeRequest = nil
+
+	c.queue.dequeue()
+
+	var promise *Value
+	if c.sink.close != nil {
+		promise = c.sink.close()
+	} else {
+		promise = s.realm.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	c.clearAlgorithms()
+
+	uponPromise(promise, func(*Value) {
+		s.finishInFlightClose(nil)
+	}, s.finishInFlightClose)
+}
+
+func (c *writableStreamController) processWrite(chunk *Value) {
+	s := c.stream
+
+	s.inFlightWriteRequest = s.writeRequests[0]
+	s.writeRequests = s.writeRequests[1:]
+
+	var promise *Value
+	if c.sink.write != nil {
+		promise = c.sink.write(c, chunk)
+	} else {
+		promise = s.realm.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	uponPromise(promise, func(*Value) {
+		s.finishInFlightWrite(nil)
+
+		c.queue.dequeue()
+
+		if !s.closeQueuedOrInFlight() && s.state == streamOpen {
+			s.updateBackpressure(c.backpressure())
+		}
+
+		c.advanceQueueIfNeeded()
+	}, func(e *Value) {
+		if s.state == streamOpen {
+			c.clearAlgorithms()
+		}
+
+		s.finishInFlightWrite(e)
+	})
+}
+
+func (c *writableStreamController) abortSteps(reason *Value) *Value {
+	var result *Value
+	if c.sink.abort != nil {
+		result = c.sink.abort(reason)
+	} else {
+		result = c.stream.realm.resolvedStreamPromise(NewUndefined()).value
+	}
+
+	c.clearAlgorithms()
+
+	return result
+}
+
+var writableStreamControllerSpec = ClassSpec{
+	Name: "WritableStreamDefaultController",
+	Methods: map[string]interface{}{
+		"error": func(r *Realm, this *Value, args ...*Value) error {
+			c, err := thisWritableStreamController(this)
+			if err != nil {
+				return err
+			}
+
+			if c.stream.state == streamOpen {
+				c.error(optionalArg(args, 0))
+			}
+
+			return nil
+		},
+	},
+	Properties: map[string]ClassProperty{
+		"signal": {
+			Get: func(r *Realm, this *Value) (*Value, error) {
+				c, err := thisWritableStreamController(this)
+				if err != nil {
+					return nil, err
+				}
+
+				if c.signal == nil {
+					return NewUndefined(), nil
+				}
+
+				return c.signal, nil
+			},
+		},
+	},
+}
+
+// setupWritableStream sets up the controller of s, which calls the start
+// algorithm of sink.
+func (api *streamsAPI) setupWritableStream(r *Realm, s *writableStream, sink writableSink, strategy queuingStrategy) error {
+	c := &writableStreamController{
+		stream:   s,
+		strategy: strategy,
+		sink:     sink,
+	}
+
+	var err error
+	if c.value, err = api.writableStreamController.NewInstance(r, c); err != nil {
+		return err
+	}
+
+	if r.events != nil {
+		if c.signal, _, err = r.events.newAbortSignal(r); err != nil {
+			return err
+		}
+	}
+
+	s.controller = c
+	s.updateBackpressure(c.backpressure())
+
+	startResult := NewUndefined()
+	if sink.start != nil {
+		if startResult, err = sink.start(c); err != nil {
+			return err
+		}
+	}
+
+	uponPromise(r.resolvedStreamPromise(startResult).value, func(*Value) {
+		c.started = true
+		c.advanceQueueIfNeeded()
+	}, func(e *Value) {
+		c.started = true
+		s.dealWithRejection(e)
+	})
+
+	return nil
+}
+
+// newWritableStream creates a WritableStream with an underlying sink that
+// is implemented in go.
+func (api *streamsAPI) newWritableStream(r *Realm, sink writableSink, strategy queuingStrategy) (*Value, *writableStream, error) {
+	s := &writableStream{realm: r}
+	if err := api.setupWritableStream(r, s, sink, strategy); err != nil {
+		return nil, nil, err
+	}
+
+	value, err := api.writableStream.NewInstance(r, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return value, s, nil
+}
+
+// newUnderlyingSink converts the underlyingSink argument of the
+// WritableStream constructor.
+func newUnderlyingSink(r *Realm, underlyingSink *Value) (writableSink, error) {
+	var sink writableSink
+
+	if underlyingSink.Tag() != TagUndefined && underlyingSink.Tag() != TagNull && !underlyingSink.IsObject() {
+		return sink, NewTypeError("The provided value is not of type 'UnderlyingSink'")
+	}
+
+	abort, err := sourceMethod(underlyingSink, "abort")
+	if err != nil {
+		return sink, err
+	}
+
+	closeMethod, err := sourceMethod(underlyingSink, "close")
+	if err != nil {
+		return sink, err
+	}
+
+	start, err := sourceMethod(underlyingSink, "start")
+	if err != nil {
+		return sink, err
+	}
+
+	if underlyingSink.IsObject() {
+		typ, err := underlyingSink.Get("type")
+		if err != nil {
+			return sink, err
+		}
+
+		if typ.Tag() != TagUndefined {
+			return sink, NewRangeError("Invalid type is specified")
+		}
+	}
+
+	write, err := sourceMethod(underlyingSink, "write")
+	if err != nil {
+		return sink, err
+	}
+
+	if start != nil {
+		sink.start = func(c *writableStreamController) (*Value, error) {
+			return start.Call(underlyingSink, c.value)
+		}
+	}
+
+	if write != nil {
+		sink.write = func(c *writableStreamController, chunk *Value) *Value {
+			return r.promiseCall(write, underlyingSink, chunk, c.value)
+		}
+	}
+
+	if closeMethod != nil {
+		sink.close = func() *Value {
+			return r.promiseCall(closeMethod, underlyingSink)
+		}
+	}
+
+	if abort != nil {
+		sink.abort = func(reason *Value) *Value {
+			return r.promiseCall(abort, underlyingSink, reason)
+		}
+	}
+
+	return sink, nil
+}
+
+func (api *streamsAPI) writableStreamSpec() ClassSpec {
+	return ClassSpec{
+		Name: "WritableStream",
+		Constructor: func(r *Realm, args []*Value) (interface{}, error) {
+			sink, err := newUnderlyingSink(r, optionalArg(args, 0))
+			if err != nil {
+				return nil, err
+			}
+
+			strategy, err := extractQueuingStrategy(r, optionalArg(args, 1), 1)
+			if err != nil {
+				return nil, err
+			}
+
+			s := &writableStream{realm: r}
+			if err := api.setupWritableStream(r, s, sink, strategy); err != nil {
+				return nil, err
+			}
+
+			return s, nil
+		},
+		Methods: map[string]interface{}{
+			"abort": func(r *Realm, this *Value, args ...*Value) (*Value, error) {
+				s, err := thisWritableStream(this)
+				if err != nil {
+					return nil, err
+				}
+
+				if s.locked() {
+					return r.rejectedStreamPromise(r.errorValue(NewTypeError("Cannot abort a locked WritableStream"))).value, nil
+				}
+
+				return s.abort(optionalArg(args, 0)), nil
+			},
+			"close": func(r *Realm, this *Value) (*Value, error) {
+				s, err := thisWritableStream(this)
+				if err != nil {
+					return nil, err
+				}
+
+				switch {
+				case s.locked():
+					return r.rejectedStreamPromise(r.errorValue(NewTypeError("Cannot close a locked WritableStream"))).value, nil
+				case s.closeQueuedOrInFlight():
+					return r.rejectedStreamPromise(r.errorValue(NewTypeError("The stream is closing or closed"))).value, nil
+				}
+
+				return s.close(), nil
+			},
+			"getWriter": func(r *Realm, this *Value) (*Value, error) {
+				s, err := thisWritableStream(this)
+				if err != nil {
+					return nil, err
+				}
+
+				w, err := api.acquireWriter(r, s)
+				if err != nil {
+					return nil, err
+				}
+
+				return api.writableStreamWriter.NewInstance(r, w)
+			},
+		},
+		Properties: map[string]ClassProperty{
+			"locked": {
+				Get: func(r *Realm, this *Value) (bool, error) {
+					s, err := thisWritableStream(this)
+					if err != nil {
+						return false, err
+					}
+
+					return s.locked(), nil
+				},
+			},
+		},
+	}
+}